@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"strings"
 
+	"github.com/ankyra/escape/model/references"
 	"github.com/spf13/cobra"
 )
 
@@ -127,7 +128,15 @@ func ParseExtraVars(extraVars []string) (result map[string]interface{}, err erro
 		key := parts[0]
 		value := strings.Join(parts[1:], "=")
 		if value == "" {
-			if strings.HasPrefix(key, "@") {
+			if strings.HasPrefix(key, "@") && strings.HasSuffix(key, ".env") {
+				values, err := parseEnvFile(key[1:])
+				if err != nil {
+					return nil, err
+				}
+				for key, val := range values {
+					result[key] = val
+				}
+			} else if strings.HasPrefix(key, "@") {
 				v, err := ioutil.ReadFile(key[1:])
 				if err != nil {
 					return nil, fmt.Errorf("Coulnd't read file '%s': %s", key[1:], err.Error())
@@ -156,6 +165,57 @@ func ParseExtraVars(extraVars []string) (result map[string]interface{}, err erro
 	return result, nil
 }
 
+// ParseExtraVarsAndReferences parses the extra variables and adds the
+// references that were given with --ref (format: key=env:NAME). The
+// references are stored as references and resolved when they are used.
+func ParseExtraVarsAndReferences(extraVars, refs []string) (map[string]interface{}, error) {
+	result, err := ParseExtraVars(extraVars)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range refs {
+		parts := strings.SplitN(r, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid reference format '%s'. Expecting 'key=<scheme>:<reference>'", r)
+		}
+		ref, err := references.ParseReference(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		result[parts[0]] = ref
+	}
+	return result, nil
+}
+
+// parseEnvFile reads KEY=value lines from an env file. Empty lines and lines
+// starting with '#' are skipped, as is an 'export ' prefix. Values can be
+// quoted.
+func parseEnvFile(path string) (map[string]interface{}, error) {
+	v, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Coulnd't read file '%s': %s", path, err.Error())
+	}
+	result := map[string]interface{}{}
+	for i, line := range strings.Split(string(v), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" {
+			return nil, fmt.Errorf("Invalid line %d in env file '%s': expecting 'KEY=value'", i+1, path)
+		}
+		value := strings.TrimSpace(parts[1])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
 func ParseExtraProviders(extraVars []string) (map[string]string, error) {
 	result := map[string]string{}
 	parsed, err := ParseExtraVars(extraVars)
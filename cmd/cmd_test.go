@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"io/ioutil"
 	"os"
 	"testing"
 
@@ -21,3 +22,43 @@ func (s *suite) SetUpTest(c *C) {
 	environment = "dev"
 	deployment = ""
 }
+
+func (s *suite) Test_ParseExtraVars_env_file(c *C) {
+	content := `# comment
+KEY=value
+export OTHER="quoted value"
+EMPTY=
+PASSWORD=secret:vault/db/password
+`
+	c.Assert(ioutil.WriteFile("values.env", []byte(content), 0644), IsNil)
+	defer os.Remove("values.env")
+	result, err := ParseExtraVars([]string{"@values.env", "KEY=override"})
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, map[string]interface{}{
+		"KEY":      "override",
+		"OTHER":    "quoted value",
+		"EMPTY":    "",
+		"PASSWORD": "secret:vault/db/password",
+	})
+}
+
+func (s *suite) Test_ParseExtraVars_env_file_fails_on_invalid_line(c *C) {
+	c.Assert(ioutil.WriteFile("values.env", []byte("KEY=value\nnope\n"), 0644), IsNil)
+	defer os.Remove("values.env")
+	_, err := ParseExtraVars([]string{"@values.env"})
+	c.Assert(err, ErrorMatches, "Invalid line 2 in env file 'values.env': expecting 'KEY=value'")
+}
+
+func (s *suite) Test_ParseExtraVarsAndReferences(c *C) {
+	result, err := ParseExtraVarsAndReferences([]string{"KEY=env:HOME", "OTHER=value"}, []string{"KEY=env:HOME", "PASSWORD=secret:vault/db/password"})
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, map[string]interface{}{
+		"KEY":      map[string]interface{}{"$ref": "env:HOME"},
+		"OTHER":    "value",
+		"PASSWORD": map[string]interface{}{"$ref": "secret:vault/db/password"},
+	})
+	_, err = ParseExtraVarsAndReferences(nil, []string{"KEY"})
+	c.Assert(err, ErrorMatches, "Invalid reference format 'KEY'. Expecting 'key=<scheme>:<reference>'")
+	_, err = ParseExtraVarsAndReferences(nil, []string{"KEY=value"})
+	c.Assert(err, ErrorMatches, "Invalid reference 'value': expecting '<scheme>:<reference>'")
+}
@@ -18,9 +18,12 @@ package cmd
 
 import (
 	"github.com/ankyra/escape/controllers"
+	"github.com/ankyra/escape/model/config"
 	"github.com/spf13/cobra"
 )
 
+var explainProfile bool
+
 var configCmd = &cobra.Command{
 	Use:     "config",
 	Short:   "Manage the escape client configuration",
@@ -34,10 +37,20 @@ var configCmd = &cobra.Command{
 var configProfileCmd = &cobra.Command{
 	Use:   "profile <profile field name>",
 	Short: "Show the currently active Escape profile",
-	Long:  ``,
+	Long: `Show the currently active Escape profile
+
+The effective profile is made up of several layers. From lowest to highest
+precedence: the defaults, the profile in the user's configuration file, the
+project configuration file (` + config.ProjectConfigFile + `, looked up in the
+directory of the Escape plan and its parents) and the ESCAPE_* environment
+variables (e.g. ESCAPE_API_SERVER, ESCAPE_INVENTORY_TYPE). Use --explain to
+see which layer each value came from.
+`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var result *controllers.ControllerResult
-		if len(args) < 1 {
+		if explainProfile {
+			result = controllers.ConfigController{}.ExplainProfile(context)
+		} else if len(args) < 1 {
 			result = controllers.ConfigController{}.ShowProfile(context, jsonFlag)
 		} else {
 			result = controllers.ConfigController{}.ShowProfileField(context, args[0])
@@ -101,6 +114,21 @@ var configCreateProfileCmd = &cobra.Command{
 	},
 }
 
+var configMigrateCredentialsCmd = &cobra.Command{
+	Use:   "migrate-credentials",
+	Short: "Move the credentials in the active profile to its credential helper",
+	Long: `Move the credentials in the active profile to its credential helper
+
+Credentials that were written to the configuration file before a credential
+helper was configured stay there, and keep being used, until they're
+migrated with this command.`,
+	PreRunE: NoExtraArgsPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result := controllers.ConfigController{}.MigrateCredentials(context)
+		return result.Print(jsonFlag)
+	},
+}
+
 func init() {
 	RootCmd.AddCommand(configCmd)
 	configCmd.AddCommand(configProfileCmd)
@@ -108,6 +136,8 @@ func init() {
 	configCmd.AddCommand(configSetProfileCmd)
 	configCmd.AddCommand(configActiveProfileCmd)
 	configCmd.AddCommand(configCreateProfileCmd)
+	configCmd.AddCommand(configMigrateCredentialsCmd)
 
 	configCmd.PersistentFlags().BoolVarP(&jsonFlag, "json", "", false, "Output profile in JSON format")
+	configProfileCmd.Flags().BoolVarP(&explainProfile, "explain", "", false, "Show which configuration layer each value came from")
 }
@@ -18,9 +18,16 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
 
+	core "github.com/ankyra/escape-core"
 	corestate "github.com/ankyra/escape-core/state"
 	"github.com/ankyra/escape/controllers"
+	"github.com/ankyra/escape/model/scheduler"
 	"github.com/spf13/cobra"
 )
 
@@ -77,15 +84,21 @@ var errandsListCmd = &cobra.Command{
 }
 
 var errand string
+var runScheduledErrands bool
 
 var errandsRunCmd = &cobra.Command{
 	Use:   "run <errand>",
 	Short: "Run an errand",
+	Long: `Run an errand.
+
+With --schedule the errands are run on the schedule that's configured in their
+'schedule' field, until escape is interrupted. If no errand is given all the
+errands that have a schedule are run.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if environment == "" {
 			return fmt.Errorf("Missing 'environment'")
 		}
-		if len(args) != 1 {
+		if len(args) > 1 || (len(args) == 0 && !runScheduledErrands) {
 			cmd.UsageFunc()(cmd)
 			return nil
 		}
@@ -96,36 +109,46 @@ var errandsRunCmd = &cobra.Command{
 			return err
 		}
 		context.SetRootDeploymentName(deployment)
-		parsedExtraVars, err := ParseExtraVars(extraVars)
+		parsedExtraVars, err := ParseExtraVarsAndReferences(extraVars, extraRefs)
 		if err != nil {
 			return err
 		}
+		if runScheduledErrands {
+			return RunScheduledErrands(deployment, args, parsedExtraVars)
+		}
 		errand := args[0]
 
 		if readLocalErrands {
-			return RunLocalErrand(deployment, errand, parsedExtraVars)
+			return RunLocalErrand(deployment, errand, parsedExtraVars, false)
 		}
-		return RunDeployedErrand(deployment, errand, parsedExtraVars)
+		return RunDeployedErrand(deployment, errand, parsedExtraVars, false)
 	},
 }
 
-func RunDeployedErrand(deployment, errand string, parsedExtraVars map[string]interface{}) error {
+func loadDeployedErrandRelease(deployment string) (*corestate.DeploymentState, error) {
 	deplState := context.GetEnvironmentState().Deployments[deployment]
 	if deplState == nil {
-		return fmt.Errorf("The deployment '%s' could not be found in environment '%s'.", deployment, context.GetEnvironmentState().Name)
+		return nil, fmt.Errorf("The deployment '%s' could not be found in environment '%s'.", deployment, context.GetEnvironmentState().Name)
 	}
 
 	if deplState.GetStageOrCreateNew("deploy").Status.Code != corestate.OK {
-		return fmt.Errorf("'%s' has not been deployed in the environment '%s'.", deployment, context.GetEnvironmentState().Name)
+		return nil, fmt.Errorf("'%s' has not been deployed in the environment '%s'.", deployment, context.GetEnvironmentState().Name)
 	}
 	releaseId := deplState.GetReleaseId("deploy")
 	if err := context.InitReleaseMetadataByReleaseId(releaseId); err != nil {
+		return nil, err
+	}
+	return deplState, nil
+}
+
+func RunDeployedErrand(deployment, errand string, parsedExtraVars map[string]interface{}, scheduled bool) error {
+	if _, err := loadDeployedErrandRelease(deployment); err != nil {
 		return err
 	}
-	return controllers.ErrandsController{}.RunRemoteErrand(context, errand, parsedExtraVars)
+	return controllers.ErrandsController{}.RunRemoteErrand(context, errand, parsedExtraVars, scheduled).Print(jsonFlag)
 }
 
-func RunLocalErrand(deployment, errand string, parsedExtraVars map[string]interface{}) error {
+func loadLocalErrandRelease(deployment string) error {
 	if err := ProcessFlagsForContextAndLoadEscapePlan(); err != nil {
 		return err
 	}
@@ -142,8 +165,115 @@ func RunLocalErrand(deployment, errand string, parsedExtraVars map[string]interf
 	if deplState.GetStageOrCreateNew("deploy").Status.Code != corestate.OK {
 		return fmt.Errorf("'%s' has not been deployed in the environment '%s'. Use 'escape run deploy' to deploy it.", deployment, context.GetEnvironmentState().Name)
 	}
+	return nil
+}
+
+func RunLocalErrand(deployment, errand string, parsedExtraVars map[string]interface{}, scheduled bool) error {
+	if err := loadLocalErrandRelease(deployment); err != nil {
+		return err
+	}
+	return controllers.ErrandsController{}.Run(context, errand, parsedExtraVars, scheduled).Print(jsonFlag)
+}
+
+// RunScheduledErrands runs the errands on their schedule until escape is
+// interrupted. The state and release are reloaded before every run, so that
+// runs pick up new deployments.
+func RunScheduledErrands(deployment string, errandNames []string, parsedExtraVars map[string]interface{}) error {
+	if readLocalErrands {
+		if err := loadLocalErrandRelease(deployment); err != nil {
+			return err
+		}
+	} else if _, err := loadDeployedErrandRelease(deployment); err != nil {
+		return err
+	}
+	errands := context.GetReleaseMetadata().GetErrands()
+	if len(errandNames) == 0 {
+		for name, errand := range errands {
+			if errand.Schedule != "" {
+				errandNames = append(errandNames, name)
+			}
+		}
+		if len(errandNames) == 0 {
+			return fmt.Errorf("None of the errands in this release have a schedule.")
+		}
+		sort.Strings(errandNames)
+	}
+	jobs := []*scheduler.Job{}
+	for _, name := range errandNames {
+		errand, ok := errands[name]
+		if !ok {
+			return fmt.Errorf("The errand '%s' could not be found. You can use 'escape errands list' to see the available errands.", name)
+		}
+		if errand.Schedule == "" {
+			return fmt.Errorf("The errand '%s' doesn't have a schedule.", name)
+		}
+		schedule, err := core.ParseSchedule(errand.Schedule)
+		if err != nil {
+			return err
+		}
+		errandName := name
+		jobs = append(jobs, &scheduler.Job{
+			Name:     errandName,
+			Schedule: schedule,
+			Run: func() error {
+				if err := LoadState(); err != nil {
+					return err
+				}
+				context.Log("errand.scheduled_run", map[string]string{
+					"errand": errandName,
+				})
+				if readLocalErrands {
+					return RunLocalErrand(deployment, errandName, parsedExtraVars, true)
+				}
+				return RunDeployedErrand(deployment, errandName, parsedExtraVars, true)
+			},
+		})
+	}
+	s := scheduler.NewScheduler(jobs)
+	s.OnSchedule = func(job *scheduler.Job, next time.Time) {
+		context.Log("errand.scheduled", map[string]string{
+			"errand": job.Name,
+			"next":   next.Format(time.RFC1123),
+		})
+	}
+	s.OnError = func(job *scheduler.Job, err error) {
+		context.Log("errand.scheduled_run_failed", map[string]string{
+			"errand": job.Name,
+			"error":  err.Error(),
+		})
+	}
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+	s.Run(stop)
+	return nil
+}
 
-	return controllers.ErrandsController{}.Run(context, errand, parsedExtraVars)
+var errandsHistoryCmd = &cobra.Command{
+	Use:   "history [<errand>]",
+	Short: "Show the errand runs recorded for a deployment",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			cmd.UsageFunc()(cmd)
+			return nil
+		}
+		if deployment == "" {
+			if err := ProcessFlagsForContextAndLoadEscapePlan(); err != nil {
+				return err
+			}
+		} else if err := ProcessFlagsForContext(); err != nil {
+			return err
+		}
+		errand := ""
+		if len(args) == 1 {
+			errand = args[0]
+		}
+		return controllers.ErrandsController{}.History(context, context.GetRootDeploymentName(), errand).Print(jsonFlag)
+	},
 }
 
 func init() {
@@ -155,6 +285,13 @@ func init() {
 	errandsListCmd.PersistentFlags().BoolVarP(&jsonFlag, "json", "", false, "Output profile in JSON format")
 
 	setPlanAndStateFlags(errandsRunCmd)
-	errandsRunCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "v", []string{}, "Extra variables (format: key=value, key=@value.txt, @values.json)")
+	errandsRunCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "v", []string{}, "Extra variables (format: key=value, key=@value.txt, @values.json, @values.env)")
+	errandsRunCmd.Flags().StringArrayVarP(&extraRefs, "ref", "", []string{}, "Extra variables that are resolved when they are used (format: key=env:NAME, key=file:path, key=secret:provider/path)")
 	errandsRunCmd.Flags().BoolVarP(&readLocalErrands, "local", "", false, "Read errands from Escape plan instead of deployment")
+	errandsRunCmd.Flags().BoolVarP(&runScheduledErrands, "schedule", "", false, "Keep running and run the errand(s) on their schedule")
+	errandsRunCmd.Flags().BoolVarP(&jsonFlag, "json", "", false, "Output the errand outputs in JSON format")
+
+	errandsCmd.AddCommand(errandsHistoryCmd)
+	setPlanAndStateFlags(errandsHistoryCmd)
+	errandsHistoryCmd.Flags().BoolVarP(&jsonFlag, "json", "", false, "Output errand history in JSON format")
 }
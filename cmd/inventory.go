@@ -17,11 +17,25 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/ankyra/escape/controllers"
+	"github.com/ankyra/escape/model/inventory/local"
+	"github.com/ankyra/escape/model/inventory/types"
 	"github.com/spf13/cobra"
 )
 
 var project, application, appVersion string
+var gcKeepLast int
+var gcKeepTagged, gcDryRun bool
+var gcMaxAge time.Duration
+var gcStateFiles []string
+var searchName, searchVersion, searchProvides, searchBranch, searchRevision, searchAuthor, searchBuiltAfter, searchBuiltBefore string
+var searchMetadata []string
+var releaseNoticeReason string
 
 var inventoryCmd = &cobra.Command{
 	Use:     "inventory",
@@ -44,6 +58,128 @@ var inventoryQueryCommand = &cobra.Command{
 	},
 }
 
+var inventorySearchCommand = &cobra.Command{
+	Use:   "search",
+	Short: "Search for releases",
+	Long: `Search for releases
+
+Search the releases in the inventory by name, version, provided interfaces,
+metadata, git branch, revision and author, and build date. Releases need to match all
+the given filters. For example, to find the release containing a commit:
+
+    escape inventory search --revision abc123
+
+Or to find everything providing 'kubernetes' in the 'infra' project:
+
+    escape inventory search --project infra --provides kubernetes
+`,
+	PreRunE: NoExtraArgsPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query, err := parseReleaseQueryFlags()
+		if err != nil {
+			return err
+		}
+		result := controllers.InventoryController{}.Search(context, query)
+		return result.Print(jsonFlag)
+	},
+}
+
+func parseReleaseQueryFlags() (*types.ReleaseQuery, error) {
+	query := types.NewReleaseQuery()
+	query.Project = project
+	query.Name = searchName
+	query.Version = strings.TrimPrefix(searchVersion, "v")
+	query.Provides = searchProvides
+	query.Branch = searchBranch
+	query.Revision = searchRevision
+	query.Author = searchAuthor
+	for _, kv := range searchMetadata {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid metadata filter '%s'. Expecting key=value", kv)
+		}
+		query.Metadata[parts[0]] = parts[1]
+	}
+	var err error
+	if query.BuiltAfter, err = parseSearchDate(searchBuiltAfter); err != nil {
+		return nil, err
+	}
+	if query.BuiltBefore, err = parseSearchDate(searchBuiltBefore); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+func parseSearchDate(date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", date); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return t, fmt.Errorf("Invalid date '%s'. Expecting YYYY-MM-DD or an RFC3339 timestamp", date)
+	}
+	return t, nil
+}
+
+var inventoryDeprecateCommand = &cobra.Command{
+	Use:   "deprecate [RELEASE_ID]",
+	Short: "Mark a release as deprecated",
+	Long: `Mark a release as deprecated
+
+Deprecated releases can still be used, but builds and deploys that depend on
+them will show a warning.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expecting [RELEASE_ID]")
+		}
+		return controllers.InventoryController{}.Deprecate(context, args[0], releaseNoticeReason)
+	},
+}
+
+var inventoryYankCommand = &cobra.Command{
+	Use:   "yank [RELEASE_ID]",
+	Short: "Mark a release as broken",
+	Long: `Mark a release as broken
+
+Yanked releases are ignored when resolving version queries such as "latest"
+and "v1.@", but can still be fetched by their exact release ID so that existing
+deployments keep working. Builds and deploys that depend on a yanked release
+will show a warning.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expecting [RELEASE_ID]")
+		}
+		return controllers.InventoryController{}.Yank(context, args[0], releaseNoticeReason)
+	},
+}
+
+var inventoryGCCommand = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete old releases from the local inventory",
+	Long: `Delete old releases from the local inventory
+
+Releases are deleted unless they are protected by one of the retention rules:
+they are in the last N versions of their application (--keep-last), they are
+younger than --max-age, they are tagged (--keep-tagged) or they are deployed
+in one of the given state files (--state). Use --dry-run to list the releases
+that would be deleted without deleting them.
+`,
+	PreRunE: NoExtraArgsPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policy := local.NewRetentionPolicy()
+		policy.KeepLast = gcKeepLast
+		policy.KeepTagged = gcKeepTagged
+		policy.MaxAge = gcMaxAge
+		result := controllers.InventoryController{}.GarbageCollect(context, policy, gcStateFiles, gcDryRun)
+		return result.Print(jsonFlag)
+	},
+}
+
 func init() {
 	RootCmd.AddCommand(inventoryCmd)
 	inventoryCmd.AddCommand(inventoryQueryCommand)
@@ -52,4 +188,31 @@ func init() {
 	inventoryQueryCommand.Flags().StringVarP(&application, "application", "a", "", "The application")
 	inventoryQueryCommand.Flags().StringVarP(&appVersion, "version", "v", "", "The application version")
 	inventoryQueryCommand.PersistentFlags().BoolVarP(&jsonFlag, "json", "", false, "Output profile in JSON format")
+
+	inventoryCmd.AddCommand(inventorySearchCommand)
+	inventorySearchCommand.Flags().StringVarP(&project, "project", "p", "", "Only search this project")
+	inventorySearchCommand.Flags().StringVarP(&searchName, "name", "n", "", "Substring of the release name (project/name)")
+	inventorySearchCommand.Flags().StringVarP(&searchVersion, "version", "", "", "Version or version prefix of the release (e.g. 1.2.)")
+	inventorySearchCommand.Flags().StringVarP(&searchProvides, "provides", "", "", "Interface provided by the release")
+	inventorySearchCommand.Flags().StringArrayVarP(&searchMetadata, "metadata", "m", []string{}, "Metadata value (format: key=value, can be repeated)")
+	inventorySearchCommand.Flags().StringVarP(&searchBranch, "branch", "", "", "Git branch the release was built from")
+	inventorySearchCommand.Flags().StringVarP(&searchRevision, "revision", "", "", "Git revision (or revision prefix) the release was built from")
+	inventorySearchCommand.Flags().StringVarP(&searchAuthor, "author", "", "", "Substring of the git author of the release")
+	inventorySearchCommand.Flags().StringVarP(&searchBuiltAfter, "built-after", "", "", "Only show releases built on or after this date (YYYY-MM-DD or RFC3339)")
+	inventorySearchCommand.Flags().StringVarP(&searchBuiltBefore, "built-before", "", "", "Only show releases built before this date (YYYY-MM-DD or RFC3339)")
+	inventorySearchCommand.PersistentFlags().BoolVarP(&jsonFlag, "json", "", false, "Output in JSON format")
+
+	inventoryCmd.AddCommand(inventoryDeprecateCommand)
+	inventoryDeprecateCommand.Flags().StringVarP(&releaseNoticeReason, "reason", "", "", "Why the release is deprecated (required)")
+
+	inventoryCmd.AddCommand(inventoryYankCommand)
+	inventoryYankCommand.Flags().StringVarP(&releaseNoticeReason, "reason", "", "", "Why the release was yanked (required)")
+
+	inventoryCmd.AddCommand(inventoryGCCommand)
+	inventoryGCCommand.Flags().IntVarP(&gcKeepLast, "keep-last", "", 0, "Keep the last N versions of every application")
+	inventoryGCCommand.Flags().BoolVarP(&gcKeepTagged, "keep-tagged", "", true, "Keep versions that are referenced by a tag")
+	inventoryGCCommand.Flags().DurationVarP(&gcMaxAge, "max-age", "", 0, "Keep versions that were uploaded less than this long ago (e.g. 720h)")
+	inventoryGCCommand.Flags().StringArrayVarP(&gcStateFiles, "state", "s", []string{}, "Keep versions that are deployed in this Escape state file (can be repeated)")
+	inventoryGCCommand.Flags().BoolVarP(&gcDryRun, "dry-run", "", false, "List the releases that would be deleted, without deleting them")
+	inventoryGCCommand.PersistentFlags().BoolVarP(&jsonFlag, "json", "", false, "Output in JSON format")
 }
@@ -21,19 +21,31 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var username, password, url, authMethod, targetProfile string
-var insecureSkipVerify bool
+var username, password, url, authMethod, targetProfile, credentialHelper, tokenFile string
+var insecureSkipVerify, deviceCode, nonInteractive bool
 
 var loginCmd = &cobra.Command{
-	Use:     "login",
-	Short:   "Authenticate with an Escape server",
+	Use:   "login",
+	Short: "Authenticate with an Escape server",
+	Long: `Authenticate with an Escape server
+
+When stdin is not a terminal, or when --non-interactive is set, escape login
+never prompts for input. Instead it fails when it's missing information, and
+OAuth logins print a URL and verification code rather than opening a browser.
+Use --token-file to store a token obtained elsewhere, e.g. from a CI secret.
+
+Exit codes:
+  1  Error
+  3  Authentication required, but not enough information was provided
+  4  Authentication failed, was denied, or timed out
+`,
 	PreRunE: NoExtraArgsPreRunE,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if url == "" {
 			cmd.UsageFunc()(cmd)
 			return nil
 		}
-		return controllers.LoginController{}.Login(context, url, authMethod, username, password, insecureSkipVerify, targetProfile)
+		return controllers.LoginController{}.Login(context, url, authMethod, username, password, insecureSkipVerify, targetProfile, credentialHelper, tokenFile, deviceCode, nonInteractive)
 	},
 }
 
@@ -46,4 +58,11 @@ func init() {
 		"Don't verify server's certificate chain and host name.")
 	loginCmd.Flags().StringVarP(&authMethod, "auth-method", "", "", "If available, use this auth method instead of prompting for user input")
 	loginCmd.Flags().StringVarP(&targetProfile, "target-profile", "", "", "The name of the new profile to create when logging in")
+	loginCmd.Flags().StringVarP(&credentialHelper, "credential-helper", "", "",
+		"Store the credentials using this credential helper instead of in the configuration file. Use 'file' for the built-in encrypted store (requires $ESCAPE_CREDENTIALS_PASSPHRASE)")
+	loginCmd.Flags().StringVarP(&tokenFile, "token-file", "", "", "Store the auth token read from this file (or stdin if '-') instead of logging in")
+	loginCmd.Flags().BoolVarP(&deviceCode, "device-code", "", false,
+		"Print the OAuth login URL, and the verification code if the server provides one, instead of opening a browser")
+	loginCmd.Flags().BoolVarP(&nonInteractive, "non-interactive", "", false,
+		"Never prompt for input. This is the default when stdin is not a terminal")
 }
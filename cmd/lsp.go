@@ -0,0 +1,44 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/ankyra/escape/controllers"
+	"github.com/spf13/cobra"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run a language server for Escape plans",
+	Long: `Run a language server for Escape plans
+
+Starts a Language Server Protocol server that communicates over stdin and
+stdout. Configure your editor to run 'escape lsp' for escape.yml files to get:
+
+  * validation of the fields and the Escape Script expressions in the plan
+  * completion of field names, input variables and Escape Script builtins
+  * documentation for fields and Escape Script builtins on hover
+`,
+	PreRunE: NoExtraArgsPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return controllers.LspController{}.Serve(context)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(lspCmd)
+}
@@ -18,14 +18,21 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/ankyra/escape/controllers"
+	"github.com/ankyra/escape/model/lint"
 	"github.com/spf13/cobra"
 )
 
 var releaseName string
 var outputPath string
-var force, minify bool
+var force, minify, explainExtensions bool
+var renderStage, renderTemplate string
+var renderDiff, renderCheck bool
+var formatCheck bool
+var lintRules []string
+var lintSarif bool
 
 var planCmd = &cobra.Command{
 	Use:     "plan",
@@ -50,15 +57,22 @@ var initCmd = &cobra.Command{
 }
 
 var fmtCmd = &cobra.Command{
-	Use:     "fmt",
-	Short:   "Format an existing Escape plan",
+	Use:   "fmt",
+	Short: "Format an existing Escape plan",
+	Long: `Format an existing Escape plan
+
+Puts the fields of the Escape plan in the canonical order, adds the fields
+that are missing and normalises the indentation and blank lines. Comments and
+the values of the fields are kept as they are. Use --check in CI to fail if
+the Escape plan is not formatted.
+`,
 	PreRunE: NoExtraArgsPreRunE,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		err := context.LoadEscapePlan(escapePlanLocation)
 		if err != nil {
 			return err
 		}
-		return controllers.PlanController{}.Format(context, escapePlanLocation)
+		return controllers.PlanController{}.Format(context, escapePlanLocation, formatCheck).Print(false)
 	},
 }
 
@@ -75,15 +89,21 @@ var diffCmd = &cobra.Command{
 }
 
 var minifyCmd = &cobra.Command{
-	Use:     "minify",
-	Short:   "Minify an existing Escape plan",
+	Use:   "minify",
+	Short: "Minify an existing Escape plan",
+	Long: `Minify an existing Escape plan
+
+Like 'escape plan fmt', but leaves out the empty fields and the blank lines.
+Empty fields that have a comment are kept. Use --check in CI to fail if the
+Escape plan is not minified.
+`,
 	PreRunE: NoExtraArgsPreRunE,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		err := context.LoadEscapePlan(escapePlanLocation)
 		if err != nil {
 			return err
 		}
-		return controllers.PlanController{}.Minify(context, escapePlanLocation)
+		return controllers.PlanController{}.Minify(context, escapePlanLocation, formatCheck).Print(false)
 	},
 }
 
@@ -96,11 +116,96 @@ var previewCmd = &cobra.Command{
 			return err
 		}
 
+		if explainExtensions {
+			return controllers.PlanController{}.ExplainExtensions(context).Print(jsonFlag)
+		}
 		controllers.PlanController{}.Compile(context)
 		return nil
 	},
 }
 
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Render the templates of the Escape plan",
+	Long: `Render the templates of the Escape plan
+
+The templates are rendered with the inputs, outputs and providers that are
+recorded for the deployment in the state, the same way 'escape run' would, but
+no steps are run and no files are written. Use --diff to compare the output
+with the files on disk, or --check to fail when the files are out of date.
+`,
+	PreRunE: NoExtraArgsPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := ProcessFlagsForContextAndLoadEscapePlan(); err != nil {
+			return err
+		}
+		return controllers.PlanController{}.Render(context, renderStage, renderTemplate, renderDiff, renderCheck).Print(jsonFlag)
+	},
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check the Escape plan for common problems",
+	Long: `Check the Escape plan for common problems
+
+Finds problems that the compiler doesn't catch, like unused inputs, outputs
+that are never set and scripts that don't exist. Every rule has a severity
+(error, warning, info or off) that can be changed with --rule, or with the
+"lint_rules" field in the configuration. The command fails if any issue with
+the error severity is found.
+
+Rules:
+` + lintRulesHelp(),
+	PreRunE: NoExtraArgsPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := ProcessFlagsForContextAndLoadEscapePlan(); err != nil {
+			return err
+		}
+		rules, err := parseLintRules(lintRules)
+		if err != nil {
+			return err
+		}
+		result := controllers.PlanController{}.Lint(context, escapePlanLocation, rules, lintSarif)
+		return result.Print(jsonFlag || lintSarif)
+	},
+}
+
+func lintRulesHelp() string {
+	result := ""
+	for _, rule := range lint.Rules {
+		result += fmt.Sprintf("  %-22s %-8s %s\n", rule.Id, rule.Severity, rule.Description)
+	}
+	return result
+}
+
+func parseLintRules(rules []string) (map[string]string, error) {
+	result := map[string]string{}
+	for _, rule := range rules {
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid lint rule format '%s'. Expecting <rule>=<severity>", rule)
+		}
+		result[parts[0]] = parts[1]
+	}
+	return result, nil
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print a JSON Schema for the Escape plan",
+	Long: `Print a JSON Schema for the Escape plan
+
+The schema describes all the fields of the Escape plan and can be used by
+editors to validate and complete escape.yml files. For example:
+
+    escape plan schema > escape-plan.schema.json
+`,
+	PreRunE: NoExtraArgsPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return controllers.PlanController{}.Schema(context).Print(false)
+	},
+}
+
 var getCmd = &cobra.Command{
 	Use:   "get <escape plan field>",
 	Short: "Get individual fields from the Escape plan",
@@ -127,6 +232,9 @@ func init() {
 	planCmd.AddCommand(previewCmd)
 	planCmd.AddCommand(diffCmd)
 	planCmd.AddCommand(getCmd)
+	planCmd.AddCommand(renderCmd)
+	planCmd.AddCommand(lintCmd)
+	planCmd.AddCommand(schemaCmd)
 
 	initCmd.Flags().StringVarP(&releaseName, "name", "n", "", "The release name (eg. hello-world)")
 	initCmd.Flags().StringVarP(&outputPath, "output", "o", "escape.yml", "The output location")
@@ -134,7 +242,21 @@ func init() {
 	initCmd.Flags().BoolVarP(&minify, "minify", "m", false, "Minify the generated Escape plan")
 
 	setPlanAndStateFlags(previewCmd)
+	previewCmd.Flags().BoolVarP(&explainExtensions, "explain-extensions", "", false, "Show where the fields that are merged from extensions came from")
+	previewCmd.Flags().BoolVarP(&jsonFlag, "json", "", false, "Output the extension report in JSON format")
 	setPlanAndStateFlags(diffCmd)
+	setPlanAndStateFlags(renderCmd)
+	renderCmd.Flags().StringVarP(&renderStage, "stage", "", "deploy", "The stage to render the templates for (build or deploy)")
+	renderCmd.Flags().StringVarP(&renderTemplate, "template", "", "", "Only render the template with this file name")
+	renderCmd.Flags().BoolVarP(&renderDiff, "diff", "", false, "Show the differences with the files on disk")
+	renderCmd.Flags().BoolVarP(&renderCheck, "check", "", false, "Fail if the files on disk are out of date (implies --diff)")
+	renderCmd.Flags().BoolVarP(&jsonFlag, "json", "", false, "Output the rendered templates in JSON format")
+	setPlanAndStateFlags(lintCmd)
+	lintCmd.Flags().StringArrayVarP(&lintRules, "rule", "", []string{}, "Set the severity of a rule (eg. --rule missing-description=off)")
+	lintCmd.Flags().BoolVarP(&jsonFlag, "json", "", false, "Output the issues in JSON format")
+	lintCmd.Flags().BoolVarP(&lintSarif, "sarif", "", false, "Output the issues in the SARIF format")
 	setEscapePlanLocationFlag(fmtCmd)
+	fmtCmd.Flags().BoolVarP(&formatCheck, "check", "", false, "Don't write the Escape plan, but fail if it's not formatted")
 	setEscapePlanLocationFlag(minifyCmd)
+	minifyCmd.Flags().BoolVarP(&formatCheck, "check", "", false, "Don't write the Escape plan, but fail if it's not minified")
 }
@@ -19,6 +19,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/ankyra/escape/model"
 	"github.com/ankyra/escape/util"
@@ -44,7 +45,7 @@ See the documentation at https://escape.ankyra.io/docs/
 `,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		context = model.NewContext()
-		err := context.LoadEscapeConfig(cfgFile, cfgProfile)
+		err := context.LoadEscapeConfig(cfgFile, cfgProfile, filepath.Dir(escapePlanLocation))
 		if err != nil {
 			fmt.Println(err.Error())
 			return err
@@ -69,7 +70,7 @@ func Execute() {
 		} else {
 			RootCmd.UsageFunc()(RootCmd)
 		}
-		os.Exit(1)
+		os.Exit(util.ExitCode(err))
 	}
 	if context != nil {
 		context.Logger.Close()
@@ -34,6 +34,7 @@ var skipDeploy, skipSmoke bool
 var skipDestroyBuild, skipDestroyDeploy, skipDestroy bool
 var tagGit, pushGitTags bool
 var skipIfExists bool
+var interactive bool
 var toEnv, toDeployment string
 
 var runCmd = &cobra.Command{
@@ -54,7 +55,7 @@ var runBuildCmd = &cobra.Command{
 		if err := ProcessFlagsForContextAndLoadEscapePlan(); err != nil {
 			return err
 		}
-		parsedExtraVars, err := ParseExtraVars(extraVars)
+		parsedExtraVars, err := ParseExtraVarsAndReferences(extraVars, extraRefs)
 		if err != nil {
 			return err
 		}
@@ -62,7 +63,7 @@ var runBuildCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		return controllers.BuildController{}.Build(context, uber, parsedExtraVars, parsedExtraProviders)
+		return controllers.BuildController{Interactive: interactive}.Build(context, uber, parsedExtraVars, parsedExtraProviders)
 	},
 }
 
@@ -87,8 +88,8 @@ var runDeployCmd = &cobra.Command{
 			return err
 		}
 
-		ctrl := controllers.DeployController{}
-		parsedExtraVars, err := ParseExtraVars(extraVars)
+		ctrl := controllers.DeployController{Interactive: interactive}
+		parsedExtraVars, err := ParseExtraVarsAndReferences(extraVars, extraRefs)
 		if err != nil {
 			return err
 		}
@@ -142,7 +143,7 @@ var runReleaseCmd = &cobra.Command{
 		if err := ProcessFlagsForContextAndLoadEscapePlanWithVersionOverride(versionOverride); err != nil {
 			return err
 		}
-		parsedExtraVars, err := ParseExtraVars(extraVars)
+		parsedExtraVars, err := ParseExtraVarsAndReferences(extraVars, extraRefs)
 		if err != nil {
 			return err
 		}
@@ -189,7 +190,7 @@ var runPromoteCmd = &cobra.Command{
 			return fmt.Errorf("Currently not supported with remote state")
 		}
 
-		parsedExtraVars, err := ParseExtraVars(extraVars)
+		parsedExtraVars, err := ParseExtraVarsAndReferences(extraVars, extraRefs)
 		if err != nil {
 			return err
 		}
@@ -208,8 +209,10 @@ func init() {
 
 	runCmd.AddCommand(runBuildCmd)
 	setPlanAndStateFlags(runBuildCmd)
-	runBuildCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "v", []string{}, "Extra variables (format: key=value, key=@value.txt, @values.json)")
+	runBuildCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "v", []string{}, "Extra variables (format: key=value, key=@value.txt, @values.json, @values.env)")
+	runBuildCmd.Flags().StringArrayVarP(&extraRefs, "ref", "", []string{}, "Extra variables that are resolved when they are used (format: key=env:NAME, key=file:path, key=secret:provider/path)")
 	runBuildCmd.Flags().StringArrayVarP(&extraProviders, "extra-providers", "p", []string{}, "Extra providers (format: provider=deployment, provider=@deployment.txt, @values.json)")
+	runBuildCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Ask for the values of input variables that haven't been configured")
 
 	runCmd.AddCommand(runConvergeCmd)
 	setPlanAndStateFlags(runConvergeCmd)
@@ -217,8 +220,10 @@ func init() {
 
 	runCmd.AddCommand(runDeployCmd)
 	setPlanAndStateFlags(runDeployCmd)
-	runDeployCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "v", []string{}, "Extra variables (format: key=value, key=@value.txt, @values.json)")
+	runDeployCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "v", []string{}, "Extra variables (format: key=value, key=@value.txt, @values.json, @values.env)")
+	runDeployCmd.Flags().StringArrayVarP(&extraRefs, "ref", "", []string{}, "Extra variables that are resolved when they are used (format: key=env:NAME, key=file:path, key=secret:provider/path)")
 	runDeployCmd.Flags().StringArrayVarP(&extraProviders, "extra-providers", "p", []string{}, "Extra providers (format: provider=deployment, provider=@deployment.txt, @values.json)")
+	runDeployCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Ask for the values of input variables that haven't been configured")
 
 	runCmd.AddCommand(runDestroyCmd)
 	setPlanAndStateFlags(runDestroyCmd)
@@ -247,7 +252,8 @@ func init() {
 	runReleaseCmd.Flags().BoolVarP(&tagGit, "tag-git", "", false, "Following a successful release tag the current commit with the version number.")
 	runReleaseCmd.Flags().BoolVarP(&pushGitTags, "push-git-tags", "", true, "Push git tags. Only used when --tag-git is set.")
 	runReleaseCmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite output file if it exists")
-	runReleaseCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "v", []string{}, "Extra variables (format: key=value, key=@value.txt, @values.json)")
+	runReleaseCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "v", []string{}, "Extra variables (format: key=value, key=@value.txt, @values.json, @values.env)")
+	runReleaseCmd.Flags().StringArrayVarP(&extraRefs, "ref", "", []string{}, "Extra variables that are resolved when they are used (format: key=env:NAME, key=file:path, key=secret:provider/path)")
 	runReleaseCmd.Flags().StringArrayVarP(&extraProviders, "extra-providers", "p", []string{}, "Extra providers (format: provider=deployment, provider=@deployment.txt, @values.json)")
 
 	runCmd.AddCommand(runSmokeCmd)
@@ -261,6 +267,7 @@ func init() {
 	runPromoteCmd.Flags().StringVarP(&toEnv, "to", "", "", "The logical environment to promote to")
 	runPromoteCmd.Flags().StringVarP(&toDeployment, "to-deployment", "", "", "The deployment name to promote to (default is the package's \"project/name\")")
 	runPromoteCmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation")
-	runPromoteCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "v", []string{}, "Extra variables (format: key=value, key=@value.txt, @values.json)")
+	runPromoteCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "v", []string{}, "Extra variables (format: key=value, key=@value.txt, @values.json, @values.env)")
+	runPromoteCmd.Flags().StringArrayVarP(&extraRefs, "ref", "", []string{}, "Extra variables that are resolved when they are used (format: key=env:NAME, key=file:path, key=secret:provider/path)")
 	runPromoteCmd.Flags().StringArrayVarP(&extraProviders, "extra-providers", "p", []string{}, "Extra providers (format: provider=deployment, provider=@deployment.txt, @values.json)")
 }
@@ -0,0 +1,62 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/ankyra/escape/controllers"
+	"github.com/spf13/cobra"
+)
+
+var serverDataDir, serverListen, serverUsername, serverPassword string
+var serverTLSCert, serverTLSKey string
+
+var serverCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Serve an Inventory and state API",
+	Long: `Serve an Inventory and state API
+
+Runs an Escape server that stores releases in a local inventory and
+deployment state in local state files under the data directory. Point a
+profile at it with:
+
+    escape login --url http://localhost:7770
+
+By default the server only listens on the loopback interface. Basic
+authentication is enabled by setting a username and password. The password
+can also be set using the ESCAPE_SERVER_PASSWORD environment variable. TLS
+is enabled by setting a certificate and key. Listening on other interfaces
+requires both authentication and TLS.`,
+	PreRunE: NoExtraArgsPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if serverPassword == "" {
+			serverPassword = os.Getenv("ESCAPE_SERVER_PASSWORD")
+		}
+		return controllers.ServerController{}.Serve(context, serverDataDir, serverListen, serverUsername, serverPassword, serverTLSCert, serverTLSKey)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(serverCmd)
+	serverCmd.Flags().StringVarP(&serverDataDir, "data-dir", "", "escape_server_data", "The directory to store releases and state in")
+	serverCmd.Flags().StringVarP(&serverListen, "listen", "", "127.0.0.1:7770", "The address to listen on")
+	serverCmd.Flags().StringVarP(&serverUsername, "username", "u", "", "Require basic authentication with this username")
+	serverCmd.Flags().StringVarP(&serverPassword, "password", "p", "", "Require basic authentication with this password")
+	serverCmd.Flags().StringVarP(&serverTLSCert, "tls-cert", "", "", "Serve over TLS using this certificate file")
+	serverCmd.Flags().StringVarP(&serverTLSKey, "tls-key", "", "", "Serve over TLS using this private key file")
+}
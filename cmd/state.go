@@ -22,7 +22,7 @@ import (
 )
 
 var deployStage bool
-var extraVars, extraProviders []string
+var extraVars, extraRefs, extraProviders []string
 
 var stateCmd = &cobra.Command{
 	Use:     "state",
@@ -92,7 +92,7 @@ var createStateCmd = &cobra.Command{
 		if deployStage {
 			stage = "deploy"
 		}
-		parsedExtraVars, err := ParseExtraVars(extraVars)
+		parsedExtraVars, err := ParseExtraVarsAndReferences(extraVars, extraRefs)
 		if err != nil {
 			return err
 		}
@@ -109,12 +109,42 @@ var createStateCmd = &cobra.Command{
 	},
 }
 
+var migrateInputsDryRun bool
+
+var migrateInputsCmd = &cobra.Command{
+	Use:   "migrate-inputs [<release id>]",
+	Short: "Migrate the inputs of renamed and deprecated variables in the state",
+	Long: `Migrate the inputs of renamed and deprecated variables in the state
+
+Values that are stored under one of the 'aliases' of an input variable are
+moved to the variable's current ID. The values of variables that are
+'deprecated_by' another variable are copied to the replacing variable.
+
+The variables are taken from the Escape plan, or from the release metadata
+if a release ID is given. Use --dry-run to list the changes without saving
+them.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useEscapePlan := len(args) == 0
+		if err := processFlagsForContext(useEscapePlan, ""); err != nil {
+			return err
+		}
+		if !useEscapePlan {
+			if err := context.InitReleaseMetadataByReleaseId(args[0]); err != nil {
+				return err
+			}
+		}
+		return controllers.StateController{}.MigrateInputs(context, migrateInputsDryRun).Print(jsonFlag)
+	},
+}
+
 func init() {
 	RootCmd.AddCommand(stateCmd)
 	stateCmd.AddCommand(listDeploymentsCmd)
 	stateCmd.AddCommand(showDeploymentCmd)
 	stateCmd.AddCommand(showProvidersCmd)
 	stateCmd.AddCommand(createStateCmd)
+	stateCmd.AddCommand(migrateInputsCmd)
 
 	setEscapeStateLocationFlag(listDeploymentsCmd)
 	setEscapeStateEnvironmentFlag(listDeploymentsCmd)
@@ -130,6 +160,11 @@ func init() {
 
 	setPlanAndStateFlags(createStateCmd)
 	createStateCmd.Flags().BoolVarP(&deployStage, "deploy", "", false, "Use deployment instead of build stage")
-	createStateCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "v", []string{}, "Extra variables (format: key=value, key=@value.txt, @values.json)")
+	createStateCmd.Flags().StringArrayVarP(&extraVars, "extra-vars", "v", []string{}, "Extra variables (format: key=value, key=@value.txt, @values.json, @values.env)")
+	createStateCmd.Flags().StringArrayVarP(&extraRefs, "ref", "", []string{}, "Extra variables that are resolved when they are used (format: key=env:NAME, key=file:path, key=secret:provider/path)")
 	createStateCmd.Flags().StringArrayVarP(&extraProviders, "extra-providers", "p", []string{}, "Extra providers (format: provider=deployment, provider=@deployment.txt, @values.json)")
+
+	setPlanAndStateFlags(migrateInputsCmd)
+	migrateInputsCmd.Flags().BoolVarP(&migrateInputsDryRun, "dry-run", "", false, "List the inputs that would be migrated, without saving them")
+	migrateInputsCmd.Flags().BoolVarP(&jsonFlag, "json", "", false, "Output the migrations in JSON format")
 }
@@ -22,15 +22,23 @@ import (
 	"github.com/ankyra/escape/model/runners/build"
 )
 
-type BuildController struct{}
+type BuildController struct {
+	// Ask the user for the values of missing input variables.
+	Interactive bool
+}
 
-func (BuildController) Build(context *model.Context, buildFatPackage bool, extraVars map[string]interface{}, extraProviders map[string]string) error {
+func (b BuildController) Build(context *model.Context, buildFatPackage bool, extraVars map[string]interface{}, extraProviders map[string]string) error {
 	context.PushLogRelease(context.GetReleaseMetadata().GetQualifiedReleaseId())
 	context.PushLogSection("Build")
 	context.Log("build.start", nil)
 	if err := SaveExtraInputsAndProvidersInDeploymentState(context, "build", extraVars, extraProviders); err != nil {
 		return err
 	}
+	if b.Interactive {
+		if err := NewTerminalInputWizard().AskForMissingInputs(context, "build"); err != nil {
+			return err
+		}
+	}
 	runnerContext, err := runners.NewRunnerContext(context)
 	if err != nil {
 		return err
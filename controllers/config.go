@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/ankyra/escape/model"
 	"github.com/ankyra/escape/util"
@@ -53,6 +54,47 @@ func (ConfigController) ShowProfileField(context *model.Context, field string) *
 	return result
 }
 
+type explainedValue struct {
+	Field    string      `json:"field"`
+	Value    interface{} `json:"value"`
+	Layer    string      `json:"layer"`
+	Location string      `json:"location,omitempty"`
+}
+
+func (ConfigController) ExplainProfile(context *model.Context) *ControllerResult {
+	result := NewControllerResult()
+	profile := context.GetEscapeConfig().GetCurrentProfile()
+
+	result.HumanOutput.AddLine("Profile: %s", context.GetEscapeConfig().ActiveProfile)
+
+	configMap := util.StructToMapStringInterface(*profile, "json")
+	fields := []string{}
+	for field := range configMap {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	explained := []*explainedValue{}
+	for _, field := range fields {
+		source := profile.GetValueSource(field)
+		value := &explainedValue{
+			Field:    field,
+			Value:    configMap[field],
+			Layer:    source.Layer,
+			Location: source.Location,
+		}
+		from := source.Layer
+		if source.Location != "" {
+			from += ": " + source.Location
+		}
+		result.HumanOutput.AddLine("%s: %v [%s]", field, configMap[field], from)
+		explained = append(explained, value)
+	}
+	result.MarshalableOutput = explained
+
+	return result
+}
+
 func (ConfigController) ActiveProfile(context *model.Context) *ControllerResult {
 	result := NewControllerResult()
 
@@ -111,3 +153,27 @@ func (ConfigController) CreateProfile(context *model.Context, targetName string)
 
 	return result
 }
+
+func (ConfigController) MigrateCredentials(context *model.Context) *ControllerResult {
+	result := NewControllerResult()
+	profile := context.GetEscapeConfig().GetCurrentProfile()
+
+	migrated, err := profile.MigrateCredentials()
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.MarshalableOutput = migrated
+	if len(migrated) == 0 {
+		result.HumanOutput.AddLine("There are no credentials to migrate in profile '%s'.", context.GetEscapeConfig().ActiveProfile)
+		return result
+	}
+	result.Error = context.GetEscapeConfig().Save()
+	if result.Error != nil {
+		return result
+	}
+	result.HumanOutput.AddLine("Moved the credentials for the following servers to the '%s' credential helper:", profile.CredentialHelper)
+	result.HumanOutput.AddStringList(migrated)
+
+	return result
+}
@@ -25,7 +25,10 @@ import (
 	"github.com/ankyra/escape/model/runners/deploy"
 )
 
-type DeployController struct{}
+type DeployController struct {
+	// Ask the user for the values of missing input variables.
+	Interactive bool
+}
 
 func SetExtraProviders(context *model.Context, stage string, extraProviders map[string]string) error {
 	envState := context.GetEnvironmentState()
@@ -60,6 +63,11 @@ func (d DeployController) Deploy(context *model.Context, extraVars map[string]in
 	if err := SaveExtraInputsAndProvidersInDeploymentState(context, "deploy", extraVars, extraProviders); err != nil {
 		return MarkDeploymentFailed(context, err, state.Failure)
 	}
+	if d.Interactive {
+		if err := NewTerminalInputWizard().AskForMissingInputs(context, "deploy"); err != nil {
+			return MarkDeploymentFailed(context, err, state.Failure)
+		}
+	}
 	runnerContext, err := runners.NewRunnerContext(context)
 	if err != nil {
 		return MarkDeploymentFailed(context, err, state.Failure)
@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 
+	core "github.com/ankyra/escape-core"
 	"github.com/ankyra/escape/model"
 	"github.com/ankyra/escape/model/paths"
 	"github.com/ankyra/escape/model/runners"
@@ -54,48 +56,137 @@ func (ErrandsController) List(context *model.Context) *ControllerResult {
 	return result
 }
 
-func (ErrandsController) Run(context *model.Context, errandStr string, extraVars map[string]interface{}) error {
-	//        applog("errand.start", errand=errand, release=escape_plan.get_versionless_build_id())
+// Run runs the errand, after running the errands it requires. The outputs
+// of the errands are returned in the result.
+func (ErrandsController) Run(context *model.Context, errandStr string, extraVars map[string]interface{}, scheduled bool) *ControllerResult {
+	result := NewControllerResult()
 	metadata := context.GetReleaseMetadata()
 	if metadata.GetErrands() == nil {
-		return fmt.Errorf("This release doesn't have any errands.")
+		result.Error = fmt.Errorf("This release doesn't have any errands.")
+		return result
 	}
-	errandObj, ok := metadata.GetErrands()[errandStr]
-	if !ok {
-		return fmt.Errorf("The errand '%s' could not be found in deployment '%s'. You can use 'escape errands list' to see the available errands.", errandStr, context.GetRootDeploymentName())
+	if _, ok := metadata.GetErrands()[errandStr]; !ok {
+		result.Error = fmt.Errorf("The errand '%s' could not be found in deployment '%s'. You can use 'escape errands list' to see the available errands.", errandStr, context.GetRootDeploymentName())
+		return result
+	}
+	chain, err := core.GetErrandChain(metadata.GetErrands(), errandStr)
+	if err != nil {
+		result.Error = err
+		return result
 	}
-	runner := errand.NewErrandRunner(errandObj, extraVars)
 	runnerContext, err := runners.NewRunnerContext(context)
 	if err != nil {
-		return err
+		result.Error = err
+		return result
 	}
-	return runner.Run(runnerContext)
+	outputs := map[string]interface{}{}
+	result.MarshalableOutput = outputs
+	for _, errandObj := range chain {
+		if len(chain) > 1 {
+			context.Log("errand.start", map[string]string{
+				"errand": errandObj.Name,
+			})
+		}
+		runner := errand.NewErrandRunner(errandObj, extraVars)
+		if scheduled {
+			runner = errand.NewScheduledErrandRunner(errandObj, extraVars)
+		}
+		if err := runner.Run(runnerContext); err != nil {
+			result.Error = err
+			return result
+		}
+		if len(errandObj.Outputs) == 0 {
+			continue
+		}
+		errandOutputs := errand.MaskSensitiveValues(errandObj.GetOutputs(), runnerContext.GetErrandOutputs()[errandObj.Name])
+		outputs[errandObj.Name] = errandOutputs
+		result.HumanOutput.AddLine("Outputs of errand '%s':", errandObj.Name)
+		keys := []string{}
+		for key := range errandOutputs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result.HumanOutput.AddLine("  %s = %v", key, errandOutputs[key])
+		}
+	}
+	return result
 }
 
-func (e ErrandsController) RunRemoteErrand(context *model.Context, errandStr string, extraVars map[string]interface{}) error {
+func (e ErrandsController) RunRemoteErrand(context *model.Context, errandStr string, extraVars map[string]interface{}, scheduled bool) *ControllerResult {
+	cleanup, err := unpackErrandRelease(context)
+	if err != nil {
+		result := NewControllerResult()
+		result.Error = err
+		return result
+	}
+	defer cleanup()
+	return e.Run(context, errandStr, extraVars, scheduled)
+}
+
+// unpackErrandRelease fetches the release into a temporary directory and
+// changes the working directory to it. The returned function changes back
+// and removes the temporary directory.
+func unpackErrandRelease(context *model.Context) (func(), error) {
 	name, err := ioutil.TempDir("", "escape-errand")
 	if err != nil {
-		return fmt.Errorf("Could not create temporary directory for errand: %s", err.Error())
+		return nil, fmt.Errorf("Could not create temporary directory for errand: %s", err.Error())
 	}
 	currentDir, err := os.Getwd()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	cleanup := func() {
+		os.Chdir(currentDir)
+		os.RemoveAll(name)
 	}
 	if err := os.Chdir(name); err != nil {
-		return fmt.Errorf("Could not change to temporary directory %s: %s", name, err.Error())
+		os.RemoveAll(name)
+		return nil, fmt.Errorf("Could not change to temporary directory %s: %s", name, err.Error())
 	}
 	releaseId := context.GetReleaseMetadata().GetQualifiedReleaseId()
 	if err := (FetchController{}.Fetch(context, []string{releaseId})); err != nil {
-		return err
+		cleanup()
+		return nil, err
 	}
 	if err := os.Chdir(paths.NewPath().UnpackedDepDirectoryByReleaseMetadata(context.GetReleaseMetadata())); err != nil {
-		return err
+		cleanup()
+		return nil, err
+	}
+	return cleanup, nil
+}
+
+func (ErrandsController) History(context *model.Context, deployment, errandStr string) *ControllerResult {
+	result := NewControllerResult()
+	deplState, exists := context.GetEnvironmentState().Deployments[deployment]
+	if !exists {
+		result.Error = fmt.Errorf("The deployment '%s' could not be found in environment '%s'", deployment, context.GetEnvironmentState().Name)
+		return result
 	}
-	if err := e.Run(context, errandStr, extraVars); err != nil {
-		return err
+	history := deplState.GetErrandHistory(errandStr)
+	result.MarshalableOutput = history
+	if len(history) == 0 {
+		result.HumanOutput.AddLine("No errand runs have been recorded for deployment '%s'", deployment)
+		return result
 	}
-	if err := os.Chdir(currentDir); err != nil {
-		return err
+	for _, run := range history {
+		line := fmt.Sprintf("%s  %s  %s", run.StartedAt.Local().Format("2006-01-02 15:04:05"), run.Errand, run.Status)
+		if run.ExitCode != nil {
+			line += fmt.Sprintf(" (exit code %d)", *run.ExitCode)
+		}
+		if run.Version != "" {
+			line += "  v" + run.Version
+		}
+		if run.User != "" {
+			line += "  by " + run.User
+		}
+		if run.Scheduled {
+			line += " (scheduled)"
+		}
+		result.HumanOutput.AddLine(line)
+		if run.Error != "" {
+			result.HumanOutput.AddLine("    %s", run.Error)
+		}
 	}
-	return os.RemoveAll(name)
+	return result
 }
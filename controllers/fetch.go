@@ -42,7 +42,8 @@ func (f FetchController) ResolveFetchAndLoad(context *model.Context, releaseId s
 	if err := parsed.EnsureConfigIsParsed(); err != nil {
 		return err
 	}
-	if parsed.NeedsResolving() {
+	resolved := parsed.NeedsResolving()
+	if resolved {
 		metadata, err := context.QueryReleaseMetadata(parsed)
 		if err != nil {
 			return err
@@ -54,6 +55,9 @@ func (f FetchController) ResolveFetchAndLoad(context *model.Context, releaseId s
 	if err := f.Fetch(context, []string{releaseId}); err != nil {
 		return err
 	}
+	if !resolved {
+		context.CheckReleaseStatus(parsed.Project, parsed.Name, parsed.Version)
+	}
 	root := paths.NewPath().UnpackedDepCfgDirectory(parsed)
 	err := os.Chdir(root)
 	if err != nil {
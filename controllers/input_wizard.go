@@ -0,0 +1,185 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/ankyra/escape-core/variables"
+	"github.com/ankyra/escape-core/variables/variable_types"
+	"github.com/ankyra/escape/model"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// InputWizard asks the user for the values of the input variables that
+// haven't been configured yet.
+type InputWizard struct {
+	Reader     *bufio.Reader
+	Writer     io.Writer
+	ReadSecret func() (string, error)
+}
+
+// NewTerminalInputWizard returns an InputWizard that reads from stdin.
+// Secrets are read without echo when stdin is a terminal.
+func NewTerminalInputWizard() *InputWizard {
+	w := &InputWizard{
+		Reader: bufio.NewReader(os.Stdin),
+		Writer: os.Stdout,
+	}
+	if terminal.IsTerminal(int(syscall.Stdin)) {
+		w.ReadSecret = func() (string, error) {
+			secret, err := terminal.ReadPassword(int(syscall.Stdin))
+			fmt.Fprintln(w.Writer)
+			return string(secret), err
+		}
+	}
+	return w
+}
+
+// AskForMissingInputs prompts for every input variable in the stage that has
+// no value and no default, and saves the answers as user inputs in the
+// deployment state.
+func (w *InputWizard) AskForMissingInputs(context *model.Context, stage string) error {
+	envState := context.GetEnvironmentState()
+	deplState, err := envState.GetOrCreateDeploymentState(context.GetRootDeploymentName())
+	if err != nil {
+		return err
+	}
+	inputs := deplState.GetUserInputs(stage)
+	vars := context.GetReleaseMetadata().GetInputs(stage)
+	migrated, _ := variables.MigrateValues(vars, inputs)
+	missing := GetMissingInputs(vars, migrated)
+	if len(missing) == 0 {
+		return nil
+	}
+	for _, v := range missing {
+		val, err := w.Ask(v)
+		if err != nil {
+			return err
+		}
+		inputs[v.Id] = val
+	}
+	return deplState.UpdateUserInputs(stage, inputs)
+}
+
+// GetMissingInputs returns the visible, user managed variables that don't
+// have a value in inputs and that don't have a default.
+func GetMissingInputs(vars []*variables.Variable, inputs map[string]interface{}) []*variables.Variable {
+	result := []*variables.Variable{}
+	for _, v := range vars {
+		if _, found := inputs[v.Id]; found || v.HasDefault() || !v.Visible {
+			continue
+		}
+		typ, err := variable_types.GetVariableType(v.Type)
+		if err != nil || !typ.UserCanOverride {
+			continue
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+// Ask prompts for the value of the variable until a valid value is given.
+func (w *InputWizard) Ask(v *variables.Variable) (interface{}, error) {
+	typ, err := variable_types.GetVariableType(v.Type)
+	if err != nil {
+		return nil, err
+	}
+	name := v.Id
+	if v.Friendly != "" {
+		name = fmt.Sprintf("%s (%s)", v.Friendly, v.Id)
+	}
+	fmt.Fprintf(w.Writer, "%s [%s]\n", name, v.Type)
+	if v.Description != "" {
+		fmt.Fprintf(w.Writer, "  %s\n", v.Description)
+	}
+	choices := getInputChoices(v)
+	for i, choice := range choices {
+		fmt.Fprintf(w.Writer, "  %d) %s\n", i+1, choice)
+	}
+	for {
+		fmt.Fprintf(w.Writer, "> ")
+		answer, err := w.readAnswer(v)
+		if err != nil {
+			return nil, fmt.Errorf("No value given for input variable '%s': %s", v.Id, err.Error())
+		}
+		if answer == "" {
+			fmt.Fprintf(w.Writer, "A value is required.\n")
+			continue
+		}
+		if i, err := strconv.Atoi(answer); err == nil && i >= 1 && i <= len(choices) {
+			answer = choices[i-1]
+		}
+		if len(choices) > 0 && !isInputChoice(answer, choices) {
+			fmt.Fprintf(w.Writer, "Expecting one of: %s\n", strings.Join(choices, ", "))
+			continue
+		}
+		val, err := typ.Validate(answer, v.Options)
+		if err != nil {
+			fmt.Fprintf(w.Writer, "%s\n", err.Error())
+			continue
+		}
+		return val, nil
+	}
+}
+
+func (w *InputWizard) readAnswer(v *variables.Variable) (string, error) {
+	if (v.Sensitive || v.Type == "secret") && w.ReadSecret != nil {
+		return w.ReadSecret()
+	}
+	line, err := w.Reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// getInputChoices returns the allowed values of the variable from its
+// 'choices' option or its literal 'items'.
+func getInputChoices(v *variables.Variable) []string {
+	result := []string{}
+	items, ok := v.Options["choices"].([]interface{})
+	if !ok {
+		items, ok = v.Items.([]interface{})
+	}
+	if !ok {
+		return result
+	}
+	for _, item := range items {
+		str := fmt.Sprintf("%v", item)
+		if strings.HasPrefix(str, "$") {
+			return []string{}
+		}
+		result = append(result, str)
+	}
+	return result
+}
+
+func isInputChoice(answer string, choices []string) bool {
+	for _, choice := range choices {
+		if choice == answer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"github.com/ankyra/escape-core/variables"
+	. "gopkg.in/check.v1"
+)
+
+func newTestInputWizard(input string) (*InputWizard, *bytes.Buffer) {
+	output := bytes.NewBuffer([]byte{})
+	return &InputWizard{
+		Reader: bufio.NewReader(strings.NewReader(input)),
+		Writer: output,
+	}, output
+}
+
+func newTestVariable(c *C, dict map[interface{}]interface{}) *variables.Variable {
+	v, err := variables.NewVariableFromDict(dict)
+	c.Assert(err, IsNil)
+	return v
+}
+
+func (s *suite) Test_GetMissingInputs(c *C) {
+	vars := []*variables.Variable{
+		newTestVariable(c, map[interface{}]interface{}{"id": "missing"}),
+		newTestVariable(c, map[interface{}]interface{}{"id": "configured"}),
+		newTestVariable(c, map[interface{}]interface{}{"id": "defaulted", "default": "value"}),
+		newTestVariable(c, map[interface{}]interface{}{"id": "hidden", "visible": false}),
+		newTestVariable(c, map[interface{}]interface{}{"id": "version"}),
+	}
+	missing := GetMissingInputs(vars, map[string]interface{}{"configured": "value"})
+	c.Assert(missing, HasLen, 1)
+	c.Assert(missing[0].Id, Equals, "missing")
+}
+
+func (s *suite) Test_InputWizard_Ask_uses_friendly_name_and_description_and_validates_type(c *C) {
+	v := newTestVariable(c, map[interface{}]interface{}{
+		"id":          "replicas",
+		"type":        "integer",
+		"friendly":    "Replicas",
+		"description": "The number of replicas",
+	})
+	wizard, output := newTestInputWizard("\nthree\n3\n")
+	val, err := wizard.Ask(v)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, 3)
+	c.Assert(output.String(), Equals, "Replicas (replicas) [integer]\n"+
+		"  The number of replicas\n"+
+		"> A value is required.\n"+
+		"> Expecting 'integer' value, but got 'string'\n"+
+		"> ")
+}
+
+func (s *suite) Test_InputWizard_Ask_offers_choices(c *C) {
+	v := newTestVariable(c, map[interface{}]interface{}{
+		"id":      "size",
+		"options": map[interface{}]interface{}{"choices": []interface{}{"small", "large"}},
+	})
+	wizard, output := newTestInputWizard("medium\n2\n")
+	val, err := wizard.Ask(v)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "large")
+	c.Assert(output.String(), Equals, "size [string]\n"+
+		"  1) small\n"+
+		"  2) large\n"+
+		"> Expecting one of: small, large\n"+
+		"> ")
+}
+
+func (s *suite) Test_InputWizard_Ask_reads_secrets_without_echo(c *C) {
+	v := newTestVariable(c, map[interface{}]interface{}{"id": "password", "type": "secret"})
+	wizard, _ := newTestInputWizard("")
+	wizard.ReadSecret = func() (string, error) {
+		return "hunter2", nil
+	}
+	val, err := wizard.Ask(v)
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "hunter2")
+}
+
+func (s *suite) Test_InputWizard_Ask_fails_on_end_of_input(c *C) {
+	v := newTestVariable(c, map[interface{}]interface{}{"id": "name"})
+	wizard, _ := newTestInputWizard("")
+	_, err := wizard.Ask(v)
+	c.Assert(err, ErrorMatches, "No value given for input variable 'name': EOF")
+}
@@ -17,7 +17,14 @@ limitations under the License.
 package controllers
 
 import (
+	"fmt"
+
+	"github.com/ankyra/escape-core/parsers"
+	"github.com/ankyra/escape-core/state"
 	"github.com/ankyra/escape/model"
+	"github.com/ankyra/escape/model/inventory"
+	"github.com/ankyra/escape/model/inventory/local"
+	"github.com/ankyra/escape/model/inventory/types"
 )
 
 type InventoryController struct{}
@@ -62,3 +69,112 @@ func (r InventoryController) Query(context *model.Context, project, application,
 	result.HumanOutput.AddStringList(resultData)
 	return result
 }
+
+func (r InventoryController) Search(context *model.Context, query *types.ReleaseQuery) *ControllerResult {
+	result := NewControllerResult()
+	releases, err := inventory.Search(context.GetInventory(), query, context.GetLogger())
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.MarshalableOutput = releases
+	if len(releases) == 0 {
+		result.HumanOutput.AddLine("Inventory returned 0 results.")
+		return result
+	}
+	releaseIds := []string{}
+	for _, metadata := range releases {
+		releaseIds = append(releaseIds, metadata.GetQualifiedReleaseId())
+	}
+	result.HumanOutput.AddStringList(releaseIds)
+	return result
+}
+
+func (r InventoryController) GarbageCollect(context *model.Context, policy *local.RetentionPolicy, stateFiles []string, dryRun bool) *ControllerResult {
+	result := NewControllerResult()
+	for _, stateFile := range stateFiles {
+		if err := addDeployedReleases(policy.KeepReleases, stateFile); err != nil {
+			result.Error = err
+			return result
+		}
+	}
+	baseDir := context.GetEscapeConfig().GetCurrentProfile().GetLocalInventoryBaseDir()
+	deleted, err := local.NewLocalInventory(baseDir).GarbageCollect(policy, dryRun)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.MarshalableOutput = deleted
+	if len(deleted) == 0 {
+		result.HumanOutput.AddLine("Nothing to clean up in the local inventory at %s.", baseDir)
+		return result
+	}
+	if dryRun {
+		result.HumanOutput.AddLine("Would delete %d release(s) from the local inventory at %s:", len(deleted), baseDir)
+	} else {
+		result.HumanOutput.AddLine("Deleted %d release(s) from the local inventory at %s:", len(deleted), baseDir)
+	}
+	result.HumanOutput.AddStringList(deleted)
+	return result
+}
+
+func addDeployedReleases(releases map[string]bool, stateFile string) error {
+	prj, err := state.NewProjectStateFromFile("", stateFile, nil)
+	if err != nil {
+		return fmt.Errorf("Couldn't read state file '%s': %s", stateFile, err.Error())
+	}
+	for _, env := range prj.Environments {
+		for _, depl := range env.GetDeployments() {
+			addDeploymentReleases(releases, depl)
+		}
+	}
+	return nil
+}
+
+func addDeploymentReleases(releases map[string]bool, depl *state.DeploymentState) {
+	for _, stage := range depl.Stages {
+		if stage == nil {
+			continue
+		}
+		if depl.Release != "" && stage.Version != "" {
+			releases[depl.Release+"-v"+stage.Version] = true
+		}
+		for _, child := range stage.Deployments {
+			addDeploymentReleases(releases, child)
+		}
+	}
+}
+
+func (r InventoryController) Deprecate(context *model.Context, releaseId, reason string) error {
+	parsed, err := parseExactReleaseId(releaseId, reason)
+	if err != nil {
+		return err
+	}
+	return context.GetInventory().DeprecateRelease(parsed.Project, parsed.Name, parsed.Version, reason)
+}
+
+func (r InventoryController) Yank(context *model.Context, releaseId, reason string) error {
+	parsed, err := parseExactReleaseId(releaseId, reason)
+	if err != nil {
+		return err
+	}
+	return context.GetInventory().YankRelease(parsed.Project, parsed.Name, parsed.Version, reason)
+}
+
+func parseExactReleaseId(releaseId, reason string) (*parsers.QualifiedReleaseId, error) {
+	parsed, err := parsers.ParseQualifiedReleaseId(releaseId)
+	if err != nil {
+		return nil, err
+	}
+	query, err := parsers.ParseVersionQuery("v" + parsed.Version)
+	if err != nil {
+		return nil, err
+	}
+	if query.SpecificVersion == "" {
+		return nil, fmt.Errorf("Expecting a release ID with an exact version (e.g. project/name-v1.0.0), got '%s'.", releaseId)
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("Missing reason. Please let your users know why the release shouldn't be used anymore.")
+	}
+	return parsed, nil
+}
@@ -20,7 +20,6 @@ import (
 	"bufio"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"os/exec"
 	"runtime"
@@ -32,19 +31,32 @@ import (
 
 	"github.com/ankyra/escape/model"
 	"github.com/ankyra/escape/model/inventory/types"
+	"github.com/ankyra/escape/model/remote"
+	"github.com/ankyra/escape/util"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
 type LoginController struct{}
 
-func (LoginController) Login(context *model.Context, url, authMethodRequested, username, password string, insecureSkipVerify bool, targetProfile string) error {
+// How long to wait for an OAuth login to be authorized, and how often to
+// check.
+var redeemTimeout = 5 * time.Minute
+var redeemPollInterval = time.Second
+
+func (LoginController) Login(context *model.Context, url, authMethodRequested, username, password string, insecureSkipVerify bool, targetProfile, credentialHelper, tokenFile string, deviceCode, nonInteractive bool) error {
 
 	if targetProfile != "" {
 		context.GetEscapeConfig().NewProfile(targetProfile)
 		context.GetEscapeConfig().SetActiveProfile(targetProfile)
 	}
+	if credentialHelper != "" {
+		context.GetEscapeConfig().GetCurrentProfile().CredentialHelper = credentialHelper
+	}
 
 	context.GetEscapeConfig().GetCurrentProfile().SetInsecureSkipVerify(insecureSkipVerify)
+	if tokenFile != "" {
+		return tokenFileAuth(context, url, tokenFile)
+	}
 	authMethods, err := context.GetInventory().GetAuthMethods(url)
 	if err != nil {
 		return err
@@ -55,12 +67,14 @@ func (LoginController) Login(context *model.Context, url, authMethodRequested, u
 		context.GetEscapeConfig().GetCurrentProfile().SetBasicAuthCredentials("", "")
 		context.GetEscapeConfig().GetCurrentProfile().SetAuthToken("")
 		context.GetEscapeConfig().GetCurrentProfile().SetApiServer(url)
-		return context.GetEscapeConfig().Save()
+		return saveProfile(context)
 	}
 
+	// Never prompt when there's no one to answer; fail instead.
+	interactive := !nonInteractive && terminal.IsTerminal(int(syscall.Stdin))
 	reader := bufio.NewReader(os.Stdin)
 	if username != "" && authMethods["service-account"] != nil {
-		return secretTokenAuth(reader, context, url, authMethods["service-account"].URL, username, password)
+		return secretTokenAuth(reader, interactive, context, url, authMethods["service-account"].URL, username, password)
 	}
 
 	var authMethod *types.AuthMethod
@@ -77,24 +91,63 @@ func (LoginController) Login(context *model.Context, url, authMethodRequested, u
 	}
 
 	if authMethod == nil {
+		if !interactive && len(authMethods) > 1 {
+			return util.NewAuthenticationRequiredError(fmt.Sprintf("Multiple authentication methods are available (%s). Use --auth-method to select one.",
+				strings.Join(sortAuthMethodMapKeys(authMethods), ", ")))
+		}
 		authMethod = authUserSelection(reader, authMethods)
 	}
 	if authMethod.Type == "oauth" {
 		fmt.Println("Logging in using OAuth2 provider.")
-		openBrowser(authMethod.URL)
-		return getEscapeTokenWithRedeemToken(context, url, authMethod.RedeemToken, authMethod.RedeemURL)
+		if deviceCode || !interactive {
+			fmt.Printf("\nOpen the following URL in a browser to authorize this login:\n\n    %s\n\n", authMethod.URL)
+			if authMethod.UserCode != "" {
+				fmt.Printf("Verification code: %s\n\n", authMethod.UserCode)
+			}
+			fmt.Println("Waiting for authorization...")
+		} else {
+			openBrowser(authMethod.URL)
+		}
+		return getEscapeTokenWithRedeemToken(context, url, authMethod)
 	} else if authMethod.Type == "secret-token" {
 		fmt.Println("Logging in using username and password combination.")
-		return secretTokenAuth(reader, context, url, authMethod.URL, username, password)
+		return secretTokenAuth(reader, interactive, context, url, authMethod.URL, username, password)
 	} else if authMethod.Type == "basic-auth" {
 		fmt.Println("Logging in using Basic Authentication against " + authMethod.URL)
-		return basicAuth(reader, context, url, authMethod.URL, username, password)
+		return basicAuth(reader, interactive, context, url, authMethod.URL, username, password)
 	} else {
 		return fmt.Errorf("The authentication method '%s' is not supported by this client.", authMethod.Type)
 	}
 	return nil
 }
 
+// tokenFileAuth stores a token that was obtained out of band, e.g. by a CI
+// system. A tokenFile of "-" reads the token from stdin.
+func tokenFileAuth(context *model.Context, url, tokenFile string) error {
+	var content []byte
+	var err error
+	if tokenFile == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(tokenFile)
+	}
+	if err != nil {
+		return fmt.Errorf("Couldn't read token file '%s': %s", tokenFile, err.Error())
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return util.NewAuthenticationRequiredError(fmt.Sprintf("The token file '%s' is empty.", tokenFile))
+	}
+	context.GetEscapeConfig().GetCurrentProfile().SetBasicAuthCredentials("", "")
+	context.GetEscapeConfig().GetCurrentProfile().SetAuthToken(token)
+	context.GetEscapeConfig().GetCurrentProfile().SetApiServer(url)
+	if err := saveProfile(context); err != nil {
+		return err
+	}
+	fmt.Printf("Successfully stored auth token for %s\n", url)
+	return nil
+}
+
 func authUserSelection(reader *bufio.Reader, authMethods map[string]*types.AuthMethod) *types.AuthMethod {
 	sortedKeys := sortAuthMethodMapKeys(authMethods)
 
@@ -139,8 +192,8 @@ func authUserSelection(reader *bufio.Reader, authMethods map[string]*types.AuthM
 	return methods[ix-1]
 }
 
-func secretTokenAuth(reader *bufio.Reader, context *model.Context, url, loginUrl, username, password string) error {
-	err := credentialsUserInput(reader, &username, &password)
+func secretTokenAuth(reader *bufio.Reader, interactive bool, context *model.Context, url, loginUrl, username, password string) error {
+	err := credentialsUserInput(reader, interactive, &username, &password)
 	if err != nil {
 		return err
 	}
@@ -151,13 +204,15 @@ func secretTokenAuth(reader *bufio.Reader, context *model.Context, url, loginUrl
 	context.GetEscapeConfig().GetCurrentProfile().SetBasicAuthCredentials("", "")
 	context.GetEscapeConfig().GetCurrentProfile().SetAuthToken(authToken)
 	context.GetEscapeConfig().GetCurrentProfile().SetApiServer(url)
-	context.GetEscapeConfig().Save()
+	if err := saveProfile(context); err != nil {
+		return err
+	}
 	fmt.Printf("\nSuccessfully retrieved and stored auth token %s\n", authToken)
 	return nil
 }
 
-func basicAuth(reader *bufio.Reader, context *model.Context, url, loginUrl, username, password string) error {
-	err := credentialsUserInput(reader, &username, &password)
+func basicAuth(reader *bufio.Reader, interactive bool, context *model.Context, url, loginUrl, username, password string) error {
+	err := credentialsUserInput(reader, interactive, &username, &password)
 	if err != nil {
 		return err
 	}
@@ -166,15 +221,33 @@ func basicAuth(reader *bufio.Reader, context *model.Context, url, loginUrl, user
 	}
 	context.GetEscapeConfig().GetCurrentProfile().SetBasicAuthCredentials(username, password)
 	context.GetEscapeConfig().GetCurrentProfile().SetApiServer(url)
-	if err := context.GetEscapeConfig().Save(); err != nil {
+	if err := saveProfile(context); err != nil {
 		return err
 	}
+	if helper := context.GetEscapeConfig().GetCurrentProfile().CredentialHelper; helper != "" {
+		fmt.Printf("\nSuccessfully logged in using basic authentication. Credentials were stored using the '%s' credential helper.\n", helper)
+		return nil
+	}
 	fmt.Printf("\nSuccessfully logged in using basic authentication. Credentials were stored in the current configuration profile (see `escape config profile`)\n")
 	return nil
 }
 
-func credentialsUserInput(reader *bufio.Reader, username, password *string) error {
-	var err error
+// saveProfile writes the credentials through to the profile's credential
+// helper (if any) before saving the configuration file.
+func saveProfile(context *model.Context) error {
+	if err := context.GetEscapeConfig().GetCurrentProfile().StoreCredentials(); err != nil {
+		return err
+	}
+	return context.GetEscapeConfig().Save()
+}
+
+func credentialsUserInput(reader *bufio.Reader, interactive bool, username, password *string) error {
+	if !interactive && *username == "" {
+		return util.NewAuthenticationRequiredError("A username is required to login. Use --username to provide one.")
+	}
+	if !interactive && *password == "" {
+		return util.NewAuthenticationRequiredError("A password is required to login. Use --password to provide one, or use --token-file.")
+	}
 	if *username == "" {
 		fmt.Printf("Username: ")
 		input, err := reader.ReadString('\n')
@@ -185,7 +258,7 @@ func credentialsUserInput(reader *bufio.Reader, username, password *string) erro
 	}
 	if *password == "" {
 		fmt.Printf("Password: ")
-		passwordBytes, _ := terminal.ReadPassword(int(syscall.Stdin))
+		passwordBytes, err := terminal.ReadPassword(int(syscall.Stdin))
 		if err != nil {
 			return err
 		}
@@ -213,51 +286,57 @@ func openBrowser(url string) {
 	}
 }
 
-func getEscapeTokenWithRedeemToken(context *model.Context, url, redeemToken, redeemURL string) error {
-
-	currentTry := 0
-	tries := 25
-	timeOut := time.Duration(1)
-	client := &http.Client{}
+// getEscapeTokenWithRedeemToken polls the redeem URL until the login has
+// been authorized, the server rejects it, or redeemTimeout is reached.
+func getEscapeTokenWithRedeemToken(context *model.Context, url string, authMethod *types.AuthMethod) error {
+	profile := context.GetEscapeConfig().GetCurrentProfile()
+	client := remote.NewRemoteClient("", "", "", profile.GetInsecureSkipVerify()).GetHTTPClient()
 	if !strings.HasSuffix(url, "/") {
 		url = url + "/"
 	}
-	redeemURL += "?redeem-token=" + redeemToken
-	for currentTry < tries {
-
-		req, err := http.NewRequest("GET", redeemURL, nil)
+	redeemURL := authMethod.RedeemURL + "?redeem-token=" + authMethod.RedeemToken
+	deadline := time.Now().Add(redeemTimeout)
+	interval := redeemPollInterval
+	currentTry := 0
+	for {
+		resp, err := client.Get(redeemURL)
 		if err != nil {
 			return fmt.Errorf("Couldn't retrieve token from server: %s", err)
 		}
-		resp, err := client.Do(req)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
-			return fmt.Errorf("Couldn't retrieve token from server: %s", err)
+			return fmt.Errorf("Couldn't read response from server '%s': %s", redeemURL, err.Error())
 		}
 		if resp.StatusCode == 200 {
-			authToken, err := ioutil.ReadAll(resp.Body)
+			token, err := remote.ParseTokenResponse(body)
 			if err != nil {
-				return fmt.Errorf("Couldn't read response from server '%s': %s", redeemURL, resp.Status)
+				return err
 			}
-			context.GetEscapeConfig().GetCurrentProfile().SetBasicAuthCredentials("", "")
-			context.GetEscapeConfig().GetCurrentProfile().SetAuthToken(string(authToken))
-			context.GetEscapeConfig().GetCurrentProfile().SetApiServer(url)
-			context.GetEscapeConfig().Save()
-			fmt.Printf("\nSuccessfully retrieved and stored auth token %s\n", authToken)
+			profile.SetBasicAuthCredentials("", "")
+			profile.SetTokenResponse(token, authMethod.RefreshURL)
+			profile.SetApiServer(url)
+			if err := saveProfile(context); err != nil {
+				return err
+			}
+			fmt.Printf("\nSuccessfully retrieved and stored auth token %s\n", token.Token)
 			return nil
 		}
+		if resp.StatusCode == 403 || resp.StatusCode == 410 {
+			return util.NewAuthenticationFailedError(fmt.Sprintf("The login was denied or has expired: %s", strings.TrimSpace(string(body))))
+		}
 		if resp.StatusCode != 404 {
 			return fmt.Errorf("Couldn't retrieve token from server. Got status code %d", resp.StatusCode)
 		}
-		time.Sleep(timeOut * time.Second)
-		currentTry++
-		if currentTry == 5 {
-			timeOut *= 2
+		if time.Now().Add(interval).After(deadline) {
+			return util.NewAuthenticationFailedError("Timed out waiting for the login to be authorized.")
 		}
-		if currentTry == 10 {
-			timeOut *= 2
+		time.Sleep(interval)
+		currentTry++
+		if currentTry == 5 || currentTry == 10 {
+			interval *= 2
 		}
 	}
-	return nil
 }
 
 func sortAuthMethodMapKeys(authMethods map[string]*types.AuthMethod) []string {
@@ -0,0 +1,194 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ankyra/escape/model"
+	"github.com/ankyra/escape/model/inventory/types"
+	"github.com/ankyra/escape/util"
+	. "gopkg.in/check.v1"
+)
+
+const loginTestDir = "testdata_login"
+
+func newLoginTestContext(c *C) *model.Context {
+	os.RemoveAll(loginTestDir)
+	c.Assert(os.MkdirAll(loginTestDir, 0755), IsNil)
+	ctx := model.NewContext()
+	c.Assert(ctx.LoadEscapeConfig(filepath.Join(loginTestDir, "escape_config"), "", loginTestDir), IsNil)
+	return ctx
+}
+
+func readLoginTestConfig(c *C) string {
+	content, err := ioutil.ReadFile(filepath.Join(loginTestDir, "escape_config"))
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func (s *suite) Test_Login_with_token_file(c *C) {
+	ctx := newLoginTestContext(c)
+	defer os.RemoveAll(loginTestDir)
+	tokenFile := filepath.Join(loginTestDir, "token")
+	c.Assert(ioutil.WriteFile(tokenFile, []byte("my-token\n"), 0600), IsNil)
+
+	err := LoginController{}.Login(ctx, "http://escape.example.com", "", "", "", false, "", "", tokenFile, false, true)
+	c.Assert(err, IsNil)
+	profile := ctx.GetEscapeConfig().GetCurrentProfile()
+	c.Assert(profile.GetAuthToken(), Equals, "my-token")
+	c.Assert(profile.GetApiServer(), Equals, "http://escape.example.com")
+	c.Assert(strings.Contains(readLoginTestConfig(c), "my-token"), Equals, true)
+}
+
+func (s *suite) Test_Login_with_empty_token_file_requires_authentication(c *C) {
+	ctx := newLoginTestContext(c)
+	defer os.RemoveAll(loginTestDir)
+	tokenFile := filepath.Join(loginTestDir, "token")
+	c.Assert(ioutil.WriteFile(tokenFile, []byte("\n"), 0600), IsNil)
+
+	err := LoginController{}.Login(ctx, "http://escape.example.com", "", "", "", false, "", "", tokenFile, false, true)
+	c.Assert(err, ErrorMatches, ".*is empty.*")
+	c.Assert(util.ExitCode(err), Equals, util.ExitCodeAuthenticationRequired)
+}
+
+func (s *suite) Test_credentialsUserInput_non_interactive(c *C) {
+	username, password := "", ""
+	err := credentialsUserInput(nil, false, &username, &password)
+	c.Assert(util.ExitCode(err), Equals, util.ExitCodeAuthenticationRequired)
+	username = "user"
+	err = credentialsUserInput(nil, false, &username, &password)
+	c.Assert(util.ExitCode(err), Equals, util.ExitCodeAuthenticationRequired)
+	password = "pass"
+	c.Assert(credentialsUserInput(nil, false, &username, &password), IsNil)
+}
+
+func withFastRedeemPolling(timeout time.Duration) func() {
+	oldTimeout, oldInterval := redeemTimeout, redeemPollInterval
+	redeemTimeout, redeemPollInterval = timeout, time.Millisecond
+	return func() {
+		redeemTimeout, redeemPollInterval = oldTimeout, oldInterval
+	}
+}
+
+func (s *suite) Test_getEscapeTokenWithRedeemToken_polls_until_authorized(c *C) {
+	defer withFastRedeemPolling(time.Second)()
+	ctx := newLoginTestContext(c)
+	defer os.RemoveAll(loginTestDir)
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.URL.Query().Get("redeem-token"), Equals, "code")
+		calls++
+		if calls < 3 {
+			w.WriteHeader(404)
+			return
+		}
+		fmt.Fprint(w, `{"token": "my-token", "refresh_token": "refresh", "expires_in": 3600}`)
+	}))
+	defer ts.Close()
+
+	authMethod := &types.AuthMethod{RedeemURL: ts.URL, RedeemToken: "code", RefreshURL: ts.URL + "/refresh"}
+	c.Assert(getEscapeTokenWithRedeemToken(ctx, "http://escape.example.com", authMethod), IsNil)
+	c.Assert(calls, Equals, 3)
+	profile := ctx.GetEscapeConfig().GetCurrentProfile()
+	c.Assert(profile.GetAuthToken(), Equals, "my-token")
+	c.Assert(profile.RefreshToken, Equals, "refresh")
+	c.Assert(profile.RefreshURL, Equals, ts.URL+"/refresh")
+	c.Assert(profile.AuthTokenExpiresAt > time.Now().Unix(), Equals, true)
+	c.Assert(profile.AuthTokenNeedsRefresh(time.Now()), Equals, false)
+	c.Assert(profile.AuthTokenNeedsRefresh(time.Now().Add(time.Hour)), Equals, true)
+}
+
+func (s *suite) Test_getEscapeTokenWithRedeemToken_accepts_plain_tokens(c *C) {
+	defer withFastRedeemPolling(time.Second)()
+	ctx := newLoginTestContext(c)
+	defer os.RemoveAll(loginTestDir)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "my-token")
+	}))
+	defer ts.Close()
+
+	authMethod := &types.AuthMethod{RedeemURL: ts.URL, RedeemToken: "code"}
+	c.Assert(getEscapeTokenWithRedeemToken(ctx, "http://escape.example.com", authMethod), IsNil)
+	profile := ctx.GetEscapeConfig().GetCurrentProfile()
+	c.Assert(profile.GetAuthToken(), Equals, "my-token")
+	c.Assert(profile.AuthTokenExpiresAt, Equals, int64(0))
+	c.Assert(profile.AuthTokenNeedsRefresh(time.Now().Add(time.Hour)), Equals, false)
+}
+
+func (s *suite) Test_getEscapeTokenWithRedeemToken_fails_if_denied(c *C) {
+	defer withFastRedeemPolling(time.Second)()
+	ctx := newLoginTestContext(c)
+	defer os.RemoveAll(loginTestDir)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(410)
+		fmt.Fprint(w, "Code expired")
+	}))
+	defer ts.Close()
+
+	err := getEscapeTokenWithRedeemToken(ctx, "http://escape.example.com", &types.AuthMethod{RedeemURL: ts.URL})
+	c.Assert(err, ErrorMatches, "The login was denied or has expired: Code expired")
+	c.Assert(util.ExitCode(err), Equals, util.ExitCodeAuthenticationFailed)
+}
+
+func (s *suite) Test_getEscapeTokenWithRedeemToken_times_out(c *C) {
+	defer withFastRedeemPolling(20 * time.Millisecond)()
+	ctx := newLoginTestContext(c)
+	defer os.RemoveAll(loginTestDir)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+	}))
+	defer ts.Close()
+
+	err := getEscapeTokenWithRedeemToken(ctx, "http://escape.example.com", &types.AuthMethod{RedeemURL: ts.URL})
+	c.Assert(err, ErrorMatches, "Timed out.*")
+	c.Assert(util.ExitCode(err), Equals, util.ExitCodeAuthenticationFailed)
+}
+
+func (s *suite) Test_LoadCredentials_refreshes_expiring_tokens(c *C) {
+	ctx := newLoginTestContext(c)
+	defer os.RemoveAll(loginTestDir)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		c.Assert(string(body), Equals, `{"refresh_token":"refresh"}`)
+		fmt.Fprint(w, `{"token": "new-token", "expires_in": 3600}`)
+	}))
+	defer ts.Close()
+	profile := ctx.GetEscapeConfig().GetCurrentProfile()
+	profile.SetAuthToken("old-token")
+	profile.AuthTokenExpiresAt = time.Now().Unix() + 60
+	profile.RefreshToken = "refresh"
+	profile.RefreshURL = ts.URL
+	c.Assert(ctx.GetEscapeConfig().Save(), IsNil)
+
+	ctx = model.NewContext()
+	c.Assert(ctx.LoadEscapeConfig(filepath.Join(loginTestDir, "escape_config"), "", loginTestDir), IsNil)
+	profile = ctx.GetEscapeConfig().GetCurrentProfile()
+	c.Assert(profile.GetAuthToken(), Equals, "old-token")
+	c.Assert(profile.LoadCredentials(), IsNil)
+	c.Assert(profile.GetAuthToken(), Equals, "new-token")
+	c.Assert(profile.RefreshToken, Equals, "refresh")
+	c.Assert(profile.AuthTokenExpiresAt > time.Now().Unix()+3000, Equals, true)
+	c.Assert(strings.Contains(readLoginTestConfig(c), "new-token"), Equals, true)
+}
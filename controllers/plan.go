@@ -17,12 +17,20 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"strings"
 
 	"github.com/ankyra/escape-core"
+	"github.com/ankyra/escape-core/state"
+	"github.com/ankyra/escape-core/templates"
 	"github.com/ankyra/escape/model"
 	"github.com/ankyra/escape/model/escape_plan"
+	"github.com/ankyra/escape/model/lint"
+	"github.com/ankyra/escape/model/runners"
 	"github.com/ankyra/escape/util"
 )
 
@@ -32,6 +40,61 @@ func (p PlanController) Compile(context *model.Context) {
 	fmt.Println(context.GetReleaseMetadata().ToJson())
 }
 
+// ExplainExtensions shows the merge rules that were used for the extensions
+// of the Escape plan, where the merged fields came from and the conflicts
+// between extensions.
+func (p PlanController) ExplainExtensions(context *model.Context) *ControllerResult {
+	result := NewControllerResult()
+	report := context.ExtensionReport
+	result.MarshalableOutput = report
+	if report == nil {
+		result.Error = fmt.Errorf("The Escape plan has not been compiled. This is a bug in Escape.")
+		return result
+	}
+	if len(context.GetEscapePlan().Extends) == 0 {
+		result.HumanOutput.AddLine("The Escape plan doesn't extend any releases.")
+		return result
+	}
+	result.HumanOutput.AddLine("Extensions:")
+	for _, extension := range context.GetReleaseMetadata().GetExtensions() {
+		result.HumanOutput.AddLine("  %s", extension)
+	}
+	result.HumanOutput.AddLine("")
+	result.HumanOutput.AddLine("Merge rules:")
+	for _, rule := range report.Rules {
+		if rule.Explicit {
+			result.HumanOutput.AddLine("  %-10s %s", rule.Field, rule.Rule)
+		} else {
+			result.HumanOutput.AddLine("  %-10s %s (default)", rule.Field, rule.Rule)
+		}
+	}
+	field := ""
+	for _, source := range report.Sources {
+		if source.Field != field {
+			field = source.Field
+			result.HumanOutput.AddLine("")
+			result.HumanOutput.AddLine("%s:", field)
+		}
+		line := fmt.Sprintf("  %s: %s", source.Key, source.Effective)
+		if len(source.Sources) > 1 {
+			line += fmt.Sprintf(" (defined in %s)", strings.Join(source.Sources, ", "))
+		}
+		result.HumanOutput.AddLine(line)
+	}
+	if len(report.Conflicts) > 0 {
+		result.HumanOutput.AddLine("")
+		result.HumanOutput.AddLine("Conflicts:")
+		for _, conflict := range report.Conflicts {
+			prefix := ""
+			if conflict.Warning {
+				prefix = "Warning: "
+			}
+			result.HumanOutput.AddLine("  %s%s", prefix, conflict.String())
+		}
+	}
+	return result
+}
+
 func (p PlanController) Diff(context *model.Context) error {
 	metadata := context.GetReleaseMetadata()
 	inventory := context.GetInventory()
@@ -48,22 +111,190 @@ func (p PlanController) Diff(context *model.Context) error {
 	return nil
 }
 
-func (p PlanController) Format(context *model.Context, outputLocation string) error {
-	yaml := context.GetEscapePlan().ToYaml()
-	fmt.Print(string(yaml))
-	if outputLocation != "" {
-		return ioutil.WriteFile(outputLocation, yaml, 0644)
+// Lint checks the Escape plan for problems that the compiler doesn't catch.
+// The severities of the rules are taken from the "lint_rules" in the
+// configuration, and then from the rules argument. The result has an
+// ExitError if any issue has the "error" severity.
+func (p PlanController) Lint(context *model.Context, planFile string, rules map[string]string, sarif bool) *ControllerResult {
+	result := NewControllerResult()
+	linter := lint.NewLinter(planFile, context.GetEscapePlan(), context.GetReleaseMetadata())
+	linter.GetDependencyMetadata = context.GetDependencyMetadata
+	if err := linter.Configure(context.GetEscapeConfig().GetCurrentProfile().LintRules); err != nil {
+		result.Error = fmt.Errorf("Invalid 'lint_rules' configuration: %s", err.Error())
+		return result
 	}
-	return nil
+	if err := linter.Configure(rules); err != nil {
+		result.Error = err
+		return result
+	}
+	issues := linter.Run()
+	if sarif {
+		result.MarshalableOutput = linter.ToSarif(issues)
+	} else {
+		result.MarshalableOutput = issues
+	}
+	counts := map[lint.Severity]int{}
+	for _, issue := range issues {
+		result.HumanOutput.AddLine(issue.String())
+		counts[issue.Severity]++
+	}
+	if len(issues) == 0 {
+		result.HumanOutput.AddLine("No issues found in %s.", planFile)
+	} else {
+		result.HumanOutput.AddLine("")
+		result.HumanOutput.AddLine("Found %d error(s), %d warning(s) and %d info message(s).", counts[lint.Error], counts[lint.Warning], counts[lint.Info])
+	}
+	if counts[lint.Error] > 0 {
+		result.ExitError = fmt.Errorf("Found %d error(s) in %s", counts[lint.Error], planFile)
+	}
+	return result
+}
+
+type RenderedTemplate struct {
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Content string `json:"content"`
+	Mode    string `json:"mode,omitempty"`
+	Changed bool   `json:"changed"`
 }
 
-func (p PlanController) Minify(context *model.Context, outputLocation string) error {
-	yaml := context.GetEscapePlan().ToMinifiedYaml()
-	fmt.Print(string(yaml))
-	if outputLocation != "" {
-		return ioutil.WriteFile(outputLocation, yaml, 0644)
+type RenderOutput struct {
+	Templates []*RenderedTemplate `json:"templates"`
+}
+
+// Render renders the templates of the stage using the script environment
+// that is stored in the deployment state, without running any steps or
+// writing any files. If diff is set the differences with the files on disk
+// are shown instead of the rendered output. If check is set the result has
+// an ExitError when any of the files is out of date.
+func (p PlanController) Render(context *model.Context, stage, file string, diff, check bool) *ControllerResult {
+	result := NewControllerResult()
+	if stage != state.BuildStage && stage != state.DeployStage {
+		result.Error = fmt.Errorf("Unknown stage '%s'. Expecting '%s' or '%s'", stage, state.BuildStage, state.DeployStage)
+		return result
 	}
-	return nil
+	deplName := context.GetRootDeploymentName()
+	envName := context.GetEnvironmentState().Name
+	depl, err := context.GetEnvironmentState().LookupDeploymentState(deplName)
+	if err == nil && (depl.Stages[stage] == nil || depl.Stages[stage].Version == "") {
+		err = fmt.Errorf("The %s stage of deployment '%s' has not been run yet", stage, deplName)
+	}
+	if err != nil {
+		result.Error = fmt.Errorf("%s. Use 'escape run %s' to create the state for environment '%s' first.", strings.TrimSuffix(err.Error(), "."), stage, envName)
+		return result
+	}
+	runnerContext, err := runners.NewRunnerContext(context)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	files, err := runners.RenderTemplates(runnerContext, stage, file)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	output := &RenderOutput{Templates: []*RenderedTemplate{}}
+	result.MarshalableOutput = output
+	if len(files) == 0 {
+		result.HumanOutput.AddLine("There are no templates to render in the %s stage.", stage)
+		return result
+	}
+	changedFiles := []string{}
+	for _, f := range files {
+		changed, err := f.HasChanged()
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		tpl := &RenderedTemplate{
+			Source:  f.Source,
+			Target:  f.Target,
+			Content: string(f.Content),
+			Changed: changed,
+		}
+		if f.Mode != 0 {
+			tpl.Mode = fmt.Sprintf("%04o", f.Mode)
+		}
+		output.Templates = append(output.Templates, tpl)
+		if changed {
+			changedFiles = append(changedFiles, f.Target)
+		}
+		if diff || check {
+			addRenderedDiff(result.HumanOutput, f, changed)
+		} else if len(files) == 1 {
+			result.HumanOutput.AddLine(strings.TrimSuffix(tpl.Content, "\n"))
+		} else {
+			if len(output.Templates) > 1 {
+				result.HumanOutput.AddLine("")
+			}
+			result.HumanOutput.AddLine("==> %s <==", f.Target)
+			result.HumanOutput.AddLine(strings.TrimSuffix(tpl.Content, "\n"))
+		}
+	}
+	if check && len(changedFiles) > 0 {
+		result.ExitError = fmt.Errorf("Rendering the templates would change %s", strings.Join(changedFiles, ", "))
+	}
+	return result
+}
+
+func addRenderedDiff(output *HumanOutput, f *templates.RenderedFile, changed bool) {
+	if !changed {
+		output.AddLine("%s is up to date.", f.Target)
+		return
+	}
+	fromName := f.Target
+	current, err := ioutil.ReadFile(f.Target)
+	if os.IsNotExist(err) {
+		fromName = "/dev/null"
+	} else if info, err := os.Stat(f.Target); err == nil && f.Mode != 0 && info.Mode().Perm() != f.Mode {
+		output.AddLine("The mode of %s would change from %04o to %04o.", f.Target, info.Mode().Perm(), f.Mode)
+	}
+	if diff := util.UnifiedDiff(fromName, f.Target+" (rendered)", string(current), string(f.Content)); diff != "" {
+		output.AddLine(strings.TrimSuffix(diff, "\n"))
+	}
+}
+
+func (p PlanController) Format(context *model.Context, planFile string, check bool) *ControllerResult {
+	return p.formatPlan(planFile, check, "fmt", escape_plan.Format)
+}
+
+func (p PlanController) Minify(context *model.Context, planFile string, check bool) *ControllerResult {
+	return p.formatPlan(planFile, check, "minify", escape_plan.Minify)
+}
+
+func (p PlanController) formatPlan(planFile string, check bool, command string, format func([]byte) ([]byte, error)) *ControllerResult {
+	result := NewControllerResult()
+	content, err := ioutil.ReadFile(planFile)
+	if err != nil {
+		result.Error = fmt.Errorf("Couldn't read Escape plan '%s': %s", planFile, err.Error())
+		return result
+	}
+	formatted, err := format(content)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if check {
+		if bytes.Equal(content, formatted) {
+			result.HumanOutput.AddLine("%s is formatted.", planFile)
+			return result
+		}
+		diff := util.UnifiedDiff(planFile, planFile+" (formatted)", string(content), string(formatted))
+		result.HumanOutput.AddLine(strings.TrimSuffix(diff, "\n"))
+		result.ExitError = fmt.Errorf("%s is not formatted. Use 'escape plan %s' to format it.", planFile, command)
+		return result
+	}
+	st, err := os.Stat(planFile)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if err := ioutil.WriteFile(planFile, formatted, st.Mode()); err != nil {
+		result.Error = err
+		return result
+	}
+	result.HumanOutput.AddLine(strings.TrimSuffix(string(formatted), "\n"))
+	return result
 }
 
 func (p PlanController) Init(context *model.Context, build_id, output_file string, force, minify bool) error {
@@ -96,3 +327,16 @@ func (p PlanController) Get(context *model.Context, field string) error {
 	return nil
 
 }
+
+func (p PlanController) Schema(context *model.Context) *ControllerResult {
+	result := NewControllerResult()
+	schema := escape_plan.JsonSchema()
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.HumanOutput.AddLine(string(data))
+	result.MarshalableOutput = schema
+	return result
+}
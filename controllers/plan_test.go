@@ -0,0 +1,216 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ankyra/escape/model"
+	"github.com/ankyra/escape/model/escape_plan"
+	. "gopkg.in/check.v1"
+)
+
+const renderTestDir = "testdata_render"
+
+const renderPlan = `name: render
+version: 0.0.1
+inputs:
+- greeting
+templates:
+- file: testdata_render/hello.txt.tpl
+- file: testdata_render/mode.sh.tpl
+  mode: "0755"
+`
+
+const renderState = `{
+    "name": "project",
+    "environments": {
+        "dev": {
+            "deployments": {
+                "_/render": {
+                    "stages": {
+                        "deploy": {
+                            "version": "0.0.1",
+                            "calculated_inputs": {
+                                "greeting": "hello"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    }
+}`
+
+func newRenderContext(c *C) *model.Context {
+	os.RemoveAll(renderTestDir)
+	c.Assert(os.MkdirAll(renderTestDir, 0755), IsNil)
+	files := map[string]string{
+		"escape.yml":        renderPlan,
+		"escape_state.json": renderState,
+		"hello.txt.tpl":     "{{greeting}} world\n",
+		"mode.sh.tpl":       "echo {{greeting}}\n",
+		"hello.txt":         "hi world\n",
+	}
+	for file, content := range files {
+		c.Assert(ioutil.WriteFile(filepath.Join(renderTestDir, file), []byte(content), 0644), IsNil)
+	}
+	ctx := model.NewContext()
+	c.Assert(ctx.InitFromLocalEscapePlanAndState(filepath.Join(renderTestDir, "escape_state.json"), "dev", filepath.Join(renderTestDir, "escape.yml")), IsNil)
+	return ctx
+}
+
+func (s *suite) Test_Render(c *C) {
+	defer os.RemoveAll(renderTestDir)
+	ctx := newRenderContext(c)
+
+	result := PlanController{}.Render(ctx, "deploy", "", false, false)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.ExitError, IsNil)
+	c.Assert(result.HumanOutput.value, Equals, `==> testdata_render/hello.txt <==
+hello world
+
+==> testdata_render/mode.sh <==
+echo hello`)
+	output := result.MarshalableOutput.(*RenderOutput)
+	c.Assert(output.Templates, HasLen, 2)
+	c.Assert(output.Templates[0].Changed, Equals, true)
+	c.Assert(output.Templates[1].Mode, Equals, "0755")
+	_, err := os.Stat(filepath.Join(renderTestDir, "mode.sh"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	result = PlanController{}.Render(ctx, "deploy", "", false, true)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.ExitError, ErrorMatches, "Rendering the templates would change testdata_render/hello.txt, testdata_render/mode.sh")
+
+	result = PlanController{}.Render(ctx, "deploy", "testdata_render/hello.txt.tpl", true, false)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.HumanOutput.value, Equals, `--- testdata_render/hello.txt
++++ testdata_render/hello.txt (rendered)
+@@ -1 +1 @@
+-hi world
++hello world`)
+}
+
+func (s *suite) Test_Render_is_up_to_date(c *C) {
+	defer os.RemoveAll(renderTestDir)
+	ctx := newRenderContext(c)
+	c.Assert(ioutil.WriteFile(filepath.Join(renderTestDir, "hello.txt"), []byte("hello world\n"), 0644), IsNil)
+
+	result := PlanController{}.Render(ctx, "deploy", "testdata_render/hello.txt.tpl", false, true)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.HumanOutput.value, Equals, "testdata_render/hello.txt is up to date.")
+	c.Assert(result.ExitError, IsNil)
+}
+
+func (s *suite) Test_Render_fails_if_stage_has_not_been_run(c *C) {
+	defer os.RemoveAll(renderTestDir)
+	ctx := newRenderContext(c)
+
+	result := PlanController{}.Render(ctx, "build", "", false, false)
+	c.Assert(result.Error, ErrorMatches, "The build stage of deployment '_/render' has not been run yet. Use 'escape run build' to create the state for environment 'dev' first.")
+}
+
+func (s *suite) Test_Render_fails_if_template_not_found(c *C) {
+	defer os.RemoveAll(renderTestDir)
+	ctx := newRenderContext(c)
+
+	result := PlanController{}.Render(ctx, "deploy", "unknown.tpl", false, false)
+	c.Assert(result.Error, ErrorMatches, "Template 'unknown.tpl' not found in the deploy stage")
+}
+
+func (s *suite) Test_Lint(c *C) {
+	defer os.RemoveAll(renderTestDir)
+	ctx := newRenderContext(c)
+	planFile := filepath.Join(renderTestDir, "escape.yml")
+
+	result := PlanController{}.Lint(ctx, planFile, map[string]string{}, false)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.ExitError, IsNil)
+	c.Assert(result.HumanOutput.value, Equals, `testdata_render/escape.yml:4: info: Input 'greeting' doesn't have a description [missing-description]
+
+Found 0 error(s), 0 warning(s) and 1 info message(s).`)
+
+	result = PlanController{}.Lint(ctx, planFile, map[string]string{"missing-description": "error"}, false)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.ExitError, ErrorMatches, "Found 1 error\\(s\\) in testdata_render/escape.yml")
+
+	result = PlanController{}.Lint(ctx, planFile, map[string]string{"missing-description": "off"}, false)
+	c.Assert(result.ExitError, IsNil)
+	c.Assert(result.HumanOutput.value, Equals, "No issues found in testdata_render/escape.yml.")
+}
+
+func (s *suite) Test_Lint_uses_configured_rules(c *C) {
+	defer os.RemoveAll(renderTestDir)
+	ctx := newRenderContext(c)
+	ctx.GetEscapeConfig().GetCurrentProfile().LintRules = map[string]string{"missing-description": "unknown"}
+
+	result := PlanController{}.Lint(ctx, filepath.Join(renderTestDir, "escape.yml"), map[string]string{}, false)
+	c.Assert(result.Error, ErrorMatches, "Invalid 'lint_rules' configuration: Invalid severity 'unknown'.*")
+}
+
+func (s *suite) Test_Schema(c *C) {
+	result := PlanController{}.Schema(model.NewContext())
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.MarshalableOutput, DeepEquals, escape_plan.JsonSchema())
+	c.Assert(result.HumanOutput.value, Matches, `(?s)\{\n  "\$schema": "http://json-schema.org/draft-07/schema#",.*`)
+}
+
+func (s *suite) Test_Format(c *C) {
+	os.MkdirAll(renderTestDir, 0755)
+	defer os.RemoveAll(renderTestDir)
+	planFile := filepath.Join(renderTestDir, "escape.yml")
+	c.Assert(ioutil.WriteFile(planFile, []byte("version: 0.0.1 # first release\nname: test\n"), 0644), IsNil)
+
+	result := PlanController{}.Minify(model.NewContext(), planFile, true)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.ExitError, ErrorMatches, "testdata_render/escape.yml is not formatted. Use 'escape plan minify' to format it.")
+	c.Assert(result.HumanOutput.value, Equals, `--- testdata_render/escape.yml
++++ testdata_render/escape.yml (formatted)
+@@ -1,2 +1,2 @@
+-version: 0.0.1 # first release
+ name: test
++version: 0.0.1 # first release`)
+	content, err := ioutil.ReadFile(planFile)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "version: 0.0.1 # first release\nname: test\n")
+
+	result = PlanController{}.Minify(model.NewContext(), planFile, false)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.HumanOutput.value, Equals, "name: test\nversion: 0.0.1 # first release")
+	content, err = ioutil.ReadFile(planFile)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "name: test\nversion: 0.0.1 # first release\n")
+
+	result = PlanController{}.Minify(model.NewContext(), planFile, true)
+	c.Assert(result.ExitError, IsNil)
+	c.Assert(result.HumanOutput.value, Equals, "testdata_render/escape.yml is formatted.")
+
+	result = PlanController{}.Format(model.NewContext(), planFile, true)
+	c.Assert(result.ExitError, ErrorMatches, "testdata_render/escape.yml is not formatted. Use 'escape plan fmt' to format it.")
+
+	result = PlanController{}.Format(model.NewContext(), planFile, false)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.HumanOutput.value, Matches, "(?s)name: test\n\nversion: 0.0.1 # first release\n\ndescription: \"\"\n\n.*")
+}
+
+func (s *suite) Test_Format_fails_if_plan_doesnt_exist(c *C) {
+	result := PlanController{}.Format(model.NewContext(), "testdata_render/doesnt_exist.yml", false)
+	c.Assert(result.Error, ErrorMatches, "Couldn't read Escape plan 'testdata_render/doesnt_exist.yml': .*")
+}
@@ -9,6 +9,10 @@ type ControllerResult struct {
 	HumanOutput       *HumanOutput
 	MarshalableOutput interface{}
 	Error             error
+
+	// Returned by Print after the output has been printed, e.g. when a
+	// check fails and the user should see why.
+	ExitError error
 }
 
 func NewControllerResult() *ControllerResult {
@@ -29,7 +33,7 @@ func (r ControllerResult) Print(jsonFlag bool) error {
 		fmt.Println(r.HumanOutput.value)
 	}
 
-	return nil
+	return r.ExitError
 }
 
 type HumanOutput struct {
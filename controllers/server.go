@@ -0,0 +1,52 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ankyra/escape/model"
+	"github.com/ankyra/escape/model/server"
+)
+
+type ServerController struct{}
+
+func (ServerController) Serve(context *model.Context, dataDir, listen, username, password, tlsCert, tlsKey string) error {
+	if password != "" && username == "" {
+		return fmt.Errorf("Missing username. A username is required when a password is set.")
+	}
+	if (tlsCert == "") != (tlsKey == "") {
+		return fmt.Errorf("Both a TLS certificate and a TLS key are required to enable TLS.")
+	}
+	if err := server.CheckListenAddress(listen, password != "", tlsCert != ""); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("Could not create data directory '%s': %s", dataDir, err.Error())
+	}
+	context.Log("server.start", map[string]string{
+		"address":  listen,
+		"data_dir": dataDir,
+	})
+	handler := server.NewServer(dataDir, username, password)
+	if tlsCert != "" {
+		return http.ListenAndServeTLS(listen, tlsCert, tlsKey, handler)
+	}
+	return http.ListenAndServe(listen, handler)
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/ankyra/escape-core/variables"
 	"github.com/ankyra/escape/model"
 )
 
@@ -104,3 +105,67 @@ func (p StateController) CreateState(context *model.Context, stage string, extra
 	fmt.Println(deplState.ToJson())
 	return deplState.Save()
 }
+
+// MigrateInputs rewrites the user inputs of the deployment for the renamed
+// and deprecated variables in the release metadata. Values stored under an
+// alias are moved to the new ID. Values of deprecated variables are copied
+// to the variables that replace them, and removed if the deprecated
+// variable has a default to fall back to.
+func (p StateController) MigrateInputs(context *model.Context, dryRun bool) *ControllerResult {
+	result := NewControllerResult()
+	metadata := context.GetReleaseMetadata()
+	deploymentName := context.GetRootDeploymentName()
+	deplState, err := context.GetEnvironmentState().LookupDeploymentState(deploymentName)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	migrated := []string{}
+	for _, stage := range []string{"build", "deploy"} {
+		st, found := deplState.Stages[stage]
+		if !found || st == nil {
+			continue
+		}
+		vars := metadata.GetInputs(stage)
+		inputs, migrations := variables.MigrateValues(vars, st.UserInputs)
+		if len(migrations) == 0 {
+			continue
+		}
+		for _, m := range migrations {
+			if m.Deprecated && getVariable(vars, m.From).HasDefault() {
+				delete(inputs, m.From)
+			}
+			migrated = append(migrated, fmt.Sprintf("%s: %s", stage, m.String()))
+		}
+		if !dryRun {
+			calculated, _ := variables.MigrateValues(vars, st.Inputs)
+			st.SetUserInputs(inputs)
+			st.SetInputs(calculated)
+		}
+	}
+	result.MarshalableOutput = migrated
+	if len(migrated) == 0 {
+		result.HumanOutput.AddLine("The inputs of deployment '%s' are up to date.", deploymentName)
+		return result
+	}
+	if dryRun {
+		result.HumanOutput.AddLine("Would migrate the inputs of deployment '%s':", deploymentName)
+	} else {
+		if err := deplState.Save(); err != nil {
+			result.Error = err
+			return result
+		}
+		result.HumanOutput.AddLine("Migrated the inputs of deployment '%s':", deploymentName)
+	}
+	result.HumanOutput.AddStringList(migrated)
+	return result
+}
+
+func getVariable(vars []*variables.Variable, id string) *variables.Variable {
+	for _, v := range vars {
+		if v.Id == id {
+			return v
+		}
+	}
+	return nil
+}
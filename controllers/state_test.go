@@ -0,0 +1,125 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ankyra/escape/model"
+	. "gopkg.in/check.v1"
+)
+
+const migrateInputsPlan = `name: migrate
+version: 0.0.1
+inputs:
+- id: new_name
+  aliases:
+  - old_name
+- id: deprecated
+  default: value
+  deprecated_by: replacement
+- replacement
+`
+
+const migrateInputsState = `{
+    "name": "project",
+    "environments": {
+        "dev": {
+            "deployments": {
+                "_/migrate": {
+                    "stages": {
+                        "deploy": {
+                            "inputs": {
+                                "old_name": "renamed value",
+                                "deprecated": "deprecated value"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    }
+}`
+
+func newMigrateInputsContext(c *C, dir, plan string) *model.Context {
+	c.Assert(os.MkdirAll(dir, 0755), IsNil)
+	planFile := filepath.Join(dir, "escape.yml")
+	stateFile := filepath.Join(dir, "escape_state.json")
+	c.Assert(ioutil.WriteFile(planFile, []byte(plan), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(stateFile, []byte(migrateInputsState), 0644), IsNil)
+	ctx := model.NewContext()
+	c.Assert(ctx.InitFromLocalEscapePlanAndState(stateFile, "dev", planFile), IsNil)
+	return ctx
+}
+
+func (s *suite) Test_MigrateInputs(c *C) {
+	dir := "testdata_migrate_inputs"
+	defer os.RemoveAll(dir)
+	ctx := newMigrateInputsContext(c, dir, migrateInputsPlan)
+
+	result := StateController{}.MigrateInputs(ctx, true)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.MarshalableOutput, DeepEquals, []string{
+		"deploy: Variable 'old_name' has been renamed to 'new_name'",
+		"deploy: Variable 'deprecated' is deprecated in favour of 'replacement'",
+	})
+	depl, err := ctx.GetEnvironmentState().LookupDeploymentState("_/migrate")
+	c.Assert(err, IsNil)
+	c.Assert(depl.GetUserInputs("deploy")["old_name"], Equals, "renamed value")
+
+	result = StateController{}.MigrateInputs(ctx, false)
+	c.Assert(result.Error, IsNil)
+	c.Assert(depl.GetUserInputs("deploy"), DeepEquals, map[string]interface{}{
+		"new_name":    "renamed value",
+		"replacement": "deprecated value",
+	})
+
+	ctx = model.NewContext()
+	c.Assert(ctx.InitFromLocalEscapePlanAndState(filepath.Join(dir, "escape_state.json"), "dev", filepath.Join(dir, "escape.yml")), IsNil)
+	result = StateController{}.MigrateInputs(ctx, false)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.MarshalableOutput, DeepEquals, []string{})
+	c.Assert(result.HumanOutput.value, Equals, "The inputs of deployment '_/migrate' are up to date.")
+}
+
+func (s *suite) Test_MigrateInputs_keeps_deprecated_values_without_default(c *C) {
+	dir := "testdata_migrate_inputs"
+	defer os.RemoveAll(dir)
+	plan := `name: migrate
+version: 0.0.1
+inputs:
+- id: deprecated
+  deprecated_by: replacement
+- replacement
+`
+	ctx := newMigrateInputsContext(c, dir, plan)
+	result := StateController{}.MigrateInputs(ctx, false)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.MarshalableOutput, DeepEquals, []string{
+		"deploy: Variable 'deprecated' is deprecated in favour of 'replacement'",
+	})
+	depl, err := ctx.GetEnvironmentState().LookupDeploymentState("_/migrate")
+	c.Assert(err, IsNil)
+	c.Assert(depl.GetUserInputs("deploy")["deprecated"], Equals, "deprecated value")
+	c.Assert(depl.GetUserInputs("deploy")["replacement"], Equals, "deprecated value")
+
+	result = StateController{}.MigrateInputs(ctx, false)
+	c.Assert(result.Error, IsNil)
+	c.Assert(result.MarshalableOutput, DeepEquals, []string{})
+	c.Assert(result.HumanOutput.value, Equals, "The inputs of deployment '_/migrate' are up to date.")
+}
@@ -18,7 +18,10 @@ package compiler
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ankyra/escape/util"
 )
@@ -36,10 +39,33 @@ func compileBasicFields(ctx *CompilerContext) error {
 		return fmt.Errorf("Missing build name. Add a 'name' field to your Escape plan")
 	}
 	ctx.Metadata.BuiltWithEscapeVersion = util.EscapeVersion
+	buildDate, err := getBuildDate()
+	if err != nil {
+		return err
+	}
+	ctx.Metadata.BuildDate = buildDate
 	ctx.Metadata.Description = strings.TrimSpace(ctx.Plan.Description)
 	ctx.Metadata.SetProvides(ctx.Plan.Provides)
+	for _, provides := range ctx.Plan.Provides {
+		ctx.ExtensionReport.AddPlanSource("provides", provides)
+	}
 	ctx.Metadata.Project = project
 	ctx.Metadata.Downloads = ctx.Plan.Downloads
 	ctx.Metadata.License = ctx.Plan.License
 	return nil
 }
+
+// getBuildDate returns the current time, unless SOURCE_DATE_EPOCH is set, so
+// that builds of the same commit can produce the same release metadata. See
+// https://reproducible-builds.org/specs/source-date-epoch/
+func getBuildDate() (string, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Now().UTC().Format(time.RFC3339), nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("Invalid SOURCE_DATE_EPOCH '%s'. Expecting a UNIX timestamp", epoch)
+	}
+	return time.Unix(seconds, 0).UTC().Format(time.RFC3339), nil
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package compiler
 
 import (
+	"os"
+
 	"github.com/ankyra/escape/model/escape_plan"
 	"github.com/ankyra/escape/util"
 	. "gopkg.in/check.v1"
@@ -74,3 +76,16 @@ func (s *suite) Test_Compile_Basics_parse_project_fails(c *C) {
 		c.Assert(compileBasicFields(ctx), Not(IsNil))
 	}
 }
+
+func (s *suite) Test_Compile_Basics_uses_SOURCE_DATE_EPOCH(c *C) {
+	os.Setenv("SOURCE_DATE_EPOCH", "1514764800")
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+	plan := escape_plan.NewEscapePlan()
+	plan.Name = "testor"
+	ctx := NewCompilerContext(plan, nil)
+	c.Assert(compileBasicFields(ctx), IsNil)
+	c.Assert(ctx.Metadata.BuildDate, Equals, "2018-01-01T00:00:00Z")
+
+	os.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	c.Assert(compileBasicFields(ctx), ErrorMatches, "Invalid SOURCE_DATE_EPOCH 'yesterday'. Expecting a UNIX timestamp")
+}
@@ -31,6 +31,18 @@ func Compile(plan *escape_plan.EscapePlan,
 	releaseQuery func(*core.DependencyConfig) (*core.ReleaseMetadata, error),
 	logger api.Logger) (*core.ReleaseMetadata, error) {
 
+	metadata, _, err := CompileWithExtensionReport(plan, reg, depFetcher, releaseQuery, logger)
+	return metadata, err
+}
+
+// CompileWithExtensionReport compiles the Escape plan and also returns a
+// report on where the merged fields came from.
+func CompileWithExtensionReport(plan *escape_plan.EscapePlan,
+	reg types.Inventory,
+	depFetcher func(*core.DependencyConfig) (*core.ReleaseMetadata, error),
+	releaseQuery func(*core.DependencyConfig) (*core.ReleaseMetadata, error),
+	logger api.Logger) (*core.ReleaseMetadata, *ExtensionReport, error) {
+
 	ctx := NewCompilerContextWithLogger(plan, reg, logger)
 	ctx.DependencyFetcher = depFetcher
 	ctx.ReleaseQuery = releaseQuery
@@ -43,6 +55,7 @@ func Compile(plan *escape_plan.EscapePlan,
 		compileVersion,
 		compileMetadata,
 		compileScripts,
+		compileHooks,
 		compileInputs,
 		compileOutputs,
 		compileErrands,
@@ -52,8 +65,9 @@ func Compile(plan *escape_plan.EscapePlan,
 	}
 	for _, step := range compilerSteps {
 		if err := step(ctx); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
-	return ctx.Metadata, ctx.Metadata.Validate()
+	ctx.ExtensionReport.SortSources()
+	return ctx.Metadata, ctx.ExtensionReport, ctx.Metadata.Validate()
 }
@@ -29,6 +29,7 @@ func compileConsumers(ctx *CompilerContext) error {
 			return fmt.Errorf("%s in 'consumes' field", err)
 		}
 		ctx.Metadata.AddConsumes(c)
+		ctx.ExtensionReport.AddPlanSource("consumes", c.VariableName)
 	}
 	for _, consumer := range ctx.Plan.BuildConsumes {
 		c, err := core.NewConsumerConfigFromInterface(consumer)
@@ -37,6 +38,7 @@ func compileConsumers(ctx *CompilerContext) error {
 		}
 		c.Scopes = []string{"build"}
 		ctx.Metadata.AddConsumes(c)
+		ctx.ExtensionReport.AddPlanSource("consumes", c.VariableName)
 	}
 	for _, consumer := range ctx.Plan.DeployConsumes {
 		c, err := core.NewConsumerConfigFromInterface(consumer)
@@ -45,6 +47,7 @@ func compileConsumers(ctx *CompilerContext) error {
 		}
 		c.Scopes = []string{"deploy"}
 		ctx.Metadata.AddConsumes(c)
+		ctx.ExtensionReport.AddPlanSource("consumes", c.VariableName)
 	}
 	return nil
 }
@@ -40,14 +40,16 @@ type CompilerContext struct {
 	ReleaseQuery      func(*core.DependencyConfig) (*core.ReleaseMetadata, error)
 	Inventory         types.Inventory
 	Logger            api.Logger
+	ExtensionReport   *ExtensionReport
 }
 
 func NewCompilerContext(plan *escape_plan.EscapePlan, inventory types.Inventory) *CompilerContext {
 	return &CompilerContext{
-		Metadata:    core.NewEmptyReleaseMetadata(),
-		Plan:        plan,
-		VariableCtx: map[string]*core.ReleaseMetadata{},
-		Inventory:   inventory,
+		Metadata:        core.NewEmptyReleaseMetadata(),
+		Plan:            plan,
+		VariableCtx:     map[string]*core.ReleaseMetadata{},
+		Inventory:       inventory,
+		ExtensionReport: NewExtensionReport(),
 	}
 }
 
@@ -32,6 +32,12 @@ func compileErrands(ctx *CompilerContext) error {
 			}
 		}
 		ctx.Metadata.Errands[name] = newErrand
+		ctx.ExtensionReport.AddPlanSource("errands", name)
+	}
+	for name := range ctx.Metadata.Errands {
+		if _, err := core.GetErrandChain(ctx.Metadata.Errands, name); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -0,0 +1,47 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compiler
+
+import (
+	"github.com/ankyra/escape/model/escape_plan"
+	. "gopkg.in/check.v1"
+)
+
+func (s *suite) Test_Compile_Errands_requires(c *C) {
+	plan := escape_plan.NewEscapePlan()
+	plan.Errands = map[string]interface{}{
+		"snapshot": map[interface{}]interface{}{
+			"script":  "testdata/script.sh",
+			"outputs": []interface{}{"snapshot_id"},
+		},
+		"export": map[interface{}]interface{}{
+			"script":   "testdata/script.sh",
+			"requires": []interface{}{"snapshot"},
+		},
+	}
+	ctx := NewCompilerContext(plan, nil)
+	c.Assert(compileErrands(ctx), IsNil)
+	c.Assert(ctx.Metadata.Errands["export"].Requires, DeepEquals, []string{"snapshot"})
+	c.Assert(ctx.Metadata.Errands["snapshot"].Outputs, HasLen, 1)
+
+	plan.Errands["export"] = map[interface{}]interface{}{
+		"script":   "testdata/script.sh",
+		"requires": []interface{}{"snapshots"},
+	}
+	ctx = NewCompilerContext(plan, nil)
+	c.Assert(compileErrands(ctx), ErrorMatches, "Errand 'export' requires unknown errand 'snapshots'")
+}
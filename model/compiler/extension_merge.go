@@ -0,0 +1,230 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The rules that can be used in the `extension_merge` field of the Escape
+// plan to decide what happens when two extensions define the same input,
+// errand, metadata key, etc.
+const (
+	// The definition of the last extension is used.
+	MergeOverride = "override"
+	// The definition of the first extension is kept; new items are added.
+	MergeAppend = "append"
+	// Defining the same item in two extensions is a compile error.
+	MergeError = "error"
+)
+
+// PlanSource is the source of the items that are defined in the Escape plan
+// itself. The Escape plan always takes precedence over its extensions.
+const PlanSource = "escape plan"
+
+// The fields that can be merged, and the rules that are used when
+// `extension_merge` doesn't configure them.
+var defaultMergeRules = map[string]string{
+	"consumes":  MergeAppend,
+	"provides":  MergeAppend,
+	"inputs":    MergeOverride,
+	"outputs":   MergeAppend,
+	"templates": MergeAppend,
+	"metadata":  MergeOverride,
+	"errands":   MergeAppend,
+	"stages":    MergeOverride,
+}
+
+// MergeFields returns the fields that extension merge rules can be
+// configured for.
+func MergeFields() []string {
+	result := []string{}
+	for field := range defaultMergeRules {
+		result = append(result, field)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// An ExtensionReport records where the items of the merged fields came from
+// while compiling an Escape plan, and the conflicts between extensions.
+type ExtensionReport struct {
+	Rules     []*MergeRule         `json:"rules"`
+	Sources   []*FieldSource       `json:"sources"`
+	Conflicts []*ExtensionConflict `json:"conflicts"`
+	rules     map[string]*MergeRule
+	sources   map[string]*FieldSource
+}
+
+type MergeRule struct {
+	Field    string `json:"field"`
+	Rule     string `json:"rule"`
+	Explicit bool   `json:"explicit"`
+}
+
+// A FieldSource lists the extensions (and the Escape plan) that define an
+// item, in the order in which they were merged. Effective is the source
+// whose definition ended up in the release metadata.
+type FieldSource struct {
+	Field     string   `json:"field"`
+	Key       string   `json:"key"`
+	Sources   []string `json:"sources"`
+	Effective string   `json:"effective"`
+}
+
+// An ExtensionConflict is an item that's defined by more than one
+// extension. Conflicts under a rule that wasn't configured explicitly are
+// reported as warnings.
+type ExtensionConflict struct {
+	Field   string   `json:"field"`
+	Key     string   `json:"key"`
+	Sources []string `json:"sources"`
+	Rule    string   `json:"rule"`
+	Warning bool     `json:"warning"`
+}
+
+func (c *ExtensionConflict) String() string {
+	action := "using the definition from " + c.Sources[len(c.Sources)-1]
+	if c.Rule == MergeAppend {
+		action = "keeping the definition from " + c.Sources[0]
+	}
+	msg := fmt.Sprintf("%s '%s' is defined in %s; %s", c.Field, c.Key, strings.Join(c.Sources, " and "), action)
+	if c.Warning {
+		msg += fmt.Sprintf(" (set 'extension_merge' for '%s' to make this explicit)", c.Field)
+	}
+	return msg
+}
+
+func NewExtensionReport() *ExtensionReport {
+	report := &ExtensionReport{
+		Rules:     []*MergeRule{},
+		Sources:   []*FieldSource{},
+		Conflicts: []*ExtensionConflict{},
+		rules:     map[string]*MergeRule{},
+		sources:   map[string]*FieldSource{},
+	}
+	for _, field := range MergeFields() {
+		rule := &MergeRule{Field: field, Rule: defaultMergeRules[field]}
+		report.Rules = append(report.Rules, rule)
+		report.rules[field] = rule
+	}
+	return report
+}
+
+// SetRules configures the rules from the `extension_merge` field.
+func (r *ExtensionReport) SetRules(rules map[string]string) error {
+	for field, rule := range rules {
+		mergeRule, ok := r.rules[field]
+		if !ok {
+			return fmt.Errorf("Unknown field '%s' in extension_merge. Expecting one of: %s", field, strings.Join(MergeFields(), ", "))
+		}
+		if rule != MergeOverride && rule != MergeAppend && rule != MergeError {
+			return fmt.Errorf("Invalid extension_merge rule '%s' for field '%s'. Expecting one of: override, append, error", rule, field)
+		}
+		mergeRule.Rule = rule
+		mergeRule.Explicit = true
+	}
+	return nil
+}
+
+func (r *ExtensionReport) GetRule(field string) string {
+	return r.rules[field].Rule
+}
+
+// AddExtensionSource records that an extension defines the item and returns
+// whether its definition should be merged into the release metadata. An
+// error is returned if another extension already defines the item and the
+// rule for the field is 'error'.
+func (r *ExtensionReport) AddExtensionSource(field, key, extension string) (bool, error) {
+	source := r.getSource(field, key)
+	previous := []string{}
+	inPlan := false
+	for _, s := range source.Sources {
+		if s == PlanSource {
+			inPlan = true
+		} else {
+			previous = append(previous, s)
+		}
+	}
+	// The Escape plan is listed last, because it's applied after the
+	// extensions.
+	source.Sources = append(previous, extension)
+	if inPlan {
+		source.Sources = append(source.Sources, PlanSource)
+	}
+	if len(previous) == 0 {
+		if !inPlan {
+			source.Effective = extension
+		}
+		return !inPlan, nil
+	}
+	rule := r.rules[field]
+	sources := append([]string{}, previous...)
+	sources = append(sources, extension)
+	if rule.Rule == MergeError {
+		return false, fmt.Errorf("Extension conflict: %s '%s' is defined in %s, but the extension_merge rule for '%s' is 'error'", field, key, strings.Join(sources, " and "), field)
+	}
+	r.Conflicts = append(r.Conflicts, &ExtensionConflict{
+		Field:   field,
+		Key:     key,
+		Sources: sources,
+		Rule:    rule.Rule,
+		Warning: !rule.Explicit,
+	})
+	if inPlan || rule.Rule != MergeOverride {
+		return false, nil
+	}
+	source.Effective = extension
+	return true, nil
+}
+
+// AddPlanSource records that the Escape plan defines the item. The plan's
+// items are recorded before the extensions are merged, and again when the
+// fields are compiled.
+func (r *ExtensionReport) AddPlanSource(field, key string) {
+	source := r.getSource(field, key)
+	source.Effective = PlanSource
+	for _, s := range source.Sources {
+		if s == PlanSource {
+			return
+		}
+	}
+	source.Sources = append(source.Sources, PlanSource)
+}
+
+func (r *ExtensionReport) getSource(field, key string) *FieldSource {
+	id := field + "\x00" + key
+	source, ok := r.sources[id]
+	if !ok {
+		source = &FieldSource{Field: field, Key: key, Sources: []string{}}
+		r.sources[id] = source
+		r.Sources = append(r.Sources, source)
+	}
+	return source
+}
+
+// SortSources orders the sources by field and key.
+func (r *ExtensionReport) SortSources() {
+	sort.SliceStable(r.Sources, func(i, j int) bool {
+		if r.Sources[i].Field != r.Sources[j].Field {
+			return r.Sources[i].Field < r.Sources[j].Field
+		}
+		return r.Sources[i].Key < r.Sources[j].Key
+	})
+}
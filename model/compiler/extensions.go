@@ -17,13 +17,20 @@ limitations under the License.
 package compiler
 
 import (
+	"sort"
 	"strings"
 
 	"github.com/ankyra/escape-core"
+	"github.com/ankyra/escape-core/templates"
+	"github.com/ankyra/escape-core/variables"
 	"github.com/ankyra/escape/model/paths"
 )
 
 func compileExtensions(ctx *CompilerContext) error {
+	if err := ctx.ExtensionReport.SetRules(ctx.Plan.ExtensionMerge); err != nil {
+		return err
+	}
+	addPlanSources(ctx)
 	for _, extend := range ctx.Plan.Extends {
 		depCfg := core.NewDependencyConfig(extend)
 		if err := depCfg.EnsureConfigIsParsed(); err != nil {
@@ -36,47 +43,8 @@ func compileExtensions(ctx *CompilerContext) error {
 		if err := doGlobPatterns(ctx, metadata.Generates); err != nil {
 			return err
 		}
-		for _, consume := range metadata.Consumes {
-			ctx.Metadata.AddConsumes(consume.Copy())
-		}
-		for _, provide := range metadata.GetProvides() {
-			ctx.Metadata.AddProvides(provide)
-		}
-		for _, input := range metadata.Inputs {
-			ctx.Metadata.AddInputVariable(input.Copy())
-		}
-		for _, output := range metadata.Outputs {
-			ctx.Metadata.AddOutputVariable(output.Copy())
-		}
-		for name, newErrand := range metadata.GetErrands() {
-			_, exists := ctx.Metadata.Errands[name]
-			if exists {
-				continue
-			}
-			newErrand.Script = extensionPath(metadata, newErrand.Script)
-			ctx.Metadata.Errands[name] = newErrand
-		}
-		for key, val := range metadata.Metadata {
-			ctx.Metadata.Metadata[key] = val
-		}
-		for _, tpl := range metadata.Templates {
-			tpl = tpl.Copy()
-			tpl.File = extensionPath(metadata, tpl.File)
-			tpl.Target = extensionPath(metadata, tpl.Target)
-			ctx.Metadata.Templates = append(ctx.Metadata.Templates, tpl)
-		}
-		for name, stage := range metadata.Stages {
-			if stage.IsEmpty() {
-				continue
-			}
-			if stage.RelativeScript != "" {
-				fields := strings.Fields(stage.RelativeScript)
-				script := extensionPath(metadata, fields[0])
-				newScript := []string{script}
-				newScript = append(newScript, fields[1:]...)
-				stage = core.NewExecStageForRelativeScript(strings.Join(newScript, " "))
-			}
-			ctx.Metadata.SetExecStage(name, stage.Copy())
+		if err := mergeExtension(ctx, metadata); err != nil {
+			return err
 		}
 		for _, d := range metadata.Depends {
 			found := false
@@ -101,12 +69,239 @@ func compileExtensions(ctx *CompilerContext) error {
 		ctx.VariableCtx[depCfg.VariableName] = metadata
 		ctx.Metadata.AddExtension(metadata.GetQualifiedReleaseId())
 	}
+	for _, conflict := range ctx.ExtensionReport.Conflicts {
+		if conflict.Warning && ctx.Logger != nil {
+			ctx.Logger.Log("compile.extension_conflict", map[string]string{
+				"conflict": conflict.String(),
+			})
+		}
+	}
 	return nil
 }
 
+// addPlanSources records the items that are defined in the Escape plan
+// before the extensions are merged, because the plan always takes
+// precedence. The fields are compiled after the extensions, which is where
+// invalid values are reported, so they're skipped here.
+func addPlanSources(ctx *CompilerContext) {
+	report := ctx.ExtensionReport
+	plan := ctx.Plan
+	for _, consumers := range [][]interface{}{plan.Consumes, plan.BuildConsumes, plan.DeployConsumes} {
+		for _, consumer := range consumers {
+			if c, err := core.NewConsumerConfigFromInterface(consumer); err == nil {
+				report.AddPlanSource("consumes", c.VariableName)
+			}
+		}
+	}
+	for _, provides := range plan.Provides {
+		report.AddPlanSource("provides", provides)
+	}
+	for _, inputs := range [][]interface{}{plan.Inputs, plan.BuildInputs, plan.DeployInputs} {
+		for _, input := range inputs {
+			if v, err := variables.NewVariableFromInterface(input); err == nil {
+				report.AddPlanSource("inputs", v.Id)
+			}
+		}
+	}
+	for _, outputs := range [][]interface{}{plan.Outputs, plan.BuildOutputs, plan.DeployOutputs} {
+		for _, output := range outputs {
+			if v, err := variables.NewVariableFromInterface(output); err == nil {
+				report.AddPlanSource("outputs", v.Id)
+			}
+		}
+	}
+	for _, tpls := range [][]interface{}{plan.Templates, plan.BuildTemplates, plan.DeployTemplates} {
+		for _, tpl := range tpls {
+			if template, err := templates.NewTemplateFromInterface(tpl); err == nil {
+				report.AddPlanSource("templates", templateKey(template))
+			}
+		}
+	}
+	for key := range plan.Metadata {
+		report.AddPlanSource("metadata", key)
+	}
+	for name := range plan.Errands {
+		report.AddPlanSource("errands", name)
+	}
+	for _, script := range scriptFields(plan) {
+		if stage, err := core.NewExecStageFromInterface(script[1]); err == nil && stage != nil {
+			report.AddPlanSource("stages", script[0].(string))
+		}
+	}
+}
+
+// mergeExtension merges the fields of the extension into the release
+// metadata, using the rules from the `extension_merge` field.
+func mergeExtension(ctx *CompilerContext, metadata *core.ReleaseMetadata) error {
+	report := ctx.ExtensionReport
+	source := metadata.GetQualifiedReleaseId()
+	for _, consume := range metadata.Consumes {
+		merge, err := report.AddExtensionSource("consumes", consume.VariableName, source)
+		if err != nil {
+			return err
+		}
+		if merge {
+			setConsumes(ctx.Metadata, consume.Copy())
+		}
+	}
+	for _, provide := range metadata.GetProvides() {
+		if _, err := report.AddExtensionSource("provides", provide, source); err != nil {
+			return err
+		}
+		ctx.Metadata.AddProvides(provide)
+	}
+	for _, input := range metadata.Inputs {
+		merge, err := report.AddExtensionSource("inputs", input.Id, source)
+		if err != nil {
+			return err
+		}
+		if merge {
+			ctx.Metadata.AddInputVariable(input.Copy())
+		}
+	}
+	for _, output := range metadata.Outputs {
+		merge, err := report.AddExtensionSource("outputs", output.Id, source)
+		if err != nil {
+			return err
+		}
+		if merge {
+			setOutputVariable(ctx.Metadata, output.Copy())
+		}
+	}
+	for _, name := range sortedKeys(metadata.GetErrands()) {
+		newErrand := metadata.GetErrands()[name]
+		merge, err := report.AddExtensionSource("errands", name, source)
+		if err != nil {
+			return err
+		}
+		if merge {
+			newErrand.Script = extensionPath(metadata, newErrand.Script)
+			ctx.Metadata.Errands[name] = newErrand
+		}
+	}
+	for _, key := range sortedKeys(metadata.Metadata) {
+		merge, err := report.AddExtensionSource("metadata", key, source)
+		if err != nil {
+			return err
+		}
+		if merge {
+			ctx.Metadata.Metadata[key] = metadata.Metadata[key]
+		}
+	}
+	for _, tpl := range metadata.Templates {
+		tpl = tpl.Copy()
+		tpl.File = extensionPath(metadata, tpl.File)
+		tpl.Target = extensionPath(metadata, tpl.Target)
+		merge, err := report.AddExtensionSource("templates", templateKey(tpl), source)
+		if err != nil {
+			return err
+		}
+		if merge {
+			setTemplate(ctx.Metadata, tpl)
+		}
+	}
+	for _, name := range sortedKeys(metadata.Stages) {
+		stage := metadata.Stages[name]
+		if stage.IsEmpty() {
+			continue
+		}
+		merge, err := report.AddExtensionSource("stages", name, source)
+		if err != nil {
+			return err
+		}
+		if merge {
+			ctx.Metadata.SetExecStage(name, extensionExecStage(metadata, stage))
+		}
+	}
+	for _, point := range sortedKeys(metadata.Hooks) {
+		for _, hook := range metadata.Hooks[point] {
+			hook = hook.Copy()
+			hook.Run = extensionExecStage(metadata, hook.Run)
+			if hook.Extension == "" {
+				hook.Extension = source
+			}
+			ctx.Metadata.AddHook(point, hook)
+		}
+	}
+	return nil
+}
+
+func extensionExecStage(extension *core.ReleaseMetadata, stage *core.ExecStage) *core.ExecStage {
+	if stage.RelativeScript == "" {
+		return stage.Copy()
+	}
+	fields := strings.Fields(stage.RelativeScript)
+	script := extensionPath(extension, fields[0])
+	newScript := []string{script}
+	newScript = append(newScript, fields[1:]...)
+	return core.NewExecStageForRelativeScript(strings.Join(newScript, " "))
+}
+
+func setConsumes(m *core.ReleaseMetadata, c *core.ConsumerConfig) {
+	for i, consumer := range m.Consumes {
+		if consumer.VariableName == c.VariableName {
+			m.Consumes[i] = c
+			return
+		}
+	}
+	m.AddConsumes(c)
+}
+
+func setOutputVariable(m *core.ReleaseMetadata, v *variables.Variable) {
+	for i, output := range m.Outputs {
+		if output.Id == v.Id {
+			m.Outputs[i] = v
+			return
+		}
+	}
+	m.AddOutputVariable(v)
+}
+
+func setTemplate(m *core.ReleaseMetadata, tpl *templates.Template) {
+	key := templateKey(tpl)
+	for i, existing := range m.Templates {
+		if templateKey(existing) == key {
+			m.Templates[i] = tpl
+			return
+		}
+	}
+	m.Templates = append(m.Templates, tpl)
+}
+
+func templateKey(tpl *templates.Template) string {
+	if tpl.Target != "" {
+		return tpl.Target
+	}
+	return tpl.File
+}
+
 func extensionPath(extension *core.ReleaseMetadata, path string) string {
 	if path == "" {
 		return ""
 	}
 	return paths.NewPath().ExtensionPath(extension, path)
 }
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch m := m.(type) {
+	case map[string]*core.Errand:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]string:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*core.ExecStage:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string][]*core.Hook:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
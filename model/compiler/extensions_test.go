@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape-core/variables"
 	"github.com/ankyra/escape/model/escape_plan"
 	. "gopkg.in/check.v1"
 )
@@ -108,3 +109,126 @@ func (s *suite) Test_Compile_Extensions_fails_if_version_cant_be_resolved(c *C)
 	}
 	c.Assert(compileExtensions(ctx), Not(IsNil))
 }
+
+func newConflictingExtensionsContext(rules map[string]string) *CompilerContext {
+	plan := escape_plan.NewEscapePlan()
+	plan.Extends = []string{"ext1-v1.0", "ext2-v1.0"}
+	plan.ExtensionMerge = rules
+	ctx := NewCompilerContext(plan, nil)
+	ctx.DependencyFetcher = func(dep *core.DependencyConfig) (*core.ReleaseMetadata, error) {
+		if dep.ReleaseId != "_/ext1-v1.0" && dep.ReleaseId != "_/ext2-v1.0" {
+			return nil, fmt.Errorf("Resolve error %s", dep.ReleaseId)
+		}
+		m := core.NewReleaseMetadata(dep.ReleaseId[2:6], "1.0")
+		input, _ := variables.NewVariableFromString("input", "string")
+		input.Default = "default from " + m.Name
+		m.AddInputVariable(input)
+		output, _ := variables.NewVariableFromString("output", "string")
+		output.Description = "output from " + m.Name
+		m.AddOutputVariable(output)
+		m.Metadata["key"] = "value from " + m.Name
+		m.Errands["errand"] = &core.Errand{Name: "errand", Description: "errand from " + m.Name}
+		return m, nil
+	}
+	return ctx
+}
+
+func (s *suite) Test_Compile_Extensions_uses_legacy_merge_rules_by_default(c *C) {
+	ctx := newConflictingExtensionsContext(nil)
+	c.Assert(compileExtensions(ctx), IsNil)
+	c.Assert(ctx.Metadata.GetInputs("deploy")[0].Default, Equals, "default from ext2")
+	c.Assert(ctx.Metadata.GetOutputs("deploy")[0].Description, Equals, "output from ext1")
+	c.Assert(ctx.Metadata.Metadata["key"], Equals, "value from ext2")
+	c.Assert(ctx.Metadata.Errands["errand"].Description, Equals, "errand from ext1")
+
+	conflicts := ctx.ExtensionReport.Conflicts
+	c.Assert(conflicts, HasLen, 4)
+	for _, conflict := range conflicts {
+		c.Assert(conflict.Warning, Equals, true)
+		c.Assert(conflict.Sources, DeepEquals, []string{"_/ext1-v1.0", "_/ext2-v1.0"})
+	}
+	c.Assert(conflicts[0].String(), Equals, "inputs 'input' is defined in _/ext1-v1.0 and _/ext2-v1.0; using the definition from _/ext2-v1.0 (set 'extension_merge' for 'inputs' to make this explicit)")
+	c.Assert(conflicts[1].String(), Equals, "outputs 'output' is defined in _/ext1-v1.0 and _/ext2-v1.0; keeping the definition from _/ext1-v1.0 (set 'extension_merge' for 'outputs' to make this explicit)")
+}
+
+func (s *suite) Test_Compile_Extensions_explicit_merge_rules(c *C) {
+	ctx := newConflictingExtensionsContext(map[string]string{
+		"inputs":   "append",
+		"outputs":  "override",
+		"metadata": "append",
+		"errands":  "override",
+	})
+	c.Assert(compileExtensions(ctx), IsNil)
+	c.Assert(ctx.Metadata.GetInputs("deploy")[0].Default, Equals, "default from ext1")
+	c.Assert(ctx.Metadata.GetOutputs("deploy"), HasLen, 1)
+	c.Assert(ctx.Metadata.GetOutputs("deploy")[0].Description, Equals, "output from ext2")
+	c.Assert(ctx.Metadata.Metadata["key"], Equals, "value from ext1")
+	c.Assert(ctx.Metadata.Errands["errand"].Description, Equals, "errand from ext2")
+	for _, conflict := range ctx.ExtensionReport.Conflicts {
+		c.Assert(conflict.Warning, Equals, false)
+	}
+}
+
+func (s *suite) Test_Compile_Extensions_error_merge_rule(c *C) {
+	ctx := newConflictingExtensionsContext(map[string]string{
+		"errands": "error",
+	})
+	err := compileExtensions(ctx)
+	c.Assert(err, Not(IsNil))
+	c.Assert(err.Error(), Equals, "Extension conflict: errands 'errand' is defined in _/ext1-v1.0 and _/ext2-v1.0, but the extension_merge rule for 'errands' is 'error'")
+}
+
+func (s *suite) Test_Compile_Extensions_fails_on_invalid_merge_rules(c *C) {
+	ctx := newConflictingExtensionsContext(map[string]string{"unknown": "error"})
+	c.Assert(compileExtensions(ctx), ErrorMatches, "Unknown field 'unknown' in extension_merge.*")
+	ctx = newConflictingExtensionsContext(map[string]string{"inputs": "merge"})
+	c.Assert(compileExtensions(ctx), ErrorMatches, "Invalid extension_merge rule 'merge' for field 'inputs'.*")
+}
+
+func (s *suite) Test_Compile_Extensions_plan_takes_precedence(c *C) {
+	ctx := newConflictingExtensionsContext(nil)
+	ctx.Plan.Metadata = map[string]string{"key": "value from plan"}
+	c.Assert(compileExtensions(ctx), IsNil)
+	c.Assert(compileMetadata(ctx), IsNil)
+	c.Assert(ctx.Metadata.Metadata["key"], Equals, "value from plan")
+	ctx.ExtensionReport.SortSources()
+	sources := ctx.ExtensionReport.Sources
+	c.Assert(sources[2].Field, Equals, "metadata")
+	c.Assert(sources[2].Sources, DeepEquals, []string{"_/ext1-v1.0", "_/ext2-v1.0", "escape plan"})
+	c.Assert(sources[2].Effective, Equals, "escape plan")
+}
+
+func (s *suite) Test_Compile_Extensions_doesnt_merge_items_defined_in_the_plan(c *C) {
+	ctx := newConflictingExtensionsContext(nil)
+	ctx.Plan.Inputs = []interface{}{"input"}
+	ctx.Plan.Errands = map[string]interface{}{"errand": map[interface{}]interface{}{"script": "errand.sh"}}
+	c.Assert(compileExtensions(ctx), IsNil)
+	c.Assert(ctx.Metadata.GetInputs("deploy"), HasLen, 0)
+	c.Assert(ctx.Metadata.Errands["errand"], IsNil)
+	for _, source := range ctx.ExtensionReport.Sources {
+		if source.Field == "inputs" || source.Field == "errands" {
+			c.Assert(source.Sources, DeepEquals, []string{"_/ext1-v1.0", "_/ext2-v1.0", "escape plan"})
+			c.Assert(source.Effective, Equals, "escape plan")
+		} else {
+			c.Assert(source.Effective, Not(Equals), "escape plan")
+		}
+	}
+}
+
+func (s *suite) Test_Compile_Extensions_adds_hooks(c *C) {
+	plan := escape_plan.NewEscapePlan()
+	plan.Extends = []string{"ext1-v1.0", "ext2-v1.0"}
+	ctx := NewCompilerContext(plan, nil)
+	ctx.DependencyFetcher = func(dep *core.DependencyConfig) (*core.ReleaseMetadata, error) {
+		m := core.NewReleaseMetadata(dep.ReleaseId[2:6], "1.0")
+		m.AddHook("before_deploy", core.NewHook(core.NewExecStageForRelativeScript("audit.sh --verbose")))
+		return m, nil
+	}
+	c.Assert(compileExtensions(ctx), IsNil)
+	hooks := ctx.Metadata.GetHooks("before_deploy")
+	c.Assert(hooks, HasLen, 2)
+	c.Assert(hooks[0].Extension, Equals, "_/ext1-v1.0")
+	c.Assert(hooks[0].Run.RelativeScript, Equals, "deps/_/ext1/audit.sh --verbose")
+	c.Assert(hooks[1].Extension, Equals, "_/ext2-v1.0")
+	c.Assert(ctx.ExtensionReport.Conflicts, HasLen, 0)
+}
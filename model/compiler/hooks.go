@@ -0,0 +1,52 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	core "github.com/ankyra/escape-core"
+)
+
+func compileHooks(ctx *CompilerContext) error {
+	points := []string{}
+	for point := range ctx.Plan.Hooks {
+		points = append(points, point)
+	}
+	sort.Strings(points)
+	for _, point := range points {
+		if err := core.ValidateHookPoint(point); err != nil {
+			return err
+		}
+		for _, h := range ctx.Plan.Hooks[point] {
+			hook, err := core.NewHookFromInterface(h)
+			if err != nil {
+				return fmt.Errorf("%s in '%s' hook", err.Error(), point)
+			}
+			if hook.Run.RelativeScript != "" {
+				script := strings.Fields(hook.Run.RelativeScript)[0]
+				if err := ctx.AddFileDigest(script); err != nil {
+					return err
+				}
+			}
+			ctx.Metadata.AddHook(point, hook)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compiler
+
+import (
+	"github.com/ankyra/escape/model/escape_plan"
+	. "gopkg.in/check.v1"
+)
+
+func (s *suite) Test_Compile_Hooks(c *C) {
+	plan := escape_plan.NewEscapePlan()
+	plan.Hooks = map[string][]interface{}{
+		"before_deploy": []interface{}{
+			"testdata/script.sh",
+			map[interface{}]interface{}{
+				"name":  "notify",
+				"cmd":   "echo",
+				"args":  []interface{}{"deploying"},
+				"fatal": false,
+			},
+		},
+		"on_failure": []interface{}{"echo failed"},
+	}
+	ctx := NewCompilerContext(plan, nil)
+	c.Assert(compileHooks(ctx), IsNil)
+	hooks := ctx.Metadata.GetHooks("before_deploy")
+	c.Assert(hooks, HasLen, 2)
+	c.Assert(hooks[0].Run.RelativeScript, Equals, "testdata/script.sh")
+	c.Assert(hooks[0].Fatal, Equals, true)
+	c.Assert(hooks[1].Name, Equals, "notify")
+	c.Assert(hooks[1].Run.Cmd, Equals, "echo")
+	c.Assert(hooks[1].Fatal, Equals, false)
+	c.Assert(ctx.Metadata.GetHooks("on_failure"), HasLen, 1)
+	c.Assert(ctx.Metadata.Files["testdata/script.sh"], Not(Equals), "")
+}
+
+func (s *suite) Test_Compile_Hooks_fails_on_invalid_hook_point(c *C) {
+	plan := escape_plan.NewEscapePlan()
+	plan.Hooks = map[string][]interface{}{
+		"before_lunch": []interface{}{"echo"},
+	}
+	ctx := NewCompilerContext(plan, nil)
+	c.Assert(compileHooks(ctx), ErrorMatches, "Invalid hook point 'before_lunch'.*")
+}
+
+func (s *suite) Test_Compile_Hooks_fails_on_invalid_hook(c *C) {
+	plan := escape_plan.NewEscapePlan()
+	plan.Hooks = map[string][]interface{}{
+		"after_deploy": []interface{}{12},
+	}
+	ctx := NewCompilerContext(plan, nil)
+	c.Assert(compileHooks(ctx), ErrorMatches, "Expecting string or dict type for hook. Got 'int' in 'after_deploy' hook")
+}
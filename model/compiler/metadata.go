@@ -27,6 +27,7 @@ func compileMetadata(ctx *CompilerContext) error {
 			return fmt.Errorf("%s in metadata field '%s'.", err.Error(), key)
 		}
 		ctx.Metadata.Metadata[key] = str
+		ctx.ExtensionReport.AddPlanSource("metadata", key)
 	}
 	return nil
 }
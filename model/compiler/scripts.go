@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape/model/escape_plan"
 )
 
 func ScriptFieldError(field string, err error) error {
@@ -27,8 +28,17 @@ func ScriptFieldError(field string, err error) error {
 }
 
 func compileScripts(ctx *CompilerContext) error {
-	plan := ctx.Plan
-	cases := [][]interface{}{
+	for _, script := range scriptFields(ctx.Plan) {
+		if err := setStage(ctx, script[0].(string), script[1]); err != nil {
+			return ScriptFieldError(script[0].(string), err)
+		}
+	}
+	return nil
+}
+
+// scriptFields returns the stage names and their values in the Escape plan.
+func scriptFields(plan *escape_plan.EscapePlan) [][]interface{} {
+	return [][]interface{}{
 		[]interface{}{"build", plan.Build},
 		[]interface{}{"deploy", plan.Deploy},
 		[]interface{}{"destroy", plan.Destroy},
@@ -43,12 +53,6 @@ func compileScripts(ctx *CompilerContext) error {
 		[]interface{}{"activate_provider", plan.ActivateProvider},
 		[]interface{}{"deactivate_provider", plan.DeactivateProvider},
 	}
-	for _, script := range cases {
-		if err := setStage(ctx, script[0].(string), script[1]); err != nil {
-			return ScriptFieldError(script[0].(string), err)
-		}
-	}
-	return nil
 }
 
 func setStage(ctx *CompilerContext, field string, script interface{}) error {
@@ -63,5 +67,6 @@ func setStage(ctx *CompilerContext, field string, script interface{}) error {
 		ctx.AddFileDigest(stage.RelativeScript)
 	}
 	ctx.Metadata.SetExecStage(field, stage)
+	ctx.ExtensionReport.AddPlanSource("stages", field)
 	return nil
 }
@@ -29,6 +29,7 @@ func compileTemplates(ctx *CompilerContext) error {
 			return err
 		}
 		ctx.Metadata.Templates = append(ctx.Metadata.Templates, template)
+		ctx.ExtensionReport.AddPlanSource("templates", templateKey(template))
 	}
 	for _, tpl := range ctx.Plan.BuildTemplates {
 		template, err := compileTemplate(ctx, tpl, "build")
@@ -36,6 +37,7 @@ func compileTemplates(ctx *CompilerContext) error {
 			return err
 		}
 		ctx.Metadata.Templates = append(ctx.Metadata.Templates, template)
+		ctx.ExtensionReport.AddPlanSource("templates", templateKey(template))
 	}
 	for _, tpl := range ctx.Plan.DeployTemplates {
 		template, err := compileTemplate(ctx, tpl, "deploy")
@@ -43,6 +45,7 @@ func compileTemplates(ctx *CompilerContext) error {
 			return err
 		}
 		ctx.Metadata.Templates = append(ctx.Metadata.Templates, template)
+		ctx.ExtensionReport.AddPlanSource("templates", templateKey(template))
 	}
 	return nil
 }
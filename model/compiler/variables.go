@@ -30,6 +30,7 @@ func compileInputs(ctx *CompilerContext) error {
 			return fmt.Errorf("Error compiling input variable: %s", err.Error())
 		}
 		ctx.Metadata.AddInputVariable(v)
+		ctx.ExtensionReport.AddPlanSource("inputs", v.Id)
 	}
 	for _, input := range ctx.Plan.BuildInputs {
 		v, err := compileVariable(ctx, input)
@@ -38,6 +39,7 @@ func compileInputs(ctx *CompilerContext) error {
 		}
 		v.Scopes = []string{"build"}
 		ctx.Metadata.AddInputVariable(v)
+		ctx.ExtensionReport.AddPlanSource("inputs", v.Id)
 	}
 	for _, input := range ctx.Plan.DeployInputs {
 		v, err := compileVariable(ctx, input)
@@ -46,6 +48,7 @@ func compileInputs(ctx *CompilerContext) error {
 		}
 		v.Scopes = []string{"deploy"}
 		ctx.Metadata.AddInputVariable(v)
+		ctx.ExtensionReport.AddPlanSource("inputs", v.Id)
 	}
 	return compileDependencyVariableMapping(ctx)
 }
@@ -57,6 +60,7 @@ func compileOutputs(ctx *CompilerContext) error {
 			return fmt.Errorf("Error compiling output variable: %s", err.Error())
 		}
 		ctx.Metadata.AddOutputVariable(v)
+		ctx.ExtensionReport.AddPlanSource("outputs", v.Id)
 	}
 	for _, output := range ctx.Plan.BuildOutputs {
 		v, err := compileVariable(ctx, output)
@@ -65,6 +69,7 @@ func compileOutputs(ctx *CompilerContext) error {
 		}
 		v.Scopes = []string{"build"}
 		ctx.Metadata.AddOutputVariable(v)
+		ctx.ExtensionReport.AddPlanSource("outputs", v.Id)
 	}
 	for _, output := range ctx.Plan.DeployOutputs {
 		v, err := compileVariable(ctx, output)
@@ -73,6 +78,7 @@ func compileOutputs(ctx *CompilerContext) error {
 		}
 		v.Scopes = []string{"deploy"}
 		ctx.Metadata.AddOutputVariable(v)
+		ctx.ExtensionReport.AddPlanSource("outputs", v.Id)
 	}
 	return nil
 }
@@ -110,6 +116,17 @@ func compileDefault(ctx *CompilerContext, v *variables.Variable) (*variables.Var
 			}
 		}
 		return v, nil
+	case map[string]interface{}:
+		for _, k := range v.Default.(map[string]interface{}) {
+			switch k.(type) {
+			case string:
+				_, err := script.ParseScript(k.(string))
+				if err != nil {
+					return nil, fmt.Errorf("Couldn't parse expression '%s' in default field: %s", k.(string), err.Error())
+				}
+			}
+		}
+		return v, nil
 	}
 	return nil, fmt.Errorf("Unexpected type '%T' for default field of variable '%s'", v.Default, v.Id)
 }
@@ -97,12 +97,21 @@ func (e *EscapeConfig) LoadConfig(cfgFile string) error {
 	if _, ok := e.Profiles[e.ActiveProfile]; !ok {
 		return fmt.Errorf("Referenced profile '%s' was not found in the Escape configuration file.", e.ActiveProfile)
 	}
-	for _, t := range e.Profiles {
+	for name, t := range e.Profiles {
 		t.fix(e)
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("Invalid profile '%s' in Escape configuration file '%s': %s", name, cfgFile, err.Error())
+		}
 	}
 	return nil
 }
 
+// GetCredentialStorePath returns the location of the built-in credential
+// store, which lives next to the configuration file.
+func (e *EscapeConfig) GetCredentialStorePath() string {
+	return filepath.Join(filepath.Dir(e.saveLocation), ".escape_credentials")
+}
+
 func (e *EscapeConfig) FromJson(cfgFile string) error {
 	data, err := ioutil.ReadFile(cfgFile)
 	if err != nil {
@@ -115,7 +124,12 @@ func (e *EscapeConfig) Save() error {
 	if e.saveLocation == "" {
 		return fmt.Errorf("Save location has not been set")
 	}
-	str, err := json.MarshalIndent(e, "", "    ")
+	cfg := *e
+	cfg.Profiles = map[string]*EscapeConfigProfile{}
+	for name, profile := range e.Profiles {
+		cfg.Profiles[name] = profile.withoutLayers().withoutSecrets()
+	}
+	str, err := json.MarshalIndent(cfg, "", "    ")
 	if err != nil {
 		return fmt.Errorf("Could not convert escape config to json: %s", err.Error())
 	}
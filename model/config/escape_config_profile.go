@@ -2,17 +2,26 @@ package config
 
 import (
 	"encoding/json"
-	"os"
+	"fmt"
+	"time"
 
+	"github.com/ankyra/escape/model/credentials"
 	"github.com/ankyra/escape/model/inventory"
+	"github.com/ankyra/escape/model/inventory/chain"
 	"github.com/ankyra/escape/model/inventory/types"
+	"github.com/ankyra/escape/model/notifications"
 	"github.com/ankyra/escape/model/paths"
+	"github.com/ankyra/escape/model/remote"
 )
 
+// Tokens are refreshed when they expire within this many seconds.
+const tokenRefreshMargin = 300
+
 type InventoryType string
 
 var LocalInventory InventoryType = "local"
 var RemoteInventory InventoryType = "remote"
+var OCIInventory InventoryType = "oci"
 
 type EscapeConfigProfile struct {
 	InventoryType         InventoryType `json:"inventory_type"`
@@ -24,16 +33,58 @@ type EscapeConfigProfile struct {
 	StatePath             string        `json:"state_path"`
 	LocalInventoryBaseDir string        `json:"local_inventory_base_dir"`
 	ProxyNamespaces       []string      `json:"proxy_namespaces"`
-	parent                *EscapeConfig
+
+	// Expiring tokens are refreshed using the escape_refresh_token before
+	// the escape_auth_token_expires_at unix timestamp is reached.
+	AuthTokenExpiresAt int64  `json:"escape_auth_token_expires_at,omitempty"`
+	RefreshToken       string `json:"escape_refresh_token,omitempty"`
+	RefreshURL         string `json:"escape_refresh_url,omitempty"`
+
+	// The repository prefix used for releases when the inventory_type is
+	// "oci". The api_server should point at the OCI registry.
+	OCIRepositoryPrefix string `json:"oci_repository_prefix,omitempty"`
+
+	// The name of a credential helper that stores the escape_auth_token,
+	// basic_auth_username and basic_auth_password for the api_server, so
+	// that they don't end up in the configuration file. Use "file" for the
+	// built-in encrypted store, or the name of an external
+	// `escape-credential-<name>` program.
+	CredentialHelper string `json:"credential_helper,omitempty"`
+
+	// An ordered list of Inventories. When set, this takes precedence over
+	// the inventory_type, api_server and proxy_namespaces fields above.
+	Inventories []*InventoryConfig `json:"inventories,omitempty"`
+
+	// Sinks that are notified when the status of a deployment stage
+	// changes, e.g. when a deployment fails.
+	Notifications []*NotificationConfig `json:"notifications,omitempty"`
+
+	// The severities of the `escape plan lint` rules, by rule id. One of
+	// "error", "warning", "info" or "off".
+	LintRules map[string]string `json:"lint_rules,omitempty"`
+
+	// Allow `cmd:` references in input values, which run shell commands.
+	AllowCommandReferences bool `json:"allow_command_references,omitempty"`
+
+	parent *EscapeConfig
+
+	// The credentials are loaded from the credential helper the first time
+	// they're needed (see LoadCredentials).
+	credentialsLoaded bool
+
+	// Whether the credential helper holds the api_server credentials, in
+	// which case they're left out of the configuration file.
+	secretsInHelper bool
+
+	// Where the values came from, and the values that were overridden by
+	// the project and environment layers (see layers.go).
+	sources    map[string]*ValueSource
+	overridden map[string]*overriddenValue
 }
 
 func newEscapeConfigProfile(cfg *EscapeConfig) *EscapeConfigProfile {
 	profile := &EscapeConfigProfile{
-		ApiServer:         os.Getenv("ESCAPE_API_SERVER"),
-		AuthToken:         os.Getenv("ESCAPE_AUTH_TOKEN"),
-		BasicAuthUsername: os.Getenv("BASIC_AUTH_USERNAME"),
-		BasicAuthPassword: os.Getenv("BASIC_AUTH_PASSWORD"),
-		ProxyNamespaces:   []string{},
+		ProxyNamespaces: []string{},
 	}
 	return profile.fix(cfg)
 }
@@ -41,23 +92,268 @@ func newEscapeConfigProfile(cfg *EscapeConfig) *EscapeConfigProfile {
 func (t *EscapeConfigProfile) fix(cfg *EscapeConfig) *EscapeConfigProfile {
 	t.parent = cfg
 	if t.InventoryType == "" {
+		t.setValueSource("inventory_type", DefaultLayer, "")
 		if t.ApiServer != "" {
 			t.InventoryType = RemoteInventory
 		} else {
 			t.InventoryType = LocalInventory
 			t.LocalInventoryBaseDir = paths.NewPath().GetDefaultLocalInventoryLocation()
+			t.setValueSource("local_inventory_base_dir", DefaultLayer, "")
 		}
 	}
 	if t.ApiServer == "" {
 		t.ApiServer = "https://escape.ankyra.io"
 		t.ProxyNamespaces = []string{"examples", "extensions", "providers"}
+		t.setValueSource("api_server", DefaultLayer, "")
+		t.setValueSource("proxy_namespaces", DefaultLayer, "")
 	}
 	if t.StatePath == "" {
 		t.StatePath = paths.NewPath().GetDefaultStateLocation()
+		t.setValueSource("state_path", DefaultLayer, "")
 	}
 	return t
 }
 
+func (t *EscapeConfigProfile) Validate() error {
+	for i, inv := range t.Inventories {
+		if err := inv.Validate(); err != nil {
+			return fmt.Errorf("Invalid inventory #%d: %s", i+1, err.Error())
+		}
+	}
+	for i, n := range t.Notifications {
+		if err := n.Validate(); err != nil {
+			return fmt.Errorf("Invalid notification #%d: %s", i+1, err.Error())
+		}
+	}
+	return nil
+}
+
+func (t *EscapeConfigProfile) GetNotifiers() []*notifications.Notifier {
+	result := []*notifications.Notifier{}
+	for _, n := range t.Notifications {
+		result = append(result, n.GetNotifier())
+	}
+	return result
+}
+
+func (t *EscapeConfigProfile) getCredentialHelper() credentials.Helper {
+	return credentials.NewHelper(t.CredentialHelper, t.parent.GetCredentialStorePath())
+}
+
+// LoadCredentials gets the credentials for the api_server and the
+// inventories from the credential helper, if one is configured, and
+// refreshes the auth token when it's about to expire. This is done the first
+// time the Inventory or remote state is used, so that commands that don't
+// talk to a server don't need access to the credential helper. Not having
+// any credentials yet is not an error. Credentials that are still in the
+// configuration file are used as they are; see MigrateCredentials.
+func (t *EscapeConfigProfile) LoadCredentials() error {
+	if t.credentialsLoaded {
+		return nil
+	}
+	if t.CredentialHelper != "" {
+		if err := t.loadCredentials(); err != nil {
+			return err
+		}
+		if err := t.loadInventoryCredentials(); err != nil {
+			return err
+		}
+	}
+	t.credentialsLoaded = true
+	return t.refreshAuthToken()
+}
+
+// MigrateCredentials moves the credentials that are still in the
+// configuration file to the credential helper, and returns the servers
+// whose credentials were moved. The caller is responsible for saving the
+// configuration, which removes the secrets from the file.
+func (t *EscapeConfigProfile) MigrateCredentials() ([]string, error) {
+	if t.CredentialHelper == "" {
+		return nil, fmt.Errorf("There is no credential helper configured in this profile. Use `escape login --credential-helper` to configure one.")
+	}
+	if err := t.LoadCredentials(); err != nil {
+		return nil, err
+	}
+	migrated := []string{}
+	if !t.secretsInHelper && (t.AuthToken != "" || t.BasicAuthUsername != "" || t.BasicAuthPassword != "") {
+		if err := t.StoreCredentials(); err != nil {
+			return nil, err
+		}
+		migrated = append(migrated, t.ApiServer)
+	}
+	helper := t.getCredentialHelper()
+	for _, inv := range t.Inventories {
+		if inv.ApiServer == "" || inv.secretsInHelper || !inv.HasCredentials() {
+			continue
+		}
+		if err := helper.Store(inv.GetCredentials()); err != nil {
+			return nil, fmt.Errorf("Couldn't store credentials for '%s' using credential helper '%s': %s", inv.ApiServer, t.CredentialHelper, err.Error())
+		}
+		inv.secretsInHelper = true
+		migrated = append(migrated, inv.ApiServer)
+	}
+	return migrated, nil
+}
+
+func (t *EscapeConfigProfile) loadCredentials() error {
+	creds, err := t.getCredentialHelper().Get(t.ApiServer)
+	if err == credentials.ErrCredentialsNotFound {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Couldn't get credentials for '%s' from credential helper '%s': %s", t.ApiServer, t.CredentialHelper, err.Error())
+	}
+	t.secretsInHelper = true
+	if creds.IsToken() {
+		t.AuthToken = creds.Secret
+		t.BasicAuthUsername = ""
+		t.BasicAuthPassword = ""
+		refresh, err := t.getCredentialHelper().Get(t.refreshTokenServerURL())
+		if err == nil {
+			t.RefreshToken = refresh.Secret
+		} else if err != credentials.ErrCredentialsNotFound {
+			return fmt.Errorf("Couldn't get refresh token for '%s' from credential helper '%s': %s", t.ApiServer, t.CredentialHelper, err.Error())
+		}
+	} else {
+		t.AuthToken = ""
+		t.BasicAuthUsername = creds.Username
+		t.BasicAuthPassword = creds.Secret
+	}
+	return nil
+}
+
+// loadInventoryCredentials gets the credentials of the inventories from the
+// credential helper. Inventories that still have credentials in the
+// configuration file keep using those.
+func (t *EscapeConfigProfile) loadInventoryCredentials() error {
+	helper := t.getCredentialHelper()
+	for _, inv := range t.Inventories {
+		if inv.ApiServer == "" || inv.HasCredentials() {
+			continue
+		}
+		creds, err := helper.Get(inv.ApiServer)
+		if err == credentials.ErrCredentialsNotFound {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("Couldn't get credentials for '%s' from credential helper '%s': %s", inv.ApiServer, t.CredentialHelper, err.Error())
+		}
+		inv.SetCredentials(creds)
+		inv.secretsInHelper = true
+	}
+	return nil
+}
+
+// refreshAuthToken refreshes the auth token when it's about to expire. This
+// is best effort: when the refresh fails the Inventory will reject the token
+// and ask the user to login again.
+func (t *EscapeConfigProfile) refreshAuthToken() error {
+	if !t.AuthTokenNeedsRefresh(time.Now()) {
+		return nil
+	}
+	if err := t.RefreshAuthToken(); err != nil {
+		return nil
+	}
+	if err := t.StoreCredentials(); err != nil {
+		return err
+	}
+	return t.parent.Save()
+}
+
+// StoreCredentials writes the current credentials through to the credential
+// helper, if one is configured. The credentials are erased from the helper
+// when there aren't any.
+func (t *EscapeConfigProfile) StoreCredentials() error {
+	if t.CredentialHelper == "" {
+		return nil
+	}
+	helper := t.getCredentialHelper()
+	var err error
+	if t.AuthToken != "" {
+		err = helper.Store(&credentials.Credentials{
+			ServerURL: t.ApiServer,
+			Username:  credentials.TokenUsername,
+			Secret:    t.AuthToken,
+		})
+	} else if t.BasicAuthUsername != "" || t.BasicAuthPassword != "" {
+		err = helper.Store(&credentials.Credentials{
+			ServerURL: t.ApiServer,
+			Username:  t.BasicAuthUsername,
+			Secret:    t.BasicAuthPassword,
+		})
+	} else {
+		err = helper.Erase(t.ApiServer)
+		if err == credentials.ErrCredentialsNotFound {
+			err = nil
+		}
+	}
+	if err == nil && t.RefreshToken != "" {
+		err = helper.Store(&credentials.Credentials{
+			ServerURL: t.refreshTokenServerURL(),
+			Username:  credentials.TokenUsername,
+			Secret:    t.RefreshToken,
+		})
+	} else if err == nil {
+		err = helper.Erase(t.refreshTokenServerURL())
+		if err == credentials.ErrCredentialsNotFound {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Couldn't store credentials for '%s' using credential helper '%s': %s", t.ApiServer, t.CredentialHelper, err.Error())
+	}
+	t.secretsInHelper = true
+	return nil
+}
+
+// withoutSecrets returns a copy of the profile that can be written to the
+// configuration file. The secrets are only removed if a credential helper is
+// storing them; secrets that haven't been migrated yet stay in the file.
+func (t *EscapeConfigProfile) withoutSecrets() *EscapeConfigProfile {
+	if t.CredentialHelper == "" {
+		return t
+	}
+	result := *t
+	if t.secretsInHelper {
+		result.AuthToken = ""
+		result.BasicAuthUsername = ""
+		result.BasicAuthPassword = ""
+		result.RefreshToken = ""
+	}
+	result.Inventories = []*InventoryConfig{}
+	for _, inv := range t.Inventories {
+		result.Inventories = append(result.Inventories, inv.withoutSecrets())
+	}
+	return &result
+}
+
+// The refresh token is kept next to the auth token in the credential helper.
+func (t *EscapeConfigProfile) refreshTokenServerURL() string {
+	return t.ApiServer + "#refresh-token"
+}
+
+// AuthTokenNeedsRefresh returns true if the auth token is about to expire
+// and can be refreshed.
+func (t *EscapeConfigProfile) AuthTokenNeedsRefresh(now time.Time) bool {
+	if t.AuthToken == "" || t.RefreshToken == "" || t.RefreshURL == "" || t.AuthTokenExpiresAt == 0 {
+		return false
+	}
+	return now.Unix() >= t.AuthTokenExpiresAt-tokenRefreshMargin
+}
+
+// RefreshAuthToken exchanges the refresh token for a new auth token. The
+// caller is responsible for saving the profile.
+func (t *EscapeConfigProfile) RefreshAuthToken() error {
+	client := remote.NewRemoteClient("", "", "", t.InsecureSkipVerify)
+	resp, err := client.RefreshAuthToken(t.RefreshURL, t.RefreshToken)
+	if err != nil {
+		return err
+	}
+	if resp.RefreshToken == "" {
+		resp.RefreshToken = t.RefreshToken
+	}
+	t.SetTokenResponse(resp, t.RefreshURL)
+	return nil
+}
+
 func (t *EscapeConfigProfile) ToJson() string {
 	str, err := json.MarshalIndent(t, "", "   ")
 	if err != nil {
@@ -66,7 +362,28 @@ func (t *EscapeConfigProfile) ToJson() string {
 	return string(str)
 }
 
+// GetInventory returns the Inventory for this profile. The credentials are
+// loaded when the Inventory is first used.
 func (t *EscapeConfigProfile) GetInventory() types.Inventory {
+	return inventory.NewLazyInventory(t.getInventory(), func() (types.Inventory, error) {
+		if err := t.LoadCredentials(); err != nil {
+			return nil, err
+		}
+		return t.getInventory(), nil
+	})
+}
+
+func (t *EscapeConfigProfile) getInventory() types.Inventory {
+	if len(t.Inventories) > 0 {
+		links := []*chain.Link{}
+		for _, invCfg := range t.Inventories {
+			links = append(links, invCfg.ToChainLink(t))
+		}
+		return inventory.NewInventoryChain(links)
+	}
+	if t.InventoryType == OCIInventory {
+		return inventory.NewOCIInventory(t.ApiServer, t.OCIRepositoryPrefix, t.BasicAuthUsername, t.BasicAuthPassword, t.InsecureSkipVerify)
+	}
 	var inv types.Inventory
 	if t.InventoryType == LocalInventory {
 		inv = inventory.NewLocalInventory(t.LocalInventoryBaseDir)
@@ -98,8 +415,24 @@ func (t *EscapeConfigProfile) GetAuthToken() string {
 func (t *EscapeConfigProfile) SetApiServer(v string) {
 	t.ApiServer = v
 }
+
+// SetAuthToken sets a token that doesn't expire.
 func (t *EscapeConfigProfile) SetAuthToken(v string) {
 	t.AuthToken = v
+	t.AuthTokenExpiresAt = 0
+	t.RefreshToken = ""
+	t.RefreshURL = ""
+}
+
+// SetTokenResponse sets the token and (if the token expires) the
+// information needed to refresh it.
+func (t *EscapeConfigProfile) SetTokenResponse(resp *remote.TokenResponse, refreshURL string) {
+	t.SetAuthToken(resp.Token)
+	t.AuthTokenExpiresAt = resp.ExpiresAt(time.Now())
+	if t.AuthTokenExpiresAt != 0 && resp.RefreshToken != "" && refreshURL != "" {
+		t.RefreshToken = resp.RefreshToken
+		t.RefreshURL = refreshURL
+	}
 }
 func (t *EscapeConfigProfile) GetInsecureSkipVerify() bool {
 	return t.InsecureSkipVerify
@@ -107,6 +440,9 @@ func (t *EscapeConfigProfile) GetInsecureSkipVerify() bool {
 func (t *EscapeConfigProfile) SetInsecureSkipVerify(v bool) {
 	t.InsecureSkipVerify = v
 }
+func (t *EscapeConfigProfile) GetAllowCommandReferences() bool {
+	return t.AllowCommandReferences
+}
 func (t *EscapeConfigProfile) SetBasicAuthCredentials(username, password string) {
 	t.BasicAuthUsername = username
 	t.BasicAuthPassword = password
@@ -114,3 +450,9 @@ func (t *EscapeConfigProfile) SetBasicAuthCredentials(username, password string)
 func (t *EscapeConfigProfile) GetStatePath() string {
 	return t.StatePath
 }
+func (t *EscapeConfigProfile) GetLocalInventoryBaseDir() string {
+	if t.LocalInventoryBaseDir == "" {
+		return paths.NewPath().GetDefaultLocalInventoryLocation()
+	}
+	return t.LocalInventoryBaseDir
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ankyra/escape/model/credentials"
+	"github.com/ankyra/escape/util"
+	. "gopkg.in/check.v1"
+)
+
+const credentialsTestConfig = `{
+    "current_profile": "default",
+    "profiles": {
+        "default": {
+            "api_server": "http://escape.example.com",
+            "credential_helper": "file",
+            "inventories": [{
+                "inventory_type": "remote",
+                "api_server": "http://private.example.com",
+                "basic_auth_username": "user",
+                "basic_auth_password": "secret"
+            }]
+        }
+    }
+}`
+
+func (s *suite) Test_LoadCredentials_is_deferred_until_needed(c *C) {
+	defer os.Unsetenv(credentials.PassphraseEnvVar)
+	os.Setenv(credentials.PassphraseEnvVar, "passphrase")
+	store := credentials.NewFileStore(filepath.Join(testDir, ".escape_credentials"))
+	c.Assert(store.Store(&credentials.Credentials{ServerURL: "http://escape.example.com", Username: "user", Secret: "pass"}), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(testDir, "escape_config"), []byte(credentialsTestConfig), 0600), IsNil)
+
+	os.Unsetenv(credentials.PassphraseEnvVar)
+	cfg := loadTestConfig(c)
+	profile := cfg.GetCurrentProfile()
+	c.Assert(profile.BasicAuthPassword, Equals, "")
+	_, err := profile.GetInventory().ListProjects()
+	c.Assert(err, ErrorMatches, "Couldn't get credentials for 'http://escape.example.com'.*")
+
+	os.Setenv(credentials.PassphraseEnvVar, "passphrase")
+	c.Assert(profile.LoadCredentials(), IsNil)
+	c.Assert(profile.BasicAuthUsername, Equals, "user")
+	c.Assert(profile.BasicAuthPassword, Equals, "pass")
+}
+
+func (s *suite) Test_LoadCredentials_doesnt_write_the_configuration(c *C) {
+	defer os.Unsetenv(credentials.PassphraseEnvVar)
+	os.Setenv(credentials.PassphraseEnvVar, "passphrase")
+	c.Assert(ioutil.WriteFile(filepath.Join(testDir, "escape_config"), []byte(credentialsTestConfig), 0600), IsNil)
+
+	cfg := loadTestConfig(c)
+	c.Assert(cfg.GetCurrentProfile().LoadCredentials(), IsNil)
+	inv := cfg.GetCurrentProfile().Inventories[0]
+	c.Assert(inv.BasicAuthUsername, Equals, "user")
+	c.Assert(inv.BasicAuthPassword, Equals, "secret")
+	content, err := ioutil.ReadFile(filepath.Join(testDir, "escape_config"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, credentialsTestConfig)
+	c.Assert(util.PathExists(filepath.Join(testDir, ".escape_credentials")), Equals, false)
+
+	// Saving the configuration for other reasons keeps the secrets that
+	// haven't been migrated.
+	c.Assert(cfg.Save(), IsNil)
+	content, err = ioutil.ReadFile(filepath.Join(testDir, "escape_config"))
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(content), "secret"), Equals, true)
+}
+
+func (s *suite) Test_MigrateCredentials_moves_secrets_to_credential_helper(c *C) {
+	defer os.Unsetenv(credentials.PassphraseEnvVar)
+	os.Setenv(credentials.PassphraseEnvVar, "passphrase")
+	c.Assert(ioutil.WriteFile(filepath.Join(testDir, "escape_config"), []byte(credentialsTestConfig), 0600), IsNil)
+
+	cfg := loadTestConfig(c)
+	migrated, err := cfg.GetCurrentProfile().MigrateCredentials()
+	c.Assert(err, IsNil)
+	c.Assert(migrated, DeepEquals, []string{"http://private.example.com"})
+	c.Assert(cfg.Save(), IsNil)
+	content, err := ioutil.ReadFile(filepath.Join(testDir, "escape_config"))
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(content), "secret"), Equals, false)
+	c.Assert(strings.Contains(string(content), "http://private.example.com"), Equals, true)
+
+	cfg = loadTestConfig(c)
+	inv := cfg.GetCurrentProfile().Inventories[0]
+	c.Assert(inv.BasicAuthPassword, Equals, "")
+	c.Assert(cfg.GetCurrentProfile().LoadCredentials(), IsNil)
+	c.Assert(inv.BasicAuthUsername, Equals, "user")
+	c.Assert(inv.BasicAuthPassword, Equals, "secret")
+
+	migrated, err = cfg.GetCurrentProfile().MigrateCredentials()
+	c.Assert(err, IsNil)
+	c.Assert(migrated, HasLen, 0)
+}
+
+func (s *suite) Test_MigrateCredentials_fails_without_credential_helper(c *C) {
+	c.Assert(ioutil.WriteFile(filepath.Join(testDir, "escape_config"), []byte(`{"current_profile": "default", "profiles": {"default": {}}}`), 0600), IsNil)
+	cfg := loadTestConfig(c)
+	_, err := cfg.GetCurrentProfile().MigrateCredentials()
+	c.Assert(err, ErrorMatches, "There is no credential helper configured in this profile.*")
+}
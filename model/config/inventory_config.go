@@ -0,0 +1,131 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/ankyra/escape/model/credentials"
+	"github.com/ankyra/escape/model/inventory"
+	"github.com/ankyra/escape/model/inventory/chain"
+	"github.com/ankyra/escape/model/inventory/types"
+)
+
+type InventoryMode string
+
+var ReadWriteMode InventoryMode = "read-write"
+var ReadOnlyMode InventoryMode = "read-only"
+var WriteOnlyMode InventoryMode = "write-only"
+
+// An InventoryConfig configures one of the Inventories in a profile's
+// resolution chain (see the "inventories" field in EscapeConfigProfile).
+type InventoryConfig struct {
+	InventoryType         InventoryType `json:"inventory_type"`
+	ApiServer             string        `json:"api_server,omitempty"`
+	AuthToken             string        `json:"escape_auth_token,omitempty"`
+	BasicAuthUsername     string        `json:"basic_auth_username,omitempty"`
+	BasicAuthPassword     string        `json:"basic_auth_password,omitempty"`
+	InsecureSkipVerify    bool          `json:"insecure_skip_verify,omitempty"`
+	LocalInventoryBaseDir string        `json:"local_inventory_base_dir,omitempty"`
+	OCIRepositoryPrefix   string        `json:"oci_repository_prefix,omitempty"`
+	Include               []string      `json:"include,omitempty"`
+	Exclude               []string      `json:"exclude,omitempty"`
+	Mode                  InventoryMode `json:"mode,omitempty"`
+
+	// Whether the credentials came from the profile's credential helper, in
+	// which case they're left out of the configuration file.
+	secretsInHelper bool
+}
+
+func (i *InventoryConfig) Validate() error {
+	if i.InventoryType != LocalInventory && i.InventoryType != RemoteInventory && i.InventoryType != OCIInventory {
+		return fmt.Errorf("Unknown inventory type '%s'. Expecting one of: %s, %s, %s", i.InventoryType, LocalInventory, RemoteInventory, OCIInventory)
+	}
+	if i.InventoryType != LocalInventory && i.ApiServer == "" {
+		return fmt.Errorf("Missing 'api_server' for %s inventory.", i.InventoryType)
+	}
+	if i.Mode != "" && i.Mode != ReadWriteMode && i.Mode != ReadOnlyMode && i.Mode != WriteOnlyMode {
+		return fmt.Errorf("Unknown inventory mode '%s'. Expecting one of: %s, %s, %s", i.Mode, ReadWriteMode, ReadOnlyMode, WriteOnlyMode)
+	}
+	return nil
+}
+
+func (i *InventoryConfig) HasCredentials() bool {
+	return i.AuthToken != "" || i.BasicAuthUsername != "" || i.BasicAuthPassword != ""
+}
+
+// GetCredentials returns the credentials to store in a credential helper.
+func (i *InventoryConfig) GetCredentials() *credentials.Credentials {
+	if i.AuthToken != "" {
+		return &credentials.Credentials{
+			ServerURL: i.ApiServer,
+			Username:  credentials.TokenUsername,
+			Secret:    i.AuthToken,
+		}
+	}
+	return &credentials.Credentials{
+		ServerURL: i.ApiServer,
+		Username:  i.BasicAuthUsername,
+		Secret:    i.BasicAuthPassword,
+	}
+}
+
+func (i *InventoryConfig) SetCredentials(creds *credentials.Credentials) {
+	if creds.IsToken() {
+		i.AuthToken = creds.Secret
+		i.BasicAuthUsername = ""
+		i.BasicAuthPassword = ""
+	} else {
+		i.AuthToken = ""
+		i.BasicAuthUsername = creds.Username
+		i.BasicAuthPassword = creds.Secret
+	}
+}
+
+func (i *InventoryConfig) withoutSecrets() *InventoryConfig {
+	if !i.secretsInHelper {
+		return i
+	}
+	result := *i
+	result.AuthToken = ""
+	result.BasicAuthUsername = ""
+	result.BasicAuthPassword = ""
+	return &result
+}
+
+func (i *InventoryConfig) GetInventory(profile *EscapeConfigProfile) types.Inventory {
+	if i.InventoryType == LocalInventory {
+		baseDir := i.LocalInventoryBaseDir
+		if baseDir == "" {
+			baseDir = profile.GetLocalInventoryBaseDir()
+		}
+		return inventory.NewLocalInventory(baseDir)
+	} else if i.InventoryType == OCIInventory {
+		return inventory.NewOCIInventory(i.ApiServer, i.OCIRepositoryPrefix, i.BasicAuthUsername, i.BasicAuthPassword, i.InsecureSkipVerify)
+	}
+	return inventory.NewRemoteInventory(i.ApiServer, i.AuthToken, i.BasicAuthUsername, i.BasicAuthPassword, i.InsecureSkipVerify)
+}
+
+func (i *InventoryConfig) ToChainLink(profile *EscapeConfigProfile) *chain.Link {
+	return &chain.Link{
+		Inventory: i.GetInventory(profile),
+		Include:   i.Include,
+		Exclude:   i.Exclude,
+		Read:      i.Mode != WriteOnlyMode,
+		Write:     i.Mode != ReadOnlyMode,
+	}
+}
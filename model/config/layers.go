@@ -0,0 +1,408 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ankyra/escape/util"
+)
+
+// The configuration layers, from lowest to highest precedence. The project
+// and environment layers are merged over the active profile, but are never
+// written back to the user's configuration file.
+const (
+	DefaultLayer     = "default"
+	UserLayer        = "user"
+	ProjectLayer     = "project"
+	EnvironmentLayer = "environment"
+)
+
+// The name of the project-local configuration file. It's looked up in the
+// directory of the Escape plan and its parents, and contains profile fields
+// (e.g. "inventory_type", "api_server", "proxy_namespaces") in JSON.
+const ProjectConfigFile = ".escape_project_config"
+
+// The profile fields that can be set in the ProjectConfigFile. The file is
+// usually checked in, so it can't contain credentials or anything that runs
+// commands or sends data elsewhere (e.g. "credential_helper" and
+// "notifications").
+var projectConfigFields = map[string]bool{
+	"inventory_type":           true,
+	"api_server":               true,
+	"state_path":               true,
+	"local_inventory_base_dir": true,
+	"proxy_namespaces":         true,
+	"oci_repository_prefix":    true,
+	"lint_rules":               true,
+}
+
+// The credentials that are only meant for the user's api_server. They're
+// not sent to an api_server set by the project configuration.
+var credentialFields = []string{
+	"escape_auth_token",
+	"basic_auth_username",
+	"basic_auth_password",
+	"escape_auth_token_expires_at",
+	"escape_refresh_token",
+	"escape_refresh_url",
+}
+
+// A ValueSource describes where the effective value of a profile field came
+// from. The Location is a file or an environment variable.
+type ValueSource struct {
+	Layer    string `json:"layer"`
+	Location string `json:"location,omitempty"`
+}
+
+type overriddenValue struct {
+	user      []byte
+	effective []byte
+}
+
+// FindProjectConfig walks up from dir looking for the ProjectConfigFile.
+// Returns an empty string if there isn't one.
+func FindProjectConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectConfigFile)
+		if util.PathExists(path) {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ApplyLayers merges the project-local configuration (found by walking up
+// from projectDir) and the ESCAPE_* environment variables over the active
+// profile.
+func (e *EscapeConfig) ApplyLayers(projectDir string) error {
+	profile := e.GetCurrentProfile()
+	if path := FindProjectConfig(projectDir); path != "" {
+		if err := profile.applyProjectConfig(path); err != nil {
+			return err
+		}
+	}
+	if err := profile.applyEnvironment(); err != nil {
+		return err
+	}
+	if err := profile.Validate(); err != nil {
+		return fmt.Errorf("Invalid configuration for profile '%s': %s", e.ActiveProfile, err.Error())
+	}
+	return nil
+}
+
+func (t *EscapeConfigProfile) applyProjectConfig(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("Couldn't parse project configuration file '%s': %s", path, err.Error())
+	}
+	notAllowed := []string{}
+	for _, field := range profileFields() {
+		raw, ok := values[field]
+		if !ok {
+			continue
+		}
+		delete(values, field)
+		if !projectConfigFields[field] {
+			notAllowed = append(notAllowed, field)
+			continue
+		}
+		if err := t.override(field, raw, ProjectLayer, path); err != nil {
+			return fmt.Errorf("Invalid value for '%s' in project configuration file '%s': %s", field, path, err.Error())
+		}
+	}
+	if len(values) > 0 {
+		unknown := []string{}
+		for field := range values {
+			unknown = append(unknown, field)
+		}
+		sort.Strings(unknown)
+		return fmt.Errorf("Unknown field(s) '%s' in project configuration file '%s'", strings.Join(unknown, "', '"), path)
+	}
+	if len(notAllowed) > 0 {
+		return fmt.Errorf("The field(s) '%s' can't be set in project configuration file '%s'. Set them in the profile or environment instead.", strings.Join(notAllowed, "', '"), path)
+	}
+	t.deriveDefaults()
+	return t.dropCredentialsForProjectApiServer(path)
+}
+
+// dropCredentialsForProjectApiServer makes sure the user's credentials
+// aren't sent to an api_server that was set by the project configuration.
+// The credentials for that server are looked up in the credential helper
+// instead, if there is one.
+func (t *EscapeConfigProfile) dropCredentialsForProjectApiServer(path string) error {
+	overridden, ok := t.overridden["api_server"]
+	if !ok || t.GetValueSource("api_server").Layer != ProjectLayer {
+		return nil
+	}
+	if bytes.Equal(overridden.user, overridden.effective) {
+		return nil
+	}
+	location := "cleared, because api_server is set in " + path
+	for _, field := range credentialFields {
+		typ := reflect.TypeOf(t).Elem().FieldByIndex(profileFieldIndex(field)).Type
+		empty, err := json.Marshal(reflect.Zero(typ).Interface())
+		if err != nil {
+			return err
+		}
+		if err := t.override(field, empty, ProjectLayer, location); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyEnvironmentVariables are still supported if the ESCAPE_ prefixed
+// variable isn't set.
+var legacyEnvironmentVariables = map[string]string{
+	"basic_auth_username": "BASIC_AUTH_USERNAME",
+	"basic_auth_password": "BASIC_AUTH_PASSWORD",
+}
+
+// EnvironmentVariable returns the name of the environment variable that
+// overrides a profile field, e.g. ESCAPE_API_SERVER for "api_server" and
+// ESCAPE_AUTH_TOKEN for "escape_auth_token".
+func EnvironmentVariable(field string) string {
+	name := strings.ToUpper(field)
+	if strings.HasPrefix(name, "ESCAPE_") {
+		return name
+	}
+	return "ESCAPE_" + name
+}
+
+func (t *EscapeConfigProfile) applyEnvironment() error {
+	v := reflect.ValueOf(t).Elem()
+	for _, field := range profileFields() {
+		name := EnvironmentVariable(field)
+		value, ok := os.LookupEnv(name)
+		if !ok && legacyEnvironmentVariables[field] != "" {
+			name = legacyEnvironmentVariables[field]
+			value, ok = os.LookupEnv(name)
+		}
+		if !ok {
+			continue
+		}
+		raw, err := environmentValueToJson(v.FieldByIndex(profileFieldIndex(field)).Type(), value)
+		if err == nil {
+			err = t.override(field, raw, EnvironmentLayer, "$"+name)
+		}
+		if err != nil {
+			return fmt.Errorf("Invalid value for environment variable '%s': %s", name, err.Error())
+		}
+	}
+	t.deriveDefaults()
+	return nil
+}
+
+// environmentValueToJson converts the value of an environment variable to
+// JSON. Lists of strings are comma separated; anything that isn't a string,
+// boolean, number or list of strings is expected to be JSON already.
+func environmentValueToJson(typ reflect.Type, value string) ([]byte, error) {
+	switch typ.Kind() {
+	case reflect.String:
+		return json.Marshal(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("expecting a boolean, got '%s'", value)
+		}
+		return json.Marshal(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expecting an integer, got '%s'", value)
+		}
+		return json.Marshal(i)
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.String {
+			result := []string{}
+			for _, part := range strings.Split(value, ",") {
+				if part = strings.TrimSpace(part); part != "" {
+					result = append(result, part)
+				}
+			}
+			return json.Marshal(result)
+		}
+	}
+	return []byte(value), nil
+}
+
+var layerPrecedence = map[string]int{
+	DefaultLayer:     0,
+	UserLayer:        1,
+	ProjectLayer:     2,
+	EnvironmentLayer: 3,
+}
+
+// deriveDefaults makes the inventory_type and proxy_namespaces follow an
+// api_server that was set by a higher layer, like they do when a new profile
+// is created with an api_server: the inventory becomes remote and nothing is
+// proxied. Setting these fields in the same layer as the api_server (or a
+// higher one) takes precedence.
+func (t *EscapeConfigProfile) deriveDefaults() {
+	source := t.GetValueSource("api_server")
+	if source.Layer != ProjectLayer && source.Layer != EnvironmentLayer {
+		return
+	}
+	location := "derived from api_server in " + source.Location
+	if layerPrecedence[t.GetValueSource("inventory_type").Layer] < layerPrecedence[source.Layer] {
+		t.override("inventory_type", []byte(`"`+RemoteInventory+`"`), source.Layer, location)
+	}
+	if layerPrecedence[t.GetValueSource("proxy_namespaces").Layer] < layerPrecedence[source.Layer] {
+		t.override("proxy_namespaces", []byte(`[]`), source.Layer, location)
+	}
+}
+
+// override sets a field from a higher layer, remembering the user's value
+// so that it can be restored when the configuration is saved.
+func (t *EscapeConfigProfile) override(field string, raw []byte, layer, location string) error {
+	fieldValue := reflect.ValueOf(t).Elem().FieldByIndex(profileFieldIndex(field))
+	user, err := json.Marshal(fieldValue.Interface())
+	if err != nil {
+		return err
+	}
+	newValue := reflect.New(fieldValue.Type())
+	if err := json.Unmarshal(raw, newValue.Interface()); err != nil {
+		return err
+	}
+	fieldValue.Set(newValue.Elem())
+	effective, err := json.Marshal(fieldValue.Interface())
+	if err != nil {
+		return err
+	}
+	if t.overridden == nil {
+		t.overridden = map[string]*overriddenValue{}
+	}
+	if previous, ok := t.overridden[field]; ok {
+		user = previous.user
+	}
+	t.overridden[field] = &overriddenValue{user: user, effective: effective}
+	t.setValueSource(field, layer, location)
+	return nil
+}
+
+// withoutLayers returns a copy of the profile without the values from the
+// project and environment layers. Values that were changed after they were
+// overridden (e.g. by `escape login`) are kept.
+func (t *EscapeConfigProfile) withoutLayers() *EscapeConfigProfile {
+	if len(t.overridden) == 0 {
+		return t
+	}
+	result := *t
+	v := reflect.ValueOf(&result).Elem()
+	for field, value := range t.overridden {
+		fieldValue := v.FieldByIndex(profileFieldIndex(field))
+		current, err := json.Marshal(fieldValue.Interface())
+		if err != nil || !bytes.Equal(current, value.effective) {
+			continue
+		}
+		newValue := reflect.New(fieldValue.Type())
+		if err := json.Unmarshal(value.user, newValue.Interface()); err == nil {
+			fieldValue.Set(newValue.Elem())
+		}
+	}
+	return &result
+}
+
+func (t *EscapeConfigProfile) setValueSource(field, layer, location string) {
+	if t.sources == nil {
+		t.sources = map[string]*ValueSource{}
+	}
+	t.sources[field] = &ValueSource{Layer: layer, Location: location}
+}
+
+// GetValueSource returns the layer that the effective value of the field
+// came from.
+func (t *EscapeConfigProfile) GetValueSource(field string) *ValueSource {
+	if source, ok := t.sources[field]; ok {
+		return source
+	}
+	value := reflect.ValueOf(t).Elem().FieldByIndex(profileFieldIndex(field))
+	if isZero(value) {
+		return &ValueSource{Layer: DefaultLayer}
+	}
+	location := ""
+	if t.parent != nil {
+		location = t.parent.saveLocation
+		for name, profile := range t.parent.Profiles {
+			if profile == t {
+				location += " (profile '" + name + "')"
+			}
+		}
+	}
+	return &ValueSource{Layer: UserLayer, Location: location}
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.Interface() == reflect.Zero(v.Type()).Interface()
+}
+
+// profileFields returns the JSON names of the profile fields in the order
+// in which they're declared.
+func profileFields() []string {
+	result := []string{}
+	typ := reflect.TypeOf(EscapeConfigProfile{})
+	for i := 0; i < typ.NumField(); i++ {
+		if name := jsonFieldName(typ.Field(i)); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+func profileFieldIndex(field string) []int {
+	typ := reflect.TypeOf(EscapeConfigProfile{})
+	for i := 0; i < typ.NumField(); i++ {
+		if jsonFieldName(typ.Field(i)) == field {
+			return typ.Field(i).Index
+		}
+	}
+	panic("Unknown profile field " + field)
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" || field.PkgPath != "" {
+		return ""
+	}
+	return name
+}
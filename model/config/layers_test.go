@@ -0,0 +1,216 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type suite struct{}
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&suite{})
+
+const testDir = "testdata_layers"
+
+var testEnvironmentVariables = []string{
+	"ESCAPE_API_SERVER", "ESCAPE_AUTH_TOKEN", "ESCAPE_INSECURE_SKIP_VERIFY",
+	"ESCAPE_PROXY_NAMESPACES", "ESCAPE_INVENTORY_TYPE", "BASIC_AUTH_USERNAME",
+	"ESCAPE_STATE_PATH",
+}
+
+func (s *suite) SetUpTest(c *C) {
+	os.RemoveAll(testDir)
+	c.Assert(os.MkdirAll(filepath.Join(testDir, "project", "sub"), 0755), IsNil)
+	for _, env := range testEnvironmentVariables {
+		os.Unsetenv(env)
+	}
+}
+
+func (s *suite) TearDownTest(c *C) {
+	os.RemoveAll(testDir)
+	for _, env := range testEnvironmentVariables {
+		os.Unsetenv(env)
+	}
+}
+
+func loadTestConfig(c *C) *EscapeConfig {
+	cfg := NewEscapeConfig()
+	c.Assert(cfg.LoadConfig(filepath.Join(testDir, "escape_config")), IsNil)
+	return cfg
+}
+
+func writeProjectConfig(c *C, content string) string {
+	path := filepath.Join(testDir, "project", ProjectConfigFile)
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+	return path
+}
+
+func (s *suite) Test_EnvironmentVariable(c *C) {
+	c.Assert(EnvironmentVariable("api_server"), Equals, "ESCAPE_API_SERVER")
+	c.Assert(EnvironmentVariable("escape_auth_token"), Equals, "ESCAPE_AUTH_TOKEN")
+	c.Assert(EnvironmentVariable("proxy_namespaces"), Equals, "ESCAPE_PROXY_NAMESPACES")
+}
+
+func (s *suite) Test_FindProjectConfig(c *C) {
+	c.Assert(FindProjectConfig(filepath.Join(testDir, "project", "sub")), Equals, "")
+	path := writeProjectConfig(c, "{}")
+	abs, _ := filepath.Abs(path)
+	c.Assert(FindProjectConfig(filepath.Join(testDir, "project", "sub")), Equals, abs)
+	c.Assert(FindProjectConfig(filepath.Join(testDir, "project")), Equals, abs)
+}
+
+func (s *suite) Test_ApplyLayers_without_layers(c *C) {
+	cfg := loadTestConfig(c)
+	c.Assert(cfg.ApplyLayers(testDir), IsNil)
+	profile := cfg.GetCurrentProfile()
+	c.Assert(profile.InventoryType, Equals, LocalInventory)
+	c.Assert(profile.GetValueSource("inventory_type").Layer, Equals, DefaultLayer)
+	c.Assert(profile.GetValueSource("escape_auth_token").Layer, Equals, DefaultLayer)
+
+	cfg = loadTestConfig(c)
+	c.Assert(cfg.ApplyLayers(testDir), IsNil)
+	source := cfg.GetCurrentProfile().GetValueSource("inventory_type")
+	c.Assert(source.Layer, Equals, UserLayer)
+	c.Assert(source.Location, Matches, ".*escape_config \\(profile 'default'\\)")
+}
+
+func (s *suite) Test_ApplyLayers_project_config(c *C) {
+	path := writeProjectConfig(c, `{"api_server": "https://inventory.example.com", "state_path": "state.json"}`)
+	cfg := loadTestConfig(c)
+	c.Assert(cfg.ApplyLayers(filepath.Join(testDir, "project", "sub")), IsNil)
+	profile := cfg.GetCurrentProfile()
+	c.Assert(profile.ApiServer, Equals, "https://inventory.example.com")
+	c.Assert(profile.StatePath, Equals, "state.json")
+	c.Assert(profile.InventoryType, Equals, RemoteInventory)
+	c.Assert(profile.ProxyNamespaces, HasLen, 0)
+	abs, _ := filepath.Abs(path)
+	c.Assert(profile.GetValueSource("api_server"), DeepEquals, &ValueSource{Layer: ProjectLayer, Location: abs})
+	c.Assert(profile.GetValueSource("inventory_type").Layer, Equals, ProjectLayer)
+}
+
+func (s *suite) Test_ApplyLayers_project_config_takes_precedence_over_derived_values(c *C) {
+	writeProjectConfig(c, `{"api_server": "https://inventory.example.com", "inventory_type": "local", "proxy_namespaces": ["examples"]}`)
+	cfg := loadTestConfig(c)
+	c.Assert(cfg.ApplyLayers(filepath.Join(testDir, "project")), IsNil)
+	profile := cfg.GetCurrentProfile()
+	c.Assert(profile.InventoryType, Equals, LocalInventory)
+	c.Assert(profile.ProxyNamespaces, DeepEquals, []string{"examples"})
+}
+
+func (s *suite) Test_ApplyLayers_fails_on_unknown_fields(c *C) {
+	writeProjectConfig(c, `{"api_servr": "https://inventory.example.com"}`)
+	cfg := loadTestConfig(c)
+	err := cfg.ApplyLayers(filepath.Join(testDir, "project"))
+	c.Assert(err, ErrorMatches, "Unknown field\\(s\\) 'api_servr' in project configuration file .*")
+}
+
+func (s *suite) Test_ApplyLayers_fails_on_fields_that_cant_be_set_by_the_project(c *C) {
+	writeProjectConfig(c, `{"escape_auth_token": "token", "credential_helper": "evil", "notifications": [], "inventories": []}`)
+	cfg := loadTestConfig(c)
+	err := cfg.ApplyLayers(filepath.Join(testDir, "project"))
+	c.Assert(err, ErrorMatches, "The field\\(s\\) 'escape_auth_token', 'credential_helper', 'inventories', 'notifications' can't be set in project configuration file .*")
+}
+
+func (s *suite) Test_ApplyLayers_project_api_server_drops_credentials(c *C) {
+	c.Assert(ioutil.WriteFile(filepath.Join(testDir, "escape_config"), []byte(`{
+    "current_profile": "default",
+    "profiles": {
+        "default": {
+            "api_server": "https://escape.example.com",
+            "escape_auth_token": "secret-token",
+            "basic_auth_username": "user",
+            "basic_auth_password": "pass"
+        }
+    }
+}`), 0600), IsNil)
+
+	writeProjectConfig(c, `{"api_server": "https://escape.example.com"}`)
+	cfg := loadTestConfig(c)
+	c.Assert(cfg.ApplyLayers(filepath.Join(testDir, "project")), IsNil)
+	c.Assert(cfg.GetCurrentProfile().AuthToken, Equals, "secret-token")
+
+	writeProjectConfig(c, `{"api_server": "https://attacker.example.com"}`)
+	cfg = loadTestConfig(c)
+	c.Assert(cfg.ApplyLayers(filepath.Join(testDir, "project")), IsNil)
+	profile := cfg.GetCurrentProfile()
+	c.Assert(profile.ApiServer, Equals, "https://attacker.example.com")
+	c.Assert(profile.AuthToken, Equals, "")
+	c.Assert(profile.BasicAuthUsername, Equals, "")
+	c.Assert(profile.BasicAuthPassword, Equals, "")
+	c.Assert(profile.GetValueSource("escape_auth_token").Layer, Equals, ProjectLayer)
+
+	// The user's credentials are kept in the configuration file.
+	c.Assert(cfg.Save(), IsNil)
+	profile = loadTestConfig(c).GetCurrentProfile()
+	c.Assert(profile.ApiServer, Equals, "https://escape.example.com")
+	c.Assert(profile.AuthToken, Equals, "secret-token")
+	c.Assert(profile.BasicAuthPassword, Equals, "pass")
+}
+
+func (s *suite) Test_ApplyLayers_environment_overrides_project(c *C) {
+	writeProjectConfig(c, `{"api_server": "https://inventory.example.com", "state_path": "state.json"}`)
+	os.Setenv("ESCAPE_API_SERVER", "https://other.example.com")
+	os.Setenv("ESCAPE_STATE_PATH", "other-state.json")
+	os.Setenv("ESCAPE_INSECURE_SKIP_VERIFY", "true")
+	os.Setenv("ESCAPE_PROXY_NAMESPACES", "a, b")
+	os.Setenv("BASIC_AUTH_USERNAME", "user")
+	cfg := loadTestConfig(c)
+	c.Assert(cfg.ApplyLayers(filepath.Join(testDir, "project")), IsNil)
+	profile := cfg.GetCurrentProfile()
+	c.Assert(profile.ApiServer, Equals, "https://other.example.com")
+	c.Assert(profile.InsecureSkipVerify, Equals, true)
+	c.Assert(profile.StatePath, Equals, "other-state.json")
+	c.Assert(profile.ProxyNamespaces, DeepEquals, []string{"a", "b"})
+	c.Assert(profile.BasicAuthUsername, Equals, "user")
+	c.Assert(profile.GetValueSource("api_server"), DeepEquals, &ValueSource{Layer: EnvironmentLayer, Location: "$ESCAPE_API_SERVER"})
+	c.Assert(profile.GetValueSource("basic_auth_username"), DeepEquals, &ValueSource{Layer: EnvironmentLayer, Location: "$BASIC_AUTH_USERNAME"})
+}
+
+func (s *suite) Test_ApplyLayers_fails_on_invalid_environment_values(c *C) {
+	os.Setenv("ESCAPE_INSECURE_SKIP_VERIFY", "maybe")
+	cfg := loadTestConfig(c)
+	err := cfg.ApplyLayers(testDir)
+	c.Assert(err, ErrorMatches, "Invalid value for environment variable 'ESCAPE_INSECURE_SKIP_VERIFY': expecting a boolean, got 'maybe'")
+}
+
+func (s *suite) Test_Save_doesnt_write_overridden_values(c *C) {
+	writeProjectConfig(c, `{"api_server": "https://inventory.example.com"}`)
+	os.Setenv("ESCAPE_AUTH_TOKEN", "secret-token")
+	cfg := loadTestConfig(c)
+	before, err := ioutil.ReadFile(filepath.Join(testDir, "escape_config"))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.ApplyLayers(filepath.Join(testDir, "project")), IsNil)
+	c.Assert(cfg.Save(), IsNil)
+	after, err := ioutil.ReadFile(filepath.Join(testDir, "escape_config"))
+	c.Assert(err, IsNil)
+	c.Assert(string(after), Equals, string(before))
+
+	// Values that are changed after they've been overridden are saved.
+	cfg.GetCurrentProfile().SetApiServer("https://login.example.com")
+	c.Assert(cfg.Save(), IsNil)
+	cfg = loadTestConfig(c)
+	c.Assert(cfg.GetCurrentProfile().ApiServer, Equals, "https://login.example.com")
+	c.Assert(cfg.GetCurrentProfile().AuthToken, Equals, "")
+	c.Assert(cfg.GetCurrentProfile().InventoryType, Equals, LocalInventory)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/ankyra/escape/model/notifications"
+)
+
+type NotificationType string
+
+var WebhookNotification NotificationType = "webhook"
+var CommandNotification NotificationType = "command"
+var SMTPNotification NotificationType = "smtp"
+
+// A NotificationConfig configures a sink that is notified when the status
+// of a deployment stage changes (see the "notifications" field in
+// EscapeConfigProfile).
+type NotificationConfig struct {
+	Type NotificationType `json:"type"`
+
+	// Glob patterns of the environments and deployments to notify about,
+	// and the statuses to notify on. By default all environments and
+	// deployments are notified on the ok, failure, test_failure and
+	// destroy_failure statuses.
+	Environments []string `json:"environments,omitempty"`
+	Deployments  []string `json:"deployments,omitempty"`
+	Statuses     []string `json:"statuses,omitempty"`
+
+	// Webhook
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Command
+	Command []string `json:"command,omitempty"`
+
+	// SMTP
+	SMTPServer   string   `json:"smtp_server,omitempty"`
+	SMTPUsername string   `json:"smtp_username,omitempty"`
+	SMTPPassword string   `json:"smtp_password,omitempty"`
+	From         string   `json:"from,omitempty"`
+	To           []string `json:"to,omitempty"`
+}
+
+func (n *NotificationConfig) Validate() error {
+	switch n.Type {
+	case WebhookNotification:
+		if n.URL == "" {
+			return fmt.Errorf("Missing 'url' for webhook notification.")
+		}
+	case CommandNotification:
+		if len(n.Command) == 0 {
+			return fmt.Errorf("Missing 'command' for command notification.")
+		}
+	case SMTPNotification:
+		if n.SMTPServer == "" {
+			return fmt.Errorf("Missing 'smtp_server' for smtp notification.")
+		}
+		if n.From == "" || len(n.To) == 0 {
+			return fmt.Errorf("Missing 'from' or 'to' for smtp notification.")
+		}
+	default:
+		return fmt.Errorf("Unknown notification type '%s'. Expecting one of: %s, %s, %s", n.Type, WebhookNotification, CommandNotification, SMTPNotification)
+	}
+	return nil
+}
+
+func (n *NotificationConfig) GetNotifier() *notifications.Notifier {
+	var sink notifications.Sink
+	switch n.Type {
+	case WebhookNotification:
+		sink = notifications.NewWebhookSink(n.URL, n.Headers)
+	case CommandNotification:
+		sink = notifications.NewCommandSink(n.Command)
+	case SMTPNotification:
+		sink = notifications.NewSMTPSink(n.SMTPServer, n.SMTPUsername, n.SMTPPassword, n.From, n.To)
+	}
+	return notifications.NewNotifier(sink, &notifications.Filter{
+		Environments: n.Environments,
+		Deployments:  n.Deployments,
+		Statuses:     n.Statuses,
+	})
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *suite) Test_NotificationConfig_Validate(c *C) {
+	cases := map[string]*NotificationConfig{
+		"Unknown notification type 'pager'.*":           &NotificationConfig{Type: "pager"},
+		"Missing 'url' for webhook notification.":       &NotificationConfig{Type: WebhookNotification},
+		"Missing 'command' for command notification.":   &NotificationConfig{Type: CommandNotification},
+		"Missing 'smtp_server' for smtp notification.":  &NotificationConfig{Type: SMTPNotification},
+		"Missing 'from' or 'to' for smtp notification.": &NotificationConfig{Type: SMTPNotification, SMTPServer: "localhost:25"},
+	}
+	for expected, cfg := range cases {
+		c.Assert(cfg.Validate(), ErrorMatches, expected)
+	}
+	valid := []*NotificationConfig{
+		&NotificationConfig{Type: WebhookNotification, URL: "http://localhost/hook"},
+		&NotificationConfig{Type: CommandNotification, Command: []string{"notify-send"}},
+		&NotificationConfig{Type: SMTPNotification, SMTPServer: "localhost:25", From: "escape@localhost", To: []string{"ops@localhost"}},
+	}
+	for _, cfg := range valid {
+		c.Assert(cfg.Validate(), IsNil)
+	}
+}
+
+func (s *suite) Test_Profile_GetNotifiers(c *C) {
+	profile := newEscapeConfigProfile(NewEscapeConfig())
+	c.Assert(profile.GetNotifiers(), HasLen, 0)
+	profile.Notifications = []*NotificationConfig{
+		&NotificationConfig{Type: WebhookNotification, URL: "http://localhost/hook", Environments: []string{"prod"}},
+		&NotificationConfig{Type: "pager"},
+	}
+	c.Assert(profile.Validate(), ErrorMatches, "Invalid notification #2: Unknown notification type 'pager'.*")
+	notifiers := profile.GetNotifiers()
+	c.Assert(notifiers, HasLen, 2)
+	c.Assert(notifiers[0].Sink.String(), Equals, "webhook http://localhost/hook")
+	c.Assert(notifiers[0].Filter.Environments, DeepEquals, []string{"prod"})
+}
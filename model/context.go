@@ -25,6 +25,7 @@ import (
 	"github.com/ankyra/escape/model/config"
 	"github.com/ankyra/escape/model/escape_plan"
 	"github.com/ankyra/escape/model/inventory/types"
+	"github.com/ankyra/escape/model/notifications"
 	"github.com/ankyra/escape/model/paths"
 	"github.com/ankyra/escape/model/state"
 	"github.com/ankyra/escape/util/logger/api"
@@ -40,6 +41,7 @@ type Context struct {
 	LogConsumers       []api.LogConsumer
 	DependencyMetadata map[string]*core.ReleaseMetadata
 	RootDeploymentName string
+	ExtensionReport    *compiler.ExtensionReport
 }
 
 func NewContext() *Context {
@@ -139,6 +141,9 @@ func (c *Context) SetRootDeploymentName(name string) {
 	c.RootDeploymentName = name
 }
 
+// QueryReleaseMetadata asks the Inventory for the release metadata, and logs
+// a warning if the release has been deprecated or yanked. The metadata is
+// cached, so the warning is only logged once per release.
 func (c *Context) QueryReleaseMetadata(dep *core.DependencyConfig) (*core.ReleaseMetadata, error) {
 	metadata, ok := c.DependencyMetadata[dep.ReleaseId]
 	if ok {
@@ -148,10 +153,15 @@ func (c *Context) QueryReleaseMetadata(dep *core.DependencyConfig) (*core.Releas
 	if err != nil {
 		return nil, err
 	}
+	c.WarnIfDeprecatedOrYanked(metadata)
 	c.DependencyMetadata[dep.ReleaseId] = metadata
 	return metadata, nil
 }
 
+// GetDependencyMetadata fetches the dependency and returns the metadata from
+// its release archive. Dependencies whose version had to be resolved have
+// already been checked for deprecation by QueryReleaseMetadata; the archive
+// itself doesn't know whether a release has been deprecated or yanked since.
 func (c *Context) GetDependencyMetadata(dep *core.DependencyConfig) (*core.ReleaseMetadata, error) {
 	metadata, ok := c.DependencyMetadata[dep.ReleaseId]
 	if ok {
@@ -166,6 +176,33 @@ func (c *Context) GetDependencyMetadata(dep *core.DependencyConfig) (*core.Relea
 	return metadata, nil
 }
 
+// CheckReleaseStatus asks the Inventory whether a release has been deprecated
+// or yanked, and logs a warning if it has. This is only needed for releases
+// that weren't looked up with QueryReleaseMetadata. Errors are ignored,
+// because the release may not be available in the Inventory at all (e.g.
+// when it was built locally).
+func (c *Context) CheckReleaseStatus(project, name, version string) {
+	metadata, err := c.GetInventory().QueryReleaseMetadata(project, name, "v"+version)
+	if err != nil {
+		return
+	}
+	c.WarnIfDeprecatedOrYanked(metadata)
+}
+
+func (c *Context) WarnIfDeprecatedOrYanked(metadata *core.ReleaseMetadata) {
+	if metadata.Yanked {
+		c.Log("release.yanked", map[string]string{
+			"release": metadata.GetQualifiedReleaseId(),
+			"reason":  metadata.YankedReason,
+		})
+	} else if metadata.Deprecated {
+		c.Log("release.deprecated", map[string]string{
+			"release": metadata.GetQualifiedReleaseId(),
+			"reason":  metadata.DeprecatedReason,
+		})
+	}
+}
+
 func (c *Context) fetchDependencyAndReadMetadata(depCfg *core.DependencyConfig) (*core.ReleaseMetadata, error) {
 	depReleaseId := depCfg.ReleaseId
 	c.Log("fetch.start", map[string]string{"dependency": depReleaseId})
@@ -182,7 +219,10 @@ func (c *Context) fetchDependencyAndReadMetadata(depCfg *core.DependencyConfig)
 	return core.NewReleaseMetadataFromFile(unpacked)
 }
 
-func (c *Context) LoadEscapeConfig(cfgFile, cfgProfile string) error {
+// LoadEscapeConfig loads the user's configuration file and merges the
+// project configuration (found by walking up from projectDir) and the
+// environment over the active profile.
+func (c *Context) LoadEscapeConfig(cfgFile, cfgProfile, projectDir string) error {
 	err := c.EscapeConfig.LoadConfig(cfgFile)
 	if err != nil {
 		return err
@@ -193,7 +233,7 @@ func (c *Context) LoadEscapeConfig(cfgFile, cfgProfile string) error {
 		return err
 	}
 
-	return nil
+	return c.EscapeConfig.ApplyLayers(projectDir)
 }
 
 func (c *Context) LoadEscapePlan(cfgFile string) error {
@@ -207,7 +247,7 @@ func (c *Context) LoadEscapePlan(cfgFile string) error {
 
 func (c *Context) CompileEscapePlan() error {
 	c.PushLogSection("Compile")
-	metadata, err := compiler.Compile(
+	metadata, report, err := compiler.CompileWithExtensionReport(
 		c.EscapePlan,
 		c.GetInventory(),
 		c.GetDependencyMetadata,
@@ -218,6 +258,7 @@ func (c *Context) CompileEscapePlan() error {
 		return err
 	}
 	c.ReleaseMetadata = metadata
+	c.ExtensionReport = report
 	c.PopLogSection()
 	return nil
 }
@@ -234,14 +275,20 @@ func (c *Context) LoadLocalState(stateFile, environment string, useProfileState
 		return errors.New("Empty environment state")
 	}
 	c.EnvironmentState = envState
+	c.addNotificationListener()
 	return nil
 }
 
 func (c *Context) LoadRemoteState(project, environment string) error {
-	apiServer := c.EscapeConfig.GetCurrentProfile().GetApiServer()
-	escapeToken := c.EscapeConfig.GetCurrentProfile().GetAuthToken()
-	insecureSkipVerify := c.EscapeConfig.GetCurrentProfile().GetInsecureSkipVerify()
-	envState, err := state.NewRemoteStateProvider(apiServer, escapeToken, insecureSkipVerify).Load(project, environment)
+	profile := c.EscapeConfig.GetCurrentProfile()
+	if err := profile.LoadCredentials(); err != nil {
+		return err
+	}
+	apiServer := profile.GetApiServer()
+	escapeToken := profile.GetAuthToken()
+	insecureSkipVerify := profile.GetInsecureSkipVerify()
+	provider := state.NewRemoteStateProvider(apiServer, escapeToken, profile.BasicAuthUsername, profile.BasicAuthPassword, insecureSkipVerify)
+	envState, err := provider.Load(project, environment)
 	if err != nil {
 		return err
 	}
@@ -249,9 +296,28 @@ func (c *Context) LoadRemoteState(project, environment string) error {
 		return errors.New("Empty environment state")
 	}
 	c.EnvironmentState = envState
+	c.addNotificationListener()
 	return nil
 }
 
+// addNotificationListener sends the status changes of the deployments in
+// the loaded state to the notification sinks that are configured in the
+// profile.
+func (c *Context) addNotificationListener() {
+	profile := c.EscapeConfig.GetCurrentProfile()
+	project := c.EnvironmentState.Project
+	if profile == nil || project == nil || len(profile.Notifications) == 0 {
+		return
+	}
+	listener := notifications.NewStatusListener(profile.GetNotifiers(), func(n *notifications.Notifier, err error) {
+		c.Log("notification.failed", map[string]string{
+			"sink":  n.Sink.String(),
+			"error": err.Error(),
+		})
+	})
+	project.StatusListeners = append(project.StatusListeners, listener)
+}
+
 func (c *Context) LoadReleaseJson() error {
 	m, err := core.NewReleaseMetadataFromFile("release.json")
 	if err != nil {
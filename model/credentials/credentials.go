@@ -0,0 +1,54 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"errors"
+)
+
+// The Username used for Escape auth tokens, which don't have a username.
+const TokenUsername = "<token>"
+
+var ErrCredentialsNotFound = errors.New("credentials not found")
+
+type Credentials struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+func (c *Credentials) IsToken() bool {
+	return c.Username == TokenUsername
+}
+
+// A Helper stores credentials outside of the Escape configuration file.
+type Helper interface {
+	// Get returns ErrCredentialsNotFound if there are no credentials for the server.
+	Get(serverURL string) (*Credentials, error)
+	Store(creds *Credentials) error
+	Erase(serverURL string) error
+}
+
+// NewHelper returns the built-in encrypted file store for the name "file",
+// which keeps its data in storePath. Any other name refers to an external
+// `escape-credential-<name>` program.
+func NewHelper(name, storePath string) Helper {
+	if name == "file" {
+		return NewFileStore(storePath)
+	}
+	return NewExternalHelper(name)
+}
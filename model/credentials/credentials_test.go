@@ -0,0 +1,142 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type suite struct{}
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&suite{})
+
+const testDir = "testdata_credentials"
+
+func (s *suite) SetUpTest(c *C) {
+	os.RemoveAll(testDir)
+	c.Assert(os.MkdirAll(testDir, 0755), IsNil)
+	os.Setenv(PassphraseEnvVar, "test passphrase")
+}
+
+func (s *suite) TearDownTest(c *C) {
+	os.RemoveAll(testDir)
+	os.Unsetenv(PassphraseEnvVar)
+}
+
+func (s *suite) Test_FileStore_reads_existing_files(c *C) {
+	unit := NewFileStore(filepath.Join("testdata", "credentials_v1"))
+	creds, err := unit.Get("https://escape.example.com")
+	c.Assert(err, IsNil)
+	c.Assert(creds.Username, Equals, "user")
+	c.Assert(creds.Secret, Equals, "secret")
+}
+
+func (s *suite) Test_FileStore(c *C) {
+	path := filepath.Join(testDir, "credentials")
+	unit := NewFileStore(path)
+	_, err := unit.Get("https://escape.example.com")
+	c.Assert(err, Equals, ErrCredentialsNotFound)
+
+	creds := &Credentials{ServerURL: "https://escape.example.com", Username: "user", Secret: "secret"}
+	c.Assert(unit.Store(creds), IsNil)
+	c.Assert(unit.Store(&Credentials{ServerURL: "https://other.example.com", Username: TokenUsername, Secret: "token"}), IsNil)
+
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(content), "secret"), Equals, false)
+
+	result, err := unit.Get("https://escape.example.com")
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, creds)
+	c.Assert(result.IsToken(), Equals, false)
+
+	c.Assert(unit.Erase("https://escape.example.com"), IsNil)
+	_, err = unit.Get("https://escape.example.com")
+	c.Assert(err, Equals, ErrCredentialsNotFound)
+
+	result, err = unit.Get("https://other.example.com")
+	c.Assert(err, IsNil)
+	c.Assert(result.IsToken(), Equals, true)
+}
+
+func (s *suite) Test_FileStore_needs_the_right_passphrase(c *C) {
+	unit := NewFileStore(filepath.Join(testDir, "credentials"))
+	c.Assert(unit.Store(&Credentials{ServerURL: "url", Username: "user", Secret: "secret"}), IsNil)
+
+	os.Setenv(PassphraseEnvVar, "wrong")
+	_, err := unit.Get("url")
+	c.Assert(err, ErrorMatches, "Could not decrypt credential store .*")
+
+	os.Unsetenv(PassphraseEnvVar)
+	_, err = unit.Get("url")
+	c.Assert(err, ErrorMatches, "The credential store requires a passphrase.*")
+}
+
+// installHelper puts an `escape-credential-test` script on the PATH that
+// keeps the credentials for a single server in a file.
+func installHelper(c *C) func() {
+	dir, err := filepath.Abs(testDir)
+	c.Assert(err, IsNil)
+	script := `#!/bin/sh
+store="` + dir + `/helper-store"
+case "$1" in
+  get)
+    if [ ! -f "$store" ]; then echo "credentials not found in store"; exit 1; fi
+    cat "$store" ;;
+  store) cat > "$store" ;;
+  erase) rm -f "$store" ;;
+  *) echo "unknown action $1" >&2; exit 1 ;;
+esac
+`
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "escape-credential-test"), []byte(script), 0755), IsNil)
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+	return func() {
+		os.Setenv("PATH", oldPath)
+	}
+}
+
+func (s *suite) Test_ExternalHelper(c *C) {
+	defer installHelper(c)()
+	unit := NewHelper("test", "")
+	_, err := unit.Get("https://escape.example.com")
+	c.Assert(err, Equals, ErrCredentialsNotFound)
+
+	creds := &Credentials{ServerURL: "https://escape.example.com", Username: TokenUsername, Secret: "token"}
+	c.Assert(unit.Store(creds), IsNil)
+	result, err := unit.Get("https://escape.example.com")
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, creds)
+
+	c.Assert(unit.Erase("https://escape.example.com"), IsNil)
+	_, err = unit.Get("https://escape.example.com")
+	c.Assert(err, Equals, ErrCredentialsNotFound)
+}
+
+func (s *suite) Test_ExternalHelper_missing_program(c *C) {
+	unit := NewHelper("does-not-exist", "")
+	_, err := unit.Get("url")
+	c.Assert(err, ErrorMatches, "Credential helper 'escape-credential-does-not-exist' failed to get credentials: .*")
+}
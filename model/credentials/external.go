@@ -0,0 +1,97 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// An ExternalHelper runs `escape-credential-<name> <action>` with the
+// following protocol, which is compatible with Docker credential helpers:
+//
+//	get:   reads the server URL from stdin, writes the credentials as JSON
+//	       ({"ServerURL": "", "Username": "", "Secret": ""}) to stdout.
+//	       Exits non-zero and prints "credentials not found" if there are
+//	       none.
+//	store: reads the credentials as JSON from stdin.
+//	erase: reads the server URL from stdin.
+type ExternalHelper struct {
+	Name string
+}
+
+func NewExternalHelper(name string) *ExternalHelper {
+	return &ExternalHelper{
+		Name: name,
+	}
+}
+
+func (e *ExternalHelper) Program() string {
+	return "escape-credential-" + e.Name
+}
+
+func (e *ExternalHelper) run(action string, input []byte) ([]byte, error) {
+	cmd := exec.Command(e.Program(), action)
+	cmd.Stdin = bytes.NewReader(input)
+	stdout := bytes.NewBuffer([]byte{})
+	stderr := bytes.NewBuffer([]byte{})
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(stdout.String() + "\n" + stderr.String())
+		if action != "store" && strings.Contains(strings.ToLower(output), ErrCredentialsNotFound.Error()) {
+			return nil, ErrCredentialsNotFound
+		}
+		if output == "" {
+			output = err.Error()
+		}
+		return nil, fmt.Errorf("Credential helper '%s' failed to %s credentials: %s", e.Program(), action, output)
+	}
+	return stdout.Bytes(), nil
+}
+
+func (e *ExternalHelper) Get(serverURL string) (*Credentials, error) {
+	output, err := e.run("get", []byte(serverURL))
+	if err != nil {
+		return nil, err
+	}
+	creds := &Credentials{}
+	if err := json.Unmarshal(output, creds); err != nil {
+		return nil, fmt.Errorf("Credential helper '%s' returned invalid credentials: %s", e.Program(), err.Error())
+	}
+	if creds.ServerURL == "" {
+		creds.ServerURL = serverURL
+	}
+	return creds, nil
+}
+
+func (e *ExternalHelper) Store(creds *Credentials) error {
+	input, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	_, err = e.run("store", input)
+	return err
+}
+
+func (e *ExternalHelper) Erase(serverURL string) error {
+	_, err := e.run("erase", []byte(serverURL))
+	return err
+}
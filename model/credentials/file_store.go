@@ -0,0 +1,173 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/ankyra/escape/util"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// The environment variable holding the passphrase for the FileStore.
+const PassphraseEnvVar = "ESCAPE_CREDENTIALS_PASSPHRASE"
+
+const keyDerivationIterations = 100000
+
+// The FileStore keeps credentials in a file encrypted with AES-256-GCM. The
+// key is derived from the passphrase in $ESCAPE_CREDENTIALS_PASSPHRASE.
+type FileStore struct {
+	Path string
+}
+
+type encryptedFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		Path: path,
+	}
+}
+
+func (f *FileStore) Get(serverURL string) (*Credentials, error) {
+	if !util.PathExists(f.Path) {
+		return nil, ErrCredentialsNotFound
+	}
+	all, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	creds, ok := all[serverURL]
+	if !ok {
+		return nil, ErrCredentialsNotFound
+	}
+	return creds, nil
+}
+
+func (f *FileStore) Store(creds *Credentials) error {
+	all := map[string]*Credentials{}
+	if util.PathExists(f.Path) {
+		var err error
+		all, err = f.load()
+		if err != nil {
+			return err
+		}
+	}
+	all[creds.ServerURL] = creds
+	return f.save(all)
+}
+
+func (f *FileStore) Erase(serverURL string) error {
+	if !util.PathExists(f.Path) {
+		return nil
+	}
+	all, err := f.load()
+	if err != nil {
+		return err
+	}
+	delete(all, serverURL)
+	return f.save(all)
+}
+
+func getPassphrase() (string, error) {
+	passphrase := os.Getenv(PassphraseEnvVar)
+	if passphrase == "" {
+		return "", fmt.Errorf("The credential store requires a passphrase. Please set the %s environment variable.", PassphraseEnvVar)
+	}
+	return passphrase, nil
+}
+
+func (f *FileStore) load() (map[string]*Credentials, error) {
+	passphrase, err := getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	file := &encryptedFile{}
+	if err := json.Unmarshal(content, file); err != nil {
+		return nil, fmt.Errorf("Could not read credential store '%s': %s", f.Path, err.Error())
+	}
+	gcm, err := newGCM(passphrase, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Could not decrypt credential store '%s'. Is %s set to the right passphrase?", f.Path, PassphraseEnvVar)
+	}
+	result := map[string]*Credentials{}
+	if err := json.Unmarshal(plaintext, &result); err != nil {
+		return nil, fmt.Errorf("Could not read credential store '%s': %s", f.Path, err.Error())
+	}
+	return result, nil
+}
+
+func (f *FileStore) save(all map[string]*Credentials) error {
+	passphrase, err := getPassphrase()
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	file := &encryptedFile{
+		Version: 1,
+		Salt:    make([]byte, 16),
+	}
+	if _, err := io.ReadFull(rand.Reader, file.Salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(passphrase, file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, file.Nonce); err != nil {
+		return err
+	}
+	file.Data = gcm.Seal(nil, file.Nonce, plaintext, nil)
+	content, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(f.Path, content, 0600)
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key := pbkdf2.Key([]byte(passphrase), salt, keyDerivationIterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
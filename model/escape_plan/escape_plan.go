@@ -105,6 +105,21 @@ type EscapePlan struct {
 
 	Extends []string `yaml:"extends,omitempty"`
 
+	// Decides what happens when more than one extension defines the same
+	// item. The keys are the fields `consumes`, `provides`, `inputs`,
+	// `outputs`, `templates`, `metadata`, `errands` and `stages`. The values
+	// are one of `override` (the last extension wins), `append` (the first
+	// extension wins) or `error` (the build fails). Definitions in the
+	// Escape plan itself always take precedence over its extensions.
+	//
+	// Example:
+	//
+	//   extension_merge:
+	//     inputs: error
+	//     errands: override
+	//
+	ExtensionMerge map[string]string `yaml:"extension_merge,omitempty"`
+
 	// The files to includes in this release. The files don't have to exist and can
 	// be produced during build time. Globbing patterns are supported. Directories
 	// are added recursively.
@@ -206,6 +221,23 @@ type EscapePlan struct {
 	// Post-destroy script.
 	PostDestroy interface{} `yaml:"post_destroy,omitempty"`
 
+	// Hooks that run before or after a step, or when a step fails. The keys
+	// are hook points (`before_<step>`, `after_<step>` or `on_failure`), the
+	// values are lists of scripts. Unlike the scripts above, hooks from
+	// extensions are not replaced by the hooks in this plan; they all run,
+	// starting with the extension hooks. See [Hooks](/docs/reference/hooks/).
+	//
+	// Example:
+	//
+	//   hooks:
+	//     before_deploy:
+	//     - audit.sh
+	//     on_failure:
+	//     - script: notify.sh
+	//       fatal: false
+	//
+	Hooks map[string][]interface{} `yaml:"hooks,omitempty"`
+
 	// Errands are scripts that can be run against the deployment of this release.
 	// The scripts receive the deployment's inputs and outputs as environment
 	// variables.
@@ -0,0 +1,179 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package escape_plan
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Format formats an Escape plan without going through the EscapePlan struct,
+// so that comments and the order of nested fields are preserved. The top
+// level fields are put in the order of Fields, fields that are missing are
+// added with an empty value, and indentation and blank lines are normalised.
+func Format(content []byte) ([]byte, error) {
+	return formatPlan(content, false)
+}
+
+// Minify formats an Escape plan like Format, but leaves out the fields that
+// are empty (unless they have a comment) and the blank lines.
+func Minify(content []byte) ([]byte, error) {
+	return formatPlan(content, true)
+}
+
+func formatPlan(content []byte, minify bool) ([]byte, error) {
+	if err := yaml.Unmarshal(content, &map[interface{}]interface{}{}); err != nil {
+		return nil, fmt.Errorf("Couldn't parse Escape plan: %s", err.Error())
+	}
+	nodes, footer := parseYamlNodes(string(content))
+	header := []string{}
+	if len(nodes) > 0 {
+		header, nodes[0].Comments = splitHeader(nodes[0].Comments)
+	}
+	fields := map[string]*yamlNode{}
+	for _, node := range nodes {
+		if node.Item || node.Key == "" {
+			return nil, fmt.Errorf("Expecting a map at the top level of the Escape plan, got '%s'", strings.TrimSpace(node.Line))
+		}
+		if _, ok := fields[node.Key]; ok {
+			return nil, fmt.Errorf("Duplicate field '%s' in the Escape plan", node.Key)
+		}
+		fields[node.Key] = node
+	}
+	ordered := []*yamlNode{}
+	for _, key := range Fields {
+		node, ok := fields[key]
+		if !ok && !minify {
+			empty := string(NewPrettyPrinter().prettyPrintValue(key, nil))
+			node = &yamlNode{Key: key, Line: empty}
+		}
+		if node != nil && !(minify && isEmptyYamlNode(node)) {
+			ordered = append(ordered, node)
+		}
+	}
+	for _, node := range nodes {
+		if !isField(node.Key) {
+			ordered = append(ordered, node)
+		}
+	}
+
+	separator := []string{""}
+	if minify {
+		separator = []string{}
+	}
+	sections := [][]string{}
+	if comments := renderYamlComments(header, "", !minify); len(comments) > 0 {
+		sections = append(sections, comments)
+	}
+	for _, node := range ordered {
+		node.Comments = trimBlankLines(node.Comments)
+		sections = append(sections, renderYamlNodes([]*yamlNode{node}, nil, 0, !minify))
+	}
+	if comments := renderYamlComments(trimBlankLines(footer), "", !minify); len(comments) > 0 {
+		sections = append(sections, comments)
+	}
+	lines := []string{}
+	for i, section := range sections {
+		if i > 0 {
+			lines = append(lines, separator...)
+		}
+		lines = append(lines, section...)
+	}
+	result := []byte(strings.Join(lines, "\n") + "\n")
+	if len(lines) == 0 {
+		result = []byte{}
+	}
+	if err := checkFormatted(content, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// splitHeader splits off the comments at the start of the file that are
+// separated from the first field by a blank line. They stay at the top.
+func splitHeader(comments []string) ([]string, []string) {
+	for i := len(comments) - 1; i >= 0; i-- {
+		if comments[i] == "" {
+			return trimBlankLines(comments[:i]), comments[i+1:]
+		}
+	}
+	return []string{}, comments
+}
+
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func isField(key string) bool {
+	for _, field := range Fields {
+		if field == key {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmptyYamlNode(node *yamlNode) bool {
+	if len(trimBlankLines(node.Comments)) > 0 || len(node.Children) > 0 || len(node.Verbatim) > 0 || len(node.Footer) > 0 {
+		return false
+	}
+	_, value := splitYamlKey(node.Line)
+	switch value {
+	case "", `""`, "''", "~", "null", "[]", "{}":
+		return true
+	}
+	return false
+}
+
+// checkFormatted makes sure that the formatted plan has the same values as
+// the original, apart from empty fields.
+func checkFormatted(original, formatted []byte) error {
+	before := map[interface{}]interface{}{}
+	yaml.Unmarshal(original, &before)
+	after := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(formatted, &after); err != nil {
+		return fmt.Errorf("Formatting the Escape plan failed: %s", err.Error())
+	}
+	if !reflect.DeepEqual(withoutEmptyValues(before), withoutEmptyValues(after)) {
+		return fmt.Errorf("Formatting the Escape plan failed: the formatted plan has different values")
+	}
+	return nil
+}
+
+func withoutEmptyValues(plan map[interface{}]interface{}) map[interface{}]interface{} {
+	result := map[interface{}]interface{}{}
+	for key, val := range plan {
+		if val == nil || val == "" {
+			continue
+		}
+		v := reflect.ValueOf(val)
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0 {
+			continue
+		}
+		result[key] = val
+	}
+	return result
+}
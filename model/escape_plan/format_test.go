@@ -0,0 +1,123 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package escape_plan
+
+import (
+	"io/ioutil"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *planSuite) readFormatFixture(c *C, name string) []byte {
+	content, err := ioutil.ReadFile("testdata/" + name)
+	c.Assert(err, IsNil)
+	return content
+}
+
+func (s *planSuite) Test_Format(c *C) {
+	formatted, err := Format(s.readFormatFixture(c, "format_input.yml"))
+	c.Assert(err, IsNil)
+	c.Assert(string(formatted), Equals, string(s.readFormatFixture(c, "format_formatted.yml")))
+}
+
+func (s *planSuite) Test_Format_is_idempotent(c *C) {
+	expected := s.readFormatFixture(c, "format_formatted.yml")
+	formatted, err := Format(expected)
+	c.Assert(err, IsNil)
+	c.Assert(string(formatted), Equals, string(expected))
+}
+
+func (s *planSuite) Test_Minify(c *C) {
+	minified, err := Minify(s.readFormatFixture(c, "format_input.yml"))
+	c.Assert(err, IsNil)
+	c.Assert(string(minified), Equals, string(s.readFormatFixture(c, "format_minified.yml")))
+
+	minified, err = Minify(s.readFormatFixture(c, "format_formatted.yml"))
+	c.Assert(err, IsNil)
+	c.Assert(string(minified), Equals, string(s.readFormatFixture(c, "format_minified.yml")))
+}
+
+func (s *planSuite) Test_Format_uses_the_canonical_field_order(c *C) {
+	minified, err := Minify([]byte("build: build.sh\nversion: 1.0\ncustom: field\nname: test\n"))
+	c.Assert(err, IsNil)
+	c.Assert(string(minified), Equals, "name: test\nversion: 1.0\nbuild: build.sh\ncustom: field\n")
+}
+
+func (s *planSuite) Test_Format_keeps_comments(c *C) {
+	minified, err := Minify([]byte(`version: 1.0 # the version
+# The name
+name: test
+inputs:
+    # first
+    - a
+    # second
+    - b
+# Nothing to deploy yet
+deploy:
+# The end
+`))
+	c.Assert(err, IsNil)
+	c.Assert(string(minified), Equals, `# The name
+name: test
+version: 1.0 # the version
+inputs:
+# first
+- a
+# second
+- b
+# Nothing to deploy yet
+deploy:
+# The end
+`)
+}
+
+func (s *planSuite) Test_Format_keeps_block_scalars(c *C) {
+	minified, err := Minify([]byte(`name: test
+version: 1.0
+description: |+
+      First line
+        indented
+
+      Last line
+
+deploy:
+    inline: >-
+        echo "hello"
+        echo "world"
+`))
+	c.Assert(err, IsNil)
+	c.Assert(string(minified), Equals, `name: test
+version: 1.0
+description: |+
+  First line
+    indented
+
+  Last line
+
+deploy:
+  inline: >-
+    echo "hello"
+    echo "world"
+`)
+}
+
+func (s *planSuite) Test_Format_fails_if_not_a_map(c *C) {
+	_, err := Format([]byte("- name: test\n"))
+	c.Assert(err, ErrorMatches, "Couldn't parse Escape plan: yaml: unmarshal errors:\n.*")
+	_, err = Format([]byte("name: [test\n"))
+	c.Assert(err, ErrorMatches, "Couldn't parse Escape plan: yaml: line 1: .*")
+}
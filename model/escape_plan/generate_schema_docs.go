@@ -0,0 +1,124 @@
+//go:build ignore
+// +build ignore
+
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Generates schema_docs.go from the doc comments on the Escape plan and the
+// core types that it references, so that they can be used as descriptions in
+// the JSON Schema. Run with `go generate` in this directory.
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+const corePath = "../../vendor/github.com/ankyra/escape-core/"
+
+var Sources = map[string][]string{
+	"escape_plan.go":                    []string{"EscapePlan"},
+	corePath + "dependency_config.go":   []string{"DependencyConfig"},
+	corePath + "consumer.go":            []string{"ConsumerConfig"},
+	corePath + "download_config.go":     []string{"DownloadConfig"},
+	corePath + "errand.go":              []string{"Errand"},
+	corePath + "exec_stage.go":          []string{"ExecStage"},
+	corePath + "hook.go":                []string{"Hook"},
+	corePath + "templates/templates.go": []string{"Template"},
+	corePath + "variables/variable.go":  []string{"Variable"},
+}
+
+const FileTemplate = `// Code generated by generate_schema_docs.go. DO NOT EDIT.
+
+package escape_plan
+
+var schemaDocs = map[string]string{
+%s}
+`
+
+func CleanDoc(doc string) string {
+	return strings.TrimSpace(doc)
+}
+
+func ParseDocs(filename string, structNames []string, docs map[string]string) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+	wanted := map[string]bool{}
+	for _, name := range structNames {
+		wanted[name] = true
+	}
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			s, ok := spec.(*ast.TypeSpec)
+			if !ok || !wanted[s.Name.String()] {
+				continue
+			}
+			structType, ok := s.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			if doc := CleanDoc(gen.Doc.Text()); doc != "" {
+				docs[s.Name.String()] = doc
+			}
+			for _, field := range structType.Fields.List {
+				doc := CleanDoc(field.Doc.Text())
+				if doc == "" {
+					continue
+				}
+				for _, name := range field.Names {
+					docs[s.Name.String()+"."+name.String()] = doc
+				}
+			}
+		}
+	}
+}
+
+func main() {
+	docs := map[string]string{}
+	for filename, structNames := range Sources {
+		ParseDocs(filename, structNames, docs)
+	}
+	keys := []string{}
+	for key := range docs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	entries := ""
+	for _, key := range keys {
+		entries += fmt.Sprintf("\t%q: %q,\n", key, docs[key])
+	}
+	src, err := format.Source([]byte(fmt.Sprintf(FileTemplate, entries)))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Writing schema_docs.go")
+	if err := ioutil.WriteFile("schema_docs.go", src, 0644); err != nil {
+		panic(err)
+	}
+}
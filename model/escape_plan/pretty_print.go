@@ -30,7 +30,7 @@ type prettyPrinter struct {
 }
 
 var Fields = []string{"name", "version", "description", "license", "logo",
-	"extends", "depends",
+	"extends", "extension_merge", "depends",
 	"consumes", "build_consumes", "deploy_consumes",
 	"provides", "inputs", "build_inputs", "deploy_inputs",
 	"outputs", "build_outputs", "deploy_outputs",
@@ -38,7 +38,8 @@ var Fields = []string{"name", "version", "description", "license", "logo",
 	"templates", "build_templates", "deploy_templates",
 	"pre_build", "build", "post_build", "test",
 	"pre_deploy", "deploy", "post_deploy", "smoke",
-	"pre_destroy", "destroy", "post_destroy", "activate_provider", "deactivate_provider"}
+	"pre_destroy", "destroy", "post_destroy", "activate_provider", "deactivate_provider",
+	"hooks"}
 
 var templateMap = map[string]string{
 	"name":                keyValTpl,
@@ -80,6 +81,8 @@ var templateMap = map[string]string{
 	"downloads":           listValTpl,
 	"metadata":            mapValTpl,
 	"errands":             mapValTpl,
+	"extension_merge":     mapValTpl,
+	"hooks":               mapValTpl,
 }
 
 type printConf func(*prettyPrinter) *prettyPrinter
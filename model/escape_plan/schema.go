@@ -0,0 +1,239 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package escape_plan
+
+//go:generate go run generate_schema_docs.go
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/ankyra/escape-core"
+	"github.com/ankyra/escape-core/scopes"
+	"github.com/ankyra/escape-core/templates"
+	"github.com/ankyra/escape-core/variables"
+)
+
+const JsonSchemaVersion = "http://json-schema.org/draft-07/schema#"
+
+// The names of the definitions that are used for the nested types.
+var schemaRefs = map[reflect.Type]string{
+	reflect.TypeOf(core.DependencyConfig{}): "dependency",
+	reflect.TypeOf(core.ConsumerConfig{}):   "consumer",
+	reflect.TypeOf(core.DownloadConfig{}):   "download",
+	reflect.TypeOf(core.ExecStage{}):        "exec_stage",
+	reflect.TypeOf(core.Errand{}):           "errand",
+	reflect.TypeOf(core.Hook{}):             "hook",
+	reflect.TypeOf(templates.Template{}):    "template",
+	reflect.TypeOf(variables.Variable{}):    "variable",
+	reflect.TypeOf(scopes.Scopes{}):         "scopes",
+}
+
+var schemaRequired = map[string][]string{
+	"EscapePlan":       []string{"name", "version"},
+	"DependencyConfig": []string{"release_id"},
+	"ConsumerConfig":   []string{"name"},
+	"DownloadConfig":   []string{"url", "dest"},
+	"Template":         []string{"file"},
+	"Variable":         []string{"id"},
+}
+
+// Most of the fields in the Escape plan are typed as interface{}, because
+// they accept both a short (string) and a long (dict) form.
+var planFieldSchemas = map[string]map[string]interface{}{
+	"depends":          listOf(stringOr("dependency")),
+	"consumes":         listOf(stringOr("consumer")),
+	"build_consumes":   listOf(stringOr("consumer")),
+	"deploy_consumes":  listOf(stringOr("consumer")),
+	"inputs":           listOf(stringOr("variable")),
+	"build_inputs":     listOf(stringOr("variable")),
+	"deploy_inputs":    listOf(stringOr("variable")),
+	"outputs":          listOf(stringOr("variable")),
+	"build_outputs":    listOf(stringOr("variable")),
+	"deploy_outputs":   listOf(stringOr("variable")),
+	"templates":        listOf(stringOr("template")),
+	"build_templates":  listOf(stringOr("template")),
+	"deploy_templates": listOf(stringOr("template")),
+	"errands":          mapOf(ref("errand")),
+	"hooks":            mapOf(listOf(stringOr("hook"))),
+}
+
+func init() {
+	for _, step := range core.HookSteps {
+		planFieldSchemas[step] = stringOr("exec_stage")
+	}
+}
+
+// JsonSchema returns a JSON Schema for the Escape plan. The descriptions are
+// taken from the doc comments on the EscapePlan struct and on the core types
+// it references; see generate_schema_docs.go.
+func JsonSchema() map[string]interface{} {
+	definitions := map[string]interface{}{
+		"scopes": map[string]interface{}{
+			"oneOf": []interface{}{scopeSchema(), listOf(scopeSchema())},
+		},
+	}
+	for typ, name := range schemaRefs {
+		if typ.Kind() == reflect.Struct {
+			definitions[name] = structSchema(typ, nil)
+		}
+	}
+	errandSchema(definitions["errand"].(map[string]interface{}))
+	hookSchema(definitions["hook"].(map[string]interface{}), definitions["exec_stage"].(map[string]interface{}))
+	variableSchema(definitions["variable"].(map[string]interface{}))
+
+	result := structSchema(reflect.TypeOf(EscapePlan{}), planFieldSchemas)
+	result["$schema"] = JsonSchemaVersion
+	result["title"] = "Escape plan"
+	result["definitions"] = definitions
+	return result
+}
+
+// GetFieldDocs returns the description of the Escape plan field with the
+// given (YAML) name.
+func GetFieldDocs(field string) string {
+	typ := reflect.TypeOf(EscapePlan{})
+	for i := 0; i < typ.NumField(); i++ {
+		if schemaFieldName(typ.Field(i)) == field {
+			return schemaDocs["EscapePlan."+typ.Field(i).Name]
+		}
+	}
+	return ""
+}
+
+func structSchema(typ reflect.Type, fieldSchemas map[string]map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := schemaFieldName(field)
+		if name == "-" {
+			continue
+		}
+		schema, ok := fieldSchemas[name]
+		if !ok {
+			schema = typeSchema(field.Type)
+		}
+		properties[name] = withDescription(schema, schemaDocs[typ.Name()+"."+field.Name])
+	}
+	result := withDescription(map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}, schemaDocs[typ.Name()])
+	if required, ok := schemaRequired[typ.Name()]; ok {
+		result["required"] = required
+	}
+	return result
+}
+
+func typeSchema(typ reflect.Type) map[string]interface{} {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if name, ok := schemaRefs[typ]; ok {
+		return ref(name)
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Slice:
+		return listOf(typeSchema(typ.Elem()))
+	case reflect.Map:
+		return mapOf(typeSchema(typ.Elem()))
+	}
+	return map[string]interface{}{}
+}
+
+// Errands are parsed from a dict that is keyed on the errand name and that
+// uses `run` for the exec stage.
+func errandSchema(errand map[string]interface{}) {
+	properties := errand["properties"].(map[string]interface{})
+	run := withDescription(stringOr("exec_stage"), schemaDocs["Errand.Run"])
+	delete(properties, "name")
+	delete(properties, "exec_stage")
+	properties["run"] = run
+	for _, field := range []string{"inputs", "outputs"} {
+		properties[field] = withDescription(listOf(stringOr("variable")), schemaDocs["Errand."+strings.Title(field)])
+	}
+}
+
+// The exec stage fields of a hook are inlined in the hook.
+func hookSchema(hook, execStage map[string]interface{}) {
+	properties := hook["properties"].(map[string]interface{})
+	delete(properties, "run")
+	delete(properties, "extension")
+	for key, val := range execStage["properties"].(map[string]interface{}) {
+		properties[key] = val
+	}
+}
+
+// Variables are parsed with the YAML decoder, which doesn't accept a string
+// for a list of scopes.
+func variableSchema(variable map[string]interface{}) {
+	properties := variable["properties"].(map[string]interface{})
+	properties["scopes"] = withDescription(listOf(scopeSchema()), schemaDocs["Variable.Scopes"])
+}
+
+func schemaFieldName(field reflect.StructField) string {
+	for _, tag := range []string{"yaml", "json"} {
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name != "" {
+			return name
+		}
+	}
+	return strings.ToLower(field.Name)
+}
+
+func withDescription(schema map[string]interface{}, description string) map[string]interface{} {
+	result := map[string]interface{}{}
+	for key, val := range schema {
+		result[key] = val
+	}
+	if description != "" {
+		result["description"] = description
+	}
+	return result
+}
+
+func scopeSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "string",
+		"enum": []string{scopes.BuildScope, scopes.DeployScope},
+	}
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/definitions/" + name}
+}
+
+func stringOr(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"oneOf": []interface{}{map[string]interface{}{"type": "string"}, ref(name)},
+	}
+}
+
+func listOf(items map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "array", "items": items}
+}
+
+func mapOf(values map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "object", "additionalProperties": values}
+}
@@ -0,0 +1,114 @@
+// Code generated by generate_schema_docs.go. DO NOT EDIT.
+
+package escape_plan
+
+var schemaDocs = map[string]string{
+	"ConsumerConfig":                       "Unlike Dependencies, which are resolved at build time and provide tight\ncoupling, we can use Consumers and Providers to resolve and loosely couple\npackages at deployment time. Providers make their output variables available to\neach consumer, making it possible to share credentials and host details for\nexample. Providers and Consumers are often used to model the different layers\nin an architecture; where the layer below is consumed by the layer on top (e.g.\nAWS -> Kubernetes -> Helm -> Service).\n\nTo signal that a package implements a certain interface, e.g. \"my-interface\", we can\ndefine it as a provider in the Escape plan:\n\n```yaml\nprovides:\n- my-interface\n```\n\nPackages that require a \"my-interface\" define this joyful fact in their Escape\nPlan as well:\n\n```yaml\nconsumes:\n- my-interface\n```\n\nWhen building or deploying the consumer Escape now makes sure that it also has\naccess to a provider's output variables. You can only link consumers to\nproviders in the same environment. Escape will link up consumers with providers\nautomatically if there's only a single provider of a particular interface; other\ntimes providers need to be specified with the `-p` flag. For example:\n\n```\nescape run deploy my-project/my-consumer-v1.0.0 -p my-interface=provider-deployment\n```\n\nTo list providers in an environment you can use the [`escape state\nshow-providers`](/docs/reference/escape_state_show-providers/) command.\n\n## Wrapper Packages\n\nProviders and consumers provide a loose coupling, but sometimes we know exactly\nwhat provider implementation we want to use. In this case we can create a wrapper\nrelease that uses one dependency as the provider for the next:\n\n```yaml\ndepends:\n- release_id: my-project/postgres-provider-latest as postgres\n- release_id: my-project/my-application-latest\n  consumes:\n\t  postgres: $postgres.deployment\n```\n\nTo read more about wrapper releases see the [blog post](https://www.ankyra.io/blog/combining-packages-into-platforms/).\n\n## Provider Activation and Deactivation\n\nWhen a package consumes another package as a provider, the provider has the\nability to run activation and deactivation scripts. The scripts can be defined by\nadding the following fields to the Escape plan:\n\n```yaml\nactivate_provider: activate.sh\ndeactivate_provider: deactivate.sh\n```\n\nThe scripts gets full access to the provider's deployment state and is in that\nway similar to running a smoke test. These steps are often used to activate\ncredentials, install packages, or otherwise manage state on the deployment\nmachine or container.\n\nTo disable activation and deactivation see the `skip_activate` and `skip_deactivate` options\non the consumer configuration below.\n\n## Escape Plan\n\nConsumers are configured in the [`consumes`](/docs/reference/escape-plan/#consumes)\nfield of the Escape Plan.\n\nProviders are configured in the [`provides`](/docs/reference/escape-plan/#provides)\nfield of the Escape Plan.",
+	"ConsumerConfig.Name":                  "The name of the interface. Can be renamed using the `as` syntax.\nFor example: `kubernetes as k8s`, `postgres`, `postgres as db`",
+	"ConsumerConfig.Scopes":                "A list of scopes (`build`, `deploy`) that defines during which stage(s)\nthis dependency should be fetched and deployed. Also see\n[`build_consumes`](/docs/reference/escape-plan/#build_consumes] and\n[`deploy_consumes`](/docs/reference/escape-plan/#deploy_consumes].",
+	"ConsumerConfig.SkipActivate":          "Skips the provider's activation step.",
+	"ConsumerConfig.SkipDeactivate":        "Skips the provider's deactivation step. Only relevant when\n`skip_activate` is false.",
+	"ConsumerConfig.VariableName":          "The variable used to reference this consumer. Overwriting this field in\nthe Escape plan has no effect.",
+	"DependencyConfig":                     "## Escape Plan\n\nDependencies are configured in the [`depends`](/docs/reference/escape-plan/#depends)\nfield of the Escape plan.",
+	"DependencyConfig.BuildMapping":        "Define the values of dependency inputs using Escape Script when running\nstages in the build scope.",
+	"DependencyConfig.Consumes":            "Map providers from the parent to dependencies.\n\nExample:\n```\nconsumes:\n- my-provider\ndepends:\n- release_id: my-org/my-dep-latest\n    consumes:\n      provider: $my-provider.deployment\n```",
+	"DependencyConfig.DeployMapping":       "Define the values of dependency inputs using Escape Script when running\nstages in the deploy scope.",
+	"DependencyConfig.DeploymentName":      "The name of the (sub)-deployment. This defaults to the versionless release id;\ne.g. if the release_id is `my-org/my-dep-v1.0` then the DeploymentName will be\n`my-org/my-dep` by default.",
+	"DependencyConfig.Mapping":             "Define the values of dependency inputs using Escape Script.",
+	"DependencyConfig.Name":                "Parsed out of the release ID. For example: when release id is\n`\"my-org/my-name-v1.0\"` this value is `\"my-name\"`.",
+	"DependencyConfig.Project":             "Parsed out of the release ID. For example: when release id is\n`\"my-org/my-name-v1.0\"` this value is `\"my-org\"`.",
+	"DependencyConfig.ReleaseId":           "The release id is required and is resolved at *build* time and then\npersisted in the release metadata ensuring that deployments always use\nthe same versions.\n\nExamples:\n- To always use the latest version: `my-organisation/my-dependency-latest`\n- To always use version 0.1.1: `my-organisation/my-dependency-v0.1.1`\n- To always use the latest version in the 0.1 series: `my-organisation/my-dependency-v0.1.@`\n- To make it possible to reference a dependency using a different name: `my-organisation/my-dependency-latest as my-name`",
+	"DependencyConfig.Scopes":              "A list of scopes (`build`, `deploy`) that defines during which stage(s)\nthis dependency should be fetched and deployed. *Currently not implemented!*",
+	"DependencyConfig.Tag":                 "Parsed out of the release ID. For example: when release id is\n`\"my-org/my-name:tag\"` this value is `\"tag\"`.",
+	"DependencyConfig.VariableName":        "The variable used to reference this dependency. By default the variable\nname is the versionless release id of the dependency, but this can be\noverruled by renaming the dependency (e.g. `my-org/my-release-latest as\nmy-variable`. This field will be set automatically at build time.\nOverwriting this field in the Escape plan has no effect.",
+	"DependencyConfig.Version":             "Parsed out of the release ID. For example: when release id is\n`\"my-org/my-name-v1.0\"` this value is `\"1.0\"`.",
+	"DownloadConfig":                       "Downloading files at build or deployment time is one of those common tasks\nthat Escape tries to cover.\n\n## Escape Plan\n\nDownloads are configured in the Escape Plan under the\n[`downloads`](/docs/reference/escape-plan/#downloads) field.",
+	"DownloadConfig.Arch":                  "Only perform this download if the architecture matches this string.\nCan be used to do architecture dependent builds.",
+	"DownloadConfig.Dest":                  "The destination path.",
+	"DownloadConfig.IfNotExists":           "Only perform this download if none of the paths in this list exist.\nSupports glob patterns (for example: `\"*.zip\"`)",
+	"DownloadConfig.OverwriteExistingDest": "Overwrite the destination path if it already exists.",
+	"DownloadConfig.Platform":              "Only perform this download if the platform matches this value.\nCan be used to do platform dependent builds.",
+	"DownloadConfig.Scopes":                "A list of scopes (`build`, `deploy`) that defines during which stage(s)\nthis download should be performed.",
+	"DownloadConfig.URL":                   "The URL to download from. This field is required.\n\nExample: `https://www.google.com/`",
+	"DownloadConfig.Unpack":                "Should Escape try and unpack the destination path after download?\nSupported extensions: `.zip`, `.tgz`, `.tar.gz`, `.tar`.",
+	"Errand":                               "Errands are an Escape mechanism that make it easy to run operational and\npublication tasks against deployed packages. They can be used to implement\nbackup procedures, user management, scalability controls, binary\npublications, etc. Errands are a good idea whenever a task needs to be aware\nof Environments.\n\nYou can inspect and run Errands using the [`escape\nerrands`](/docs/reference/escape_errands/) command.\n\n## Escape Plan\n\nErrands are configured in the Escape Plan under the\n[`errands`](/docs/reference/escape-plan/#errands) field.",
+	"Errand.Description":                   "An optional description of the errand.",
+	"Errand.Inputs":                        "A list of [Variables](/docs/reference/input-and-output-variables/). The values\nwill be made available to the `script` (along with the regular\ndeployment inputs and outputs) as environment variables. For example: a\nvariable with `\"id\": \"input_variable\"` will be accessible as environment\nvariable `INPUT_input_variable`",
+	"Errand.Name":                          "The name of the errand. This field is required.",
+	"Errand.Outputs":                       "A list of [Variables](/docs/reference/input-and-output-variables/)\nproduced by the errand. The script can set their values by writing\na JSON object to `.escape/outputs.json`, like a deployment script. The\noutputs are shown by `escape errands run`, recorded in the errand\nhistory (sensitive values are masked) and can be referenced by errands\nthat require this one.",
+	"Errand.Requires":                      "The names of errands that should run before this one, in order. Their\noutputs can be referenced in the default values of this errand's\ninputs; for example: `$errands.snapshot.outputs.snapshot_id`.",
+	"Errand.Run":                           "The script or command performing the errand.\n\nThe command has access to the deployment inputs and outputs as\nenviroment variables. For example: an input with `\"id\":\n\"input_variable\"` will be accessible as `INPUT_input_variable`; and an\noutput with `\"id\": \"output_variable\"` as `OUTPUT_output_variable`.",
+	"Errand.Schedule":                      "An optional cron-like schedule (e.g. `\"0 3 * * *\"` or `\"@daily\"`).\nScheduled errands are run by `escape errands run --schedule`, which\ncan be used for backups, certificate rotation and similar recurring\ntasks. See [Schedule](https://godoc.org/github.com/ankyra/escape-core#Schedule)\nfor the syntax.",
+	"Errand.Script":                        "The script or command performing the errand (deprecated, use 'run' instead).\n\nThe script has access to the deployment inputs and outputs as enviroment\nvariables. For example: an input with `\"id\": \"input_variable\"` will be\naccessible as `INPUT_input_variable`; and an output with `\"id\":\n\"output_variable\"` as `OUTPUT_output_variable`.",
+	"EscapePlan":                           "Everything starts with a plan. An Escape plan.\n\nThe Escape plan gets compiled into release metadata at build time.",
+	"EscapePlan.ActivateProvider":          "Activate provider script. This script is run when this release is being\nconsumed as a provider by another release during a build or deployment.\nThe script has access to all the deploy scoped input and output\nvariables.",
+	"EscapePlan.Build":                     "Build script.",
+	"EscapePlan.BuildConsumes":             "Same as `consumes`, but scoped to the build stage (ie. the consumer is\nnot required/available at deploy time).",
+	"EscapePlan.BuildInputs":               "Same as `inputs`, but all variables are scoped to the build phase (ie. the\nvariables won't be required/available at deploy time).",
+	"EscapePlan.BuildOutputs":              "Same as `outputs`, but all variables are scoped to the build phase (ie. the\nvariables won't be required/available at deploy time).",
+	"EscapePlan.BuildTemplates":            "Same as `templates`, but all the templates are scoped to the build stage\n(ie. templates won't be rendered at deploy time).",
+	"EscapePlan.Consumes":                  "At deploy time a package can consume zero or more providers from the\ntarget environment.",
+	"EscapePlan.DeactivateProvider":        "Deactive provider script. This script is run when this release is being\ndone being consumed by another release using it as a provider. The\nscript has access to all the deploy scoped input and output variables.",
+	"EscapePlan.Depends":                   "Reference depedencies by their full ID or use the `@` symbol to resolve\nversions at build time.",
+	"EscapePlan.Deploy":                    "Deploy script. The script has access to the deployment input variables,\nand can define outputs by writing a JSON object to .escape/outputs.json.",
+	"EscapePlan.DeployConsumes":            "Same as `consumes`, but scoped to the deploy stage (ie. the consumer is\nnot required/available at build time).",
+	"EscapePlan.DeployInputs":              "Same as `inputs`, but all variables are scoped to the deployment phase (ie. the\nvariables won't be required/available at build time).",
+	"EscapePlan.DeployOutputs":             "Same as `outputs`, but all variables are scoped to the deployment phase (ie. the\nvariables won't be required/available at build time).",
+	"EscapePlan.DeployTemplates":           "Same as `templates`, but all the templates are scoped to the deploy stage\n(ie. templates won't be rendered at deploy time).",
+	"EscapePlan.Description":               "A description for this package. Only used for presentation purposes.",
+	"EscapePlan.Destroy":                   "Destroy script.",
+	"EscapePlan.Downloads":                 "Downloads.",
+	"EscapePlan.Errands":                   "Errands are scripts that can be run against the deployment of this release.\nThe scripts receive the deployment's inputs and outputs as environment\nvariables.",
+	"EscapePlan.ExtensionMerge":            "Decides what happens when more than one extension defines the same\nitem. The keys are the fields `consumes`, `provides`, `inputs`,\n`outputs`, `templates`, `metadata`, `errands` and `stages`. The values\nare one of `override` (the last extension wins), `append` (the first\nextension wins) or `error` (the build fails). Definitions in the\nEscape plan itself always take precedence over its extensions.\n\nExample:\n\n  extension_merge:\n    inputs: error\n    errands: override",
+	"EscapePlan.Generates":                 "Files that are generated during the build phase. Globbing patterns are\nsupported.  Directories are added recursively. The main reason to use\nthis over `includes` is that the `generates` field is copied to the\nparent release, when a release gets extended, but `includes` aren't.",
+	"EscapePlan.Hooks":                     "Hooks that run before or after a step, or when a step fails. The keys\nare hook points (`before_<step>`, `after_<step>` or `on_failure`), the\nvalues are lists of scripts. Unlike the scripts above, hooks from\nextensions are not replaced by the hooks in this plan; they all run,\nstarting with the extension hooks. See [Hooks](/docs/reference/hooks/).\n\nExample:\n\n  hooks:\n    before_deploy:\n    - audit.sh\n    on_failure:\n    - script: notify.sh\n      fatal: false",
+	"EscapePlan.Includes":                  "The files to includes in this release. The files don't have to exist and can\nbe produced during build time. Globbing patterns are supported. Directories\nare added recursively.",
+	"EscapePlan.Inputs":                    "Input variables.",
+	"EscapePlan.License":                   "The license. For example `Apache Software License`, `BSD License`, `GPLv3`, etc.\nCurrently no input validation is performed on this field.",
+	"EscapePlan.Logo":                      "A path to an image. Only used for presentation purposes.",
+	"EscapePlan.Metadata":                  "Metadata key value pairs.\n\n[Escape Script](/docs/scripting-language/) can be used to\nprogrammatically set values using the [default\ncontext](/docs/scripting-language/#context).\n\nExample:\n\n  metadata:\n    author: Fictional Character\n    co_author: $dependency.metadata.author",
+	"EscapePlan.Name":                      "The package name is a required field. The name can be qualified by a\nproject name, but if no project is specified then the default project `_`\nwill be used.\n\nFormat: `/([a-za-z]+[a-za-z0-9-]*\\/)?[a-za-z]+[a-za-z0-9-]*/`\n\nExamples:\n\n* Fully qualified: `name: my-project/my-package`\n\n* Default project: `name: my-package`",
+	"EscapePlan.Outputs":                   "Output variables.",
+	"EscapePlan.PostBuild":                 "Post-build script. The script has access to all the build scoped input\nand output variables.",
+	"EscapePlan.PostDeploy":                "Post-deploy script. The script has access to all the deploy scoped input\nand output variables.",
+	"EscapePlan.PostDestroy":               "Post-destroy script.",
+	"EscapePlan.PreBuild":                  "Pre-build script. The script has access to all the build scoped input\nvariables.",
+	"EscapePlan.PreDeploy":                 "Pre-deploy script. The script has access to all the deploy scoped input\nvariables.",
+	"EscapePlan.PreDestroy":                "Pre-destroy script.",
+	"EscapePlan.Provides":                  "The release can declare zero or more providers so that consumers\ncan loosely depend on it at deploy time.",
+	"EscapePlan.Smoke":                     "Smoke test script.",
+	"EscapePlan.Templates":                 "Templates.",
+	"EscapePlan.Test":                      "Test script.  Generally run after a build as part of the release\nprocess, but can be triggered separately using `escape run test`.  The\nscript has access to all the build scoped input and output variables.",
+	"EscapePlan.Version":                   "The version is a required field. Escape uses semantic versioning to\nversion packages.  Either specify the full version or use the '@' symbol\nto let Escape pick the next version at build time. See\n[here](/docs/guides/versioning/) for more versioning approaches.\n\nFormat: `/[0-9]+(\\.[0-9]+)*(\\.@)?/`\n\nExamples:\n\n* Build version 1.5: `version: 1.5`\n\n* Build the next minor release in the 1.* series: `version: 1.@`\n\n* Build the next patch release in the 1.1.* series: `version: 1.1.@`",
+	"ExecStage.Args":                       "Arguments to the command.",
+	"ExecStage.Cmd":                        "The command to run. Its arguments, if any, should be defined using the\n\"args\" field.",
+	"ExecStage.Inline":                     "An inline script, which will be executed using sh. It's an error to\nspecify both the \"cmd\" and \"inline\" fields.",
+	"ExecStage.RelativeScript":             "Relative path to a script. If the \"cmd\" field is already populated\nthen this field will be ignored entirely.",
+	"Hook":                                 "Hooks are scripts that run before or after a step, or when a step fails.\nUnlike the step itself, which can only be replaced as a whole, hooks from\nextensions and from the Escape plan are all run, in order: first the hooks\nof the extensions (in the order in which they're extended) and then the\nhooks of the Escape plan. This makes it possible for an extension to wrap\nthe `deploy` step of the releases that extend it with, for example, an audit\nor notification step.\n\nHooks have access to the same environment variables as the step.\n`on_failure` hooks additionally receive the name of the failed step in\n`ESCAPE_FAILED_STEP` and the error in `ESCAPE_ERROR`.\n\n## Escape Plan\n\nHooks are configured in the Escape Plan under the\n[`hooks`](/docs/reference/escape-plan/#hooks) field. The keys are hook\npoints (`before_<step>`, `after_<step>` or `on_failure`), the values are\nlists of scripts or dicts.\n\n\thooks:\n\t  before_deploy:\n\t  - audit.sh\n\t  after_deploy:\n\t  - name: notify\n\t    script: notify.sh\n\t    fatal: false",
+	"Hook.Extension":                       "The extension that contributed this hook, if any. Set by the\ncompiler.",
+	"Hook.Fatal":                           "Whether a failure of the hook should fail the step. When set to\n`false` the failure is logged and the step continues. Defaults to\n`true`. Failures of `on_failure` hooks are always logged, because\nthe step has already failed.",
+	"Hook.Name":                            "An optional name, used in logging and error messages.",
+	"Hook.Run":                             "The script or command to run.",
+	"Template":                             "Escape provides the Mustache and Go templating languages and integrates them\nwith the package's [Variables](/docs/reference/input-and-output-variables/),\nmaking for a quick and easy way to render files at either build or deploy\ntime.\n\nGo templates support conditionals and loops, and can use the following\nfunctions besides the built-in ones: `upper`, `lower`, `title`, `trim`,\n`trimPrefix`, `trimSuffix`, `replace`, `contains`, `hasPrefix`, `hasSuffix`,\n`split`, `join`, `quote`, `default`, `indent`, `nindent`, `keys`, `toJson`,\n`toYaml`, `b64enc` and `b64dec`. For example:\n\n```\n{{ range .hosts }}\n- {{ . | upper | quote }}\n{{ end }}\n```\n\n## Previewing Templates\n\n`escape plan render` shows what the templates render to for a deployment,\nusing the values recorded in its state. Add `--diff` to compare the output\nwith the files on disk, or `--check` to fail when they are out of date.\n\n## Escape Plan\n\nTemplates are configured in the Escape Plan under the\n[`templates`](/docs/reference/escape-plan/#templates) field.",
+	"Template.Engine":                      "The template engine: `mustache` or `go`.\n\nDefault: `mustache`",
+	"Template.File":                        "The file containing the template. This field is required.\n\nThis can also be a directory, in which case the whole tree is rendered\ninto the `target` directory. Files in the tree that have the `.tpl`\nextension are rendered and lose their extension; other files are\ncopied as is.",
+	"Template.Mapping":                     "This mapping can be used to relate template variables to Escape variables.",
+	"Template.Mode":                        "The file mode of the rendered file(s), in octal notation (e.g.\n`\"0755\"`). If no mode is set, new files are created with mode `0644`\nand the mode of existing files is left alone. Files that are copied\nfrom a directory template keep their mode.",
+	"Template.Scopes":                      "A list of scopes (`build`, `deploy`) that defines during which stage(s)\nthe template should be rendered.",
+	"Template.Target":                      "The target location for the rendered template. If the source location\nspecified in `file` has the `.tpl` extension this `target` will default\nto source location minus that extension.\n\nFor example: if `file` is `\"hello.txt.tpl\"` then the default value for\ntarget will be `\"hello.txt\"`",
+	"Template.When":                        "An Escape Script expression that should evaluate to a boolean. The\ntemplate is only rendered when it's `true`, e.g.\n`$this.inputs.enable_monitoring`.",
+	"Variable":                             "Variables can be used to defined inputs and outputs for the build and\ndeployment stages. They can also be used to make [Errands](/docs/reference/errands/)\nconfigurable.\n\nVariables are strongly typed, which is checked at both build and deploy\ntime.  A task can't succeed if the required variables have not been\nconfigured correctly.\n\n## Escape Plan\n\nVariables can be configured in the Escape Plan under the\n[`inputs`](/docs/reference/escape-plan/#inputs),\n[`build_inputs`](/docs/reference/escape-plan/#build_inputs),\n[`deploy_inputs`](/docs/reference/escape-plan/#deploy_inputs) and\n[`outputs`](/docs/reference/escape-plan/#outputs) fields.",
+	"Variable.Aliases":                     "Previous IDs of this variable. Values that are still configured under\none of these IDs are used for this variable, so that a variable can be\nrenamed without breaking existing deployments. The state can be\nupdated using `escape state migrate-inputs`.",
+	"Variable.Default":                     "A default value for this variable. This value will be used if no value\nhas been specified by the user.",
+	"Variable.DeprecatedBy":                "The ID of the variable that replaces this one. A warning is shown\nwhen a value is configured for this variable and the value is also\nused for the replacing variable, unless that one has been configured\nas well.",
+	"Variable.Description":                 "A description of the variable.",
+	"Variable.EvalBeforeDependencies":      "Should the variables be evaluated before the dependencies are deployed?",
+	"Variable.Friendly":                    "A friendly name for this variable for presentational purposes only.",
+	"Variable.Id":                          "A unique name for this variable. Required field.",
+	"Variable.Items":                       "If set, this should contain all the valid values for this variable.",
+	"Variable.Options":                     "Options that put more constraints on the type. The values are checked\nat build, deploy and errand time.\n\n`string` variables support `choices` (a list of allowed values),\n`pattern` (a regular expression the value should match), `min_length`\nand `max_length`.\n\n`integer` variables support `choices`, `min` and `max`.\n\n`list` variables support `min_items`, `max_items` and `unique`.\n\nInteger constraints can also be set in the type itself, e.g.\n`integer[min=1, max=10]`.",
+	"Variable.Scopes":                      "A list of scopes (`build`, `deploy`) that defines during which stage(s)\nthis variable should be active. You wouldn't usually use this field\ndirectly, but use something like\n[`build_inputs`](/docs/escape-plan/#build_inputs) or\n[`deploy_inputs`](/docs/escape-plan/#deploy_inputs), which usually\nexpress intent better.",
+	"Variable.Sensitive":                   "Is this sensitive data?",
+	"Variable.Type":                        "The variable type. Before executing any steps Escape will make sure that\nall the values match the types that are set on the variables.\n\nOne of: `string`, `list`, `map`, `integer`, `float`, `bool`, `secret`,\n`file`, `json`.\n\nThe values of a `list` or `map` can be typed, e.g. `list[integer]` or\n`map[float]`. A `secret` is a string that is always treated as\nsensitive data. The value of a `file` variable is a path, which is\nreplaced by the contents of the file. A `json` variable can hold any\nJSON value, which is validated against the JSON Schema in the `schema`\noption, if set.\n\nDefault: `string`",
+	"Variable.Visible":                     "Control whether or not this variable should be visible when deploying\ninteractively. In other words: should the user be asked to input this\nvalue?  It only really makes sense to set this to `true` if there a\n`default` is set.",
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package escape_plan
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *planSuite) Test_JsonSchema(c *C) {
+	schema := JsonSchema()
+	c.Assert(schema["$schema"], Equals, JsonSchemaVersion)
+	c.Assert(schema["required"], DeepEquals, []string{"name", "version"})
+	c.Assert(schema["additionalProperties"], Equals, false)
+	properties := schema["properties"].(map[string]interface{})
+	for _, field := range Fields {
+		c.Assert(properties[field], Not(IsNil), Commentf("Missing field %s", field))
+	}
+	c.Assert(properties, HasLen, len(Fields))
+	c.Assert(properties["name"].(map[string]interface{})["type"], Equals, "string")
+	c.Assert(properties["name"].(map[string]interface{})["description"], Matches, "(?s)The package name is a required field.*")
+}
+
+func (s *planSuite) Test_JsonSchema_short_and_long_forms(c *C) {
+	properties := JsonSchema()["properties"].(map[string]interface{})
+	c.Assert(properties["build"].(map[string]interface{})["oneOf"], DeepEquals, []interface{}{
+		map[string]interface{}{"type": "string"},
+		map[string]interface{}{"$ref": "#/definitions/exec_stage"},
+	})
+	inputs := properties["inputs"].(map[string]interface{})
+	c.Assert(inputs["type"], Equals, "array")
+	c.Assert(inputs["items"].(map[string]interface{})["oneOf"], DeepEquals, []interface{}{
+		map[string]interface{}{"type": "string"},
+		map[string]interface{}{"$ref": "#/definitions/variable"},
+	})
+	errands := properties["errands"].(map[string]interface{})
+	c.Assert(errands["additionalProperties"], DeepEquals, map[string]interface{}{"$ref": "#/definitions/errand"})
+}
+
+func (s *planSuite) Test_JsonSchema_definitions(c *C) {
+	definitions := JsonSchema()["definitions"].(map[string]interface{})
+	cases := map[string][]string{
+		"dependency": []string{"build_mapping", "consumes", "deploy_mapping", "deployment_name", "mapping", "release_id", "scopes", "variable"},
+		"consumer":   []string{"name", "scopes", "skip_activate", "skip_deactivate", "variable"},
+		"download":   []string{"arch", "dest", "if_not_exists", "overwrite", "platform", "scopes", "unpack", "url"},
+		"exec_stage": []string{"args", "cmd", "inline", "script"},
+		"errand":     []string{"description", "inputs", "outputs", "requires", "run", "schedule", "script"},
+		"hook":       []string{"args", "cmd", "fatal", "inline", "name", "script"},
+		"template":   []string{"engine", "file", "mapping", "mode", "scopes", "target", "when"},
+	}
+	for name, fields := range cases {
+		def := definitions[name].(map[string]interface{})
+		properties := def["properties"].(map[string]interface{})
+		c.Assert(properties, HasLen, len(fields), Commentf("Definition %s", name))
+		for _, field := range fields {
+			c.Assert(properties[field], Not(IsNil), Commentf("Missing field %s in %s", field, name))
+		}
+	}
+	template := definitions["template"].(map[string]interface{})
+	c.Assert(template["required"], DeepEquals, []string{"file"})
+	c.Assert(template["properties"].(map[string]interface{})["scopes"], DeepEquals, map[string]interface{}{
+		"$ref":        "#/definitions/scopes",
+		"description": schemaDocs["Template.Scopes"],
+	})
+}
+
+func (s *planSuite) Test_GetFieldDocs(c *C) {
+	c.Assert(GetFieldDocs("version"), Matches, "(?s)The version is a required field.*")
+	c.Assert(GetFieldDocs("unknown"), Equals, "")
+}
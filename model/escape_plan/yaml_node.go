@@ -0,0 +1,256 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package escape_plan
+
+import (
+	"regexp"
+	"strings"
+)
+
+var blockScalarRegex = regexp.MustCompile(`^[|>][-+0-9]*(\s+#.*)?$`)
+
+// yamlNode is a node in the block structure of a YAML document. Only the
+// structure that is needed to format the document is parsed; scalar values
+// are kept the way they were written, so that formatting never changes the
+// value of a field.
+type yamlNode struct {
+	// The comment lines before the node. Blank lines are kept as empty
+	// strings.
+	Comments []string
+
+	// Whether the node is a sequence item. The value of an item is in its
+	// Children.
+	Item bool
+
+	// The first line of the node, without indentation.
+	Line string
+
+	// The key, if the node is a mapping entry.
+	Key string
+
+	// The continuation lines of a block scalar or of a multi-line value,
+	// without their common indentation.
+	Verbatim []string
+
+	Children []*yamlNode
+
+	// The comment lines after the last child.
+	Footer []string
+}
+
+type yamlLine struct {
+	indent int
+	text   string
+}
+
+func (l yamlLine) isBlank() bool {
+	return l.text == ""
+}
+
+func (l yamlLine) isComment() bool {
+	return strings.HasPrefix(l.text, "#") || l.text == "---"
+}
+
+func (l yamlLine) isItem() bool {
+	return l.text == "-" || strings.HasPrefix(l.text, "- ")
+}
+
+// parseYamlNodes parses the block structure of a YAML document.
+func parseYamlNodes(content string) ([]*yamlNode, []string) {
+	lines := []yamlLine{}
+	for _, line := range strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n") {
+		text := strings.TrimLeft(line, " ")
+		lines = append(lines, yamlLine{
+			indent: len(line) - len(text),
+			text:   strings.TrimRight(text, " \t"),
+		})
+	}
+	return parseYamlBlock(lines)
+}
+
+func parseYamlBlock(lines []yamlLine) ([]*yamlNode, []string) {
+	nodes := []*yamlNode{}
+	comments := []string{}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if line.isBlank() || line.isComment() {
+			comments = append(comments, line.text)
+			continue
+		}
+		end := yamlNodeEnd(lines, i)
+		node := &yamlNode{
+			Comments: comments,
+		}
+		comments = []string{}
+		if line.isItem() {
+			node.Item = true
+			rest := strings.TrimPrefix(line.text, "-")
+			value := strings.TrimLeft(rest, " ")
+			sub := []yamlLine{}
+			if value != "" {
+				sub = append(sub, yamlLine{line.indent + 1 + len(rest) - len(value), value})
+			}
+			node.Children, node.Footer = parseYamlBlock(append(sub, lines[i+1:end]...))
+		} else {
+			node.Line = line.text
+			key, value := splitYamlKey(line.text)
+			node.Key = key
+			if key == "" || value != "" {
+				node.Verbatim = verbatimLines(lines[i+1 : end])
+			} else {
+				node.Children, node.Footer = parseYamlBlock(lines[i+1 : end])
+			}
+		}
+		nodes = append(nodes, node)
+		i = end - 1
+	}
+	return nodes, comments
+}
+
+// yamlNodeEnd returns the index of the first line after the node that starts
+// on line i.
+func yamlNodeEnd(lines []yamlLine, i int) int {
+	start := lines[i]
+	_, value := splitYamlKey(start.text)
+	sequenceValue := !start.isItem() && value == ""
+	keepTrailing := blockScalarRegex.MatchString(value) && strings.Contains(value, "+")
+	belongs := func(l yamlLine) bool {
+		return l.indent > start.indent || (sequenceValue && l.indent == start.indent && l.isItem())
+	}
+	end := i + 1
+	for j := i + 1; j < len(lines); j++ {
+		l := lines[j]
+		if l.isBlank() || (l.isComment() && !belongs(l)) {
+			if keepTrailing && l.isBlank() {
+				end = j + 1
+			}
+			continue
+		}
+		if !belongs(l) {
+			break
+		}
+		end = j + 1
+	}
+	// Comments right after the node that are indented deeper belong to
+	// the node.
+	for end < len(lines) && lines[end].isComment() && lines[end].indent > start.indent {
+		end++
+	}
+	return end
+}
+
+func verbatimLines(lines []yamlLine) []string {
+	minIndent := -1
+	for _, l := range lines {
+		if !l.isBlank() && (minIndent == -1 || l.indent < minIndent) {
+			minIndent = l.indent
+		}
+	}
+	result := []string{}
+	for _, l := range lines {
+		if l.isBlank() {
+			result = append(result, "")
+		} else {
+			result = append(result, strings.Repeat(" ", l.indent-minIndent)+l.text)
+		}
+	}
+	return result
+}
+
+// splitYamlKey splits a mapping entry into its key and value. The value is
+// empty if there is only a comment after the key. The key is empty if the
+// line is not a mapping entry.
+func splitYamlKey(line string) (string, string) {
+	if strings.HasPrefix(line, "{") || strings.HasPrefix(line, "[") || strings.HasPrefix(line, "-") {
+		return "", line
+	}
+	keyEnd := 0
+	if strings.HasPrefix(line, `"`) || strings.HasPrefix(line, "'") {
+		closing := strings.Index(line[1:], line[:1])
+		if closing == -1 {
+			return "", line
+		}
+		keyEnd = closing + 2
+	}
+	colon := strings.Index(line[keyEnd:], ":")
+	for colon != -1 {
+		pos := keyEnd + colon
+		if pos+1 == len(line) || line[pos+1] == ' ' || line[pos+1] == '\t' {
+			key := strings.TrimSpace(line[:pos])
+			if strings.Contains(key, " #") {
+				return "", line
+			}
+			value := strings.TrimSpace(line[pos+1:])
+			if strings.HasPrefix(value, "#") {
+				value = ""
+			}
+			return strings.Trim(key, `"'`), value
+		}
+		next := strings.Index(line[pos+1:], ":")
+		if next == -1 {
+			break
+		}
+		colon += next + 1
+	}
+	return "", line
+}
+
+// render writes the nodes with two spaces of indentation per level, with
+// sequences at the same level as the key that holds them.
+func renderYamlNodes(nodes []*yamlNode, footer []string, indent int, keepBlankLines bool) []string {
+	prefix := strings.Repeat(" ", indent)
+	result := []string{}
+	for _, node := range nodes {
+		result = append(result, renderYamlComments(node.Comments, prefix, keepBlankLines)...)
+		if node.Item {
+			children := renderYamlNodes(node.Children, node.Footer, indent+2, keepBlankLines)
+			if len(children) == 0 {
+				result = append(result, prefix+"-")
+				continue
+			}
+			children[0] = prefix + "- " + strings.TrimLeft(children[0], " ")
+			result = append(result, children...)
+			continue
+		}
+		result = append(result, prefix+node.Line)
+		for _, line := range node.Verbatim {
+			if line == "" {
+				result = append(result, "")
+			} else {
+				result = append(result, prefix+"  "+line)
+			}
+		}
+		childIndent := indent + 2
+		if len(node.Children) > 0 && node.Children[0].Item {
+			childIndent = indent
+		}
+		result = append(result, renderYamlNodes(node.Children, node.Footer, childIndent, keepBlankLines)...)
+	}
+	return append(result, renderYamlComments(footer, prefix, keepBlankLines)...)
+}
+
+func renderYamlComments(comments []string, prefix string, keepBlankLines bool) []string {
+	result := []string{}
+	for i, comment := range comments {
+		if comment != "" {
+			result = append(result, prefix+comment)
+		} else if keepBlankLines && (i == 0 || comments[i-1] != "") {
+			result = append(result, "")
+		}
+	}
+	return result
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chain
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape/model/inventory/types"
+)
+
+// A Link is an Inventory in an InventoryChain, together with the namespaces
+// (projects) it should be used for.
+type Link struct {
+	Inventory types.Inventory
+	// Glob patterns of the projects this Inventory is used for. If empty, the
+	// Inventory is used for all projects.
+	Include []string
+	// Glob patterns of the projects this Inventory should not be used for.
+	// Takes precedence over Include.
+	Exclude []string
+	Read    bool
+	Write   bool
+}
+
+func (l *Link) Matches(project string) bool {
+	for _, pattern := range l.Exclude {
+		if ok, _ := path.Match(pattern, project); ok {
+			return false
+		}
+	}
+	if len(l.Include) == 0 {
+		return true
+	}
+	for _, pattern := range l.Include {
+		if ok, _ := path.Match(pattern, project); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// An InventoryChain is an ordered list of Inventories. Lookups fall through
+// the readable Inventories matching the project until one of them succeeds.
+// Writes go to the first writable Inventory matching the project.
+type InventoryChain struct {
+	Links []*Link
+}
+
+func NewInventoryChain(links []*Link) *InventoryChain {
+	return &InventoryChain{
+		Links: links,
+	}
+}
+
+func (r *InventoryChain) getReadableInventories(project string) ([]types.Inventory, error) {
+	result := []types.Inventory{}
+	for _, link := range r.Links {
+		if link.Read && link.Matches(project) {
+			result = append(result, link.Inventory)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("There is no readable Inventory configured for the project '%s'.", project)
+	}
+	return result, nil
+}
+
+func (r *InventoryChain) getWritableInventory(project string) (types.Inventory, error) {
+	for _, link := range r.Links {
+		if link.Write && link.Matches(project) {
+			return link.Inventory, nil
+		}
+	}
+	return nil, fmt.Errorf("There is no writable Inventory configured for the project '%s'.", project)
+}
+
+// lookup calls f on every readable Inventory for the project until one of
+// them returns without an error. Only "not found" errors fall through to the
+// next Inventory; any other error (authentication, connection, server side)
+// is returned straight away, so that a release can't be served from a later
+// Inventory just because an earlier one is unavailable. If the release can't
+// be found anywhere, the error of the first Inventory is returned.
+func (r *InventoryChain) lookup(project string, f func(types.Inventory) error) error {
+	invs, err := r.getReadableInventories(project)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, inv := range invs {
+		err := f(inv)
+		if err == nil {
+			return nil
+		}
+		if !types.IsNotFound(err) {
+			return err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// merge calls f on every readable Inventory for the project and combines the
+// results, leaving out duplicates. Inventories that fail are skipped, so that
+// an unavailable Inventory doesn't hide the others; an error is only returned
+// if none of them succeeded.
+func (r *InventoryChain) merge(project string, f func(types.Inventory) ([]string, error)) ([]string, error) {
+	invs, err := r.getReadableInventories(project)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	succeeded := false
+	seen := map[string]bool{}
+	result := []string{}
+	for _, inv := range invs {
+		values, err := f(inv)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		succeeded = true
+		for _, v := range values {
+			if !seen[v] {
+				seen[v] = true
+				result = append(result, v)
+			}
+		}
+	}
+	if !succeeded {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+func (r *InventoryChain) QueryReleaseMetadata(project, name, version string) (*core.ReleaseMetadata, error) {
+	var result *core.ReleaseMetadata
+	err := r.lookup(project, func(inv types.Inventory) error {
+		var err error
+		result, err = inv.QueryReleaseMetadata(project, name, version)
+		return err
+	})
+	return result, err
+}
+
+func (r *InventoryChain) QueryNextVersion(project, name, versionPrefix string) (string, error) {
+	inv, err := r.getWritableInventory(project)
+	if err != nil {
+		return "", err
+	}
+	return inv.QueryNextVersion(project, name, versionPrefix)
+}
+
+func (r *InventoryChain) DownloadRelease(project, name, version, targetFile string) error {
+	return r.lookup(project, func(inv types.Inventory) error {
+		return inv.DownloadRelease(project, name, version, targetFile)
+	})
+}
+
+func (r *InventoryChain) UploadRelease(project, releasePath string, metadata *core.ReleaseMetadata) error {
+	inv, err := r.getWritableInventory(project)
+	if err != nil {
+		return err
+	}
+	return inv.UploadRelease(project, releasePath, metadata)
+}
+
+func (r *InventoryChain) TagRelease(project, name, version, tag string) error {
+	inv, err := r.getWritableInventory(project)
+	if err != nil {
+		return err
+	}
+	return inv.TagRelease(project, name, version, tag)
+}
+
+func (r *InventoryChain) DeleteRelease(project, name, version string) error {
+	inv, err := r.getWritableInventory(project)
+	if err != nil {
+		return err
+	}
+	return inv.DeleteRelease(project, name, version)
+}
+
+func (r *InventoryChain) DeprecateRelease(project, name, version, reason string) error {
+	inv, err := r.getWritableInventory(project)
+	if err != nil {
+		return err
+	}
+	return inv.DeprecateRelease(project, name, version, reason)
+}
+
+func (r *InventoryChain) YankRelease(project, name, version, reason string) error {
+	inv, err := r.getWritableInventory(project)
+	if err != nil {
+		return err
+	}
+	return inv.YankRelease(project, name, version, reason)
+}
+
+func (r *InventoryChain) ListProjects() ([]string, error) {
+	var firstErr error
+	succeeded := false
+	seen := map[string]bool{}
+	result := []string{}
+	for _, link := range r.Links {
+		if !link.Read {
+			continue
+		}
+		projects, err := link.Inventory.ListProjects()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		succeeded = true
+		for _, p := range projects {
+			if !seen[p] && link.Matches(p) {
+				seen[p] = true
+				result = append(result, p)
+			}
+		}
+	}
+	if !succeeded && firstErr != nil {
+		return nil, firstErr
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (r *InventoryChain) ListApplications(project string) ([]string, error) {
+	result, err := r.merge(project, func(inv types.Inventory) ([]string, error) {
+		return inv.ListApplications(project)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (r *InventoryChain) ListVersions(project, app string) ([]string, error) {
+	return r.merge(project, func(inv types.Inventory) ([]string, error) {
+		return inv.ListVersions(project, app)
+	})
+}
+
+// Logging in is done per Inventory, using the credentials in the
+// 'inventories' section of the profile, so there is no single server the
+// chain could log in to.
+var LoginNotSupportedError = fmt.Errorf("Login is not supported for chained inventories. Configure the credentials of each inventory in the 'inventories' section of the profile instead.")
+
+func (r *InventoryChain) Login(url, username, password string) (string, error) {
+	return "", LoginNotSupportedError
+}
+func (r *InventoryChain) LoginWithBasicAuth(url, username, password string) error {
+	return LoginNotSupportedError
+}
+func (r *InventoryChain) GetAuthMethods(url string) (map[string]*types.AuthMethod, error) {
+	return nil, LoginNotSupportedError
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chain
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape/model/inventory/local"
+	"github.com/ankyra/escape/model/inventory/remote"
+	"github.com/ankyra/escape/util"
+	. "gopkg.in/check.v1"
+)
+
+type suite struct{}
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&suite{})
+
+const testDir = "testdata_chain"
+
+func (s *suite) SetUpTest(c *C) {
+	os.RemoveAll(testDir)
+	c.Assert(os.MkdirAll(testDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(testDir, "archive.tgz"), []byte("archive"), 0644), IsNil)
+}
+
+func (s *suite) TearDownTest(c *C) {
+	os.RemoveAll(testDir)
+}
+
+func upload(c *C, inv *local.LocalInventory, project, name, version string) {
+	metadata := core.NewReleaseMetadata(name, version)
+	metadata.Project = project
+	c.Assert(inv.UploadRelease(project, filepath.Join(testDir, "archive.tgz"), metadata), IsNil)
+}
+
+func (s *suite) Test_Link_Matches(c *C) {
+	link := &Link{}
+	c.Assert(link.Matches("anything"), Equals, true)
+
+	link = &Link{Include: []string{"team-*", "shared"}, Exclude: []string{"team-secret"}}
+	c.Assert(link.Matches("team-a"), Equals, true)
+	c.Assert(link.Matches("shared"), Equals, true)
+	c.Assert(link.Matches("team-secret"), Equals, false)
+	c.Assert(link.Matches("other"), Equals, false)
+}
+
+func (s *suite) Test_QueryReleaseMetadata_falls_through_the_chain(c *C) {
+	private := local.NewLocalInventory(filepath.Join(testDir, "private"))
+	public := local.NewLocalInventory(filepath.Join(testDir, "public"))
+	upload(c, private, "prj", "private-app", "1.0")
+	upload(c, public, "prj", "public-app", "1.0")
+
+	unit := NewInventoryChain([]*Link{
+		&Link{Inventory: private, Read: true, Write: true},
+		&Link{Inventory: public, Read: true},
+	})
+	m, err := unit.QueryReleaseMetadata("prj", "private-app", "latest")
+	c.Assert(err, IsNil)
+	c.Assert(m.Name, Equals, "private-app")
+
+	m, err = unit.QueryReleaseMetadata("prj", "public-app", "latest")
+	c.Assert(err, IsNil)
+	c.Assert(m.Name, Equals, "public-app")
+
+	_, err = unit.QueryReleaseMetadata("prj", "unknown-app", "v1.0")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *suite) Test_QueryReleaseMetadata_doesnt_fall_through_on_server_errors(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+	public := local.NewLocalInventory(filepath.Join(testDir, "public"))
+	upload(c, public, "prj", "app", "1.0")
+
+	unit := NewInventoryChain([]*Link{
+		&Link{Inventory: remote.NewRemoteInventory(server.URL, "", "", "", false), Read: true},
+		&Link{Inventory: public, Read: true},
+	})
+	_, err := unit.QueryReleaseMetadata("prj", "app", "1.0")
+	c.Assert(err, ErrorMatches, ".*server-side error.*")
+
+	err = unit.DownloadRelease("prj", "app", "1.0", filepath.Join(testDir, "download.tgz"))
+	c.Assert(err, ErrorMatches, ".*server-side error.*")
+	c.Assert(util.PathExists(filepath.Join(testDir, "download.tgz")), Equals, false)
+}
+
+func (s *suite) Test_QueryReleaseMetadata_skips_inventories_that_dont_match(c *C) {
+	private := local.NewLocalInventory(filepath.Join(testDir, "private"))
+	public := local.NewLocalInventory(filepath.Join(testDir, "public"))
+	upload(c, private, "prj", "app", "1.0")
+	upload(c, public, "prj", "app", "2.0")
+
+	unit := NewInventoryChain([]*Link{
+		&Link{Inventory: private, Read: true, Exclude: []string{"prj"}},
+		&Link{Inventory: public, Read: true},
+	})
+	m, err := unit.QueryReleaseMetadata("prj", "app", "latest")
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "2.0")
+}
+
+func (s *suite) Test_UploadRelease_goes_to_first_writable_inventory(c *C) {
+	readOnly := local.NewLocalInventory(filepath.Join(testDir, "read-only"))
+	other := local.NewLocalInventory(filepath.Join(testDir, "other"))
+	writable := local.NewLocalInventory(filepath.Join(testDir, "writable"))
+	unit := NewInventoryChain([]*Link{
+		&Link{Inventory: readOnly, Read: true},
+		&Link{Inventory: other, Read: true, Write: true, Include: []string{"other"}},
+		&Link{Inventory: writable, Read: true, Write: true},
+	})
+	metadata := core.NewReleaseMetadata("app", "1.0")
+	metadata.Project = "prj"
+	c.Assert(unit.UploadRelease("prj", filepath.Join(testDir, "archive.tgz"), metadata), IsNil)
+
+	versions, err := writable.ListVersions("prj", "app")
+	c.Assert(err, IsNil)
+	c.Assert(versions, DeepEquals, []string{"1.0"})
+	_, err = readOnly.ListVersions("prj", "app")
+	c.Assert(err, Not(IsNil))
+	_, err = other.ListVersions("prj", "app")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *suite) Test_UploadRelease_fails_without_writable_inventory(c *C) {
+	unit := NewInventoryChain([]*Link{
+		&Link{Inventory: local.NewLocalInventory(testDir), Read: true},
+	})
+	metadata := core.NewReleaseMetadata("app", "1.0")
+	err := unit.UploadRelease("prj", filepath.Join(testDir, "archive.tgz"), metadata)
+	c.Assert(err, ErrorMatches, "There is no writable Inventory configured for the project 'prj'.")
+}
+
+func (s *suite) Test_ListProjects_merges_readable_inventories(c *C) {
+	private := local.NewLocalInventory(filepath.Join(testDir, "private"))
+	public := local.NewLocalInventory(filepath.Join(testDir, "public"))
+	upload(c, private, "a", "app", "1.0")
+	upload(c, private, "b", "app", "1.0")
+	upload(c, public, "b", "app", "1.0")
+	upload(c, public, "c", "app", "1.0")
+	upload(c, public, "d", "app", "1.0")
+
+	unit := NewInventoryChain([]*Link{
+		&Link{Inventory: private, Read: true},
+		&Link{Inventory: public, Read: true, Exclude: []string{"d"}},
+	})
+	projects, err := unit.ListProjects()
+	c.Assert(err, IsNil)
+	c.Assert(projects, DeepEquals, []string{"a", "b", "c"})
+}
+
+func (s *suite) Test_ListProjects_skips_inventories_that_fail(c *C) {
+	public := local.NewLocalInventory(filepath.Join(testDir, "public"))
+	upload(c, public, "a", "app", "1.0")
+
+	unit := NewInventoryChain([]*Link{
+		&Link{Inventory: local.NewLocalInventory(filepath.Join(testDir, "doesnt-exist")), Read: true},
+		&Link{Inventory: public, Read: true},
+	})
+	projects, err := unit.ListProjects()
+	c.Assert(err, IsNil)
+	c.Assert(projects, DeepEquals, []string{"a"})
+}
+
+func (s *suite) Test_ListApplications_merges_readable_inventories(c *C) {
+	private := local.NewLocalInventory(filepath.Join(testDir, "private"))
+	public := local.NewLocalInventory(filepath.Join(testDir, "public"))
+	upload(c, private, "prj", "b", "1.0")
+	upload(c, public, "prj", "a", "1.0")
+	upload(c, public, "prj", "b", "1.0")
+
+	unit := NewInventoryChain([]*Link{
+		&Link{Inventory: local.NewLocalInventory(filepath.Join(testDir, "doesnt-exist")), Read: true},
+		&Link{Inventory: private, Read: true},
+		&Link{Inventory: public, Read: true},
+	})
+	apps, err := unit.ListApplications("prj")
+	c.Assert(err, IsNil)
+	c.Assert(apps, DeepEquals, []string{"a", "b"})
+
+	_, err = unit.ListApplications("unknown")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *suite) Test_ListVersions_merges_readable_inventories(c *C) {
+	private := local.NewLocalInventory(filepath.Join(testDir, "private"))
+	public := local.NewLocalInventory(filepath.Join(testDir, "public"))
+	upload(c, private, "prj", "app", "1.0")
+	upload(c, public, "prj", "app", "1.0")
+	upload(c, public, "prj", "app", "2.0")
+
+	unit := NewInventoryChain([]*Link{
+		&Link{Inventory: private, Read: true},
+		&Link{Inventory: public, Read: true},
+	})
+	versions, err := unit.ListVersions("prj", "app")
+	c.Assert(err, IsNil)
+	c.Assert(versions, HasLen, 2)
+	c.Assert(versions[0], Equals, "1.0")
+	c.Assert(versions[1], Equals, "2.0")
+}
+
+func (s *suite) Test_Login_is_not_supported(c *C) {
+	unit := NewInventoryChain([]*Link{
+		&Link{Inventory: local.NewLocalInventory(testDir), Read: true},
+	})
+	_, err := unit.GetAuthMethods("http://localhost")
+	c.Assert(err, Equals, LoginNotSupportedError)
+	_, err = unit.Login("http://localhost", "user", "pass")
+	c.Assert(err, Equals, LoginNotSupportedError)
+	c.Assert(unit.LoginWithBasicAuth("http://localhost", "user", "pass"), Equals, LoginNotSupportedError)
+}
@@ -17,7 +17,10 @@ limitations under the License.
 package inventory
 
 import (
+	"github.com/ankyra/escape/model/inventory/chain"
+	"github.com/ankyra/escape/model/inventory/lazy"
 	"github.com/ankyra/escape/model/inventory/local"
+	"github.com/ankyra/escape/model/inventory/oci"
 	"github.com/ankyra/escape/model/inventory/proxy"
 	"github.com/ankyra/escape/model/inventory/remote"
 	"github.com/ankyra/escape/model/inventory/types"
@@ -31,6 +34,18 @@ func NewRemoteInventory(apiServer, authToken, basicAuthUsername, basicAuthPasswo
 	return remote.NewRemoteInventory(apiServer, authToken, basicAuthUsername, basicAuthPassword, insecureSkipVerify)
 }
 
+func NewOCIInventory(registry, repositoryPrefix, username, password string, insecureSkipVerify bool) types.Inventory {
+	return oci.NewOCIInventory(registry, repositoryPrefix, username, password, insecureSkipVerify)
+}
+
 func NewInventoryProxy(inv types.Inventory, proxiedNamespaces []string, proxyInv types.Inventory) types.Inventory {
 	return proxy.NewInventoryProxy(inv, proxyInv, proxiedNamespaces)
 }
+
+func NewInventoryChain(links []*chain.Link) types.Inventory {
+	return chain.NewInventoryChain(links)
+}
+
+func NewLazyInventory(unauthenticated types.Inventory, load func() (types.Inventory, error)) types.Inventory {
+	return lazy.NewLazyInventory(unauthenticated, load)
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lazy
+
+import (
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape/model/inventory/types"
+)
+
+// A LazyInventory defers creating the Inventory until it's used, so that
+// setting it up (e.g. getting the credentials from a credential helper) is
+// only done by the commands that need it.
+type LazyInventory struct {
+	// Used to get the authentication methods and to login, which doesn't
+	// require the stored credentials.
+	Unauthenticated types.Inventory
+	Load            func() (types.Inventory, error)
+
+	inventory types.Inventory
+	err       error
+	loaded    bool
+}
+
+func NewLazyInventory(unauthenticated types.Inventory, load func() (types.Inventory, error)) *LazyInventory {
+	return &LazyInventory{
+		Unauthenticated: unauthenticated,
+		Load:            load,
+	}
+}
+
+// GetInventory loads the Inventory the first time it's called. Later calls
+// return the same Inventory (or error).
+func (r *LazyInventory) GetInventory() (types.Inventory, error) {
+	if !r.loaded {
+		r.inventory, r.err = r.Load()
+		r.loaded = true
+	}
+	return r.inventory, r.err
+}
+
+func (r *LazyInventory) QueryReleaseMetadata(project, name, version string) (*core.ReleaseMetadata, error) {
+	inv, err := r.GetInventory()
+	if err != nil {
+		return nil, err
+	}
+	return inv.QueryReleaseMetadata(project, name, version)
+}
+
+func (r *LazyInventory) QueryNextVersion(project, name, versionPrefix string) (string, error) {
+	inv, err := r.GetInventory()
+	if err != nil {
+		return "", err
+	}
+	return inv.QueryNextVersion(project, name, versionPrefix)
+}
+
+func (r *LazyInventory) DownloadRelease(project, name, version, targetFile string) error {
+	inv, err := r.GetInventory()
+	if err != nil {
+		return err
+	}
+	return inv.DownloadRelease(project, name, version, targetFile)
+}
+
+func (r *LazyInventory) UploadRelease(project, releasePath string, metadata *core.ReleaseMetadata) error {
+	inv, err := r.GetInventory()
+	if err != nil {
+		return err
+	}
+	return inv.UploadRelease(project, releasePath, metadata)
+}
+
+func (r *LazyInventory) TagRelease(project, name, version, tag string) error {
+	inv, err := r.GetInventory()
+	if err != nil {
+		return err
+	}
+	return inv.TagRelease(project, name, version, tag)
+}
+
+func (r *LazyInventory) DeleteRelease(project, name, version string) error {
+	inv, err := r.GetInventory()
+	if err != nil {
+		return err
+	}
+	return inv.DeleteRelease(project, name, version)
+}
+
+func (r *LazyInventory) DeprecateRelease(project, name, version, reason string) error {
+	inv, err := r.GetInventory()
+	if err != nil {
+		return err
+	}
+	return inv.DeprecateRelease(project, name, version, reason)
+}
+
+func (r *LazyInventory) YankRelease(project, name, version, reason string) error {
+	inv, err := r.GetInventory()
+	if err != nil {
+		return err
+	}
+	return inv.YankRelease(project, name, version, reason)
+}
+
+func (r *LazyInventory) ListProjects() ([]string, error) {
+	inv, err := r.GetInventory()
+	if err != nil {
+		return nil, err
+	}
+	return inv.ListProjects()
+}
+
+func (r *LazyInventory) ListApplications(project string) ([]string, error) {
+	inv, err := r.GetInventory()
+	if err != nil {
+		return nil, err
+	}
+	return inv.ListApplications(project)
+}
+
+func (r *LazyInventory) ListVersions(project, app string) ([]string, error) {
+	inv, err := r.GetInventory()
+	if err != nil {
+		return nil, err
+	}
+	return inv.ListVersions(project, app)
+}
+
+func (r *LazyInventory) GetAuthMethods(url string) (map[string]*types.AuthMethod, error) {
+	return r.Unauthenticated.GetAuthMethods(url)
+}
+
+func (r *LazyInventory) Login(url, username, password string) (string, error) {
+	return r.Unauthenticated.Login(url, username, password)
+}
+
+func (r *LazyInventory) LoginWithBasicAuth(url, username, password string) error {
+	return r.Unauthenticated.LoginWithBasicAuth(url, username, password)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lazy
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ankyra/escape/model/inventory/local"
+	"github.com/ankyra/escape/model/inventory/types"
+	. "gopkg.in/check.v1"
+)
+
+type suite struct{}
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&suite{})
+
+func (s *suite) Test_LazyInventory_loads_once(c *C) {
+	calls := 0
+	unit := NewLazyInventory(nil, func() (types.Inventory, error) {
+		calls++
+		return local.NewLocalInventory(filepath.Join(c.MkDir(), "inventory")), nil
+	})
+	c.Assert(calls, Equals, 0)
+	_, err := unit.ListProjects()
+	c.Assert(err, Not(IsNil)) // the inventory directory doesn't exist
+	_, err = unit.ListApplications("prj")
+	c.Assert(err, Not(IsNil))
+	c.Assert(calls, Equals, 1)
+}
+
+func (s *suite) Test_LazyInventory_returns_load_error(c *C) {
+	calls := 0
+	unit := NewLazyInventory(nil, func() (types.Inventory, error) {
+		calls++
+		return nil, fmt.Errorf("no passphrase")
+	})
+	_, err := unit.QueryReleaseMetadata("prj", "app", "latest")
+	c.Assert(err, ErrorMatches, "no passphrase")
+	c.Assert(unit.TagRelease("prj", "app", "1.0", "tag"), ErrorMatches, "no passphrase")
+	c.Assert(calls, Equals, 1)
+}
+
+func (s *suite) Test_LazyInventory_logs_in_without_loading(c *C) {
+	unit := NewLazyInventory(local.NewLocalInventory(c.MkDir()), func() (types.Inventory, error) {
+		c.Fatal("Shouldn't load the inventory")
+		return nil, nil
+	})
+	methods, err := unit.GetAuthMethods("http://localhost")
+	c.Assert(err, IsNil)
+	c.Assert(methods, IsNil)
+	c.Assert(unit.LoginWithBasicAuth("http://localhost", "user", "pass"), IsNil)
+}
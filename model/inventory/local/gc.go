@@ -0,0 +1,144 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	core "github.com/ankyra/escape-core"
+)
+
+// A RetentionPolicy decides which releases survive a garbage collection run.
+// A release is kept when any of the rules applies to it; everything else is
+// deleted.
+type RetentionPolicy struct {
+	// Keep the last N versions of every application. Zero disables the rule.
+	KeepLast int
+	// Keep every version that is referenced by a tag.
+	KeepTagged bool
+	// Keep every version that was uploaded less than MaxAge ago. Zero
+	// disables the rule.
+	MaxAge time.Duration
+	// Keep the releases in this set. Keys are qualified release IDs (e.g.
+	// "project/name-v1.0.0").
+	KeepReleases map[string]bool
+}
+
+func NewRetentionPolicy() *RetentionPolicy {
+	return &RetentionPolicy{
+		KeepTagged:   true,
+		KeepReleases: map[string]bool{},
+	}
+}
+
+func (p *RetentionPolicy) Validate() error {
+	if p.KeepLast < 0 {
+		return fmt.Errorf("Invalid retention policy: the number of versions to keep can't be negative.")
+	}
+	if p.MaxAge < 0 {
+		return fmt.Errorf("Invalid retention policy: the maximum age can't be negative.")
+	}
+	if p.KeepLast == 0 && p.MaxAge == 0 {
+		return fmt.Errorf("Invalid retention policy: at least one of 'keep last' or 'max age' needs to be set.")
+	}
+	return nil
+}
+
+// GarbageCollect deletes all the releases from the inventory that are not
+// protected by the retention policy and returns the IDs of the releases that
+// were deleted. If dryRun is set nothing is deleted, but the releases that
+// would have been deleted are still returned.
+func (r *LocalInventory) GarbageCollect(policy *RetentionPolicy, dryRun bool) ([]string, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	result := []string{}
+	projects, err := r.ListProjects()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+	for _, project := range projects {
+		apps, err := r.ListApplications(project)
+		if err != nil {
+			return nil, err
+		}
+		for _, app := range apps {
+			deleted, err := r.garbageCollectApplication(project, app, policy, dryRun)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, deleted...)
+		}
+	}
+	return result, nil
+}
+
+func (r *LocalInventory) garbageCollectApplication(project, name string, policy *RetentionPolicy, dryRun bool) ([]string, error) {
+	indexPath := filepath.Join(r.BaseDir, project, name, "index.json")
+	index, err := LoadVersionIndexFromFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	versions := index.GetVersions()
+	sort.Slice(versions, func(i, j int) bool {
+		return !core.NewSemanticVersion(versions[i]).LessOrEqual(core.NewSemanticVersion(versions[j]))
+	})
+	result := []string{}
+	for i, version := range versions {
+		releaseId := project + "/" + name + "-v" + version
+		if policy.KeepLast > 0 && i < policy.KeepLast {
+			continue
+		}
+		if policy.KeepTagged && index.IsTagged(version) {
+			continue
+		}
+		if policy.KeepReleases[releaseId] {
+			continue
+		}
+		if policy.MaxAge > 0 && time.Since(r.getUploadTime(index, project, name, version)) < policy.MaxAge {
+			continue
+		}
+		if !dryRun {
+			if err := r.DeleteRelease(project, name, version); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, releaseId)
+	}
+	return result, nil
+}
+
+// Indexes written by older versions of Escape don't record upload times, in
+// which case we fall back to the modification time of the release metadata.
+func (r *LocalInventory) getUploadTime(index *VersionIndex, project, name, version string) time.Time {
+	if uploaded, ok := index.UploadedAt[version]; ok {
+		return uploaded
+	}
+	metaPath := filepath.Join(r.BaseDir, project, name, name+"-v"+version+".json")
+	st, err := os.Stat(metaPath)
+	if err != nil {
+		return time.Now()
+	}
+	return st.ModTime()
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape/util"
+	. "gopkg.in/check.v1"
+)
+
+type suite struct{}
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&suite{})
+
+const testInventoryDir = "testdata_inventory"
+
+func (s *suite) SetUpTest(c *C) {
+	os.RemoveAll(testInventoryDir)
+}
+
+func (s *suite) TearDownTest(c *C) {
+	os.RemoveAll(testInventoryDir)
+}
+
+func (s *suite) uploadVersions(c *C, inv *LocalInventory, versions ...string) {
+	archive := filepath.Join(testInventoryDir, "archive.tgz")
+	c.Assert(os.MkdirAll(testInventoryDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(archive, []byte("archive"), 0644), IsNil)
+	for _, v := range versions {
+		metadata := core.NewReleaseMetadata("name", v)
+		metadata.Project = "prj"
+		c.Assert(inv.UploadRelease("prj", archive, metadata), IsNil)
+	}
+}
+
+func (s *suite) Test_DeleteRelease(c *C) {
+	inv := NewLocalInventory(testInventoryDir)
+	s.uploadVersions(c, inv, "1.0", "1.1")
+	c.Assert(inv.TagRelease("prj", "name", "1.0", "stable"), IsNil)
+
+	c.Assert(inv.DeleteRelease("prj", "name", "1.0"), IsNil)
+
+	versions, err := inv.ListVersions("prj", "name")
+	c.Assert(err, IsNil)
+	c.Assert(versions, DeepEquals, []string{"1.1"})
+	c.Assert(util.PathExists(filepath.Join(testInventoryDir, "prj", "name", "name-v1.0.tgz")), Equals, false)
+	c.Assert(util.PathExists(filepath.Join(testInventoryDir, "prj", "name", "name-v1.0.json")), Equals, false)
+	_, err = inv.resolveTagToVersion("prj", "name", "stable")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *suite) Test_DeleteRelease_fails_if_version_doesnt_exist(c *C) {
+	inv := NewLocalInventory(testInventoryDir)
+	s.uploadVersions(c, inv, "1.0")
+	err := inv.DeleteRelease("prj", "name", "2.0")
+	c.Assert(err, ErrorMatches, "The referenced version '2.0' couldn't be deleted, because it couldn't be found.")
+}
+
+func (s *suite) Test_GarbageCollect_keeps_last_tagged_and_referenced_versions(c *C) {
+	inv := NewLocalInventory(testInventoryDir)
+	s.uploadVersions(c, inv, "1", "2", "3", "4", "10")
+	c.Assert(inv.TagRelease("prj", "name", "1", "stable"), IsNil)
+
+	policy := NewRetentionPolicy()
+	policy.KeepLast = 2
+	policy.KeepReleases["prj/name-v3"] = true
+	deleted, err := inv.GarbageCollect(policy, false)
+	c.Assert(err, IsNil)
+	c.Assert(deleted, DeepEquals, []string{"prj/name-v2"})
+
+	versions, err := inv.ListVersions("prj", "name")
+	c.Assert(err, IsNil)
+	c.Assert(versions, HasLen, 4)
+}
+
+func (s *suite) Test_GarbageCollect_dry_run_doesnt_delete(c *C) {
+	inv := NewLocalInventory(testInventoryDir)
+	s.uploadVersions(c, inv, "1", "2")
+
+	policy := NewRetentionPolicy()
+	policy.KeepLast = 1
+	deleted, err := inv.GarbageCollect(policy, true)
+	c.Assert(err, IsNil)
+	c.Assert(deleted, DeepEquals, []string{"prj/name-v1"})
+
+	versions, err := inv.ListVersions("prj", "name")
+	c.Assert(err, IsNil)
+	c.Assert(versions, HasLen, 2)
+}
+
+func (s *suite) Test_GarbageCollect_max_age(c *C) {
+	inv := NewLocalInventory(testInventoryDir)
+	s.uploadVersions(c, inv, "1", "2")
+	indexPath := filepath.Join(testInventoryDir, "prj", "name", "index.json")
+	index, err := LoadVersionIndexFromFile(indexPath)
+	c.Assert(err, IsNil)
+	index.UploadedAt["1"] = time.Now().Add(-48 * time.Hour)
+	c.Assert(index.Save(), IsNil)
+
+	policy := NewRetentionPolicy()
+	policy.MaxAge = 24 * time.Hour
+	deleted, err := inv.GarbageCollect(policy, false)
+	c.Assert(err, IsNil)
+	c.Assert(deleted, DeepEquals, []string{"prj/name-v1"})
+}
+
+func (s *suite) Test_GarbageCollect_requires_a_retention_rule(c *C) {
+	inv := NewLocalInventory(testInventoryDir)
+	_, err := inv.GarbageCollect(NewRetentionPolicy(), true)
+	c.Assert(err, ErrorMatches, "Invalid retention policy: .*")
+}
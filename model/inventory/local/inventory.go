@@ -47,7 +47,7 @@ func (r *LocalInventory) QueryReleaseMetadata(project, name, version string) (*c
 	}
 	metaPath := filepath.Join(r.BaseDir, project, name, name+"-v"+version+".json")
 	if !util.PathExists(metaPath) {
-		return nil, fmt.Errorf("The release %s/%s-v%s could not be found in the local inventory at %s. You may have to release it first?", project, name, version, r.BaseDir)
+		return nil, types.NewNotFoundError("The release %s/%s-v%s could not be found in the local inventory at %s. You may have to release it first?", project, name, version, r.BaseDir)
 	}
 	return core.NewReleaseMetadataFromFile(metaPath)
 }
@@ -68,6 +68,39 @@ func (r *LocalInventory) TagRelease(project, name, version, tag string) error {
 	return index.Save()
 }
 
+func (r *LocalInventory) DeprecateRelease(project, name, version, reason string) error {
+	return r.updateRelease(project, name, version, func(index *VersionIndex) error {
+		return index.DeprecateRelease(version, reason)
+	})
+}
+
+func (r *LocalInventory) YankRelease(project, name, version, reason string) error {
+	return r.updateRelease(project, name, version, func(index *VersionIndex) error {
+		return index.YankRelease(version, reason)
+	})
+}
+
+// The release metadata is stored in both the index and the metadata file, so
+// changes are applied to the index and then written out to both.
+func (r *LocalInventory) updateRelease(project, name, version string, update func(*VersionIndex) error) error {
+	indexPath := filepath.Join(r.BaseDir, project, name, "index.json")
+	if !util.PathExists(indexPath) {
+		return fmt.Errorf("The application '%s/%s' could not be found in the local inventory at %s.", project, name, r.BaseDir)
+	}
+	index, err := LoadVersionIndexFromFile(indexPath)
+	if err != nil {
+		return err
+	}
+	if err := update(index); err != nil {
+		return err
+	}
+	metaPath := filepath.Join(r.BaseDir, project, name, name+"-v"+version+".json")
+	if err := index.Versions[version].WriteJsonFile(metaPath); err != nil {
+		return fmt.Errorf("Could not write release metadata file %s: %s", metaPath, err.Error())
+	}
+	return index.Save()
+}
+
 func (r *LocalInventory) resolveReleaseVersion(project, name, version string) (string, error) {
 	query, err := parsers.ParseVersionQuery(version)
 	if err != nil {
@@ -80,7 +113,7 @@ func (r *LocalInventory) resolveReleaseVersion(project, name, version string) (s
 	} else if query.SpecificTag != "" {
 		v, err := r.resolveTagToVersion(project, name, query.SpecificTag)
 		if err != nil {
-			return "", fmt.Errorf("The application %s/%s:%s could not be found", project, name, version)
+			return "", types.NewNotFoundError("The application %s/%s:%s could not be found", project, name, version)
 		}
 		return v, nil
 	}
@@ -105,7 +138,7 @@ func (r *LocalInventory) getLastVersionForPrefix(project, name, prefix string) (
 	if err != nil {
 		return "", fmt.Errorf("The application '%s/%s' could not be found: %s", project, name, err.Error())
 	}
-	return prefix + getMaxFromVersions(index.GetVersions(), prefix).ToString(), nil
+	return prefix + getMaxFromVersions(index.GetResolvableVersions(), prefix).ToString(), nil
 }
 
 func (r *LocalInventory) QueryNextVersion(project, name, versionPrefix string) (string, error) {
@@ -143,7 +176,7 @@ func getMaxFromVersions(versions []string, prefix string) *core.SemanticVersion
 func (r *LocalInventory) DownloadRelease(project, name, version, targetFile string) error {
 	path := filepath.Join(r.BaseDir, project, name, name+"-"+version+".tgz")
 	if !util.PathExists(path) {
-		return fmt.Errorf("The release %s/%s-%s could not be found in the local inventory (expected at %s)", project, name, version, path)
+		return types.NewNotFoundError("The release %s/%s-%s could not be found in the local inventory (expected at %s)", project, name, version, path)
 	}
 	return util.CopyFile(path, targetFile)
 }
@@ -172,6 +205,28 @@ func (r *LocalInventory) UploadRelease(project, releasePath string, metadata *co
 	return index.Save()
 }
 
+func (r *LocalInventory) DeleteRelease(project, name, version string) error {
+	indexPath := filepath.Join(r.BaseDir, project, name, "index.json")
+	if !util.PathExists(indexPath) {
+		return fmt.Errorf("The application '%s/%s' could not be found in the local inventory at %s.", project, name, r.BaseDir)
+	}
+	index, err := LoadVersionIndexFromFile(indexPath)
+	if err != nil {
+		return err
+	}
+	if err := index.DeleteRelease(version); err != nil {
+		return err
+	}
+	releaseId := name + "-v" + version
+	for _, ext := range []string{".tgz", ".json"} {
+		path := filepath.Join(r.BaseDir, project, name, releaseId+ext)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Could not remove '%s' from the local inventory: %s", path, err.Error())
+		}
+	}
+	return index.Save()
+}
+
 func (r *LocalInventory) ListProjects() ([]string, error) {
 	path := r.BaseDir
 	result := []string{}
@@ -0,0 +1,80 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *suite) Test_YankRelease_excludes_version_from_resolution(c *C) {
+	inv := NewLocalInventory(testInventoryDir)
+	s.uploadVersions(c, inv, "1.0", "1.1", "2.0")
+
+	c.Assert(inv.YankRelease("prj", "name", "2.0", "broken"), IsNil)
+	c.Assert(inv.YankRelease("prj", "name", "1.1", "broken"), IsNil)
+
+	metadata, err := inv.QueryReleaseMetadata("prj", "name", "latest")
+	c.Assert(err, IsNil)
+	c.Assert(metadata.Version, Equals, "1.0")
+
+	metadata, err = inv.QueryReleaseMetadata("prj", "name", "v1.@")
+	c.Assert(err, IsNil)
+	c.Assert(metadata.Version, Equals, "1.0")
+}
+
+func (s *suite) Test_YankRelease_can_still_fetch_exact_version(c *C) {
+	inv := NewLocalInventory(testInventoryDir)
+	s.uploadVersions(c, inv, "1.0")
+
+	c.Assert(inv.YankRelease("prj", "name", "1.0", "broken"), IsNil)
+
+	metadata, err := inv.QueryReleaseMetadata("prj", "name", "v1.0")
+	c.Assert(err, IsNil)
+	c.Assert(metadata.Yanked, Equals, true)
+	c.Assert(metadata.YankedReason, Equals, "broken")
+}
+
+func (s *suite) Test_YankRelease_doesnt_reuse_version(c *C) {
+	inv := NewLocalInventory(testInventoryDir)
+	s.uploadVersions(c, inv, "1.0")
+
+	c.Assert(inv.YankRelease("prj", "name", "1.0", "broken"), IsNil)
+
+	next, err := inv.QueryNextVersion("prj", "name", "")
+	c.Assert(err, IsNil)
+	c.Assert(next, Equals, "2")
+}
+
+func (s *suite) Test_DeprecateRelease(c *C) {
+	inv := NewLocalInventory(testInventoryDir)
+	s.uploadVersions(c, inv, "1.0")
+
+	c.Assert(inv.DeprecateRelease("prj", "name", "1.0", "use v2"), IsNil)
+
+	metadata, err := inv.QueryReleaseMetadata("prj", "name", "latest")
+	c.Assert(err, IsNil)
+	c.Assert(metadata.Version, Equals, "1.0")
+	c.Assert(metadata.Deprecated, Equals, true)
+	c.Assert(metadata.DeprecatedReason, Equals, "use v2")
+}
+
+func (s *suite) Test_YankRelease_fails_if_version_doesnt_exist(c *C) {
+	inv := NewLocalInventory(testInventoryDir)
+	s.uploadVersions(c, inv, "1.0")
+	err := inv.YankRelease("prj", "name", "2.0", "broken")
+	c.Assert(err, ErrorMatches, "The referenced version '2.0' couldn't be yanked, because it couldn't be found.")
+}
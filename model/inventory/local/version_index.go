@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"time"
 
 	core "github.com/ankyra/escape-core"
 	"github.com/ankyra/escape/util"
@@ -33,6 +34,7 @@ type VersionIndex struct {
 	CoreAPIVersion    int                              `json:"core_api_version"`
 	Versions          map[string]*core.ReleaseMetadata `json:"versions"`
 	Tags              map[string]string                `json:"tags"`
+	UploadedAt        map[string]time.Time             `json:"uploaded_at"`
 }
 
 func NewVersionIndex() *VersionIndex {
@@ -42,6 +44,7 @@ func NewVersionIndex() *VersionIndex {
 		CoreAPIVersion:    core.CurrentApiVersion,
 		Versions:          map[string]*core.ReleaseMetadata{},
 		Tags:              map[string]string{},
+		UploadedAt:        map[string]time.Time{},
 	}
 }
 
@@ -88,6 +91,21 @@ func (v *VersionIndex) AddRelease(m *core.ReleaseMetadata) error {
 		return fmt.Errorf("Could not add release to local inventory at %s. Version %s already exists.", v.Path, m.Version)
 	}
 	v.Versions[m.Version] = m
+	v.UploadedAt[m.Version] = time.Now()
+	return nil
+}
+
+func (v *VersionIndex) DeleteRelease(version string) error {
+	if _, ok := v.Versions[version]; !ok {
+		return fmt.Errorf("The referenced version '%s' couldn't be deleted, because it couldn't be found.", version)
+	}
+	delete(v.Versions, version)
+	delete(v.UploadedAt, version)
+	for tag, taggedVersion := range v.Tags {
+		if taggedVersion == version {
+			delete(v.Tags, tag)
+		}
+	}
 	return nil
 }
 
@@ -107,6 +125,35 @@ func (v *VersionIndex) ResolveTagToVersion(tag string) (string, error) {
 	return version, nil
 }
 
+func (v *VersionIndex) DeprecateRelease(version, reason string) error {
+	m, ok := v.Versions[version]
+	if !ok {
+		return fmt.Errorf("The referenced version '%s' couldn't be deprecated, because it couldn't be found.", version)
+	}
+	m.Deprecated = true
+	m.DeprecatedReason = reason
+	return nil
+}
+
+func (v *VersionIndex) YankRelease(version, reason string) error {
+	m, ok := v.Versions[version]
+	if !ok {
+		return fmt.Errorf("The referenced version '%s' couldn't be yanked, because it couldn't be found.", version)
+	}
+	m.Yanked = true
+	m.YankedReason = reason
+	return nil
+}
+
+func (v *VersionIndex) IsTagged(version string) bool {
+	for _, taggedVersion := range v.Tags {
+		if taggedVersion == version {
+			return true
+		}
+	}
+	return false
+}
+
 func (v *VersionIndex) GetVersions() []string {
 	versions := []string{}
 	for version := range v.Versions {
@@ -114,3 +161,16 @@ func (v *VersionIndex) GetVersions() []string {
 	}
 	return versions
 }
+
+// GetResolvableVersions returns the versions that can be returned for version
+// queries such as "latest" and "v1.@"; i.e. all the versions that haven't
+// been yanked.
+func (v *VersionIndex) GetResolvableVersions() []string {
+	versions := []string{}
+	for version, m := range v.Versions {
+		if m == nil || !m.Yanked {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
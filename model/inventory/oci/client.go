@@ -0,0 +1,235 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// The registryClient speaks the OCI distribution API. It authenticates using
+// basic auth and, when the registry asks for it, by exchanging the
+// credentials for a bearer token at the token server the registry points to
+// in its WWW-Authenticate challenge.
+type registryClient struct {
+	Registry           string
+	Username           string
+	Password           string
+	InsecureSkipVerify bool
+	token              string
+}
+
+func newRegistryClient(registry, username, password string, insecureSkipVerify bool) *registryClient {
+	return &registryClient{
+		Registry:           strings.TrimRight(registry, "/"),
+		Username:           username,
+		Password:           password,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+}
+
+// A requestBody can be opened multiple times, because requests need to be
+// replayed after an authentication challenge.
+type requestBody interface {
+	Open() (io.ReadCloser, int64, error)
+}
+
+type bytesBody []byte
+
+func (b bytesBody) Open() (io.ReadCloser, int64, error) {
+	return ioutil.NopCloser(bytes.NewReader(b)), int64(len(b)), nil
+}
+
+type fileBody string
+
+func (f fileBody) Open() (io.ReadCloser, int64, error) {
+	fh, err := os.Open(string(f))
+	if err != nil {
+		return nil, 0, err
+	}
+	st, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return nil, 0, err
+	}
+	return fh, st.Size(), nil
+}
+
+func (c *registryClient) getHTTPClient() *http.Client {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify},
+	}
+	return &http.Client{
+		Transport: transport,
+	}
+}
+
+// resolve turns paths and (relative) Location headers into absolute URLs.
+func (c *registryClient) resolve(location string) (string, error) {
+	base, err := url.Parse(c.Registry + "/")
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+func (c *registryClient) Do(method, location string, headers map[string]string, body requestBody) (*http.Response, error) {
+	resp, err := c.do(method, location, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 401 {
+		return resp, nil
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return nil, fmt.Errorf("The OCI registry at '%s' rejected the credentials. Please check the 'basic_auth_username' and 'basic_auth_password' in your Escape profile.", c.Registry)
+	}
+	if err := c.fetchToken(parseChallenge(challenge[len("bearer "):])); err != nil {
+		return nil, err
+	}
+	return c.do(method, location, headers, body)
+}
+
+func (c *registryClient) do(method, location string, headers map[string]string, body requestBody) (*http.Response, error) {
+	u, err := c.resolve(location)
+	if err != nil {
+		return nil, err
+	}
+	var reader io.ReadCloser
+	var size int64
+	if body != nil {
+		reader, size, err = body.Open()
+		if err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		if reader != nil {
+			reader.Close()
+		}
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	return c.getHTTPClient().Do(req)
+}
+
+// fetchToken gets a bearer token from the token server for the scope in the
+// challenge. The token is reused until the registry rejects it, which happens
+// when the next request needs a different scope (e.g. another repository).
+func (c *registryClient) fetchToken(params map[string]string) error {
+	realm := params["realm"]
+	if realm == "" {
+		return fmt.Errorf("The OCI registry at '%s' asked for a bearer token, but didn't say where to get one.", c.Registry)
+	}
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		query.Set("scope", params["scope"])
+	}
+	u := realm
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.getHTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("Couldn't get a token for the OCI registry at '%s': %s", c.Registry, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Couldn't get a token for the OCI registry at '%s', because the token server responded with status code %d.", c.Registry, resp.StatusCode)
+	}
+	result := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("Couldn't get a token for the OCI registry at '%s': %s", c.Registry, err.Error())
+	}
+	if result.Token == "" {
+		result.Token = result.AccessToken
+	}
+	c.token = result.Token
+	return nil
+}
+
+// parseChallenge parses the key="value" pairs in a WWW-Authenticate header.
+func parseChallenge(challenge string) map[string]string {
+	result := map[string]string{}
+	for challenge != "" {
+		challenge = strings.TrimLeft(challenge, " ,")
+		eq := strings.Index(challenge, "=")
+		if eq == -1 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(challenge[:eq]))
+		challenge = challenge[eq+1:]
+		value := ""
+		if strings.HasPrefix(challenge, "\"") {
+			end := strings.Index(challenge[1:], "\"")
+			if end == -1 {
+				value = challenge[1:]
+				challenge = ""
+			} else {
+				value = challenge[1 : end+1]
+				challenge = challenge[end+2:]
+			}
+		} else {
+			end := strings.Index(challenge, ",")
+			if end == -1 {
+				end = len(challenge)
+			}
+			value = challenge[:end]
+			challenge = challenge[end:]
+		}
+		result[key] = value
+	}
+	return result
+}
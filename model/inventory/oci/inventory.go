@@ -0,0 +1,643 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape-core/parsers"
+	"github.com/ankyra/escape/model/inventory/types"
+)
+
+const ManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+const ReleaseArtifactType = "application/vnd.ankyra.escape.release.v1"
+const ReleaseMetadataMediaType = "application/vnd.ankyra.escape.release.metadata.v1+json"
+const ReleaseArchiveMediaType = "application/vnd.ankyra.escape.release.v1.tar+gzip"
+
+// Versions are stored as OCI tags (e.g. "1.0.3"), next to the tags created
+// with `escape tag`. Release tags can't look like versions, because the
+// version query parser would never resolve them as tags.
+var versionTagRegex = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type Manifest struct {
+	SchemaVersion int           `json:"schemaVersion"`
+	MediaType     string        `json:"mediaType"`
+	ArtifactType  string        `json:"artifactType,omitempty"`
+	Config        *Descriptor   `json:"config"`
+	Layers        []*Descriptor `json:"layers"`
+}
+
+// The OCIInventory stores releases in an OCI registry. Every application is
+// a repository ("<prefix>/<project>/<name>"). A release is an OCI artifact
+// whose config blob is the release metadata and whose only layer is the
+// release archive.
+type OCIInventory struct {
+	Registry         string
+	RepositoryPrefix string
+	client           *registryClient
+}
+
+func NewOCIInventory(registry, repositoryPrefix, username, password string, insecureSkipVerify bool) *OCIInventory {
+	return &OCIInventory{
+		Registry:         strings.TrimRight(registry, "/"),
+		RepositoryPrefix: strings.Trim(repositoryPrefix, "/"),
+		client:           newRegistryClient(registry, username, password, insecureSkipVerify),
+	}
+}
+
+func (r *OCIInventory) repository(project, name string) string {
+	if r.RepositoryPrefix == "" {
+		return project + "/" + name
+	}
+	return r.RepositoryPrefix + "/" + project + "/" + name
+}
+
+func (r *OCIInventory) unexpectedStatus(action string, resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		return fmt.Errorf("%s, because the OCI registry at '%s' responded with status code %d.", action, r.Registry, resp.StatusCode)
+	}
+	return fmt.Errorf("%s, because the OCI registry at '%s' responded with status code %d: %s", action, r.Registry, resp.StatusCode, msg)
+}
+
+func (r *OCIInventory) QueryReleaseMetadata(project, name, version string) (*core.ReleaseMetadata, error) {
+	version, err := r.resolveReleaseVersion(project, name, version)
+	if err != nil {
+		return nil, err
+	}
+	_, _, metadata, err := r.getRelease(project, name, version)
+	return metadata, err
+}
+
+func (r *OCIInventory) resolveReleaseVersion(project, name, version string) (string, error) {
+	query, err := parsers.ParseVersionQuery(version)
+	if err != nil {
+		return "", err
+	}
+	if query.LatestVersion {
+		return r.getLastVersionForPrefix(project, name, "")
+	} else if query.VersionPrefix != "" {
+		return r.getLastVersionForPrefix(project, name, query.VersionPrefix)
+	} else if query.SpecificTag != "" {
+		_, _, metadata, err := r.getRelease(project, name, query.SpecificTag)
+		if types.IsNotFound(err) {
+			return "", types.NewNotFoundError("The application %s/%s:%s could not be found", project, name, version)
+		} else if err != nil {
+			return "", err
+		}
+		return metadata.Version, nil
+	}
+	return query.SpecificVersion, nil
+}
+
+// getLastVersionForPrefix skips yanked releases. The yanked flag lives in the
+// release metadata, so the candidates are checked from newest to oldest.
+func (r *OCIInventory) getLastVersionForPrefix(project, name, prefix string) (string, error) {
+	versions, err := r.listVersionTags(project, name)
+	if err != nil {
+		return "", err
+	}
+	candidates := []*core.SemanticVersion{}
+	for _, v := range versions {
+		if strings.HasPrefix(v, prefix) {
+			candidates = append(candidates, core.NewSemanticVersion(v[len(prefix):]))
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return !candidates[i].LessOrEqual(candidates[j])
+	})
+	for _, candidate := range candidates {
+		_, _, metadata, err := r.getRelease(project, name, prefix+candidate.ToString())
+		if err != nil {
+			return "", err
+		}
+		if !metadata.Yanked {
+			return prefix + candidate.ToString(), nil
+		}
+	}
+	return prefix + "0", nil
+}
+
+func (r *OCIInventory) QueryNextVersion(project, name, versionPrefix string) (string, error) {
+	versions, err := r.listVersionTags(project, name)
+	if err != nil {
+		return "", err
+	}
+	semver := core.NewSemanticVersion("-1")
+	for _, v := range versions {
+		if strings.HasPrefix(v, versionPrefix) {
+			newver := core.NewSemanticVersion(v[len(versionPrefix):])
+			if semver.LessOrEqual(newver) {
+				semver = newver
+			}
+		}
+	}
+	semver.OnlyKeepLeadingVersionPart()
+	if err := semver.IncrementSmallest(); err != nil {
+		return "", err
+	}
+	return versionPrefix + semver.ToString(), nil
+}
+
+func (r *OCIInventory) DownloadRelease(project, name, version, targetFile string) error {
+	releaseId := project + "/" + name + "-v" + version
+	manifest, _, err := r.getManifest(project, name, version)
+	if err != nil {
+		return err
+	}
+	if manifest == nil || len(manifest.Layers) == 0 {
+		return types.NewNotFoundError("The release %s could not be found in the OCI registry at %s.", releaseId, r.Registry)
+	}
+	resp, err := r.client.Do("GET", "v2/"+r.repository(project, name)+"/blobs/"+manifest.Layers[0].Digest, nil, nil)
+	if err != nil {
+		return fmt.Errorf("Couldn't download release '%s', because the OCI registry at '%s' could not be reached: %s", releaseId, r.Registry, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return r.unexpectedStatus("Couldn't download release '"+releaseId+"'", resp)
+	}
+	fp, err := os.Create(targetFile)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	if _, err := io.Copy(fp, resp.Body); err != nil {
+		return fmt.Errorf("Couldn't download release '%s': %s", releaseId, err.Error())
+	}
+	return nil
+}
+
+func (r *OCIInventory) UploadRelease(project, releasePath string, metadata *core.ReleaseMetadata) error {
+	releaseId := project + "/" + metadata.GetReleaseId()
+	existing, _, err := r.getManifest(project, metadata.Name, metadata.Version)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("Couldn't upload release '%s', because version %s already exists in the OCI registry at %s.", releaseId, metadata.Version, r.Registry)
+	}
+	repository := r.repository(project, metadata.Name)
+	archiveDigest, archiveSize, err := fileDigest(releasePath)
+	if err != nil {
+		return fmt.Errorf("Couldn't upload release '%s': %s", releaseId, err.Error())
+	}
+	if err := r.uploadBlob(repository, archiveDigest, fileBody(releasePath)); err != nil {
+		return fmt.Errorf("Couldn't upload release '%s': %s", releaseId, err.Error())
+	}
+	archive := &Descriptor{
+		MediaType: ReleaseArchiveMediaType,
+		Digest:    archiveDigest,
+		Size:      archiveSize,
+		Annotations: map[string]string{
+			"org.opencontainers.image.title": filepath.Base(releasePath),
+		},
+	}
+	return r.putRelease(project, metadata, archive, []string{metadata.Version})
+}
+
+// putRelease uploads the metadata as the config blob and points the given
+// tags at a manifest referencing it and the (already uploaded) archive.
+func (r *OCIInventory) putRelease(project string, metadata *core.ReleaseMetadata, archive *Descriptor, tags []string) error {
+	releaseId := project + "/" + metadata.GetReleaseId()
+	repository := r.repository(project, metadata.Name)
+	config := []byte(metadata.ToJson())
+	configDigest := bytesDigest(config)
+	if err := r.uploadBlob(repository, configDigest, bytesBody(config)); err != nil {
+		return fmt.Errorf("Couldn't upload release '%s': %s", releaseId, err.Error())
+	}
+	manifest := &Manifest{
+		SchemaVersion: 2,
+		MediaType:     ManifestMediaType,
+		ArtifactType:  ReleaseArtifactType,
+		Config: &Descriptor{
+			MediaType: ReleaseMetadataMediaType,
+			Digest:    configDigest,
+			Size:      int64(len(config)),
+		},
+		Layers: []*Descriptor{archive},
+	}
+	payload, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := r.putManifest(repository, tag, payload); err != nil {
+			return fmt.Errorf("Couldn't upload release '%s': %s", releaseId, err.Error())
+		}
+	}
+	return nil
+}
+
+func (r *OCIInventory) uploadBlob(repository, digest string, body requestBody) error {
+	resp, err := r.client.Do("HEAD", "v2/"+repository+"/blobs/"+digest, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == 200 {
+		return nil
+	}
+	resp, err = r.client.Do("POST", "v2/"+repository+"/blobs/uploads/", nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 202 {
+		return fmt.Errorf("the registry responded with status code %d when starting the upload", resp.StatusCode)
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return fmt.Errorf("the registry didn't return an upload location")
+	}
+	if strings.Contains(location, "?") {
+		location += "&digest=" + digest
+	} else {
+		location += "?digest=" + digest
+	}
+	headers := map[string]string{"Content-Type": "application/octet-stream"}
+	resp, err = r.client.Do("PUT", location, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 201 {
+		return fmt.Errorf("the registry responded with status code %d when uploading blob %s", resp.StatusCode, digest)
+	}
+	return nil
+}
+
+func (r *OCIInventory) putManifest(repository, tag string, payload []byte) error {
+	headers := map[string]string{"Content-Type": ManifestMediaType}
+	resp, err := r.client.Do("PUT", "v2/"+repository+"/manifests/"+tag, headers, bytesBody(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 201 {
+		return fmt.Errorf("the registry responded with status code %d when writing tag %s", resp.StatusCode, tag)
+	}
+	return nil
+}
+
+// getManifest returns a nil manifest if the reference (a tag or digest)
+// doesn't exist. The digest of the manifest is returned as well.
+func (r *OCIInventory) getManifest(project, name, reference string) (*Manifest, string, error) {
+	headers := map[string]string{"Accept": ManifestMediaType}
+	resp, err := r.client.Do("GET", "v2/"+r.repository(project, name)+"/manifests/"+reference, headers, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("Couldn't get release '%s/%s:%s', because the OCI registry at '%s' could not be reached: %s", project, name, reference, r.Registry, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return nil, "", nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, "", r.unexpectedStatus("Couldn't get release '"+project+"/"+name+":"+reference+"'", resp)
+	}
+	payload, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(payload, manifest); err != nil {
+		return nil, "", fmt.Errorf("Couldn't parse the manifest for '%s/%s:%s': %s", project, name, reference, err.Error())
+	}
+	if manifest.Config == nil || manifest.Config.MediaType != ReleaseMetadataMediaType {
+		return nil, "", fmt.Errorf("The artifact '%s/%s:%s' in the OCI registry at '%s' is not an Escape release.", project, name, reference, r.Registry)
+	}
+	return manifest, bytesDigest(payload), nil
+}
+
+// getRelease fetches the manifest, its digest and the release metadata for a
+// tag or version.
+func (r *OCIInventory) getRelease(project, name, reference string) (*Manifest, string, *core.ReleaseMetadata, error) {
+	manifest, digest, err := r.getManifest(project, name, reference)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if manifest == nil {
+		return nil, "", nil, types.NewNotFoundError("The release %s/%s:%s could not be found in the OCI registry at %s. You may have to release it first?", project, name, reference, r.Registry)
+	}
+	resp, err := r.client.Do("GET", "v2/"+r.repository(project, name)+"/blobs/"+manifest.Config.Digest, nil, nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, "", nil, r.unexpectedStatus("Couldn't get release metadata for '"+project+"/"+name+":"+reference+"'", resp)
+	}
+	payload, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	metadata, err := core.NewReleaseMetadataFromJsonString(string(payload))
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return manifest, digest, metadata, nil
+}
+
+func (r *OCIInventory) TagRelease(project, name, version, tag string) error {
+	if versionTagRegex.MatchString(tag) {
+		return fmt.Errorf("Invalid tag '%s'. Tags can't look like versions.", tag)
+	}
+	version, err := r.resolveReleaseVersion(project, name, version)
+	if err != nil {
+		return err
+	}
+	manifest, _, err := r.getManifest(project, name, version)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		return fmt.Errorf("The referenced version '%s' couldn't be tagged, because it couldn't be found.", version)
+	}
+	payload, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return r.putManifest(r.repository(project, name), tag, payload)
+}
+
+func (r *OCIInventory) DeleteRelease(project, name, version string) error {
+	manifest, digest, err := r.getManifest(project, name, version)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		return fmt.Errorf("The referenced version '%s' couldn't be deleted, because it couldn't be found.", version)
+	}
+	resp, err := r.client.Do("DELETE", "v2/"+r.repository(project, name)+"/manifests/"+digest, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 202 {
+		return r.unexpectedStatus("Couldn't delete release '"+project+"/"+name+"-v"+version+"'", resp)
+	}
+	return nil
+}
+
+func (r *OCIInventory) DeprecateRelease(project, name, version, reason string) error {
+	return r.updateRelease(project, name, version, func(m *core.ReleaseMetadata) {
+		m.Deprecated = true
+		m.DeprecatedReason = reason
+	})
+}
+
+func (r *OCIInventory) YankRelease(project, name, version, reason string) error {
+	return r.updateRelease(project, name, version, func(m *core.ReleaseMetadata) {
+		m.Yanked = true
+		m.YankedReason = reason
+	})
+}
+
+// Manifests are immutable, so updating the metadata creates a new manifest.
+// The version tag and all the tags pointing at the old manifest are moved
+// over to the new one.
+func (r *OCIInventory) updateRelease(project, name, version string, update func(*core.ReleaseMetadata)) error {
+	manifest, digest, metadata, err := r.getRelease(project, name, version)
+	if err != nil {
+		return err
+	}
+	tags, err := r.listTags(project, name)
+	if err != nil {
+		return err
+	}
+	moveTags := []string{version}
+	for _, tag := range tags {
+		if versionTagRegex.MatchString(tag) {
+			continue
+		}
+		_, tagDigest, err := r.getManifest(project, name, tag)
+		if err != nil {
+			return err
+		}
+		if tagDigest == digest {
+			moveTags = append(moveTags, tag)
+		}
+	}
+	update(metadata)
+	return r.putRelease(project, metadata, manifest.Layers[0], moveTags)
+}
+
+func (r *OCIInventory) listTags(project, name string) ([]string, error) {
+	resp, err := r.client.Do("GET", "v2/"+r.repository(project, name)+"/tags/list", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list versions for application '%s' in project '%s', because the OCI registry at '%s' could not be reached: %s", name, project, r.Registry, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return []string{}, nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, r.unexpectedStatus("Couldn't list versions for application '"+name+"' in project '"+project+"'", resp)
+	}
+	result := struct {
+		Tags []string `json:"tags"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Tags, nil
+}
+
+func (r *OCIInventory) listVersionTags(project, name string) ([]string, error) {
+	tags, err := r.listTags(project, name)
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, tag := range tags {
+		if versionTagRegex.MatchString(tag) {
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
+// listRepositories uses the catalog API and returns the repositories under
+// the repository prefix, with the prefix stripped.
+func (r *OCIInventory) listRepositories() ([]string, error) {
+	result := []string{}
+	location := "v2/_catalog"
+	for location != "" {
+		resp, err := r.client.Do("GET", location, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't list projects, because the OCI registry at '%s' could not be reached: %s", r.Registry, err.Error())
+		}
+		if resp.StatusCode != 200 {
+			defer resp.Body.Close()
+			return nil, r.unexpectedStatus("Couldn't list projects", resp)
+		}
+		catalog := struct {
+			Repositories []string `json:"repositories"`
+		}{}
+		err = json.NewDecoder(resp.Body).Decode(&catalog)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, repo := range catalog.Repositories {
+			if r.RepositoryPrefix != "" {
+				if !strings.HasPrefix(repo, r.RepositoryPrefix+"/") {
+					continue
+				}
+				repo = repo[len(r.RepositoryPrefix)+1:]
+			}
+			result = append(result, repo)
+		}
+		location = nextLink(resp.Header.Get("Link"))
+	}
+	return result, nil
+}
+
+// nextLink parses the pagination Link header: `</v2/_catalog?last=x&n=y>; rel="next"`
+func nextLink(header string) string {
+	if !strings.Contains(header, `rel="next"`) {
+		return ""
+	}
+	start := strings.Index(header, "<")
+	end := strings.Index(header, ">")
+	if start == -1 || end < start {
+		return ""
+	}
+	return header[start+1 : end]
+}
+
+func (r *OCIInventory) ListProjects() ([]string, error) {
+	repositories, err := r.listRepositories()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	result := []string{}
+	for _, repo := range repositories {
+		parts := strings.Split(repo, "/")
+		if len(parts) != 2 || seen[parts[0]] {
+			continue
+		}
+		seen[parts[0]] = true
+		result = append(result, parts[0])
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (r *OCIInventory) ListApplications(project string) ([]string, error) {
+	repositories, err := r.listRepositories()
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, repo := range repositories {
+		parts := strings.Split(repo, "/")
+		if len(parts) == 2 && parts[0] == project {
+			result = append(result, parts[1])
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("The project '%s' could not be found in the OCI registry at %s.", project, r.Registry)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (r *OCIInventory) ListVersions(project, app string) ([]string, error) {
+	versions, err := r.listVersionTags(project, app)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("The application '%s/%s' could not be found in the OCI registry at %s.", project, app, r.Registry)
+	}
+	return versions, nil
+}
+
+// Registries only support basic authentication (which may be exchanged for a
+// bearer token behind the scenes), so there's no token to return from Login.
+func (r *OCIInventory) Login(url, username, password string) (string, error) { return "", nil }
+func (r *OCIInventory) GetAuthMethods(url string) (map[string]*types.AuthMethod, error) {
+	return map[string]*types.AuthMethod{
+		"basic-auth": &types.AuthMethod{
+			Type: "basic-auth",
+			URL:  r.getRegistry(url),
+		},
+	}, nil
+}
+
+func (r *OCIInventory) LoginWithBasicAuth(url, username, password string) error {
+	registry := r.getRegistry(url)
+	client := newRegistryClient(registry, username, password, r.client.InsecureSkipVerify)
+	resp, err := client.Do("GET", "v2/", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Couldn't login to the OCI registry at '%s', because it responded with status code %d.", registry, resp.StatusCode)
+	}
+	return nil
+}
+
+// getRegistry returns the registry to log in to: the url passed to 'escape
+// login', or the configured registry if there is none.
+func (r *OCIInventory) getRegistry(url string) string {
+	if url == "" {
+		return r.Registry
+	}
+	return strings.TrimRight(url, "/")
+}
+
+func bytesDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func fileDigest(path string) (string, int64, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer fh.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, fh)
+	if err != nil {
+		return "", 0, err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), size, nil
+}
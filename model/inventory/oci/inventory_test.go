@@ -0,0 +1,381 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	core "github.com/ankyra/escape-core"
+	. "gopkg.in/check.v1"
+)
+
+type suite struct{}
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&suite{})
+
+const testDir = "testdata_oci"
+
+// testRegistry is a minimal in-memory implementation of the parts of the OCI
+// distribution API that the inventory uses.
+type testRegistry struct {
+	sync.Mutex
+	blobs        map[string][]byte
+	manifests    map[string]map[string][]byte
+	tags         map[string]map[string]string
+	uploads      int
+	requireToken bool
+	server       *httptest.Server
+}
+
+func newTestRegistry(requireToken bool) *testRegistry {
+	reg := &testRegistry{
+		blobs:        map[string][]byte{},
+		manifests:    map[string]map[string][]byte{},
+		tags:         map[string]map[string]string{},
+		requireToken: requireToken,
+	}
+	reg.server = httptest.NewServer(reg)
+	return reg
+}
+
+func (t *testRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.Lock()
+	defer t.Unlock()
+	if r.URL.Path == "/token" {
+		user, pass, _ := r.BasicAuth()
+		if user != "user" || pass != "pass" {
+			w.WriteHeader(401)
+			return
+		}
+		fmt.Fprint(w, `{"token": "secret-token"}`)
+		return
+	}
+	if t.requireToken && r.Header.Get("Authorization") != "Bearer secret-token" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+t.server.URL+`/token",service="test",scope="registry:catalog:*"`)
+		w.WriteHeader(401)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	if r.URL.Path == "/v2/" {
+		w.WriteHeader(200)
+		return
+	}
+	if path == "_catalog" {
+		repos := []string{}
+		for repo := range t.manifests {
+			repos = append(repos, repo)
+		}
+		sort.Strings(repos)
+		json.NewEncoder(w).Encode(map[string][]string{"repositories": repos})
+		return
+	}
+	if strings.HasSuffix(path, "/blobs/uploads/") {
+		t.uploads++
+		w.Header().Set("Location", fmt.Sprintf("/v2/%supload-%d", path, t.uploads))
+		w.WriteHeader(202)
+		return
+	}
+	if strings.Contains(path, "/blobs/uploads/") && r.Method == "PUT" {
+		data, _ := ioutil.ReadAll(r.Body)
+		digest := r.URL.Query().Get("digest")
+		if bytesDigest(data) != digest {
+			w.WriteHeader(400)
+			return
+		}
+		t.blobs[digest] = data
+		w.WriteHeader(201)
+		return
+	}
+	if i := strings.Index(path, "/blobs/"); i != -1 {
+		data, ok := t.blobs[path[i+len("/blobs/"):]]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write(data)
+		return
+	}
+	if strings.HasSuffix(path, "/tags/list") {
+		repo := strings.TrimSuffix(path, "/tags/list")
+		if _, ok := t.tags[repo]; !ok {
+			w.WriteHeader(404)
+			return
+		}
+		tags := []string{}
+		for tag := range t.tags[repo] {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": repo, "tags": tags})
+		return
+	}
+	if i := strings.Index(path, "/manifests/"); i != -1 {
+		repo, ref := path[:i], path[i+len("/manifests/"):]
+		if t.manifests[repo] == nil {
+			t.manifests[repo] = map[string][]byte{}
+			t.tags[repo] = map[string]string{}
+		}
+		switch r.Method {
+		case "PUT":
+			data, _ := ioutil.ReadAll(r.Body)
+			digest := bytesDigest(data)
+			t.manifests[repo][digest] = data
+			t.tags[repo][ref] = digest
+			w.WriteHeader(201)
+		case "DELETE":
+			if _, ok := t.manifests[repo][ref]; !ok {
+				w.WriteHeader(404)
+				return
+			}
+			delete(t.manifests[repo], ref)
+			for tag, digest := range t.tags[repo] {
+				if digest == ref {
+					delete(t.tags[repo], tag)
+				}
+			}
+			w.WriteHeader(202)
+		default:
+			if digest, ok := t.tags[repo][ref]; ok {
+				ref = digest
+			}
+			data, ok := t.manifests[repo][ref]
+			if !ok {
+				w.WriteHeader(404)
+				return
+			}
+			w.Header().Set("Content-Type", ManifestMediaType)
+			w.Write(data)
+		}
+		return
+	}
+	w.WriteHeader(404)
+}
+
+func (s *suite) SetUpTest(c *C) {
+	os.RemoveAll(testDir)
+	c.Assert(os.MkdirAll(testDir, 0755), IsNil)
+}
+
+func (s *suite) TearDownTest(c *C) {
+	os.RemoveAll(testDir)
+}
+
+func upload(c *C, unit *OCIInventory, project, name, version string) {
+	archive := filepath.Join(testDir, name+"-v"+version+".tgz")
+	c.Assert(ioutil.WriteFile(archive, []byte("archive "+version), 0644), IsNil)
+	metadata := core.NewReleaseMetadata(name, version)
+	metadata.Project = project
+	c.Assert(unit.UploadRelease(project, archive, metadata), IsNil)
+}
+
+func (s *suite) Test_UploadRelease_and_QueryReleaseMetadata(c *C) {
+	reg := newTestRegistry(false)
+	defer reg.server.Close()
+	unit := NewOCIInventory(reg.server.URL, "escape", "", "", false)
+	upload(c, unit, "prj", "app", "1.0")
+	upload(c, unit, "prj", "app", "1.1")
+
+	c.Assert(reg.tags["escape/prj/app"]["1.0"], Not(Equals), "")
+	m, err := unit.QueryReleaseMetadata("prj", "app", "v1.0")
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "1.0")
+
+	m, err = unit.QueryReleaseMetadata("prj", "app", "latest")
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "1.1")
+
+	_, err = unit.QueryReleaseMetadata("prj", "app", "v2.0")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *suite) Test_UploadRelease_fails_if_version_exists(c *C) {
+	reg := newTestRegistry(false)
+	defer reg.server.Close()
+	unit := NewOCIInventory(reg.server.URL, "", "", "", false)
+	upload(c, unit, "prj", "app", "1.0")
+	metadata := core.NewReleaseMetadata("app", "1.0")
+	err := unit.UploadRelease("prj", filepath.Join(testDir, "app-v1.0.tgz"), metadata)
+	c.Assert(err, ErrorMatches, "Couldn't upload release 'prj/app-v1.0', because version 1.0 already exists.*")
+}
+
+func (s *suite) Test_DownloadRelease(c *C) {
+	reg := newTestRegistry(false)
+	defer reg.server.Close()
+	unit := NewOCIInventory(reg.server.URL, "", "", "", false)
+	upload(c, unit, "prj", "app", "1.0")
+	target := filepath.Join(testDir, "download.tgz")
+	c.Assert(unit.DownloadRelease("prj", "app", "1.0", target), IsNil)
+	content, err := ioutil.ReadFile(target)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "archive 1.0")
+}
+
+func (s *suite) Test_QueryNextVersion(c *C) {
+	reg := newTestRegistry(false)
+	defer reg.server.Close()
+	unit := NewOCIInventory(reg.server.URL, "", "", "", false)
+	next, err := unit.QueryNextVersion("prj", "app", "")
+	c.Assert(err, IsNil)
+	c.Assert(next, Equals, "0")
+	upload(c, unit, "prj", "app", "0.1")
+	upload(c, unit, "prj", "app", "0.2")
+	next, err = unit.QueryNextVersion("prj", "app", "0.")
+	c.Assert(err, IsNil)
+	c.Assert(next, Equals, "0.3")
+}
+
+func (s *suite) Test_TagRelease(c *C) {
+	reg := newTestRegistry(false)
+	defer reg.server.Close()
+	unit := NewOCIInventory(reg.server.URL, "", "", "", false)
+	upload(c, unit, "prj", "app", "1.0")
+	upload(c, unit, "prj", "app", "1.1")
+	c.Assert(unit.TagRelease("prj", "app", "1.0", "production"), IsNil)
+
+	m, err := unit.QueryReleaseMetadata("prj", "app", "production")
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "1.0")
+
+	versions, err := unit.ListVersions("prj", "app")
+	c.Assert(err, IsNil)
+	c.Assert(versions, DeepEquals, []string{"1.0", "1.1"})
+
+	c.Assert(unit.TagRelease("prj", "app", "1.0", "2.0"), ErrorMatches, "Invalid tag '2.0'.*")
+}
+
+func (s *suite) Test_YankRelease_keeps_tags_and_skips_release_in_latest(c *C) {
+	reg := newTestRegistry(false)
+	defer reg.server.Close()
+	unit := NewOCIInventory(reg.server.URL, "", "", "", false)
+	upload(c, unit, "prj", "app", "1.0")
+	upload(c, unit, "prj", "app", "1.1")
+	c.Assert(unit.TagRelease("prj", "app", "1.1", "production"), IsNil)
+	c.Assert(unit.YankRelease("prj", "app", "1.1", "broken"), IsNil)
+
+	m, err := unit.QueryReleaseMetadata("prj", "app", "latest")
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "1.0")
+
+	m, err = unit.QueryReleaseMetadata("prj", "app", "production")
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "1.1")
+	c.Assert(m.Yanked, Equals, true)
+	c.Assert(m.YankedReason, Equals, "broken")
+}
+
+func (s *suite) Test_DeprecateRelease(c *C) {
+	reg := newTestRegistry(false)
+	defer reg.server.Close()
+	unit := NewOCIInventory(reg.server.URL, "", "", "", false)
+	upload(c, unit, "prj", "app", "1.0")
+	c.Assert(unit.DeprecateRelease("prj", "app", "1.0", "use 2.0"), IsNil)
+	m, err := unit.QueryReleaseMetadata("prj", "app", "latest")
+	c.Assert(err, IsNil)
+	c.Assert(m.Deprecated, Equals, true)
+	c.Assert(m.DeprecatedReason, Equals, "use 2.0")
+}
+
+func (s *suite) Test_DeleteRelease(c *C) {
+	reg := newTestRegistry(false)
+	defer reg.server.Close()
+	unit := NewOCIInventory(reg.server.URL, "", "", "", false)
+	upload(c, unit, "prj", "app", "1.0")
+	upload(c, unit, "prj", "app", "1.1")
+	c.Assert(unit.DeleteRelease("prj", "app", "1.1"), IsNil)
+	versions, err := unit.ListVersions("prj", "app")
+	c.Assert(err, IsNil)
+	c.Assert(versions, DeepEquals, []string{"1.0"})
+	c.Assert(unit.DeleteRelease("prj", "app", "1.1"), ErrorMatches, ".*couldn't be found.")
+}
+
+func (s *suite) Test_ListProjects_and_ListApplications(c *C) {
+	reg := newTestRegistry(false)
+	defer reg.server.Close()
+	unit := NewOCIInventory(reg.server.URL, "escape", "", "", false)
+	upload(c, unit, "prj", "app", "1.0")
+	upload(c, unit, "prj", "other-app", "1.0")
+	upload(c, unit, "prj2", "app", "1.0")
+	reg.manifests["not-escape/image"] = map[string][]byte{}
+
+	projects, err := unit.ListProjects()
+	c.Assert(err, IsNil)
+	c.Assert(projects, DeepEquals, []string{"prj", "prj2"})
+
+	apps, err := unit.ListApplications("prj")
+	c.Assert(err, IsNil)
+	c.Assert(apps, DeepEquals, []string{"app", "other-app"})
+
+	_, err = unit.ListApplications("unknown")
+	c.Assert(err, ErrorMatches, "The project 'unknown' could not be found.*")
+}
+
+func (s *suite) Test_bearer_token_authentication(c *C) {
+	reg := newTestRegistry(true)
+	defer reg.server.Close()
+	unit := NewOCIInventory(reg.server.URL, "", "user", "pass", false)
+	upload(c, unit, "prj", "app", "1.0")
+	m, err := unit.QueryReleaseMetadata("prj", "app", "latest")
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "1.0")
+
+	unit = NewOCIInventory(reg.server.URL, "", "user", "wrong", false)
+	_, err = unit.QueryReleaseMetadata("prj", "app", "v1.0")
+	c.Assert(err, ErrorMatches, ".*token server responded with status code 401.")
+}
+
+func (s *suite) Test_parseChallenge(c *C) {
+	result := parseChallenge(`realm="https://auth.example.com/token",service="registry.example.com",scope="repository:prj/app:pull,push"`)
+	c.Assert(result, DeepEquals, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:prj/app:pull,push",
+	})
+}
+
+func (s *suite) Test_GetAuthMethods_returns_basic_auth(c *C) {
+	unit := NewOCIInventory("http://registry.example.com/", "", "", "", false)
+	methods, err := unit.GetAuthMethods("")
+	c.Assert(err, IsNil)
+	c.Assert(methods, HasLen, 1)
+	c.Assert(methods["basic-auth"].Type, Equals, "basic-auth")
+	c.Assert(methods["basic-auth"].URL, Equals, "http://registry.example.com")
+
+	methods, err = unit.GetAuthMethods("http://other.example.com")
+	c.Assert(err, IsNil)
+	c.Assert(methods["basic-auth"].URL, Equals, "http://other.example.com")
+}
+
+func (s *suite) Test_LoginWithBasicAuth(c *C) {
+	reg := newTestRegistry(true)
+	defer reg.server.Close()
+	unit := NewOCIInventory("http://unused.example.com", "", "", "", false)
+	c.Assert(unit.LoginWithBasicAuth(reg.server.URL, "user", "pass"), IsNil)
+	err := unit.LoginWithBasicAuth(reg.server.URL, "user", "wrong")
+	c.Assert(err, Not(IsNil))
+}
@@ -69,6 +69,18 @@ func (r *InventoryProxy) TagRelease(project, name, version, tag string) error {
 	return r.GetInventory(project).TagRelease(project, name, version, tag)
 }
 
+func (r *InventoryProxy) DeleteRelease(project, name, version string) error {
+	return r.GetInventory(project).DeleteRelease(project, name, version)
+}
+
+func (r *InventoryProxy) DeprecateRelease(project, name, version, reason string) error {
+	return r.GetInventory(project).DeprecateRelease(project, name, version, reason)
+}
+
+func (r *InventoryProxy) YankRelease(project, name, version, reason string) error {
+	return r.GetInventory(project).YankRelease(project, name, version, reason)
+}
+
 func (r *InventoryProxy) ListProjects() ([]string, error) {
 	projects, err := r.From.ListProjects()
 	if err != nil {
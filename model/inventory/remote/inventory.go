@@ -19,6 +19,7 @@ package remote
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -29,6 +30,7 @@ import (
 	"github.com/ankyra/escape-core/parsers"
 	"github.com/ankyra/escape/model/inventory/types"
 	"github.com/ankyra/escape/model/remote"
+	"github.com/ankyra/escape/util"
 )
 
 type inventory struct {
@@ -69,6 +71,9 @@ const error_Download = "Couldn't download release '%s'"
 const error_DownloadNotFound = ", because the package could not be found in the Inventory at '%s'"
 const error_Upload = "Couldn't upload release '%s/%s'"
 const error_Register = "Couldn't register release '%s/%s'"
+const error_Delete = "Couldn't delete release '%s'"
+const error_Deprecate = "Couldn't deprecate release '%s'"
+const error_Yank = "Couldn't yank release '%s'"
 
 func (r *inventory) QueryReleaseMetadata(project, name, version string) (*core.ReleaseMetadata, error) {
 	query, err := parsers.ParseVersionQuery(version)
@@ -93,11 +98,11 @@ func (r *inventory) QueryReleaseMetadata(project, name, version string) (*core.R
 	if resp.StatusCode == 400 {
 		return nil, fmt.Errorf(error_QueryReleaseMetadata+error_InventoryUserSide, releaseQuery, r.apiServer, body)
 	} else if resp.StatusCode == 401 {
-		return nil, fmt.Errorf(error_Unauthorized, r.apiServer, r.apiServer)
+		return nil, util.NewAuthenticationRequiredError(fmt.Sprintf(error_Unauthorized, r.apiServer, r.apiServer))
 	} else if resp.StatusCode == 403 {
 		return nil, fmt.Errorf(error_QueryReleaseMetadata+error_QueryReleaseMetadataForbidden, releaseQuery, releaseQuery, r.apiServer)
 	} else if resp.StatusCode == 404 {
-		return nil, fmt.Errorf(error_QueryReleaseMetadata+error_QueryReleaseMetadataNotFound, releaseQuery, r.apiServer, releaseQuery)
+		return nil, types.NewNotFoundError(error_QueryReleaseMetadata+error_QueryReleaseMetadataNotFound, releaseQuery, r.apiServer, releaseQuery)
 	} else if resp.StatusCode == 500 {
 		return nil, fmt.Errorf(error_QueryReleaseMetadata+error_InventoryServerSide, releaseQuery, r.apiServer)
 	} else if resp.StatusCode != 200 {
@@ -126,7 +131,7 @@ func (r *inventory) QueryNextVersion(project, name, versionPrefix string) (strin
 	if resp.StatusCode == 400 {
 		return "", fmt.Errorf(error_QueryNextVersion+error_InventoryUserSide, releaseQuery, r.apiServer, body)
 	} else if resp.StatusCode == 401 {
-		return "", fmt.Errorf(error_Unauthorized, r.apiServer, r.apiServer)
+		return "", util.NewAuthenticationRequiredError(fmt.Sprintf(error_Unauthorized, r.apiServer, r.apiServer))
 	} else if resp.StatusCode == 403 {
 		return "", fmt.Errorf(error_QueryNextVersion+error_QueryReleaseMetadataForbidden, releaseQuery, releaseQuery, r.apiServer)
 	} else if resp.StatusCode == 500 {
@@ -187,11 +192,13 @@ func (r *inventory) urlToList(url, baseErrorMessage, notFoundMessage string, tra
 	if resp.StatusCode == 400 {
 		return nil, fmt.Errorf(baseErrorMessage+error_InventoryUserSide, r.apiServer, body)
 	} else if resp.StatusCode == 401 {
-		return nil, fmt.Errorf(error_Unauthorized, r.apiServer, r.apiServer)
+		return nil, util.NewAuthenticationRequiredError(fmt.Sprintf(error_Unauthorized, r.apiServer, r.apiServer))
 	} else if resp.StatusCode == 403 {
 		return nil, fmt.Errorf(baseErrorMessage+error_ListProjectForbidden, r.apiServer)
 	} else if resp.StatusCode == 404 && notFoundMessage != "" {
-		return nil, fmt.Errorf(baseErrorMessage + notFoundMessage)
+		// The messages have already been formatted and may contain a '%'
+		// from the project or application name.
+		return nil, errors.New(baseErrorMessage + notFoundMessage)
 	} else if resp.StatusCode == 500 {
 		return nil, fmt.Errorf(baseErrorMessage+error_InventoryServerSide, r.apiServer)
 	} else if resp.StatusCode != 200 {
@@ -253,7 +260,7 @@ func (r *inventory) LoginWithBasicAuth(url, username, password string) error {
 	if resp.StatusCode == 400 {
 		return fmt.Errorf(error_Login+error_InventoryUserSide, url, body)
 	} else if resp.StatusCode == 401 {
-		return fmt.Errorf(error_Login + error_LoginCredentials)
+		return util.NewAuthenticationFailedError(error_Login + error_LoginCredentials)
 	} else if resp.StatusCode == 500 {
 		return fmt.Errorf(error_Login+error_InventoryServerSide, url)
 	} else if resp.StatusCode != 200 {
@@ -278,7 +285,7 @@ func (r *inventory) Login(url, username, password string) (string, error) {
 	if resp.StatusCode == 400 {
 		return "", fmt.Errorf(error_Login+error_InventoryUserSide, url, body)
 	} else if resp.StatusCode == 401 {
-		return "", fmt.Errorf(error_Login + error_LoginCredentials)
+		return "", util.NewAuthenticationFailedError(error_Login + error_LoginCredentials)
 	} else if resp.StatusCode == 500 {
 		return "", fmt.Errorf(error_Login+error_InventoryServerSide, url)
 	} else if resp.StatusCode != 200 {
@@ -304,11 +311,11 @@ func (r *inventory) DownloadRelease(project, name, version, targetFile string) e
 		body := buf.String()
 		return fmt.Errorf(error_Download+error_InventoryUserSide, releaseQuery, r.apiServer, body)
 	} else if resp.StatusCode == 401 {
-		return fmt.Errorf(error_Unauthorized, r.apiServer, r.apiServer)
+		return util.NewAuthenticationRequiredError(fmt.Sprintf(error_Unauthorized, r.apiServer, r.apiServer))
 	} else if resp.StatusCode == 403 {
 		return fmt.Errorf(error_Download+error_ListProjectForbidden, releaseQuery, r.apiServer)
 	} else if resp.StatusCode == 404 {
-		return fmt.Errorf(error_Download+error_DownloadNotFound, releaseQuery, r.apiServer)
+		return types.NewNotFoundError(error_Download+error_DownloadNotFound, releaseQuery, r.apiServer)
 	} else if resp.StatusCode == 500 {
 		return fmt.Errorf(error_Download+error_InventoryServerSide, releaseQuery, r.apiServer)
 	} else if resp.StatusCode != 200 {
@@ -344,7 +351,7 @@ func (r *inventory) UploadRelease(project, releasePath string, metadata *core.Re
 		body := buf.String()
 		return fmt.Errorf(baseError+error_InventoryUserSide, r.apiServer, body)
 	} else if resp.StatusCode == 401 {
-		return fmt.Errorf(error_Unauthorized, r.apiServer, r.apiServer)
+		return util.NewAuthenticationRequiredError(fmt.Sprintf(error_Unauthorized, r.apiServer, r.apiServer))
 	} else if resp.StatusCode == 403 {
 		return fmt.Errorf(baseError+error_ListProjectForbidden, r.apiServer)
 	} else if resp.StatusCode == 404 {
@@ -373,7 +380,7 @@ func (r *inventory) register(project string, metadata *core.ReleaseMetadata) err
 		body := buf.String()
 		return fmt.Errorf(baseError+error_InventoryUserSide, r.apiServer, body)
 	} else if resp.StatusCode == 401 {
-		return fmt.Errorf(error_Unauthorized, r.apiServer, r.apiServer)
+		return util.NewAuthenticationRequiredError(fmt.Sprintf(error_Unauthorized, r.apiServer, r.apiServer))
 	} else if resp.StatusCode == 403 {
 		return fmt.Errorf(baseError+error_ListProjectForbidden, r.apiServer)
 	} else if resp.StatusCode == 404 {
@@ -417,3 +424,69 @@ func (r *inventory) TagRelease(project, name, version, tag string) error {
 	}
 	return nil
 }
+
+func (r *inventory) DeleteRelease(project, name, version string) error {
+	releaseQuery := project + "/" + name + "-v" + version
+	if project == "_" {
+		releaseQuery = name + "-v" + version
+	}
+	url := r.endpoints.DeleteRelease(project, name, version)
+	resp, err := r.client.DELETE_with_authentication(url)
+	if err != nil {
+		return fmt.Errorf(error_Delete+error_InventoryConnection, releaseQuery, r.apiServer, err.Error())
+	}
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(resp.Body)
+	body := buf.String()
+	if resp.StatusCode == 400 {
+		return fmt.Errorf(error_Delete+error_InventoryUserSide, releaseQuery, r.apiServer, body)
+	} else if resp.StatusCode == 401 {
+		return util.NewAuthenticationRequiredError(fmt.Sprintf(error_Unauthorized, r.apiServer, r.apiServer))
+	} else if resp.StatusCode == 403 {
+		return fmt.Errorf(error_Delete+error_ListProjectForbidden, releaseQuery, r.apiServer)
+	} else if resp.StatusCode == 404 {
+		return fmt.Errorf(error_Delete+error_DownloadNotFound, releaseQuery, r.apiServer)
+	} else if resp.StatusCode == 500 {
+		return fmt.Errorf(error_Delete+error_InventoryServerSide, releaseQuery, r.apiServer)
+	} else if resp.StatusCode != 200 {
+		return fmt.Errorf(error_Delete+error_InventoryUnknownStatus, releaseQuery, r.apiServer, resp.StatusCode, body)
+	}
+	return nil
+}
+
+func (r *inventory) DeprecateRelease(project, name, version, reason string) error {
+	url := r.endpoints.DeprecateRelease(project, name, version)
+	return r.markRelease(url, fmt.Sprintf(error_Deprecate, project+"/"+name+"-v"+version), reason)
+}
+
+func (r *inventory) YankRelease(project, name, version, reason string) error {
+	url := r.endpoints.YankRelease(project, name, version)
+	return r.markRelease(url, fmt.Sprintf(error_Yank, project+"/"+name+"-v"+version), reason)
+}
+
+func (r *inventory) markRelease(url, baseError, reason string) error {
+	data := map[string]string{
+		"reason": reason,
+	}
+	resp, err := r.client.POST_json_with_authentication(url, data)
+	if err != nil {
+		return fmt.Errorf(baseError+error_InventoryConnection, r.apiServer, err.Error())
+	}
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(resp.Body)
+	body := buf.String()
+	if resp.StatusCode == 400 {
+		return fmt.Errorf(baseError+error_InventoryUserSide, r.apiServer, body)
+	} else if resp.StatusCode == 401 {
+		return util.NewAuthenticationRequiredError(fmt.Sprintf(error_Unauthorized, r.apiServer, r.apiServer))
+	} else if resp.StatusCode == 403 {
+		return fmt.Errorf(baseError+error_ListProjectForbidden, r.apiServer)
+	} else if resp.StatusCode == 404 {
+		return fmt.Errorf(baseError+error_DownloadNotFound, r.apiServer)
+	} else if resp.StatusCode == 500 {
+		return fmt.Errorf(baseError+error_InventoryServerSide, r.apiServer)
+	} else if resp.StatusCode != 200 {
+		return fmt.Errorf(baseError+error_InventoryUnknownStatus, r.apiServer, resp.StatusCode, body)
+	}
+	return nil
+}
@@ -43,6 +43,9 @@ const authMethodsURL = "/api/v1/auth/login-methods"
 const downloadURL = "/api/v1/inventory/prj/units/name/versions/v1.0/download"
 const uploadURL = "/api/v1/inventory/prj/units/name/versions/v1.0/upload"
 const registerURL = "/api/v1/inventory/prj/register"
+const deleteURL = "/api/v1/inventory/prj/units/name/versions/v1.0/"
+const yankURL = "/api/v1/inventory/prj/units/name/versions/v1.0/yank"
+const deprecateURL = "/api/v1/inventory/prj/units/name/versions/v1.0/deprecate"
 
 /*
 
@@ -310,6 +313,15 @@ func (s *suite) Test_ListApplications_Errors(c *C) {
 	}, listApplicationsURL, s.listApplications)
 }
 
+func (s *suite) Test_ListApplications_NotFound_keeps_percent_signs_in_the_error(c *C) {
+	server := NewMockServer().WithResponseCode(404).Start(c)
+	defer server.Stop()
+	unit := NewRemoteInventory(server.URL, "token", "", "", false)
+	_, err := unit.ListApplications("a%25b")
+	c.Assert(err, Not(IsNil))
+	c.Assert(err.Error(), Equals, fmt.Sprintf(error_ListApplications, "a%25b")+fmt.Sprintf(error_ListApplicationsNotFound, "a%25b", server.URL+"/"))
+}
+
 func (s *suite) Test_ListApplications_fails_if_server_doesnt_respond(c *C) {
 	s.test_ConnectionError(c, s.listApplications, func(url string) string {
 		err := fmt.Sprintf("Get %s%s: dial tcp %s: connect: connection refused", url, listApplicationsURL, url[7:])
@@ -470,7 +482,7 @@ func (s *suite) Test_Login_Errors(c *C) {
 			return fmt.Sprintf(baseError+error_InventoryUserSide, url, "Server Error")
 		},
 		401: func(url string) string {
-			return fmt.Sprintf(baseError + error_LoginCredentials)
+			return baseError + error_LoginCredentials
 		},
 		404: func(url string) string {
 			return fmt.Sprintf(baseError+error_InventoryUnknownStatus, url, 404, "Server Error")
@@ -622,6 +634,103 @@ func (s *suite) Test_Register_fails_if_server_doesnt_respond(c *C) {
 	})
 }
 
+/*
+
+	DELETE
+
+*/
+
+func (s *suite) deleteRelease(url string) error {
+	unit := NewRemoteInventory(url, "token", "", "", false)
+	return unit.DeleteRelease("prj", "name", "1.0")
+}
+
+func (s *suite) Test_DeleteRelease_happy_path(c *C) {
+	server := NewMockServer().Start(c)
+	defer server.Stop()
+
+	c.Assert(s.deleteRelease(server.URL), IsNil)
+	server.ExpectCalled(c, true, deleteURL)
+}
+
+func (s *suite) Test_DeleteRelease_Errors(c *C) {
+	baseError := fmt.Sprintf(error_Delete, "prj/name-v1.0")
+	s.test_RemoteErrorHandling(c, map[int]func(string) string{
+		400: func(url string) string {
+			return fmt.Sprintf(baseError+error_InventoryUserSide, url+"/", "Server Error")
+		},
+		401: func(url string) string {
+			return fmt.Sprintf(error_Unauthorized, url+"/", url+"/")
+		},
+		403: func(url string) string {
+			return fmt.Sprintf(baseError+error_ListProjectForbidden, url+"/")
+		},
+		404: func(url string) string {
+			return fmt.Sprintf(baseError+error_DownloadNotFound, url+"/")
+		},
+		500: func(url string) string {
+			return fmt.Sprintf(baseError+error_InventoryServerSide, url+"/")
+		},
+		416: func(url string) string {
+			return fmt.Sprintf(baseError+error_InventoryUnknownStatus, url+"/", 416, "Server Error")
+		},
+	}, deleteURL, s.deleteRelease)
+}
+
+/*
+
+	YANK AND DEPRECATE
+
+*/
+
+func (s *suite) yankRelease(url string) error {
+	unit := NewRemoteInventory(url, "token", "", "", false)
+	return unit.YankRelease("prj", "name", "1.0", "broken")
+}
+
+func (s *suite) Test_YankRelease_happy_path(c *C) {
+	server := NewMockServer().Start(c)
+	defer server.Stop()
+
+	c.Assert(s.yankRelease(server.URL), IsNil)
+	server.ExpectCalled(c, true, yankURL)
+	c.Assert(server.CapturedBody, Equals, `{"reason":"broken"}`)
+}
+
+func (s *suite) Test_DeprecateRelease_happy_path(c *C) {
+	server := NewMockServer().Start(c)
+	defer server.Stop()
+
+	unit := NewRemoteInventory(server.URL, "token", "", "", false)
+	c.Assert(unit.DeprecateRelease("prj", "name", "1.0", "use v2"), IsNil)
+	server.ExpectCalled(c, true, deprecateURL)
+	c.Assert(server.CapturedBody, Equals, `{"reason":"use v2"}`)
+}
+
+func (s *suite) Test_YankRelease_Errors(c *C) {
+	baseError := fmt.Sprintf(error_Yank, "prj/name-v1.0")
+	s.test_RemoteErrorHandling(c, map[int]func(string) string{
+		400: func(url string) string {
+			return fmt.Sprintf(baseError+error_InventoryUserSide, url+"/", "Server Error")
+		},
+		401: func(url string) string {
+			return fmt.Sprintf(error_Unauthorized, url+"/", url+"/")
+		},
+		403: func(url string) string {
+			return fmt.Sprintf(baseError+error_ListProjectForbidden, url+"/")
+		},
+		404: func(url string) string {
+			return fmt.Sprintf(baseError+error_DownloadNotFound, url+"/")
+		},
+		500: func(url string) string {
+			return fmt.Sprintf(baseError+error_InventoryServerSide, url+"/")
+		},
+		416: func(url string) string {
+			return fmt.Sprintf(baseError+error_InventoryUnknownStatus, url+"/", 416, "Server Error")
+		},
+	}, yankURL, s.yankRelease)
+}
+
 /*
 
 	HELPER FUNCTIONS
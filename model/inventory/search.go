@@ -0,0 +1,84 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"strings"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape/model/inventory/types"
+	"github.com/ankyra/escape/util/logger/api"
+)
+
+// Search walks the projects, applications and versions in the inventory and
+// returns the metadata of every release matching the query. This works for
+// every type of inventory, but can be slow on large remote inventories, so
+// it's a good idea to narrow the search down with a project where possible.
+// The name and version are checked before the release metadata is fetched,
+// so that only the candidates are fetched. Projects, applications and
+// releases that can't be read are logged and skipped, so that one broken
+// entry doesn't fail the whole search.
+func Search(inv types.Inventory, query *types.ReleaseQuery, logger api.Logger) ([]*core.ReleaseMetadata, error) {
+	result := []*core.ReleaseMetadata{}
+	projects := []string{query.Project}
+	if query.Project == "" {
+		var err error
+		projects, err = inv.ListProjects()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, project := range projects {
+		apps, err := inv.ListApplications(project)
+		if err != nil {
+			logSkipped(logger, project, err)
+			continue
+		}
+		for _, app := range apps {
+			if !query.MatchesName(project, app) {
+				continue
+			}
+			versions, err := inv.ListVersions(project, app)
+			if err != nil {
+				logSkipped(logger, project+"/"+app, err)
+				continue
+			}
+			for _, version := range versions {
+				version = strings.TrimPrefix(version, "v")
+				if !query.MatchesVersion(version) {
+					continue
+				}
+				metadata, err := inv.QueryReleaseMetadata(project, app, "v"+version)
+				if err != nil {
+					logSkipped(logger, project+"/"+app+"-v"+version, err)
+					continue
+				}
+				if query.Matches(metadata) {
+					result = append(result, metadata)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func logSkipped(logger api.Logger, entry string, err error) {
+	logger.Log("inventory.search_skipped", map[string]string{
+		"entry": entry,
+		"error": err.Error(),
+	})
+}
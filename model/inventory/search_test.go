@@ -0,0 +1,102 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape/model/inventory/local"
+	"github.com/ankyra/escape/model/inventory/types"
+	"github.com/ankyra/escape/util/logger/loggers"
+	. "gopkg.in/check.v1"
+)
+
+type suite struct{}
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&suite{})
+
+const testDir = "testdata_search"
+
+func (s *suite) SetUpTest(c *C) {
+	os.RemoveAll(testDir)
+	c.Assert(os.MkdirAll(testDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(testDir, "archive.tgz"), []byte("archive"), 0644), IsNil)
+}
+
+func (s *suite) TearDownTest(c *C) {
+	os.RemoveAll(testDir)
+}
+
+// countingInventory counts the release metadata queries.
+type countingInventory struct {
+	*local.LocalInventory
+	queried []string
+}
+
+func (i *countingInventory) QueryReleaseMetadata(project, name, version string) (*core.ReleaseMetadata, error) {
+	i.queried = append(i.queried, project+"/"+name+"-"+version)
+	return i.LocalInventory.QueryReleaseMetadata(project, name, version)
+}
+
+type recordingLogger struct {
+	loggers.LoggerDummy
+	keys []string
+}
+
+func (l *recordingLogger) Log(key string, values map[string]string) {
+	l.keys = append(l.keys, key)
+}
+
+func newSearchInventory(c *C) *countingInventory {
+	inv := local.NewLocalInventory(filepath.Join(testDir, "inventory"))
+	for _, release := range [][]string{{"app", "1.0"}, {"app", "1.1"}, {"app", "2.0"}, {"other", "1.0"}} {
+		metadata := core.NewReleaseMetadata(release[0], release[1])
+		metadata.Project = "prj"
+		c.Assert(inv.UploadRelease("prj", filepath.Join(testDir, "archive.tgz"), metadata), IsNil)
+	}
+	return &countingInventory{LocalInventory: inv}
+}
+
+func (s *suite) Test_Search_only_fetches_candidates(c *C) {
+	inv := newSearchInventory(c)
+	query := types.NewReleaseQuery()
+	query.Name = "prj/app"
+	query.Version = "1."
+	result, err := Search(inv, query, &recordingLogger{})
+	c.Assert(err, IsNil)
+	c.Assert(result, HasLen, 2)
+	c.Assert(result[0].GetReleaseId(), Equals, "app-v1.0")
+	c.Assert(result[1].GetReleaseId(), Equals, "app-v1.1")
+	c.Assert(inv.queried, DeepEquals, []string{"prj/app-v1.0", "prj/app-v1.1"})
+}
+
+func (s *suite) Test_Search_skips_releases_that_cant_be_read(c *C) {
+	inv := newSearchInventory(c)
+	broken := filepath.Join(testDir, "inventory", "prj", "app", "app-v1.1.json")
+	c.Assert(ioutil.WriteFile(broken, []byte("not json"), 0644), IsNil)
+	logger := &recordingLogger{}
+	result, err := Search(inv, types.NewReleaseQuery(), logger)
+	c.Assert(err, IsNil)
+	c.Assert(result, HasLen, 3)
+	c.Assert(logger.keys, DeepEquals, []string{"inventory.search_skipped"})
+}
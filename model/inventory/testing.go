@@ -56,6 +56,15 @@ func (m *mockInventory) Login(url, username, password string) (string, error) {
 func (m *mockInventory) TagRelease(project, name, version, tag string) error {
 	return nil
 }
+func (m *mockInventory) DeleteRelease(project, name, version string) error {
+	return nil
+}
+func (m *mockInventory) DeprecateRelease(project, name, version, reason string) error {
+	return nil
+}
+func (m *mockInventory) YankRelease(project, name, version, reason string) error {
+	return nil
+}
 func (m *mockInventory) LoginWithBasicAuth(url, username, password string) error {
 	return nil
 }
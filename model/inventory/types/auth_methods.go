@@ -21,4 +21,13 @@ type AuthMethod struct {
 	Type        string `json:"type"`
 	RedeemToken string `json:"redeem-token"`
 	RedeemURL   string `json:"redeem-url"`
+
+	// Servers that support logging in from another device can hand out a
+	// short code for the user to confirm in the browser. Unlike the redeem
+	// token, which grants access to the Escape token, it's safe to display.
+	UserCode string `json:"user-code,omitempty"`
+
+	// Servers that hand out expiring tokens can advertise an endpoint to
+	// exchange a refresh token for a new token.
+	RefreshURL string `json:"refresh-url,omitempty"`
 }
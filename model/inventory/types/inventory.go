@@ -29,6 +29,9 @@ type Inventory interface {
 	Login(url, username, password string) (string, error)
 	LoginWithBasicAuth(url, username, password string) error
 	TagRelease(project, name, version, tag string) error
+	DeleteRelease(project, name, version string) error
+	DeprecateRelease(project, name, version, reason string) error
+	YankRelease(project, name, version, reason string) error
 
 	ListProjects() ([]string, error)
 	ListApplications(project string) ([]string, error)
@@ -0,0 +1,122 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"strings"
+	"time"
+
+	core "github.com/ankyra/escape-core"
+)
+
+// A ReleaseQuery is used to search the releases in an Inventory. Empty fields
+// are ignored; a release needs to match all the other fields.
+type ReleaseQuery struct {
+	// Only search the releases in this project.
+	Project string
+	// Substring of the qualified, versionless release ID (e.g. "project/name").
+	Name string
+	// The release version starts with this prefix (e.g. "1.2.").
+	Version string
+	// The release provides an interface of this type.
+	Provides string
+	// The release has these metadata keys set to these values.
+	Metadata map[string]string
+	// The release was built from this git branch.
+	Branch string
+	// The release was built from a git revision starting with this prefix.
+	Revision string
+	// Substring of the git author of the release. Case insensitive.
+	Author string
+	// The release was built on or after this time.
+	BuiltAfter time.Time
+	// The release was built before this time.
+	BuiltBefore time.Time
+}
+
+func NewReleaseQuery() *ReleaseQuery {
+	return &ReleaseQuery{
+		Metadata: map[string]string{},
+	}
+}
+
+func (q *ReleaseQuery) Matches(m *core.ReleaseMetadata) bool {
+	if q.Project != "" && m.GetProject() != q.Project {
+		return false
+	}
+	if !q.MatchesName(m.GetProject(), m.Name) || !q.MatchesVersion(m.Version) {
+		return false
+	}
+	if q.Provides != "" && !q.matchesProvides(m) {
+		return false
+	}
+	for key, val := range q.Metadata {
+		if m.Metadata[key] != val {
+			return false
+		}
+	}
+	if q.Branch != "" && m.Branch != q.Branch {
+		return false
+	}
+	if q.Revision != "" && !strings.HasPrefix(m.Revision, q.Revision) {
+		return false
+	}
+	if q.Author != "" && !strings.Contains(strings.ToLower(m.RevisionAuthor), strings.ToLower(q.Author)) {
+		return false
+	}
+	if !q.BuiltAfter.IsZero() || !q.BuiltBefore.IsZero() {
+		return q.matchesBuildDate(m)
+	}
+	return true
+}
+
+// MatchesName only checks the Name field, so that candidates can be
+// filtered before their metadata is fetched.
+func (q *ReleaseQuery) MatchesName(project, name string) bool {
+	return q.Name == "" || strings.Contains(project+"/"+name, q.Name)
+}
+
+// MatchesVersion only checks the Version field, so that candidates can be
+// filtered before their metadata is fetched.
+func (q *ReleaseQuery) MatchesVersion(version string) bool {
+	return q.Version == "" || strings.HasPrefix(version, q.Version)
+}
+
+func (q *ReleaseQuery) matchesProvides(m *core.ReleaseMetadata) bool {
+	for _, p := range m.GetProvides() {
+		if p == q.Provides {
+			return true
+		}
+	}
+	return false
+}
+
+// Releases built with older versions of Escape don't have a build date and
+// never match a date range.
+func (q *ReleaseQuery) matchesBuildDate(m *core.ReleaseMetadata) bool {
+	built, err := time.Parse(time.RFC3339, m.BuildDate)
+	if err != nil {
+		return false
+	}
+	if !q.BuiltAfter.IsZero() && built.Before(q.BuiltAfter) {
+		return false
+	}
+	if !q.BuiltBefore.IsZero() && !built.Before(q.BuiltBefore) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"testing"
+	"time"
+
+	core "github.com/ankyra/escape-core"
+	. "gopkg.in/check.v1"
+)
+
+type suite struct{}
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&suite{})
+
+func newTestMetadata() *core.ReleaseMetadata {
+	m := core.NewReleaseMetadata("my-app", "1.0")
+	m.Project = "prj"
+	m.Branch = "master"
+	m.Revision = "abc123def456"
+	m.RevisionAuthor = "Test User <test@example.com>"
+	m.Metadata["team"] = "platform"
+	m.BuildDate = "2018-03-01T12:00:00Z"
+	m.SetProvides([]string{"kubernetes"})
+	return m
+}
+
+func (s *suite) Test_ReleaseQuery_empty_query_matches_everything(c *C) {
+	c.Assert(NewReleaseQuery().Matches(newTestMetadata()), Equals, true)
+}
+
+func (s *suite) Test_ReleaseQuery_Matches(c *C) {
+	cases := []struct {
+		Configure func(*ReleaseQuery)
+		Expected  bool
+	}{
+		{func(q *ReleaseQuery) { q.Project = "prj" }, true},
+		{func(q *ReleaseQuery) { q.Project = "other" }, false},
+		{func(q *ReleaseQuery) { q.Name = "prj/my" }, true},
+		{func(q *ReleaseQuery) { q.Name = "other" }, false},
+		{func(q *ReleaseQuery) { q.Version = "1." }, true},
+		{func(q *ReleaseQuery) { q.Version = "2." }, false},
+		{func(q *ReleaseQuery) { q.Provides = "kubernetes" }, true},
+		{func(q *ReleaseQuery) { q.Provides = "gcp" }, false},
+		{func(q *ReleaseQuery) { q.Metadata["team"] = "platform" }, true},
+		{func(q *ReleaseQuery) { q.Metadata["team"] = "other" }, false},
+		{func(q *ReleaseQuery) { q.Metadata["unknown"] = "platform" }, false},
+		{func(q *ReleaseQuery) { q.Branch = "master" }, true},
+		{func(q *ReleaseQuery) { q.Branch = "develop" }, false},
+		{func(q *ReleaseQuery) { q.Revision = "abc123" }, true},
+		{func(q *ReleaseQuery) { q.Revision = "def456" }, false},
+		{func(q *ReleaseQuery) { q.Author = "test user" }, true},
+		{func(q *ReleaseQuery) { q.Author = "someone else" }, false},
+		{func(q *ReleaseQuery) { q.BuiltAfter = time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC) }, true},
+		{func(q *ReleaseQuery) { q.BuiltAfter = time.Date(2018, 3, 2, 0, 0, 0, 0, time.UTC) }, false},
+		{func(q *ReleaseQuery) { q.BuiltBefore = time.Date(2018, 3, 2, 0, 0, 0, 0, time.UTC) }, true},
+		{func(q *ReleaseQuery) { q.BuiltBefore = time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC) }, false},
+	}
+	for _, test := range cases {
+		query := NewReleaseQuery()
+		test.Configure(query)
+		c.Assert(query.Matches(newTestMetadata()), Equals, test.Expected)
+	}
+}
+
+func (s *suite) Test_ReleaseQuery_date_range_doesnt_match_releases_without_build_date(c *C) {
+	m := newTestMetadata()
+	m.BuildDate = ""
+	query := NewReleaseQuery()
+	query.BuiltBefore = time.Now()
+	c.Assert(query.Matches(m), Equals, false)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape/model/escape_plan"
+)
+
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Info    Severity = "info"
+	Off     Severity = "off"
+)
+
+var severities = []Severity{Error, Warning, Info, Off}
+
+func ParseSeverity(str string) (Severity, error) {
+	for _, s := range severities {
+		if string(s) == str {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("Invalid severity '%s'. Expecting one of: error, warning, info, off", str)
+}
+
+// An Issue is a problem that was found in the Escape plan. The Line is 0 if
+// the problem couldn't be tied to a line in the File.
+type Issue struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"`
+}
+
+func (i *Issue) String() string {
+	location := i.File
+	if i.Line > 0 {
+		location = fmt.Sprintf("%s:%d", i.File, i.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", location, i.Severity, i.Message, i.Rule)
+}
+
+type Linter struct {
+	PlanFile string
+	Plan     *escape_plan.EscapePlan
+	Metadata *core.ReleaseMetadata
+
+	// Used to find out which inputs are passed on to the dependencies by
+	// name. If it's not set, these inputs are not considered to be used.
+	GetDependencyMetadata func(*core.DependencyConfig) (*core.ReleaseMetadata, error)
+
+	severities map[string]Severity
+	planLines  []string
+}
+
+// NewLinter returns a Linter for the Escape plan, and the release metadata
+// it was compiled into.
+func NewLinter(planFile string, plan *escape_plan.EscapePlan, metadata *core.ReleaseMetadata) *Linter {
+	l := &Linter{
+		PlanFile:   planFile,
+		Plan:       plan,
+		Metadata:   metadata,
+		severities: map[string]Severity{},
+	}
+	for _, rule := range Rules {
+		l.severities[rule.Id] = rule.Severity
+	}
+	if content, err := ioutil.ReadFile(planFile); err == nil {
+		l.planLines = strings.Split(string(content), "\n")
+	}
+	return l
+}
+
+// Configure changes the severities of the rules. The keys of the map are
+// rule ids, the values are severities; "off" disables the rule.
+func (l *Linter) Configure(rules map[string]string) error {
+	ids := []string{}
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if GetRule(id) == nil {
+			return fmt.Errorf("Unknown lint rule '%s'", id)
+		}
+		severity, err := ParseSeverity(rules[id])
+		if err != nil {
+			return fmt.Errorf("%s (in lint rule '%s')", err.Error(), id)
+		}
+		l.severities[id] = severity
+	}
+	return nil
+}
+
+func (l *Linter) GetSeverity(rule string) Severity {
+	return l.severities[rule]
+}
+
+// Run runs the rules that haven't been turned off, in the order of Rules.
+func (l *Linter) Run() []*Issue {
+	result := []*Issue{}
+	for _, rule := range Rules {
+		severity := l.severities[rule.Id]
+		if severity == Off {
+			continue
+		}
+		for _, issue := range rule.check(l) {
+			issue.Rule = rule.Id
+			issue.Severity = severity
+			if issue.File == "" {
+				issue.File = l.PlanFile
+			}
+			result = append(result, issue)
+		}
+	}
+	return result
+}
+
+// findLine returns the first line in the Escape plan that contains the
+// token as a YAML key or value, or 0 if there is no such line.
+func (l *Linter) findLine(token string) int {
+	re := regexp.MustCompile(`(^|[\s:\-"'\[,])` + regexp.QuoteMeta(token) + `($|[\s:"'\],])`)
+	for i, line := range l.planLines {
+		if re.MatchString(line) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (l *Linter) newIssue(token, msg string, a ...interface{}) *Issue {
+	return &Issue{
+		Message: fmt.Sprintf(msg, a...),
+		Line:    l.findLine(token),
+	}
+}
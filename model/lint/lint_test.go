@@ -0,0 +1,102 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ankyra/escape/model/compiler"
+	"github.com/ankyra/escape/model/escape_plan"
+	. "gopkg.in/check.v1"
+)
+
+type suite struct{}
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&suite{})
+
+const testPlan = "testdata/plan.yml"
+
+func newTestLinter(c *C) *Linter {
+	plan := escape_plan.NewEscapePlan()
+	c.Assert(plan.LoadConfig(testPlan), IsNil)
+	metadata, err := compiler.Compile(plan, nil, nil, nil, nil)
+	c.Assert(err, IsNil)
+	return NewLinter(testPlan, plan, metadata)
+}
+
+func (s *suite) Test_Run(c *C) {
+	issues := newTestLinter(c).Run()
+	result := []string{}
+	for _, issue := range issues {
+		result = append(result, issue.String())
+	}
+	c.Assert(result, DeepEquals, []string{
+		"testdata/plan.yml:12: warning: Input 'unused' is not used by any script, template, dependency or variable [unused-input]",
+		"testdata/plan.yml:21: warning: Output 'never_set' doesn't have a default and is not set by any script [unset-output]",
+		"testdata/plan.yml:7: warning: Consumer 'kubernetes' is not used by any dependency mapping, variable or script [unused-consumer]",
+		"testdata/plan.yml:5: warning: Include pattern 'testdata/docs/*.md' doesn't match any files [unmatched-include]",
+		"testdata/plan.yml:12: info: Input 'unused' doesn't have a description [missing-description]",
+		"testdata/plan.yml:25: error: The build script 'build.sh' doesn't exist [missing-script]",
+		"testdata/plan.yml:27: warning: The errand 'backup' script 'testdata/backup.sh' is not executable [non-executable-script]",
+	})
+}
+
+func (s *suite) Test_Configure(c *C) {
+	linter := newTestLinter(c)
+	c.Assert(linter.Configure(map[string]string{
+		"unused-input":          "error",
+		"unset-output":          "off",
+		"unused-consumer":       "off",
+		"unmatched-include":     "off",
+		"missing-description":   "off",
+		"missing-script":        "off",
+		"non-executable-script": "off",
+	}), IsNil)
+	issues := linter.Run()
+	c.Assert(issues, HasLen, 1)
+	c.Assert(issues[0].Rule, Equals, "unused-input")
+	c.Assert(issues[0].Severity, Equals, Error)
+}
+
+func (s *suite) Test_Configure_fails_on_unknown_rule(c *C) {
+	err := newTestLinter(c).Configure(map[string]string{"unknown": "off"})
+	c.Assert(err, ErrorMatches, "Unknown lint rule 'unknown'")
+}
+
+func (s *suite) Test_Configure_fails_on_invalid_severity(c *C) {
+	err := newTestLinter(c).Configure(map[string]string{"unused-input": "fatal"})
+	c.Assert(err, ErrorMatches, "Invalid severity 'fatal'. Expecting one of: error, warning, info, off \\(in lint rule 'unused-input'\\)")
+}
+
+func (s *suite) Test_ToSarif(c *C) {
+	linter := newTestLinter(c)
+	c.Assert(linter.Configure(map[string]string{"missing-description": "off"}), IsNil)
+	log := linter.ToSarif([]*Issue{
+		&Issue{Rule: "unused-input", Severity: Warning, Message: "Unused", File: testPlan, Line: 12},
+		&Issue{Rule: "missing-script", Severity: Error, Message: "Missing", File: testPlan},
+	})
+	data, err := json.Marshal(log.Runs[0].Results)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, `[{"ruleId":"unused-input","level":"warning","message":{"text":"Unused"},"locations":[{"physicalLocation":{"artifactLocation":{"uri":"testdata/plan.yml"},"region":{"startLine":12}}}]},`+
+		`{"ruleId":"missing-script","level":"error","message":{"text":"Missing"},"locations":[{"physicalLocation":{"artifactLocation":{"uri":"testdata/plan.yml"}}}]}]`)
+	c.Assert(log.Version, Equals, "2.1.0")
+	c.Assert(log.Runs[0].Tool.Driver.Rules, HasLen, len(Rules))
+	c.Assert(log.Runs[0].Tool.Driver.Rules[4].DefaultConfiguration.Level, Equals, "none")
+}
@@ -0,0 +1,347 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape-core/variables"
+	"github.com/ankyra/escape/util"
+)
+
+type Rule struct {
+	Id          string
+	Description string
+
+	// The default severity.
+	Severity Severity
+
+	check func(l *Linter) []*Issue
+}
+
+var Rules = []*Rule{
+	&Rule{"unused-input", "Input variables should be used by a script, template, dependency or other variable", Warning, checkUnusedInputs},
+	&Rule{"unset-output", "Output variables should have a default or be set by a script", Warning, checkUnsetOutputs},
+	&Rule{"unused-consumer", "Consumers should be used by a dependency mapping, variable or script", Warning, checkUnusedConsumers},
+	&Rule{"unmatched-include", "Include patterns should match at least one file", Warning, checkUnmatchedIncludes},
+	&Rule{"missing-description", "Input variables should have a description", Info, checkMissingDescriptions},
+	&Rule{"missing-script", "Scripts referenced in the Escape plan should exist", Error, checkMissingScripts},
+	&Rule{"non-executable-script", "Scripts referenced in the Escape plan should be executable", Warning, checkNonExecutableScripts},
+}
+
+func GetRule(id string) *Rule {
+	for _, rule := range Rules {
+		if rule.Id == id {
+			return rule
+		}
+	}
+	return nil
+}
+
+func checkUnusedInputs(l *Linter) []*Issue {
+	result := []*Issue{}
+	for _, input := range l.planVariables(l.Plan.Inputs, l.Plan.BuildInputs, l.Plan.DeployInputs) {
+		if !isReferenced(l.usages(input.Id), input.Id) && !l.isPassedToDependency(input.Id) {
+			result = append(result, l.newIssue(input.Id, "Input '%s' is not used by any script, template, dependency or variable", input.Id))
+		}
+	}
+	return result
+}
+
+func checkUnsetOutputs(l *Linter) []*Issue {
+	result := []*Issue{}
+	scripts := strings.Join(l.scriptContents(), "\n")
+	for _, output := range l.planVariables(l.Plan.Outputs, l.Plan.BuildOutputs, l.Plan.DeployOutputs) {
+		if !output.HasDefault() && !isReferenced(scripts, output.Id) {
+			result = append(result, l.newIssue(output.Id, "Output '%s' doesn't have a default and is not set by any script", output.Id))
+		}
+	}
+	return result
+}
+
+func checkUnusedConsumers(l *Linter) []*Issue {
+	result := []*Issue{}
+	usages := l.usages("")
+	for _, consumer := range l.Metadata.Consumes {
+		if consumer.SkipActivate && consumer.SkipDeactivate {
+			// Added by the compiler for a dependency.
+			continue
+		}
+		if !isReferenced(usages, consumer.VariableName) {
+			result = append(result, l.newIssue(consumer.VariableName, "Consumer '%s' is not used by any dependency mapping, variable or script", consumer.VariableName))
+		}
+	}
+	return result
+}
+
+func checkUnmatchedIncludes(l *Linter) []*Issue {
+	result := []*Issue{}
+	for _, pattern := range l.Plan.Includes {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			result = append(result, l.newIssue(pattern, "Invalid include pattern '%s': %s", pattern, err.Error()))
+		} else if len(paths) == 0 {
+			result = append(result, l.newIssue(pattern, "Include pattern '%s' doesn't match any files", pattern))
+		}
+	}
+	return result
+}
+
+func checkMissingDescriptions(l *Linter) []*Issue {
+	result := []*Issue{}
+	for _, input := range l.planVariables(l.Plan.Inputs, l.Plan.BuildInputs, l.Plan.DeployInputs) {
+		if input.Description == "" {
+			result = append(result, l.newIssue(input.Id, "Input '%s' doesn't have a description", input.Id))
+		}
+	}
+	return result
+}
+
+// scriptFileExtension matches commands that look like script files, e.g.
+// "deploy.sh". These are reported as missing if they're not on the PATH.
+var scriptFileExtension = regexp.MustCompile(`\.(sh|bash|py|rb|pl|js|ps1)$`)
+
+func checkMissingScripts(l *Linter) []*Issue {
+	result := []*Issue{}
+	for _, s := range l.scripts() {
+		missing := ""
+		if s.stage.RelativeScript != "" {
+			if path := scriptPath(s.stage); !util.PathExists(path) {
+				missing = path
+			}
+		} else if cmd := s.stage.Cmd; cmd != "" {
+			if filepath.IsAbs(cmd) || strings.Contains(cmd, "/") {
+				if !util.PathExists(cmd) {
+					missing = cmd
+				}
+			} else if scriptFileExtension.MatchString(cmd) {
+				if _, err := exec.LookPath(cmd); err != nil && !util.PathExists(cmd) {
+					missing = cmd
+				}
+			}
+		}
+		if missing != "" {
+			result = append(result, l.newIssue(s.key, "The %s script '%s' doesn't exist", s.name, missing))
+		}
+	}
+	return result
+}
+
+func checkNonExecutableScripts(l *Linter) []*Issue {
+	result := []*Issue{}
+	for _, s := range l.scripts() {
+		if s.stage.RelativeScript == "" {
+			continue
+		}
+		path := scriptPath(s.stage)
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() && info.Mode().Perm()&0111 == 0 {
+			result = append(result, l.newIssue(s.key, "The %s script '%s' is not executable", s.name, path))
+		}
+	}
+	return result
+}
+
+type namedScript struct {
+	// Used in messages, e.g. "deploy" or "errand 'backup'".
+	name string
+	// The key to look for in the Escape plan.
+	key   string
+	stage *core.ExecStage
+}
+
+func (l *Linter) scripts() []*namedScript {
+	result := []*namedScript{}
+	for _, name := range sortedKeys(l.Metadata.Stages) {
+		if stage := l.Metadata.Stages[name]; stage != nil {
+			result = append(result, &namedScript{name, name, stage})
+		}
+	}
+	for _, name := range sortedKeys(l.Metadata.Errands) {
+		if errand := l.Metadata.Errands[name]; errand.Run != nil {
+			result = append(result, &namedScript{fmt.Sprintf("errand '%s'", name), name, errand.Run})
+		}
+	}
+	for _, point := range sortedKeys(l.Metadata.Hooks) {
+		for _, hook := range l.Metadata.Hooks[point] {
+			if hook.Run != nil {
+				result = append(result, &namedScript{fmt.Sprintf("%s hook", point), point, hook.Run})
+			}
+		}
+	}
+	return result
+}
+
+func scriptPath(stage *core.ExecStage) string {
+	fields := strings.Fields(stage.RelativeScript)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// scriptContents returns the commands and inline scripts, and the contents
+// of the script files that exist.
+func (l *Linter) scriptContents() []string {
+	result := []string{}
+	for _, s := range l.scripts() {
+		result = append(result, s.stage.Cmd, strings.Join(s.stage.Args, " "), s.stage.Inline, s.stage.RelativeScript)
+		if path := scriptPath(s.stage); path != "" {
+			if content, err := ioutil.ReadFile(path); err == nil {
+				result = append(result, string(content))
+			}
+		}
+	}
+	return result
+}
+
+// usages returns the text of everything that can refer to a variable: the
+// scripts, the templates, the dependency mappings, the metadata and the
+// defaults of the variables other than the input with id `except`.
+func (l *Linter) usages(except string) string {
+	result := l.scriptContents()
+	for _, tpl := range l.Metadata.Templates {
+		result = append(result, tpl.When)
+		for key, val := range tpl.Mapping {
+			if val != "$this.inputs."+key {
+				result = append(result, fmt.Sprintf("%v", val))
+			}
+		}
+		result = append(result, templateContents(tpl.File)...)
+	}
+	for _, dep := range l.Metadata.Depends {
+		for _, mapping := range []map[string]interface{}{dep.Mapping, dep.BuildMapping, dep.DeployMapping} {
+			for key, val := range mapping {
+				if val != "$this.inputs."+key {
+					result = append(result, fmt.Sprintf("%v", val))
+				}
+			}
+		}
+		for _, val := range dep.Consumes {
+			result = append(result, val)
+		}
+	}
+	for _, val := range l.Metadata.Metadata {
+		result = append(result, val)
+	}
+	for _, v := range l.Metadata.Inputs {
+		if v.Id != except && v.HasDefault() {
+			result = append(result, fmt.Sprintf("%v", v.Default))
+		}
+	}
+	for _, v := range l.Metadata.Outputs {
+		if v.HasDefault() {
+			result = append(result, fmt.Sprintf("%v", v.Default))
+		}
+	}
+	for _, errand := range l.Metadata.Errands {
+		result = append(result, errand.Script)
+		for _, v := range errand.Inputs {
+			if v.HasDefault() {
+				result = append(result, fmt.Sprintf("%v", v.Default))
+			}
+		}
+	}
+	return strings.Join(result, "\n")
+}
+
+func templateContents(file string) []string {
+	result := []string{}
+	filepath.Walk(file, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if content, err := ioutil.ReadFile(path); err == nil {
+			result = append(result, string(content))
+		}
+		return nil
+	})
+	return result
+}
+
+// isPassedToDependency returns true if a dependency has an input with the
+// same id, because the compiler passes these on automatically.
+func (l *Linter) isPassedToDependency(id string) bool {
+	if l.GetDependencyMetadata == nil {
+		return false
+	}
+	for _, dep := range l.Metadata.Depends {
+		metadata, err := l.GetDependencyMetadata(dep)
+		if err != nil {
+			continue
+		}
+		for _, input := range metadata.Inputs {
+			if input.Id == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// planVariables returns the variables that are defined in the Escape plan
+// itself, as opposed to the ones that come from extensions and
+// dependencies. Variables that can't be parsed are skipped; the compiler
+// reports those.
+func (l *Linter) planVariables(lists ...[]interface{}) []*variables.Variable {
+	result := []*variables.Variable{}
+	for _, list := range lists {
+		for _, v := range list {
+			variable, err := variables.NewVariableFromInterface(v)
+			if err == nil {
+				result = append(result, variable)
+			}
+		}
+	}
+	return result
+}
+
+// isReferenced returns true if the id is used in the text. Scripts get the
+// variables as environment variables, which may be prefixed with INPUT_ or
+// OUTPUT_.
+func isReferenced(text, id string) bool {
+	re := regexp.MustCompile(`(^|[^A-Za-z0-9_]|INPUT_|OUTPUT_)` + regexp.QuoteMeta(id) + `($|[^A-Za-z0-9_])`)
+	return re.MatchString(text)
+}
+
+func sortedKeys(m interface{}) []string {
+	result := []string{}
+	switch m.(type) {
+	case map[string]*core.ExecStage:
+		for key := range m.(map[string]*core.ExecStage) {
+			result = append(result, key)
+		}
+	case map[string]*core.Errand:
+		for key := range m.(map[string]*core.Errand) {
+			result = append(result, key)
+		}
+	case map[string][]*core.Hook:
+		for key := range m.(map[string][]*core.Hook) {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
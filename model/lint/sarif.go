@@ -0,0 +1,142 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"path/filepath"
+
+	"github.com/ankyra/escape/util"
+)
+
+// The SARIF 2.1.0 format is used by code review tools to annotate the
+// lines that have issues. Only the fields that Escape uses are defined.
+type SarifLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*SarifRun `json:"runs"`
+}
+
+type SarifRun struct {
+	Tool    *SarifTool     `json:"tool"`
+	Results []*SarifResult `json:"results"`
+}
+
+type SarifTool struct {
+	Driver *SarifDriver `json:"driver"`
+}
+
+type SarifDriver struct {
+	Name           string       `json:"name"`
+	Version        string       `json:"version"`
+	InformationUri string       `json:"informationUri"`
+	Rules          []*SarifRule `json:"rules"`
+}
+
+type SarifRule struct {
+	Id                   string                  `json:"id"`
+	ShortDescription     *SarifMessage           `json:"shortDescription"`
+	DefaultConfiguration *SarifRuleConfiguration `json:"defaultConfiguration"`
+}
+
+type SarifRuleConfiguration struct {
+	Level string `json:"level"`
+}
+
+type SarifMessage struct {
+	Text string `json:"text"`
+}
+
+type SarifResult struct {
+	RuleId    string           `json:"ruleId"`
+	Level     string           `json:"level"`
+	Message   *SarifMessage    `json:"message"`
+	Locations []*SarifLocation `json:"locations"`
+}
+
+type SarifLocation struct {
+	PhysicalLocation *SarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type SarifPhysicalLocation struct {
+	ArtifactLocation *SarifArtifactLocation `json:"artifactLocation"`
+	Region           *SarifRegion           `json:"region,omitempty"`
+}
+
+type SarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type SarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+func sarifLevel(severity Severity) string {
+	switch severity {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Off:
+		return "none"
+	}
+	return "note"
+}
+
+// ToSarif converts the issues into a SARIF log. The rules are included with
+// their configured severity.
+func (l *Linter) ToSarif(issues []*Issue) *SarifLog {
+	driver := &SarifDriver{
+		Name:           "escape",
+		Version:        util.EscapeVersion,
+		InformationUri: "https://escape.ankyra.io/",
+		Rules:          []*SarifRule{},
+	}
+	for _, rule := range Rules {
+		driver.Rules = append(driver.Rules, &SarifRule{
+			Id:               rule.Id,
+			ShortDescription: &SarifMessage{rule.Description},
+			DefaultConfiguration: &SarifRuleConfiguration{
+				Level: sarifLevel(l.GetSeverity(rule.Id)),
+			},
+		})
+	}
+	results := []*SarifResult{}
+	for _, issue := range issues {
+		location := &SarifPhysicalLocation{
+			ArtifactLocation: &SarifArtifactLocation{filepath.ToSlash(issue.File)},
+		}
+		if issue.Line > 0 {
+			location.Region = &SarifRegion{issue.Line}
+		}
+		results = append(results, &SarifResult{
+			RuleId:    issue.Rule,
+			Level:     sarifLevel(issue.Severity),
+			Message:   &SarifMessage{issue.Message},
+			Locations: []*SarifLocation{&SarifLocation{location}},
+		})
+	}
+	return &SarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []*SarifRun{
+			&SarifRun{
+				Tool:    &SarifTool{driver},
+				Results: results,
+			},
+		},
+	}
+}
@@ -0,0 +1 @@
+{{in_template}} {{region}} {{mapped}}
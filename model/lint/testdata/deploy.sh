@@ -0,0 +1,3 @@
+#!/bin/sh
+echo $INPUT_used
+echo "{\"url\": \"http://localhost\"}" > .escape/outputs.json
@@ -0,0 +1,180 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lsp
+
+import (
+	"io/ioutil"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type lspSuite struct{}
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&lspSuite{})
+
+func readTestPlan(c *C, name string) string {
+	content, err := ioutil.ReadFile("testdata/" + name)
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func (s *lspSuite) Test_Validate_valid_plan(c *C) {
+	c.Assert(Validate(readTestPlan(c, "plan.yml")), DeepEquals, []Diagnostic{})
+}
+
+func (s *lspSuite) Test_Validate(c *C) {
+	diagnostics := Validate(readTestPlan(c, "invalid_plan.yml"))
+	expected := []struct {
+		Line     int
+		Severity int
+		Message  string
+	}{
+		{5, SeverityWarning, "Input 'unknown' is not defined in the Escape plan"},
+		{8, SeverityError, "Couldn't parse expression '\\$this.inputs.name.concat\\('.* in field 'inputs\\[2\\].default'"},
+		{12, SeverityWarning, "Input 'missing' is not defined in the Escape plan"},
+		{17, SeverityWarning, "Unknown field 'targt' in 'templates\\[0\\]'"},
+		{19, SeverityError, "Missing required field 'file' in 'templates\\[1\\]'"},
+		{26, SeverityWarning, "Unknown field 'descr' in 'errands.backup'"},
+		{30, SeverityError, "Expecting boolean for field 'hooks.before_deploy\\[0\\].fatal'; got string"},
+		{32, SeverityError, "Missing required field 'dest' in 'downloads\\[0\\]'"},
+	}
+	c.Assert(diagnostics, HasLen, len(expected))
+	for i, e := range expected {
+		c.Assert(diagnostics[i].Range.Start.Line, Equals, e.Line, Commentf("%s", diagnostics[i].Message))
+		c.Assert(diagnostics[i].Severity, Equals, e.Severity)
+		c.Assert(diagnostics[i].Message, Matches, e.Message)
+		c.Assert(diagnostics[i].Source, Equals, "escape")
+	}
+}
+
+func (s *lspSuite) Test_Validate_top_level_fields(c *C) {
+	diagnostics := Validate("description: test\nunknown: field\n")
+	c.Assert(diagnostics, HasLen, 3)
+	c.Assert(diagnostics[0].Message, Equals, "Missing required field 'name'")
+	c.Assert(diagnostics[1].Message, Equals, "Missing required field 'version'")
+	c.Assert(diagnostics[2].Message, Equals, "Unknown field 'unknown'")
+	c.Assert(diagnostics[2].Range, DeepEquals, Range{Position{1, 0}, Position{1, 14}})
+}
+
+func (s *lspSuite) Test_Validate_yaml_errors(c *C) {
+	diagnostics := Validate("name: test\nversion: 1.0\ninputs: [a, b\n")
+	c.Assert(diagnostics, HasLen, 1)
+	c.Assert(diagnostics[0].Range.Start.Line, Equals, 2)
+	c.Assert(diagnostics[0].Severity, Equals, SeverityError)
+	c.Assert(diagnostics[0].Message, Equals, "yaml: line 3: did not find expected ',' or ']'")
+}
+
+func (s *lspSuite) Test_Validate_ignores_scripts(c *C) {
+	diagnostics := Validate("name: test\nversion: 1.0\nbuild:\n  inline: echo $(\nhooks:\n  before_deploy:\n  - $x\n")
+	c.Assert(diagnostics, DeepEquals, []Diagnostic{})
+}
+
+func (s *lspSuite) Test_Validate_ignores_inputs_if_plan_extends(c *C) {
+	diagnostics := Validate("name: test\nversion: 1.0\nextends:\n- base-latest\nmetadata:\n  value: $this.inputs.from_extension\n")
+	c.Assert(diagnostics, DeepEquals, []Diagnostic{})
+}
+
+func (s *lspSuite) Test_Complete_top_level_fields(c *C) {
+	items := Complete("name: test\nver", Position{1, 3})
+	c.Assert(len(items) > 30, Equals, true)
+	found := false
+	for _, item := range items {
+		c.Assert(item.Kind, Equals, CompletionItemKindField)
+		if item.Label == "version" {
+			found = true
+			c.Assert(item.Documentation.Value, Matches, "(?s)The version is a required field.*")
+		}
+	}
+	c.Assert(found, Equals, true)
+}
+
+func (s *lspSuite) Test_Complete_nested_fields(c *C) {
+	labels := func(items []CompletionItem) []string {
+		result := []string{}
+		for _, item := range items {
+			result = append(result, item.Label)
+		}
+		return result
+	}
+	plan := readTestPlan(c, "plan.yml")
+	c.Assert(labels(Complete(plan+"  - \n", Position{17, 4})), DeepEquals,
+		[]string{"args", "cmd", "fatal", "inline", "name", "script"})
+	c.Assert(labels(Complete(plan+"    fa\n", Position{17, 6})), DeepEquals,
+		[]string{"args", "cmd", "fatal", "inline", "name", "script"})
+	c.Assert(labels(Complete("templates:\n- file: test\n  \n", Position{2, 2})), DeepEquals,
+		[]string{"engine", "file", "mapping", "mode", "scopes", "target", "when"})
+	c.Assert(labels(Complete("errands:\n  backup:\n    \n", Position{2, 4})), DeepEquals,
+		[]string{"description", "inputs", "outputs", "requires", "run", "schedule", "script"})
+	c.Assert(Complete("metadata:\n  \n", Position{1, 2}), DeepEquals, []CompletionItem{})
+}
+
+func (s *lspSuite) Test_Complete_inputs(c *C) {
+	plan := readTestPlan(c, "plan.yml") + "metadata:\n  value: $this.inputs.\n"
+	items := Complete(plan, Position{18, 22})
+	c.Assert(items, DeepEquals, []CompletionItem{
+		CompletionItem{
+			Label:         "greeting",
+			Kind:          CompletionItemKindVariable,
+			Detail:        "string",
+			Documentation: NewMarkdown("The greeting to use"),
+		},
+		CompletionItem{
+			Label: "name",
+			Kind:  CompletionItemKindVariable,
+		},
+	})
+}
+
+func (s *lspSuite) Test_Complete_builtins(c *C) {
+	items := Complete("metadata:\n  value: $this.inputs.name.up\n", Position{1, 30})
+	c.Assert(len(items) > 10, Equals, true)
+	c.Assert(items[0].Kind, Equals, CompletionItemKindFunction)
+	c.Assert(items[0].Label, Equals, "id")
+	c.Assert(items[0].Detail, Equals, "id(parameter :: *)")
+}
+
+func (s *lspSuite) Test_GetHover_builtin(c *C) {
+	plan := readTestPlan(c, "plan.yml")
+	hover := GetHover(plan, Position{12, 40})
+	c.Assert(hover, Not(IsNil))
+	c.Assert(hover.Contents.Kind, Equals, "markdown")
+	c.Assert(hover.Contents.Value, Equals, "```\nupper(v :: string)\n```\n\n"+
+		"Returns a copy of the string v with all Unicode characters mapped to their upper case\n\nActs on: strings")
+	c.Assert(*hover.Range, DeepEquals, Range{Position{12, 36}, Position{12, 41}})
+
+	hover = GetHover("metadata:\n  value: $__concat(\"a\", \"b\")\n", Position{1, 12})
+	c.Assert(hover, Not(IsNil))
+	c.Assert(hover.Contents.Value, Matches, "(?s)```\nconcat\\(.*")
+}
+
+func (s *lspSuite) Test_GetHover_field(c *C) {
+	plan := readTestPlan(c, "plan.yml")
+	hover := GetHover(plan, Position{10, 4})
+	c.Assert(hover, Not(IsNil))
+	c.Assert(hover.Contents.Value, Matches, "(?s)The file containing the template.*")
+	c.Assert(*hover.Range, DeepEquals, Range{Position{10, 2}, Position{10, 6}})
+}
+
+func (s *lspSuite) Test_GetHover_returns_nil_if_nothing_to_show(c *C) {
+	plan := readTestPlan(c, "plan.yml")
+	c.Assert(GetHover(plan, Position{0, 8}), IsNil)
+	c.Assert(GetHover(plan, Position{2, 0}), IsNil)
+	c.Assert(GetHover(plan, Position{100, 0}), IsNil)
+}
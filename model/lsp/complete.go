@@ -0,0 +1,221 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lsp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ankyra/escape-core/script"
+	"gopkg.in/yaml.v2"
+)
+
+var inputCompletionRegex = regexp.MustCompile(`\$this\.inputs\.[a-zA-Z0-9_]*$`)
+var builtinCompletionRegex = regexp.MustCompile(`(\$__|\$this\.inputs\.[a-zA-Z0-9_]+\.|\)\.)[a-z_]*$`)
+var fieldCompletionRegex = regexp.MustCompile(`^\s*(- )*[a-z_]*$`)
+
+// Complete returns the completions at the given position: the ids of the
+// inputs after `$this.inputs.`, the Escape Script builtins after a dot and
+// the field names in the Escape plan.
+func Complete(text string, pos Position) []CompletionItem {
+	doc := newDocument(text)
+	if pos.Line >= len(doc.lines) {
+		return []CompletionItem{}
+	}
+	line := []rune(doc.lines[pos.Line])
+	if pos.Character < len(line) {
+		line = line[:pos.Character]
+	}
+	prefix := string(line)
+	if inputCompletionRegex.MatchString(prefix) {
+		return completeInputs(doc, pos.Line)
+	}
+	if builtinCompletionRegex.MatchString(prefix) {
+		return completeBuiltins()
+	}
+	if fieldCompletionRegex.MatchString(prefix) {
+		return completeFields(doc.keyPath(pos.Line, keyCol(prefix)))
+	}
+	return []CompletionItem{}
+}
+
+func completeInputs(doc *document, line int) []CompletionItem {
+	// The line that is being edited usually doesn't parse, so it's left out.
+	lines := append([]string{}, doc.lines[:line]...)
+	lines = append(lines, doc.lines[line+1:]...)
+	plan := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal([]byte(strings.Join(lines, "\n")), &plan); err != nil {
+		return []CompletionItem{}
+	}
+	inputs := getInputs(plan)
+	ids := []string{}
+	for id := range inputs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	result := []CompletionItem{}
+	for _, id := range ids {
+		item := CompletionItem{
+			Label: id,
+			Kind:  CompletionItemKindVariable,
+		}
+		if typ, ok := inputs[id]["type"].(string); ok {
+			item.Detail = typ
+		}
+		if description, ok := inputs[id]["description"].(string); ok {
+			item.Documentation = NewMarkdown(description)
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+func completeBuiltins() []CompletionItem {
+	result := []CompletionItem{}
+	for _, f := range script.Stdlib {
+		result = append(result, CompletionItem{
+			Label:         f.Id,
+			Kind:          CompletionItemKindFunction,
+			Detail:        fmt.Sprintf("%s(%s)", f.Id, f.Args),
+			Documentation: NewMarkdown(f.Doc),
+		})
+	}
+	return result
+}
+
+func completeFields(path []string) []CompletionItem {
+	schema := schemaForPath(path)
+	if schema == nil {
+		return []CompletionItem{}
+	}
+	properties, _ := objectSchema(schema)["properties"].(map[string]interface{})
+	keys := []string{}
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := []CompletionItem{}
+	for _, key := range keys {
+		item := CompletionItem{
+			Label: key,
+			Kind:  CompletionItemKindField,
+		}
+		if description, ok := properties[key].(map[string]interface{})["description"].(string); ok {
+			item.Documentation = NewMarkdown(description)
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// GetHover returns the documentation for the Escape Script builtin or the
+// field name at the given position, or nil if there is nothing to show.
+func GetHover(text string, pos Position) *Hover {
+	doc := newDocument(text)
+	if pos.Line >= len(doc.lines) {
+		return nil
+	}
+	line := []rune(doc.lines[pos.Line])
+	start, end := pos.Character, pos.Character
+	if start > len(line) {
+		return nil
+	}
+	for start > 0 && isWordChar(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isWordChar(line[end]) {
+		end++
+	}
+	if start == end {
+		return nil
+	}
+	if start+2 < end && string(line[start:start+2]) == "__" && start > 0 && line[start-1] == '$' {
+		start += 2
+	}
+	word := string(line[start:end])
+	wordRange := &Range{Position{pos.Line, start}, Position{pos.Line, end}}
+	before := string(line[:start])
+	if strings.HasSuffix(before, ".") || strings.HasSuffix(before, "$__") {
+		for _, f := range script.Stdlib {
+			if f.Id == word {
+				return &Hover{Contents: *NewMarkdown(builtinDocs(f)), Range: wordRange}
+			}
+		}
+	}
+	m := keyRegex.FindStringSubmatch(doc.lines[pos.Line])
+	if m == nil || m[1] != word || strings.Contains(strings.TrimLeft(before, " -"), ":") {
+		return nil
+	}
+	schema := schemaForPath(append(doc.keyPath(pos.Line, keyCol(doc.lines[pos.Line])), word))
+	if schema == nil {
+		return nil
+	}
+	description, ok := schema["description"].(string)
+	if !ok {
+		return nil
+	}
+	return &Hover{Contents: *NewMarkdown(description), Range: wordRange}
+}
+
+func builtinDocs(f script.StdlibFunc) string {
+	result := fmt.Sprintf("```\n%s(%s)\n```\n\n%s", f.Id, f.Args, f.Doc)
+	if f.ActsOn != "" {
+		result += "\n\nActs on: " + f.ActsOn
+	}
+	return result
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// schemaForPath returns the schema of the value at the given path of keys,
+// or nil if there's no such value.
+func schemaForPath(path []string) map[string]interface{} {
+	schema := planSchema
+	for _, key := range path {
+		obj := objectSchema(schema)
+		properties, _ := obj["properties"].(map[string]interface{})
+		if prop, ok := properties[key].(map[string]interface{}); ok {
+			schema = prop
+		} else if additional, ok := obj["additionalProperties"].(map[string]interface{}); ok {
+			schema = additional
+		} else {
+			return nil
+		}
+	}
+	return schema
+}
+
+// objectSchema returns the object schema that is used for the value, looking
+// through references, lists and alternatives.
+func objectSchema(schema map[string]interface{}) map[string]interface{} {
+	schema = resolve(schema)
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		return objectSchema(items)
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		for _, s := range oneOf {
+			if obj := objectSchema(s.(map[string]interface{})); obj["type"] == "object" {
+				return obj
+			}
+		}
+	}
+	return schema
+}
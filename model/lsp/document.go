@@ -0,0 +1,180 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lsp
+
+import (
+	"regexp"
+	"strings"
+)
+
+var keyRegex = regexp.MustCompile(`^\s*(?:- )*["']?([^\s:#'"][^:#'"]*?)["']?\s*:(?:\s|$)`)
+
+// document gives line based access to an Escape plan, so that the values in
+// the parsed YAML can be tied back to lines. The YAML parser doesn't keep
+// track of positions.
+type document struct {
+	lines []string
+}
+
+// A block is a range of lines that holds a YAML value. Line is the line on
+// which the value starts; for values in a map that's the line of the key. Col
+// is the column of the keys in the block, if the value is a map.
+type block struct {
+	line, start, end, col int
+}
+
+func newDocument(text string) *document {
+	return &document{
+		lines: strings.Split(text, "\n"),
+	}
+}
+
+func (d *document) topLevel() block {
+	return block{0, 0, len(d.lines), 0}
+}
+
+func (d *document) isContent(i int) bool {
+	trimmed := strings.TrimSpace(d.lines[i])
+	return trimmed != "" && !strings.HasPrefix(trimmed, "#")
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// keyCol returns the column of the first key or value on the line, skipping
+// list item markers.
+func keyCol(line string) int {
+	col := leadingSpaces(line)
+	for strings.HasPrefix(line[col:], "- ") {
+		col += 2 + leadingSpaces(line[col+2:])
+	}
+	return col
+}
+
+// findKey returns the line in the block that holds key, or the line of the
+// value if it can't be found.
+func (d *document) findKey(b block, key string) int {
+	for i := b.start; i < b.end; i++ {
+		if !d.isContent(i) || keyCol(d.lines[i]) != b.col {
+			continue
+		}
+		if m := keyRegex.FindStringSubmatch(d.lines[i]); m != nil && m[1] == key {
+			return i
+		}
+	}
+	return b.line
+}
+
+// findText returns the first line of the value that contains the text, or
+// the line of the value.
+func (d *document) findText(b block, text string) int {
+	text = strings.TrimSpace(strings.Split(text, "\n")[0])
+	for i := b.line; i < b.end; i++ {
+		if strings.Contains(d.lines[i], text) {
+			return i
+		}
+	}
+	return b.line
+}
+
+// valueBlock returns the block of the value of the key on the given line.
+func (d *document) valueBlock(keyLine int) block {
+	col := keyCol(d.lines[keyLine])
+	end := keyLine + 1
+	childCol := -1
+	for ; end < len(d.lines); end++ {
+		if !d.isContent(end) {
+			continue
+		}
+		line := d.lines[end]
+		spaces := leadingSpaces(line)
+		if spaces < col || (spaces == col && !strings.HasPrefix(line[spaces:], "- ")) {
+			break
+		}
+		if childCol == -1 {
+			childCol = keyCol(line)
+		}
+	}
+	return block{keyLine, keyLine + 1, end, childCol}
+}
+
+// itemBlocks splits a block that holds a list into a block per item.
+func (d *document) itemBlocks(b block) []block {
+	result := []block{}
+	dashCol := -1
+	for i := b.start; i < b.end; i++ {
+		if !d.isContent(i) {
+			continue
+		}
+		line := d.lines[i]
+		spaces := leadingSpaces(line)
+		if dashCol == -1 {
+			dashCol = spaces
+		}
+		if spaces == dashCol && strings.HasPrefix(line[spaces:], "- ") {
+			if len(result) > 0 {
+				result[len(result)-1].end = i
+			}
+			result = append(result, block{i, i, b.end, keyCol(line)})
+		}
+	}
+	return result
+}
+
+func (d *document) lineRange(i int) Range {
+	if i >= len(d.lines) {
+		i = len(d.lines) - 1
+	}
+	line := []rune(d.lines[i])
+	start := len(line) - len([]rune(strings.TrimLeft(d.lines[i], " ")))
+	return Range{
+		Start: Position{i, start},
+		End:   Position{i, len(line)},
+	}
+}
+
+func (d *document) diagnostic(line, severity int, message string) Diagnostic {
+	return Diagnostic{
+		Range:    d.lineRange(line),
+		Severity: severity,
+		Source:   "escape",
+		Message:  message,
+	}
+}
+
+// keyPath returns the keys of the maps that contain the given column on the
+// given line.
+func (d *document) keyPath(line, col int) []string {
+	result := []string{}
+	for i := line - 1; i >= 0 && col > 0; i-- {
+		if !d.isContent(i) {
+			continue
+		}
+		c := keyCol(d.lines[i])
+		if c >= col {
+			continue
+		}
+		m := keyRegex.FindStringSubmatch(d.lines[i])
+		if m == nil {
+			continue
+		}
+		result = append([]string{m[1]}, result...)
+		col = c
+	}
+	return result
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Server is a minimal language server for Escape plans. It talks JSON-RPC
+// over a reader and writer (usually stdin and stdout) and keeps the open
+// documents in memory.
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]string
+	shutdown  bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: map[string]string{},
+	}
+}
+
+// The largest message body the server accepts. Plans are a lot smaller than
+// this, so anything bigger is most likely a broken client.
+const maxContentLength = 16 * 1024 * 1024
+
+// Serve handles messages until the client sends an 'exit' notification or
+// closes the input. Messages that aren't valid JSON are answered with a parse
+// error, after which the server carries on with the next message.
+func (s *Server) Serve() error {
+	for {
+		body, err := s.readMessage()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msg := &Message{}
+		if err := json.Unmarshal(body, msg); err != nil {
+			if err := s.writeError(nil, ParseError, "Couldn't parse message: "+err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("Language server exited without shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *Message) error {
+	var result interface{}
+	var err error
+	switch msg.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": TextDocumentSyncFull,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{"."},
+				},
+				"hoverProvider": true,
+			},
+			"serverInfo": map[string]interface{}{
+				"name": "escape",
+			},
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		params := DidOpenTextDocumentParams{}
+		if err = unmarshalParams(msg, &params); err == nil {
+			err = s.updateDocument(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		params := DidChangeTextDocumentParams{}
+		if err = unmarshalParams(msg, &params); err == nil && len(params.ContentChanges) > 0 {
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			err = s.updateDocument(params.TextDocument.URI, text)
+		}
+	case "textDocument/didClose":
+		params := DidCloseTextDocumentParams{}
+		if err = unmarshalParams(msg, &params); err == nil {
+			delete(s.documents, params.TextDocument.URI)
+			err = s.publishDiagnostics(params.TextDocument.URI, []Diagnostic{})
+		}
+	case "textDocument/completion":
+		params := TextDocumentPositionParams{}
+		if err = unmarshalParams(msg, &params); err == nil {
+			text := s.documents[params.TextDocument.URI]
+			result = CompletionList{Items: Complete(text, params.Position)}
+		}
+	case "textDocument/hover":
+		params := TextDocumentPositionParams{}
+		if err = unmarshalParams(msg, &params); err == nil {
+			text := s.documents[params.TextDocument.URI]
+			if hover := GetHover(text, params.Position); hover != nil {
+				result = hover
+			}
+		}
+	default:
+		if msg.Id != nil {
+			return s.writeError(msg.Id, MethodNotFound, fmt.Sprintf("Method '%s' not found", msg.Method))
+		}
+		return nil
+	}
+	if msg.Id == nil {
+		return err
+	}
+	if err != nil {
+		return s.writeError(msg.Id, InvalidParams, err.Error())
+	}
+	return s.write(Response{JsonRPC: "2.0", Id: msg.Id, Result: result})
+}
+
+func (s *Server) updateDocument(uri, text string) error {
+	s.documents[uri] = text
+	return s.publishDiagnostics(uri, Validate(text))
+}
+
+func (s *Server) publishDiagnostics(uri string, diagnostics []Diagnostic) error {
+	return s.write(Notification{
+		JsonRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params: PublishDiagnosticsParams{
+			URI:         uri,
+			Diagnostics: diagnostics,
+		},
+	})
+}
+
+func unmarshalParams(msg *Message, v interface{}) error {
+	if msg.Params == nil {
+		return fmt.Errorf("Missing parameters for method '%s'", msg.Method)
+	}
+	if err := json.Unmarshal(*msg.Params, v); err != nil {
+		return fmt.Errorf("Invalid parameters for method '%s': %s", msg.Method, err.Error())
+	}
+	return nil
+}
+
+func (s *Server) readMessage() ([]byte, error) {
+	headers, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Couldn't read message headers: %s", err.Error())
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(headers.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("Invalid Content-Length header '%s'", headers.Get("Content-Length"))
+	}
+	if length > maxContentLength {
+		return nil, fmt.Errorf("Message of %d bytes exceeds the maximum of %d bytes", length, maxContentLength)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, fmt.Errorf("Couldn't read message body: %s", err.Error())
+	}
+	return body, nil
+}
+
+func (s *Server) writeError(id *json.RawMessage, code int, message string) error {
+	return s.write(ErrorResponse{
+		JsonRPC: "2.0",
+		Id:      id,
+		Error: ResponseError{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+func (s *Server) write(v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+
+	. "gopkg.in/check.v1"
+)
+
+func frame(messages ...string) *bytes.Buffer {
+	result := bytes.NewBufferString("")
+	for _, msg := range messages {
+		fmt.Fprintf(result, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+	return result
+}
+
+func readOutput(c *C, out *bytes.Buffer) []map[string]interface{} {
+	reader := bufio.NewReader(out)
+	result := []map[string]interface{}{}
+	for {
+		headers, err := textproto.NewReader(reader).ReadMIMEHeader()
+		if err == io.EOF {
+			return result
+		}
+		c.Assert(err, IsNil)
+		length, err := strconv.Atoi(headers.Get("Content-Length"))
+		c.Assert(err, IsNil)
+		body := make([]byte, length)
+		_, err = io.ReadFull(reader, body)
+		c.Assert(err, IsNil)
+		msg := map[string]interface{}{}
+		c.Assert(json.Unmarshal(body, &msg), IsNil)
+		result = append(result, msg)
+	}
+}
+
+func (s *lspSuite) Test_Server(c *C) {
+	in := frame(
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///escape.yml","text":"name: test\nunknown: field\n"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"file:///escape.yml"},"contentChanges":[{"text":"name: test\nversion: 1.0\nmetadata:\n  x: $__upper(\"x\")\n"}]}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///escape.yml"},"position":{"line":3,"character":9}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"textDocument/completion","params":{"textDocument":{"uri":"file:///escape.yml"},"position":{"line":2,"character":3}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"textDocument/definition","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didClose","params":{"textDocument":{"uri":"file:///escape.yml"}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	out := bytes.NewBufferString("")
+	c.Assert(NewServer(in, out).Serve(), IsNil)
+	messages := readOutput(c, out)
+	c.Assert(messages, HasLen, 8)
+
+	c.Assert(messages[0]["id"], Equals, 1.0)
+	capabilities := messages[0]["result"].(map[string]interface{})["capabilities"].(map[string]interface{})
+	c.Assert(capabilities["textDocumentSync"], Equals, 1.0)
+	c.Assert(capabilities["hoverProvider"], Equals, true)
+
+	c.Assert(messages[1]["method"], Equals, "textDocument/publishDiagnostics")
+	params := messages[1]["params"].(map[string]interface{})
+	c.Assert(params["uri"], Equals, "file:///escape.yml")
+	c.Assert(params["diagnostics"], HasLen, 2)
+
+	c.Assert(messages[2]["method"], Equals, "textDocument/publishDiagnostics")
+	c.Assert(messages[2]["params"].(map[string]interface{})["diagnostics"], HasLen, 0)
+
+	c.Assert(messages[3]["id"], Equals, 2.0)
+	contents := messages[3]["result"].(map[string]interface{})["contents"].(map[string]interface{})
+	c.Assert(contents["value"], Matches, "(?s)```\nupper.*")
+
+	c.Assert(messages[4]["id"], Equals, 3.0)
+	items := messages[4]["result"].(map[string]interface{})["items"].([]interface{})
+	c.Assert(len(items) > 30, Equals, true)
+
+	c.Assert(messages[5]["id"], Equals, 4.0)
+	c.Assert(messages[5]["error"], DeepEquals, map[string]interface{}{
+		"code":    -32601.0,
+		"message": "Method 'textDocument/definition' not found",
+	})
+
+	c.Assert(messages[6]["method"], Equals, "textDocument/publishDiagnostics")
+	c.Assert(messages[6]["params"].(map[string]interface{})["diagnostics"], HasLen, 0)
+
+	c.Assert(messages[7]["id"], Equals, 5.0)
+	c.Assert(messages[7]["result"], IsNil)
+}
+
+func (s *lspSuite) Test_Server_returns_error_for_invalid_params(c *C) {
+	in := frame(`{"jsonrpc":"2.0","id":1,"method":"textDocument/hover","params":[]}`)
+	out := bytes.NewBufferString("")
+	c.Assert(NewServer(in, out).Serve(), IsNil)
+	messages := readOutput(c, out)
+	c.Assert(messages, HasLen, 1)
+	c.Assert(messages[0]["error"].(map[string]interface{})["code"], Equals, -32602.0)
+}
+
+func (s *lspSuite) Test_Server_fails_on_exit_without_shutdown(c *C) {
+	in := frame(`{"jsonrpc":"2.0","method":"exit"}`)
+	err := NewServer(in, bytes.NewBufferString("")).Serve()
+	c.Assert(err, ErrorMatches, "Language server exited without shutdown")
+}
+
+func (s *lspSuite) Test_Server_fails_on_invalid_header(c *C) {
+	in := bufio.NewReader(bytes.NewBufferString("Content-Length: abc\r\n\r\n{}"))
+	err := NewServer(in, bytes.NewBufferString("")).Serve()
+	c.Assert(err, ErrorMatches, "Invalid Content-Length header 'abc'")
+}
+
+func (s *lspSuite) Test_Server_fails_on_negative_content_length(c *C) {
+	in := bufio.NewReader(bytes.NewBufferString("Content-Length: -1\r\n\r\n{}"))
+	err := NewServer(in, bytes.NewBufferString("")).Serve()
+	c.Assert(err, ErrorMatches, "Invalid Content-Length header '-1'")
+}
+
+func (s *lspSuite) Test_Server_fails_on_content_length_over_the_limit(c *C) {
+	in := bufio.NewReader(bytes.NewBufferString("Content-Length: 1000000000000\r\n\r\n{}"))
+	err := NewServer(in, bytes.NewBufferString("")).Serve()
+	c.Assert(err, ErrorMatches, "Message of 1000000000000 bytes exceeds the maximum of 16777216 bytes")
+}
+
+func (s *lspSuite) Test_Server_returns_parse_error_and_continues_on_invalid_json(c *C) {
+	in := frame(
+		`{"jsonrpc":"2.0","id":1,`,
+		`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	out := bytes.NewBufferString("")
+	c.Assert(NewServer(in, out).Serve(), IsNil)
+	messages := readOutput(c, out)
+	c.Assert(messages, HasLen, 2)
+	c.Assert(messages[0]["id"], IsNil)
+	c.Assert(messages[0]["error"].(map[string]interface{})["code"], Equals, -32700.0)
+	c.Assert(messages[1]["id"], Equals, 2.0)
+}
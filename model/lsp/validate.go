@@ -0,0 +1,286 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lsp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ankyra/escape-core/script"
+	"github.com/ankyra/escape/model/escape_plan"
+	"gopkg.in/yaml.v2"
+)
+
+var planSchema = escape_plan.JsonSchema()
+var definitions = planSchema["definitions"].(map[string]interface{})
+
+var yamlLineRegex = regexp.MustCompile(`line ([0-9]+)`)
+var inputReferenceRegex = regexp.MustCompile(`\$this\.inputs\.([a-zA-Z_][a-zA-Z0-9_]*)`)
+
+// The values of these definitions are shell commands and scripts, not Escape
+// Script.
+var scriptDefinitions = []string{"exec_stage", "hook"}
+
+// Validate checks the Escape plan against the JSON Schema and checks the
+// Escape Script expressions in its values.
+func Validate(text string) []Diagnostic {
+	doc := newDocument(text)
+	plan := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal([]byte(text), &plan); err != nil {
+		line := 0
+		if m := yamlLineRegex.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			line--
+		}
+		return []Diagnostic{doc.diagnostic(line, SeverityError, err.Error())}
+	}
+	v := &validator{
+		doc:         doc,
+		diagnostics: []Diagnostic{},
+		inputs:      getInputs(plan),
+	}
+	if _, ok := plan["extends"]; ok {
+		// Inputs can be defined in the extensions.
+		v.inputs = nil
+	}
+	v.validate(plan, planSchema, "", doc.topLevel(), true)
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		return v.diagnostics[i].Range.Start.Line < v.diagnostics[j].Range.Start.Line
+	})
+	return v.diagnostics
+}
+
+type validator struct {
+	doc         *document
+	diagnostics []Diagnostic
+	inputs      map[string]map[interface{}]interface{}
+}
+
+func (v *validator) add(line, severity int, format string, a ...interface{}) {
+	v.diagnostics = append(v.diagnostics, v.doc.diagnostic(line, severity, fmt.Sprintf(format, a...)))
+}
+
+func (v *validator) validate(value interface{}, schema map[string]interface{}, path string, b block, isScript bool) {
+	if refersTo(schema, scriptDefinitions...) {
+		isScript = false
+	}
+	schema = resolve(schema)
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		expected := []string{}
+		for _, s := range oneOf {
+			s := resolve(s.(map[string]interface{}))
+			if matchesType(value, s["type"]) {
+				v.validate(value, s, path, b, isScript)
+				return
+			}
+			expected = append(expected, fmt.Sprintf("%v", s["type"]))
+		}
+		v.add(b.line, SeverityError, "Expecting %s for field '%s'; got %s", strings.Join(expected, " or "), path, typeName(value))
+		return
+	}
+	if typ, ok := schema["type"]; ok && !matchesType(value, typ) {
+		v.add(b.line, SeverityError, "Expecting %s for field '%s'; got %s", typ, path, typeName(value))
+		return
+	}
+	switch value.(type) {
+	case map[interface{}]interface{}:
+		v.validateMap(value.(map[interface{}]interface{}), schema, path, b, isScript)
+	case []interface{}:
+		items, _ := schema["items"].(map[string]interface{})
+		blocks := v.doc.itemBlocks(b)
+		for i, item := range value.([]interface{}) {
+			itemBlock := b
+			if i < len(blocks) {
+				itemBlock = blocks[i]
+			}
+			v.validate(item, items, fmt.Sprintf("%s[%d]", path, i), itemBlock, isScript)
+		}
+	case string:
+		if isScript {
+			v.validateScript(value.(string), path, b)
+		}
+	}
+}
+
+func (v *validator) validateMap(value map[interface{}]interface{}, schema map[string]interface{}, path string, b block, isScript bool) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	values := map[string]interface{}{}
+	for key, val := range value {
+		values[fmt.Sprintf("%v", key)] = val
+	}
+	for _, key := range sortedKeys(values) {
+		line := v.doc.findKey(b, key)
+		valueBlock := v.doc.valueBlock(line)
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		if prop, ok := properties[key].(map[string]interface{}); ok {
+			v.validate(values[key], prop, fieldPath, valueBlock, isScript)
+		} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+			v.validate(values[key], additional, fieldPath, valueBlock, isScript)
+		} else if schema["additionalProperties"] == false {
+			if path == "" {
+				v.add(line, SeverityWarning, "Unknown field '%s'", key)
+			} else {
+				v.add(line, SeverityWarning, "Unknown field '%s' in '%s'", key, path)
+			}
+		}
+	}
+	required, _ := schema["required"].([]string)
+	for _, key := range required {
+		if _, ok := values[key]; !ok {
+			if path == "" {
+				v.add(b.line, SeverityError, "Missing required field '%s'", key)
+			} else {
+				v.add(b.line, SeverityError, "Missing required field '%s' in '%s'", key, path)
+			}
+		}
+	}
+}
+
+func (v *validator) validateScript(str, path string, b block) {
+	isExpression := strings.HasPrefix(str, "$") && !strings.HasPrefix(str, "$$")
+	if !isExpression && !strings.Contains(str, "{{") {
+		return
+	}
+	line := v.doc.findText(b, str)
+	if _, err := script.ParseScript(str); err != nil {
+		v.add(line, SeverityError, "%s in field '%s'", err.Error(), path)
+		return
+	}
+	if v.inputs == nil {
+		return
+	}
+	for _, m := range inputReferenceRegex.FindAllStringSubmatch(str, -1) {
+		if _, ok := v.inputs[m[1]]; !ok {
+			v.add(line, SeverityWarning, "Input '%s' is not defined in the Escape plan", m[1])
+		}
+	}
+}
+
+// getInputs returns the input variables that are defined in the plan and
+// its errands, by id.
+func getInputs(plan map[interface{}]interface{}) map[string]map[interface{}]interface{} {
+	result := map[string]map[interface{}]interface{}{}
+	lists := []interface{}{plan["inputs"], plan["build_inputs"], plan["deploy_inputs"]}
+	if errands, ok := plan["errands"].(map[interface{}]interface{}); ok {
+		for _, errand := range errands {
+			if errandMap, ok := errand.(map[interface{}]interface{}); ok {
+				lists = append(lists, errandMap["inputs"])
+			}
+		}
+	}
+	for _, list := range lists {
+		inputs, _ := list.([]interface{})
+		for _, input := range inputs {
+			switch input.(type) {
+			case string:
+				result[input.(string)] = map[interface{}]interface{}{}
+			case map[interface{}]interface{}:
+				if id, ok := input.(map[interface{}]interface{})["id"].(string); ok {
+					result[id] = input.(map[interface{}]interface{})
+				}
+			}
+		}
+	}
+	return result
+}
+
+func resolve(schema map[string]interface{}) map[string]interface{} {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/definitions/")
+		if def, ok := definitions[name].(map[string]interface{}); ok {
+			return def
+		}
+	}
+	return schema
+}
+
+func refersTo(schema map[string]interface{}, names ...string) bool {
+	refs := []interface{}{schema}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		refs = append(refs, oneOf...)
+	}
+	for _, r := range refs {
+		for _, name := range names {
+			if r.(map[string]interface{})["$ref"] == "#/definitions/"+name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesType(value interface{}, typ interface{}) bool {
+	if value == nil || typ == nil {
+		// Empty fields are treated as unset.
+		return true
+	}
+	switch typ {
+	case "object":
+		_, ok := value.(map[interface{}]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		_, ok := value.(int)
+		return ok
+	case "string":
+		// Scalars are accepted where strings are expected.
+		switch value.(type) {
+		case string, int, float64, bool:
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[interface{}]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case bool:
+		return "boolean"
+	case int:
+		return "integer"
+	case float64:
+		return "number"
+	}
+	return "string"
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	result := []string{}
+	for key := range m {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
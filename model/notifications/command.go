@@ -0,0 +1,80 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ankyra/escape-core/state"
+)
+
+// A CommandSink runs a local command for every status change. The status
+// change is written to the command's stdin as JSON, and is also available
+// in the ESCAPE_NOTIFY_* environment variables.
+type CommandSink struct {
+	Command []string
+}
+
+func NewCommandSink(command []string) *CommandSink {
+	return &CommandSink{
+		Command: command,
+	}
+}
+
+func (c *CommandSink) Send(change *state.StatusChange) error {
+	if len(c.Command) == 0 {
+		return fmt.Errorf("Missing command")
+	}
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), SendTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(),
+		"ESCAPE_NOTIFY_PROJECT="+change.Project,
+		"ESCAPE_NOTIFY_ENVIRONMENT="+change.Environment,
+		"ESCAPE_NOTIFY_DEPLOYMENT="+change.Deployment,
+		"ESCAPE_NOTIFY_PATH="+change.Path,
+		"ESCAPE_NOTIFY_STAGE="+change.Stage,
+		"ESCAPE_NOTIFY_RELEASE="+change.Release,
+		"ESCAPE_NOTIFY_VERSION="+change.Version,
+		"ESCAPE_NOTIFY_STATUS="+string(change.Status.Code),
+		"ESCAPE_NOTIFY_PREVIOUS_STATUS="+string(change.PreviousCode),
+		"ESCAPE_NOTIFY_DATA="+change.Status.Data,
+		"ESCAPE_NOTIFY_SUMMARY="+Summary(change),
+	)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("Notification command '%s' timed out after %s", strings.Join(c.Command, " "), SendTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("Notification command '%s' failed: %s %s", strings.Join(c.Command, " "), err.Error(), strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (c *CommandSink) String() string {
+	return "command " + strings.Join(c.Command, " ")
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package notifications
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/ankyra/escape-core/state"
+)
+
+// The statuses that are notified when a Filter doesn't specify any.
+var DefaultStatuses = []string{
+	string(state.OK),
+	state.Failure,
+	state.TestFailure,
+	state.DestroyFailure,
+}
+
+// How long a Sink gets to deliver a notification. Notifications are sent
+// while the deployment state is updated, so a slow Sink shouldn't be able
+// to hold up a deployment.
+var SendTimeout = 10 * time.Second
+
+// A Sink delivers notifications about status changes.
+type Sink interface {
+	Send(change *state.StatusChange) error
+	String() string
+}
+
+// A Filter decides which status changes are sent to a Sink. The
+// environments and deployments are glob patterns; an empty list matches
+// everything. Deployments are matched against both the root deployment
+// name and the deployment path, so that dependencies can be selected too.
+type Filter struct {
+	Environments []string
+	Deployments  []string
+	Statuses     []string
+}
+
+func (f *Filter) Matches(change *state.StatusChange) bool {
+	if change.Status == nil {
+		return false
+	}
+	statuses := f.Statuses
+	if len(statuses) == 0 {
+		statuses = DefaultStatuses
+	}
+	if !contains(statuses, string(change.Status.Code)) {
+		return false
+	}
+	if len(f.Environments) > 0 && !matchesAny(f.Environments, change.Environment) {
+		return false
+	}
+	if len(f.Deployments) > 0 && !matchesAny(f.Deployments, change.Deployment) && !matchesAny(f.Deployments, change.Path) {
+		return false
+	}
+	return true
+}
+
+// A Notifier sends the status changes that match its Filter to its Sink.
+type Notifier struct {
+	Sink   Sink
+	Filter *Filter
+}
+
+func NewNotifier(sink Sink, filter *Filter) *Notifier {
+	if filter == nil {
+		filter = &Filter{}
+	}
+	return &Notifier{
+		Sink:   sink,
+		Filter: filter,
+	}
+}
+
+// NewStatusListener returns a listener that can be added to the
+// StatusListeners of a project state. Sending a notification should never
+// fail a deployment, so errors are passed to onError instead.
+func NewStatusListener(notifiers []*Notifier, onError func(*Notifier, error)) state.StatusListener {
+	return func(change *state.StatusChange) {
+		for _, n := range notifiers {
+			if !n.Filter.Matches(change) {
+				continue
+			}
+			if err := n.Sink.Send(change); err != nil && onError != nil {
+				onError(n, err)
+			}
+		}
+	}
+}
+
+// Summary returns a one line description of the status change.
+func Summary(change *state.StatusChange) string {
+	deployment := change.Path
+	if deployment == "" {
+		deployment = change.Deployment
+	}
+	release := change.Release
+	if release != "" && change.Version != "" {
+		release += "-v" + change.Version
+	}
+	result := fmt.Sprintf("Stage '%s' of deployment '%s' in environment '%s' is now '%s'", change.Stage, deployment, change.Environment, change.Status.Code)
+	if release != "" {
+		result += fmt.Sprintf(" (%s)", release)
+	}
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifications
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ankyra/escape-core/state"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type suite struct{}
+
+var _ = Suite(&suite{})
+
+func newStatusChange(code state.StatusCode) *state.StatusChange {
+	status := state.NewStatus(code)
+	status.Data = "it broke"
+	return &state.StatusChange{
+		Project:      "_",
+		Environment:  "prod",
+		Deployment:   "_/app",
+		Path:         "_/app:_/database",
+		Stage:        "deploy",
+		Release:      "_/database",
+		Version:      "1.0.0",
+		PreviousCode: state.RunningMainStep,
+		Status:       status,
+	}
+}
+
+type recordingSink struct {
+	changes []*state.StatusChange
+	err     error
+}
+
+func (r *recordingSink) Send(change *state.StatusChange) error {
+	r.changes = append(r.changes, change)
+	return r.err
+}
+
+func (r *recordingSink) String() string {
+	return "recording"
+}
+
+func (s *suite) Test_Filter_Matches(c *C) {
+	change := newStatusChange(state.Failure)
+	cases := []struct {
+		filter  *Filter
+		matches bool
+	}{
+		{&Filter{}, true},
+		{&Filter{Environments: []string{"prod"}}, true},
+		{&Filter{Environments: []string{"dev", "ci-*"}}, false},
+		{&Filter{Deployments: []string{"_/app"}}, true},
+		{&Filter{Deployments: []string{"_/app:_/*"}}, true},
+		{&Filter{Deployments: []string{"_/other"}}, false},
+		{&Filter{Statuses: []string{"ok"}}, false},
+		{&Filter{Statuses: []string{"failure"}, Environments: []string{"p*"}}, true},
+	}
+	for _, test := range cases {
+		c.Assert(test.filter.Matches(change), Equals, test.matches, Commentf("%v", test.filter))
+	}
+	c.Assert((&Filter{}).Matches(newStatusChange(state.RunningPreStep)), Equals, false)
+	c.Assert((&Filter{Statuses: []string{"running_pre_step"}}).Matches(newStatusChange(state.RunningPreStep)), Equals, true)
+}
+
+func (s *suite) Test_NewStatusListener(c *C) {
+	failing := &recordingSink{err: errors.New("unreachable")}
+	prodOnly := &recordingSink{}
+	devOnly := &recordingSink{}
+	errs := []error{}
+	listener := NewStatusListener([]*Notifier{
+		NewNotifier(failing, nil),
+		NewNotifier(prodOnly, &Filter{Environments: []string{"prod"}}),
+		NewNotifier(devOnly, &Filter{Environments: []string{"dev"}}),
+	}, func(n *Notifier, err error) {
+		errs = append(errs, err)
+	})
+	listener(newStatusChange(state.Failure))
+	c.Assert(failing.changes, HasLen, 1)
+	c.Assert(prodOnly.changes, HasLen, 1)
+	c.Assert(devOnly.changes, HasLen, 0)
+	c.Assert(errs, DeepEquals, []error{failing.err})
+}
+
+func (s *suite) Test_Summary(c *C) {
+	c.Assert(Summary(newStatusChange(state.Failure)), Equals, "Stage 'deploy' of deployment '_/app:_/database' in environment 'prod' is now 'failure' (_/database-v1.0.0)")
+}
+
+func (s *suite) Test_WebhookSink(c *C) {
+	var payload map[string]interface{}
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("X-Token")
+		c.Assert(r.Method, Equals, "POST")
+		c.Assert(json.NewDecoder(r.Body).Decode(&payload), IsNil)
+	}))
+	defer server.Close()
+	sink := NewWebhookSink(server.URL, map[string]string{"X-Token": "secret"})
+	c.Assert(sink.Send(newStatusChange(state.Failure)), IsNil)
+	c.Assert(header, Equals, "secret")
+	c.Assert(payload["environment"], Equals, "prod")
+	c.Assert(payload["deployment"], Equals, "_/app")
+	c.Assert(payload["previous_status"], Equals, "running_main_step")
+	c.Assert(payload["status"].(map[string]interface{})["status"], Equals, "failure")
+	c.Assert(payload["summary"], Equals, Summary(newStatusChange(state.Failure)))
+}
+
+func (s *suite) Test_WebhookSink_fails_on_error_status(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+	err := NewWebhookSink(server.URL, nil).Send(newStatusChange(state.Failure))
+	c.Assert(err, ErrorMatches, "Webhook '.*' returned status code 500")
+}
+
+func (s *suite) Test_CommandSink(c *C) {
+	output := filepath.Join(c.MkDir(), "output")
+	sink := NewCommandSink([]string{"sh", "-c", "echo $ESCAPE_NOTIFY_ENVIRONMENT $ESCAPE_NOTIFY_STATUS > " + output + " && cat >> " + output})
+	c.Assert(sink.Send(newStatusChange(state.Failure)), IsNil)
+	content, err := ioutil.ReadFile(output)
+	c.Assert(err, IsNil)
+	lines := strings.SplitN(string(content), "\n", 2)
+	c.Assert(lines[0], Equals, "prod failure")
+	c.Assert(strings.HasPrefix(lines[1], `{"project":"_","environment":"prod"`), Equals, true)
+}
+
+func (s *suite) Test_CommandSink_fails_if_command_fails(c *C) {
+	sink := NewCommandSink([]string{"sh", "-c", "echo oops; exit 1"})
+	c.Assert(sink.Send(newStatusChange(state.Failure)), ErrorMatches, "Notification command 'sh -c echo oops; exit 1' failed: exit status 1 oops")
+}
+
+func (s *suite) Test_CommandSink_times_out(c *C) {
+	defer func(timeout time.Duration) { SendTimeout = timeout }(SendTimeout)
+	SendTimeout = 50 * time.Millisecond
+	sink := NewCommandSink([]string{"sleep", "5"})
+	c.Assert(sink.Send(newStatusChange(state.Failure)), ErrorMatches, "Notification command 'sleep 5' timed out after 50ms")
+}
+
+// A minimal SMTP server that accepts a single message.
+func startSMTPServer(c *C) (string, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	messages := make(chan string, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		data := []string{}
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if inData {
+				if line == "." {
+					inData = false
+					messages <- strings.Join(data, "\n")
+					reply("250 OK")
+				} else {
+					data = append(data, line)
+				}
+				continue
+			}
+			switch {
+			case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+				reply("250 localhost")
+			case line == "DATA":
+				inData = true
+				reply("354 Go ahead")
+			case line == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().String(), messages
+}
+
+func (s *suite) Test_SMTPSink(c *C) {
+	addr, messages := startSMTPServer(c)
+	sink := NewSMTPSink(addr, "", "", "escape@localhost", []string{"ops@localhost"})
+	c.Assert(sink.Send(newStatusChange(state.Failure)), IsNil)
+	message := <-messages
+	c.Assert(strings.Contains(message, "Subject: [escape] failure: _/app deploy in prod"), Equals, true)
+	c.Assert(strings.Contains(message, "To: ops@localhost"), Equals, true)
+	c.Assert(strings.Contains(message, Summary(newStatusChange(state.Failure))), Equals, true)
+	c.Assert(strings.Contains(message, "it broke"), Equals, true)
+}
+
+func (s *suite) Test_SMTPSink_times_out(c *C) {
+	defer func(timeout time.Duration) { SendTimeout = timeout }(SendTimeout)
+	SendTimeout = 50 * time.Millisecond
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer listener.Close()
+	// Accept connections, but never greet.
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+	sink := NewSMTPSink(listener.Addr().String(), "", "", "escape@localhost", []string{"ops@localhost"})
+	c.Assert(sink.Send(newStatusChange(state.Failure)), ErrorMatches, ".*i/o timeout")
+}
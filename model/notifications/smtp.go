@@ -0,0 +1,121 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package notifications
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/ankyra/escape-core/state"
+)
+
+// An SMTPSink emails the status change. Authentication is only used when a
+// username is configured.
+type SMTPSink struct {
+	Server   string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func NewSMTPSink(server, username, password, from string, to []string) *SMTPSink {
+	return &SMTPSink{
+		Server:   server,
+		Username: username,
+		Password: password,
+		From:     from,
+		To:       to,
+	}
+}
+
+// Send does what smtp.SendMail does, but with a deadline for the whole
+// conversation.
+func (s *SMTPSink) Send(change *state.StatusChange) error {
+	host, _, err := net.SplitHostPort(s.Server)
+	if err != nil {
+		return fmt.Errorf("Invalid SMTP server '%s': %s", s.Server, err.Error())
+	}
+	conn, err := net.DialTimeout("tcp", s.Server, SendTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(SendTimeout)); err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.From); err != nil {
+		return err
+	}
+	for _, to := range s.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.message(change)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (s *SMTPSink) message(change *state.StatusChange) []byte {
+	subject := fmt.Sprintf("[escape] %s: %s %s in %s", change.Status.Code, change.Deployment, change.Stage, change.Environment)
+	msg := bytes.NewBuffer([]byte{})
+	fmt.Fprintf(msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(msg, "%s.\r\n", Summary(change))
+	if change.PreviousCode != "" {
+		fmt.Fprintf(msg, "\r\nPrevious status: %s\r\n", change.PreviousCode)
+	}
+	if change.Status.Data != "" {
+		fmt.Fprintf(msg, "\r\n%s\r\n", strings.Replace(change.Status.Data, "\n", "\r\n", -1))
+	}
+	return msg.Bytes()
+}
+
+func (s *SMTPSink) String() string {
+	return "smtp " + s.Server
+}
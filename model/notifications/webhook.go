@@ -0,0 +1,73 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ankyra/escape-core/state"
+)
+
+// A WebhookSink POSTs the status change as JSON to a URL.
+type WebhookSink struct {
+	URL     string
+	Headers map[string]string
+	client  *http.Client
+}
+
+func NewWebhookSink(url string, headers map[string]string) *WebhookSink {
+	return &WebhookSink{
+		URL:     url,
+		Headers: headers,
+		client:  &http.Client{Timeout: SendTimeout},
+	}
+}
+
+type webhookPayload struct {
+	*state.StatusChange
+	Summary string `json:"summary"`
+}
+
+func (w *WebhookSink) Send(change *state.StatusChange) error {
+	body, err := json.Marshal(&webhookPayload{change, Summary(change)})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook '%s' returned status code %d", w.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *WebhookSink) String() string {
+	return "webhook " + w.URL
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package references
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// A reference is a value that points to where the actual value can be
+// found. References are kept as-is in the deployment state and resolved
+// when the value is needed, so that secrets don't end up in the state file
+// and can be rotated without touching it. References are opt-in: they are
+// written as a map with a single "$ref" key, e.g. {"$ref": "env:NAME"}, or
+// given on the command line with `--ref NAME=env:NAME`. Plain strings and
+// maps with an ordinary "ref" key are never treated as references, so map
+// variables can't be mistaken for one. The following references are
+// supported:
+//
+//	env:NAME                  the value of the environment variable NAME
+//	file:path                 the contents of the file at path
+//	cmd:command               the output of the shell command; only when
+//	                          allow_command_references is set in the profile
+//	secret:<provider>/<path>  the secret at path, as returned by the
+//	                          `escape-secret-<provider>` program
+//
+// Trailing newlines are stripped from file contents and command output.
+type resolver func(ref string) (string, error)
+
+// The key that marks a map as a reference. The "$" prefix keeps it apart
+// from the keys used in map variables.
+const ReferenceKey = "$ref"
+
+var resolvers = map[string]resolver{
+	"env":    resolveEnv,
+	"file":   resolveFile,
+	"cmd":    resolveCmd,
+	"secret": resolveSecret,
+}
+
+// NewReference returns the value for the reference, e.g. "env:NAME".
+func NewReference(ref string) map[string]interface{} {
+	return map[string]interface{}{ReferenceKey: ref}
+}
+
+// ParseReference parses a reference given as "scheme:ref", e.g. on the
+// command line.
+func ParseReference(ref string) (map[string]interface{}, error) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Invalid reference '%s': expecting '<scheme>:<reference>'", ref)
+	}
+	if _, found := resolvers[parts[0]]; !found {
+		return nil, fmt.Errorf("Invalid reference '%s': unknown scheme '%s'", ref, parts[0])
+	}
+	return NewReference(ref), nil
+}
+
+// IsReference returns true if the value is a map with a single "$ref" key
+// holding a string.
+func IsReference(value interface{}) bool {
+	_, ok := getReference(value)
+	return ok
+}
+
+// A Resolver resolves references. Every reference is resolved once; later
+// lookups return the same value, so that secret providers and files are
+// consulted once per run.
+type Resolver struct {
+	// Command references run arbitrary shell commands, so they need to be
+	// enabled explicitly.
+	AllowCommands bool
+
+	values map[string]string
+}
+
+func NewResolver() *Resolver {
+	return &Resolver{
+		values: map[string]string{},
+	}
+}
+
+// Resolve returns the value the reference points to. Values that aren't
+// references are returned unchanged.
+func (r *Resolver) Resolve(value interface{}) (interface{}, error) {
+	ref, ok := getReference(value)
+	if !ok {
+		return value, nil
+	}
+	if result, found := r.values[ref]; found {
+		return result, nil
+	}
+	result, err := r.resolve(ref)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't resolve reference '%s': %s", ref, err.Error())
+	}
+	r.values[ref] = result
+	return result, nil
+}
+
+func (r *Resolver) resolve(ref string) (string, error) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("expecting '<scheme>:<reference>'")
+	}
+	resolve, found := resolvers[parts[0]]
+	if !found {
+		return "", fmt.Errorf("unknown scheme '%s'", parts[0])
+	}
+	if parts[0] == "cmd" && !r.AllowCommands {
+		return "", fmt.Errorf("command references are disabled. Set allow_command_references in the profile to enable them")
+	}
+	return resolve(parts[1])
+}
+
+// ResolveAll returns a copy of values in which all the references have
+// been resolved.
+func (r *Resolver) ResolveAll(values map[string]interface{}) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for key, val := range values {
+		resolved, err := r.Resolve(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s in variable '%s'", err.Error(), key)
+		}
+		result[key] = resolved
+	}
+	return result, nil
+}
+
+// KeepReferences returns a copy of values in which every value that was
+// given as a reference in raw is replaced by that reference again. This
+// is used to make sure that resolved values are not written to the state.
+func KeepReferences(values, raw map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for key, val := range values {
+		if rawVal, found := raw[key]; found && IsReference(rawVal) {
+			val = rawVal
+		}
+		result[key] = val
+	}
+	return result
+}
+
+func getReference(value interface{}) (string, bool) {
+	var ref interface{}
+	switch value.(type) {
+	case map[string]interface{}:
+		m := value.(map[string]interface{})
+		if len(m) != 1 {
+			return "", false
+		}
+		ref = m[ReferenceKey]
+	case map[interface{}]interface{}:
+		m := value.(map[interface{}]interface{})
+		if len(m) != 1 {
+			return "", false
+		}
+		ref = m[ReferenceKey]
+	default:
+		return "", false
+	}
+	str, ok := ref.(string)
+	return str, ok
+}
+
+func resolveEnv(name string) (string, error) {
+	value, found := os.LookupEnv(name)
+	if !found {
+		return "", fmt.Errorf("environment variable '%s' is not set", name)
+	}
+	return value, nil
+}
+
+func resolveFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func resolveCmd(command string) (string, error) {
+	return runCommand(exec.Command("sh", "-c", command), nil)
+}
+
+func resolveSecret(ref string) (string, error) {
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("expecting 'secret:<provider>/<path>'")
+	}
+	return NewSecretProvider(parts[0]).Get(parts[1])
+}
+
+func runCommand(cmd *exec.Cmd, input []byte) (string, error) {
+	cmd.Stdin = bytes.NewReader(input)
+	stdout := bytes.NewBuffer([]byte{})
+	stderr := bytes.NewBuffer([]byte{})
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(stderr.String())
+		if output == "" {
+			output = err.Error()
+		}
+		return "", fmt.Errorf("'%s' failed: %s", strings.Join(cmd.Args, " "), output)
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
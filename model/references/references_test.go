@@ -0,0 +1,203 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package references
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type suite struct{}
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&suite{})
+
+const testDir = "testdata_references"
+
+func (s *suite) SetUpTest(c *C) {
+	os.RemoveAll(testDir)
+	c.Assert(os.MkdirAll(testDir, 0755), IsNil)
+}
+
+func (s *suite) TearDownTest(c *C) {
+	os.RemoveAll(testDir)
+}
+
+// installSecretProvider puts an `escape-secret-test` script on the PATH that
+// knows a single secret.
+func installSecretProvider(c *C) func() {
+	dir, err := filepath.Abs(testDir)
+	c.Assert(err, IsNil)
+	script := `#!/bin/sh
+read path
+if [ "$1" = "get" ] && [ "$path" = "db/password" ]; then echo "s3cr3t"; exit 0; fi
+echo "secret '$path' not found" >&2
+exit 1
+`
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "escape-secret-test"), []byte(script), 0755), IsNil)
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+	return func() {
+		os.Setenv("PATH", oldPath)
+	}
+}
+
+func (s *suite) Test_IsReference(c *C) {
+	c.Assert(IsReference(NewReference("env:HOME")), Equals, true)
+	c.Assert(IsReference(map[interface{}]interface{}{"$ref": "file:/etc/hosts"}), Equals, true)
+	c.Assert(IsReference("env:HOME"), Equals, false)
+	c.Assert(IsReference("secret:vault/db/password"), Equals, false)
+	c.Assert(IsReference(map[string]interface{}{"$ref": "env:HOME", "other": "value"}), Equals, false)
+	c.Assert(IsReference(map[string]interface{}{"$ref": 12}), Equals, false)
+	c.Assert(IsReference(map[string]interface{}{"ref": "env:HOME"}), Equals, false)
+	c.Assert(IsReference(12), Equals, false)
+	c.Assert(IsReference(nil), Equals, false)
+}
+
+func (s *suite) Test_ParseReference(c *C) {
+	ref, err := ParseReference("secret:vault/db/password")
+	c.Assert(err, IsNil)
+	c.Assert(ref, DeepEquals, map[string]interface{}{"$ref": "secret:vault/db/password"})
+	_, err = ParseReference("nope")
+	c.Assert(err, ErrorMatches, "Invalid reference 'nope': expecting '<scheme>:<reference>'")
+	_, err = ParseReference("http://example.com")
+	c.Assert(err, ErrorMatches, "Invalid reference 'http://example.com': unknown scheme 'http'")
+}
+
+func (s *suite) Test_Resolve(c *C) {
+	defer installSecretProvider(c)()
+	os.Setenv("ESCAPE_TEST_REFERENCE", "env value")
+	defer os.Unsetenv("ESCAPE_TEST_REFERENCE")
+	path := filepath.Join(testDir, "value.txt")
+	c.Assert(ioutil.WriteFile(path, []byte("file value\n"), 0644), IsNil)
+	cases := map[string]interface{}{
+		"env:ESCAPE_TEST_REFERENCE": "env value",
+		"file:" + path:              "file value",
+		"cmd:echo cmd value":        "cmd value",
+		"secret:test/db/password":   "s3cr3t",
+	}
+	unit := NewResolver()
+	unit.AllowCommands = true
+	for ref, expected := range cases {
+		result, err := unit.Resolve(NewReference(ref))
+		c.Assert(err, IsNil)
+		c.Assert(result, Equals, expected)
+	}
+	for _, value := range []interface{}{"env:ESCAPE_TEST_REFERENCE", "not a reference", 12} {
+		result, err := unit.Resolve(value)
+		c.Assert(err, IsNil)
+		c.Assert(result, Equals, value)
+	}
+}
+
+func (s *suite) Test_Resolve_resolves_references_once(c *C) {
+	os.Setenv("ESCAPE_TEST_REFERENCE", "env value")
+	defer os.Unsetenv("ESCAPE_TEST_REFERENCE")
+	unit := NewResolver()
+	result, err := unit.Resolve(NewReference("env:ESCAPE_TEST_REFERENCE"))
+	c.Assert(err, IsNil)
+	c.Assert(result, Equals, "env value")
+
+	os.Setenv("ESCAPE_TEST_REFERENCE", "changed")
+	result, err = unit.Resolve(NewReference("env:ESCAPE_TEST_REFERENCE"))
+	c.Assert(err, IsNil)
+	c.Assert(result, Equals, "env value")
+
+	result, err = NewResolver().Resolve(NewReference("env:ESCAPE_TEST_REFERENCE"))
+	c.Assert(err, IsNil)
+	c.Assert(result, Equals, "changed")
+}
+
+func (s *suite) Test_Resolve_fails_on_command_references_by_default(c *C) {
+	_, err := NewResolver().Resolve(NewReference("cmd:echo hi"))
+	c.Assert(err, ErrorMatches, "Couldn't resolve reference 'cmd:echo hi': command references are disabled. Set allow_command_references in the profile to enable them")
+}
+
+func (s *suite) Test_Resolve_fails(c *C) {
+	defer installSecretProvider(c)()
+	os.Unsetenv("ESCAPE_TEST_REFERENCE")
+	cases := map[string]string{
+		"env:ESCAPE_TEST_REFERENCE":  "Couldn't resolve reference 'env:ESCAPE_TEST_REFERENCE': environment variable 'ESCAPE_TEST_REFERENCE' is not set",
+		"file:" + testDir + "/nope":  "Couldn't resolve reference 'file:.*': open .*",
+		"cmd:echo oops >&2; exit 1":  "Couldn't resolve reference 'cmd:.*': 'sh -c echo oops >&2; exit 1' failed: oops",
+		"secret:test/db/user":        "Couldn't resolve reference 'secret:test/db/user': 'escape-secret-test get' failed: secret 'db/user' not found",
+		"secret:test":                "Couldn't resolve reference 'secret:test': expecting 'secret:<provider>/<path>'",
+		"secret:doesnt-exist/secret": "Couldn't resolve reference 'secret:doesnt-exist/secret': 'escape-secret-doesnt-exist get' failed: .*",
+		"http://example.com":         "Couldn't resolve reference 'http://example.com': unknown scheme 'http'",
+		"nope":                       "Couldn't resolve reference 'nope': expecting '<scheme>:<reference>'",
+	}
+	for ref, expected := range cases {
+		unit := NewResolver()
+		unit.AllowCommands = true
+		_, err := unit.Resolve(NewReference(ref))
+		c.Assert(err, ErrorMatches, expected)
+	}
+}
+
+func (s *suite) Test_ResolveAll(c *C) {
+	os.Setenv("ESCAPE_TEST_REFERENCE", "env value")
+	defer os.Unsetenv("ESCAPE_TEST_REFERENCE")
+	values := map[string]interface{}{
+		"ref":   NewReference("env:ESCAPE_TEST_REFERENCE"),
+		"value": "env:ESCAPE_TEST_REFERENCE",
+	}
+	result, err := NewResolver().ResolveAll(values)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, map[string]interface{}{
+		"ref":   "env value",
+		"value": "env:ESCAPE_TEST_REFERENCE",
+	})
+	c.Assert(values["ref"], DeepEquals, NewReference("env:ESCAPE_TEST_REFERENCE"))
+
+	os.Unsetenv("ESCAPE_TEST_REFERENCE")
+	_, err = NewResolver().ResolveAll(values)
+	c.Assert(err, ErrorMatches, ".*is not set in variable 'ref'")
+}
+
+func (s *suite) Test_Resolve_leaves_maps_with_a_ref_key_alone(c *C) {
+	value := map[string]interface{}{"ref": "refs/heads/main"}
+	result, err := NewResolver().Resolve(value)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, value)
+
+	yamlValue := map[interface{}]interface{}{"ref": "refs/heads/main"}
+	result, err = NewResolver().Resolve(yamlValue)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, yamlValue)
+}
+
+func (s *suite) Test_KeepReferences(c *C) {
+	raw := map[string]interface{}{
+		"ref":   NewReference("env:ESCAPE_TEST_REFERENCE"),
+		"value": "env:ESCAPE_TEST_REFERENCE",
+	}
+	values := map[string]interface{}{
+		"ref":        "env value",
+		"value":      "env:ESCAPE_TEST_REFERENCE",
+		"calculated": "calculated",
+	}
+	c.Assert(KeepReferences(values, raw), DeepEquals, map[string]interface{}{
+		"ref":        NewReference("env:ESCAPE_TEST_REFERENCE"),
+		"value":      "env:ESCAPE_TEST_REFERENCE",
+		"calculated": "calculated",
+	})
+}
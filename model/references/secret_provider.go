@@ -0,0 +1,42 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package references
+
+import (
+	"os/exec"
+)
+
+// A SecretProvider runs `escape-secret-<name> get` with the path of the
+// secret on stdin. The program should write the secret to stdout, or exit
+// non-zero and write the reason to stderr if it can't be retrieved.
+type SecretProvider struct {
+	Name string
+}
+
+func NewSecretProvider(name string) *SecretProvider {
+	return &SecretProvider{
+		Name: name,
+	}
+}
+
+func (s *SecretProvider) Program() string {
+	return "escape-secret-" + s.Name
+}
+
+func (s *SecretProvider) Get(path string) (string, error) {
+	return runCommand(exec.Command(s.Program(), "get"), []byte(path))
+}
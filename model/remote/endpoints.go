@@ -78,6 +78,15 @@ func (s *ServerEndpoints) UploadRelease(project, name, version string) string {
 func (s *ServerEndpoints) DownloadRelease(project, name, version string) string {
 	return s.ProjectReleaseQuery(project, name, version) + "download"
 }
+func (s *ServerEndpoints) DeleteRelease(project, name, version string) string {
+	return s.ProjectReleaseQuery(project, name, version)
+}
+func (s *ServerEndpoints) DeprecateRelease(project, name, version string) string {
+	return s.ProjectReleaseQuery(project, name, version) + "deprecate"
+}
+func (s *ServerEndpoints) YankRelease(project, name, version string) string {
+	return s.ProjectReleaseQuery(project, name, version) + "yank"
+}
 func (s *ServerEndpoints) AuthMethods(baseUrl string) string {
 	if !strings.HasSuffix(baseUrl, "/") {
 		baseUrl += "/"
@@ -0,0 +1,83 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// A TokenResponse is what the server returns when a redeem token or refresh
+// token is exchanged for an Escape token.
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// The number of seconds the token is valid for. Zero if it doesn't
+	// expire.
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// ParseTokenResponse parses the body of a token response. Older servers
+// return the plain token, newer ones a JSON object with expiry information.
+func ParseTokenResponse(body []byte) (*TokenResponse, error) {
+	str := strings.TrimSpace(string(body))
+	if !strings.HasPrefix(str, "{") {
+		if str == "" {
+			return nil, fmt.Errorf("Expecting a token, but the server returned an empty response")
+		}
+		return &TokenResponse{Token: str}, nil
+	}
+	result := &TokenResponse{}
+	if err := json.Unmarshal([]byte(str), result); err != nil {
+		return nil, fmt.Errorf("Couldn't parse token response: %s", err.Error())
+	}
+	if result.Token == "" {
+		return nil, fmt.Errorf("Expecting a 'token' field in the token response")
+	}
+	return result, nil
+}
+
+// ExpiresAt returns the unix timestamp at which the token expires, relative
+// to now, or zero if the token doesn't expire.
+func (t *TokenResponse) ExpiresAt(now time.Time) int64 {
+	if t.ExpiresIn <= 0 {
+		return 0
+	}
+	return now.Unix() + t.ExpiresIn
+}
+
+// RefreshAuthToken exchanges the refresh token for a new token.
+func (c *InventoryClient) RefreshAuthToken(url, refreshToken string) (*TokenResponse, error) {
+	resp, err := c.POST_json(url, map[string]string{
+		"refresh_token": refreshToken,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't refresh authentication token: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't refresh authentication token: %s", err.Error())
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Couldn't refresh authentication token. Server responded with status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return ParseTokenResponse(body)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deploy
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape-core/state"
+	. "gopkg.in/check.v1"
+)
+
+func newRecordingHook(logFile, message string) *core.Hook {
+	return core.NewHook(&core.ExecStage{
+		Inline: "echo \"" + message + "\" >> " + logFile,
+	})
+}
+
+func readHookLog(c *C, logFile string) string {
+	content, err := ioutil.ReadFile(logFile)
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func (s *testSuite) Test_DeployRunner_runs_hooks_around_steps(c *C) {
+	logFile := filepath.Join(c.MkDir(), "hooks.log")
+	runCtx := getRunContext(c, "testdata/deploy_state.json", "testdata/deploy_plan.yml")
+	metadata := runCtx.GetReleaseMetadata()
+	metadata.SetExecStage(Stage, &core.ExecStage{Inline: "echo deploy >> " + logFile})
+	metadata.AddHook("before_pre_deploy", newRecordingHook(logFile, "before_pre_deploy"))
+	metadata.AddHook("before_deploy", newRecordingHook(logFile, "before_deploy 1"))
+	metadata.AddHook("before_deploy", newRecordingHook(logFile, "before_deploy 2"))
+	metadata.AddHook("after_deploy", newRecordingHook(logFile, "after_deploy"))
+	metadata.AddHook("on_failure", newRecordingHook(logFile, "on_failure"))
+	c.Assert(NewDeployRunner().Run(runCtx), IsNil)
+	c.Assert(readHookLog(c, logFile), Equals, "before_pre_deploy\nbefore_deploy 1\nbefore_deploy 2\ndeploy\nafter_deploy\n")
+	checkStatus(c, runCtx, state.OK)
+}
+
+func (s *testSuite) Test_DeployRunner_fatal_hook_fails_step(c *C) {
+	logFile := filepath.Join(c.MkDir(), "hooks.log")
+	runCtx := getRunContext(c, "testdata/deploy_state.json", "testdata/deploy_plan.yml")
+	metadata := runCtx.GetReleaseMetadata()
+	metadata.SetExecStage(Stage, &core.ExecStage{Inline: "echo deploy >> " + logFile})
+	metadata.AddHook("before_deploy", core.NewHook(&core.ExecStage{Inline: "exit 1"}))
+	metadata.AddHook("on_failure", newRecordingHook(logFile, "on_failure $ESCAPE_FAILED_STEP"))
+	err := NewDeployRunner().Run(runCtx)
+	c.Assert(err, Not(IsNil))
+	c.Assert(err.Error(), Matches, "The before_deploy hook '<inline script starting with 'exit 1'>' failed: .*")
+	c.Assert(readHookLog(c, logFile), Equals, "on_failure deploy\n")
+	checkStatus(c, runCtx, state.Failure)
+}
+
+func (s *testSuite) Test_DeployRunner_runs_on_failure_hooks_when_step_fails(c *C) {
+	logFile := filepath.Join(c.MkDir(), "hooks.log")
+	runCtx := getRunContext(c, "testdata/deploy_state.json", "testdata/deploy_plan.yml")
+	metadata := runCtx.GetReleaseMetadata()
+	metadata.SetExecStage(Stage, &core.ExecStage{Inline: "exit 3"})
+	metadata.AddHook("after_deploy", newRecordingHook(logFile, "after_deploy"))
+	metadata.AddHook("on_failure", core.NewHook(&core.ExecStage{Inline: "exit 1"}))
+	metadata.AddHook("on_failure", newRecordingHook(logFile, "on_failure $ESCAPE_FAILED_STEP"))
+	c.Assert(NewDeployRunner().Run(runCtx), Not(IsNil))
+	c.Assert(readHookLog(c, logFile), Equals, "on_failure deploy\n")
+	checkStatus(c, runCtx, state.Failure)
+}
+
+func (s *testSuite) Test_DeployRunner_best_effort_hook_doesnt_fail_step(c *C) {
+	runCtx := getRunContext(c, "testdata/deploy_state.json", "testdata/deploy_plan.yml")
+	hook := core.NewHook(&core.ExecStage{Inline: "exit 1"})
+	hook.Fatal = false
+	runCtx.GetReleaseMetadata().AddHook("after_deploy", hook)
+	c.Assert(NewDeployRunner().Run(runCtx), IsNil)
+	checkStatus(c, runCtx, state.OK)
+}
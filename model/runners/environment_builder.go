@@ -18,12 +18,15 @@ package runners
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"runtime"
 	"strings"
 
 	"github.com/ankyra/escape-core"
 	"github.com/ankyra/escape-core/script"
+	"github.com/ankyra/escape-core/variables"
+	"github.com/ankyra/escape/model/references"
 	"github.com/ankyra/escape/util"
 )
 
@@ -50,9 +53,21 @@ func (e *environmentBuilder) GetEnviron() []string {
 	return result
 }
 
+// GetInputsForPreStep calculates the inputs for the stage. References in
+// the user inputs are resolved; use GetInputsForCommit to get the inputs
+// that should be written to the state.
 func (e *environmentBuilder) GetInputsForPreStep(ctx *RunnerContext, stage string) (map[string]interface{}, error) {
 	calculatedInputs := map[string]interface{}{}
-	inputs := ctx.GetDeploymentState().GetPreStepInputs(stage)
+	migrated, migrations := variables.MigrateValues(ctx.GetReleaseMetadata().GetInputs(stage), ctx.GetDeploymentState().GetPreStepInputs(stage))
+	for _, m := range migrations {
+		ctx.Logger().Log("variable.migrated", map[string]string{
+			"migration": m.String(),
+		})
+	}
+	inputs, err := ctx.ResolveReferences(migrated)
+	if err != nil {
+		return nil, err
+	}
 	scriptEnv, err := ctx.GetScriptEnvironment(stage)
 	if err != nil {
 		return nil, err
@@ -67,10 +82,29 @@ func (e *environmentBuilder) GetInputsForPreStep(ctx *RunnerContext, stage strin
 	return prepInputs(ctx, stage, &calculatedInputs, false)
 }
 
+// GetInputsForCommit returns the inputs that should be stored in the state
+// for the stage. Inputs that were given as references are stored as
+// references, so that the resolved values never end up in the state.
+func (e *environmentBuilder) GetInputsForCommit(ctx *RunnerContext, stage string, inputs map[string]interface{}) map[string]interface{} {
+	raw, _ := variables.MigrateValues(ctx.GetReleaseMetadata().GetInputs(stage), ctx.GetDeploymentState().GetPreStepInputs(stage))
+	return references.KeepReferences(inputs, raw)
+}
+
+// GetCalculatedInputs returns the inputs that were calculated in the
+// pre-step of the stage, with their references resolved.
+func (e *environmentBuilder) GetCalculatedInputs(ctx *RunnerContext, stage string) (map[string]interface{}, error) {
+	return ctx.ResolveReferences(ctx.GetDeploymentState().GetCalculatedInputs(stage))
+}
+
+// GetPreDependencyInputs returns the user inputs for the stage and the
+// values of the variables that are evaluated before the dependencies.
+// References are passed on as references, so they can be resolved by the
+// dependencies themselves.
 func (e *environmentBuilder) GetPreDependencyInputs(ctx *RunnerContext, stage string) (map[string]interface{}, error) {
-	inputs := map[string]interface{}{}
-	for key, val := range ctx.GetDeploymentState().GetUserInputs(stage) {
-		inputs[key] = val
+	inputs, _ := variables.MigrateValues(ctx.GetReleaseMetadata().GetInputs(stage), ctx.GetDeploymentState().GetUserInputs(stage))
+	resolved, err := ctx.ResolveReferences(inputs)
+	if err != nil {
+		return nil, err
 	}
 	scriptEnv, err := ctx.GetScriptEnvironment(stage)
 	if err != nil {
@@ -78,11 +112,14 @@ func (e *environmentBuilder) GetPreDependencyInputs(ctx *RunnerContext, stage st
 	}
 	for _, inputVar := range ctx.GetReleaseMetadata().GetInputs(stage) {
 		if inputVar.EvalBeforeDependencies {
-			val, err := inputVar.GetValue(&inputs, scriptEnv)
+			val, err := inputVar.GetValue(&resolved, scriptEnv)
 			if err != nil {
 				return nil, err
 			}
-			inputs[inputVar.Id] = val
+			resolved[inputVar.Id] = val
+			if !references.IsReference(inputs[inputVar.Id]) {
+				inputs[inputVar.Id] = val
+			}
 		}
 	}
 	return inputs, nil
@@ -114,15 +151,19 @@ func (e *environmentBuilder) GetInputsForDependency(ctx *RunnerContext, parentSt
 
 func (e *environmentBuilder) GetInputsForErrand(ctx *RunnerContext, errand *core.Errand, extraVars map[string]interface{}) (map[string]interface{}, error) {
 	deplState := ctx.GetDeploymentState()
-	inputs := deplState.GetCalculatedInputs("deploy")
+	inputs, _ := variables.MigrateValues(ctx.GetReleaseMetadata().GetInputs("deploy"), deplState.GetCalculatedInputs("deploy"))
 	for key, val := range extraVars {
 		inputs[key] = val
 	}
+	inputs, err := ctx.ResolveReferences(inputs)
+	if err != nil {
+		return nil, err
+	}
 	result, err := prepInputs(ctx, "deploy", &inputs, true)
 	if err != nil {
 		return nil, err
 	}
-	scriptEnv, err := ctx.GetScriptEnvironment("deploy")
+	scriptEnv, err := e.getErrandScriptEnvironment(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -136,15 +177,71 @@ func (e *environmentBuilder) GetInputsForErrand(ctx *RunnerContext, errand *core
 	return result, nil
 }
 
+// GetOutputsForErrand returns the values of the errand's output variables,
+// given the values the errand script wrote to the outputs file.
+func (e *environmentBuilder) GetOutputsForErrand(ctx *RunnerContext, errand *core.Errand, errandOutputs map[string]interface{}) (map[string]interface{}, error) {
+	if errandOutputs == nil {
+		errandOutputs = map[string]interface{}{}
+	}
+	scriptEnv, err := e.getErrandScriptEnvironment(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{}
+	for _, outputVar := range errand.GetOutputs() {
+		val, err := outputVar.GetValue(&errandOutputs, scriptEnv)
+		if err != nil {
+			return nil, fmt.Errorf("%s in errand '%s'", err.Error(), errand.Name)
+		}
+		result[outputVar.Id] = val
+	}
+	for key, _ := range errandOutputs {
+		if _, expected := result[key]; !expected {
+			fmt.Printf("Warning: received unexpected output variable '%s' from errand '%s'\n", key, errand.Name)
+		}
+	}
+	return result, nil
+}
+
+// getErrandScriptEnvironment returns the deploy stage script environment,
+// extended with the outputs of the errands that already ran as
+// `$errands.<name>.outputs`.
+func (e *environmentBuilder) getErrandScriptEnvironment(ctx *RunnerContext) (*script.ScriptEnvironment, error) {
+	scriptEnv, err := ctx.GetScriptEnvironment("deploy")
+	if err != nil {
+		return nil, err
+	}
+	errands := map[string]script.Script{}
+	for name, outputs := range ctx.GetErrandOutputs() {
+		lifted, err := script.Lift(outputs)
+		if err != nil {
+			return nil, err
+		}
+		errands[name] = script.LiftDict(map[string]script.Script{"outputs": lifted})
+	}
+	globals := map[string]script.Script{}
+	for key, val := range script.ExpectDictAtom((*scriptEnv)["$"]) {
+		globals[key] = val
+	}
+	globals["errands"] = script.LiftDict(errands)
+	(*scriptEnv)["$"] = script.LiftDict(globals)
+	return scriptEnv, nil
+}
+
 func (e *environmentBuilder) GetOutputs(ctx *RunnerContext, stage string) (map[string]interface{}, error) {
 	metadata := ctx.GetReleaseMetadata()
-	buildOutputs := ctx.GetBuildOutputs()
+	outputVariables := metadata.GetOutputs(stage)
+	buildOutputs, migrations := variables.MigrateValues(outputVariables, ctx.GetBuildOutputs())
+	for _, m := range migrations {
+		ctx.Logger().Log("variable.migrated_output", map[string]string{
+			"migration": m.String(),
+		})
+	}
 	result := map[string]interface{}{}
 	scriptEnv, err := ctx.GetScriptEnvironment(stage)
 	if err != nil {
 		return nil, err
 	}
-	outputVariables := metadata.GetOutputs(stage)
 	for _, outputVar := range outputVariables {
 		val, err := outputVar.GetValue(&buildOutputs, scriptEnv)
 		if err != nil {
@@ -179,7 +276,7 @@ func (e *environmentBuilder) GetEscapeEnvironmentVariables() map[string]interfac
 
 func (e *environmentBuilder) MergeInputsWithOsEnvironment(ctx *RunnerContext) []string {
 	result := e.GetEnviron()
-	inputs := ctx.GetBuildInputs()
+	inputs := ctx.GetExportedBuildInputs()
 	escapeEnv := e.GetEscapeEnvironmentVariables()
 	result = addToEnvironmentWithKeyPrefix(result, inputs, "INPUT_")
 	result = addToEnvironmentWithKeyPrefix(result, escapeEnv, "")
@@ -188,7 +285,7 @@ func (e *environmentBuilder) MergeInputsWithOsEnvironment(ctx *RunnerContext) []
 
 func (e *environmentBuilder) MergeInputsAndOutputsWithOsEnvironment(ctx *RunnerContext) []string {
 	result := e.GetEnviron()
-	inputs := ctx.GetBuildInputs()
+	inputs := ctx.GetExportedBuildInputs()
 	outputs := ctx.GetBuildOutputs()
 	escapeEnv := e.GetEscapeEnvironmentVariables()
 	result = addToEnvironmentWithKeyPrefix(result, inputs, "INPUT_")
@@ -197,6 +294,39 @@ func (e *environmentBuilder) MergeInputsAndOutputsWithOsEnvironment(ctx *RunnerC
 	return result
 }
 
+// exportInputs returns a copy of the inputs in which the values of the file
+// variables, which are paths, have been replaced by the contents of the files.
+func exportInputs(vars []*variables.Variable, inputs map[string]interface{}) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for key, val := range inputs {
+		result[key] = val
+	}
+	for _, v := range vars {
+		val, ok := result[v.Id]
+		if !ok || v.Type != "file" {
+			continue
+		}
+		contents, err := readFileInput(v.Id, val)
+		if err != nil {
+			return nil, err
+		}
+		result[v.Id] = contents
+	}
+	return result, nil
+}
+
+func readFileInput(id string, val interface{}) (string, error) {
+	path, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("Expecting 'file' value (a path) for variable '%s', but got '%T'", id, val)
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't read file '%s' for variable '%s': %s", path, id, err.Error())
+	}
+	return string(contents), nil
+}
+
 func addValues(result, values *map[string]interface{}, prefix string) {
 	if values == nil {
 		return
@@ -215,7 +345,7 @@ func prepInputs(ctx *RunnerContext, stage string, inputs *map[string]interface{}
 	for key, val := range metadata.Metadata {
 		result["METADATA_"+key] = val
 	}
-	calcInputs := deplState.GetCalculatedInputs(stage)
+	calcInputs, _ := variables.MigrateValues(metadata.GetInputs(stage), deplState.GetCalculatedInputs(stage))
 	calcOutputs := deplState.GetCalculatedOutputs(stage)
 	if isErrand {
 		addValues(&result, &calcInputs, "")
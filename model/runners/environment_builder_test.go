@@ -17,7 +17,11 @@ limitations under the License.
 package runners
 
 import (
+	"os"
+
+	"github.com/ankyra/escape-core/script"
 	"github.com/ankyra/escape/model"
+	"github.com/ankyra/escape/model/references"
 	. "gopkg.in/check.v1"
 )
 
@@ -53,6 +57,128 @@ func (s *testSuite) Test_GetInputsForPreStep_calculated_inputs(c *C) {
 	c.Assert(inputs["PREVIOUS_OUTPUT_output_variable"], DeepEquals, "testoutput")
 }
 
+func (s *testSuite) Test_GetInputsForPreStep_migrates_aliases(c *C) {
+	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/env_aliases_plan.yml")
+	inputs, err := NewEmptyEnvEnvironmentBuilder().GetInputsForPreStep(runCtx, "deploy")
+	c.Assert(err, IsNil)
+	c.Assert(inputs, HasLen, 4)
+	c.Assert(inputs["renamed"], DeepEquals, "testinput")
+	c.Assert(inputs["PREVIOUS_renamed"], DeepEquals, "previous testinput")
+	c.Assert(inputs["METADATA_key"], DeepEquals, "value")
+	c.Assert(inputs["PREVIOUS_OUTPUT_output_variable"], DeepEquals, "testoutput")
+}
+
+func (s *testSuite) Test_GetInputsForPreStep_resolves_references(c *C) {
+	os.Setenv("ESCAPE_TEST_REFERENCE", "resolved")
+	defer os.Unsetenv("ESCAPE_TEST_REFERENCE")
+	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/env_test_plan.yml")
+	ref := references.NewReference("env:ESCAPE_TEST_REFERENCE")
+	runCtx.GetDeploymentState().GetUserInputs("deploy")["input_variable"] = ref
+	unit := NewEmptyEnvEnvironmentBuilder()
+	inputs, err := unit.GetInputsForPreStep(runCtx, "deploy")
+	c.Assert(err, IsNil)
+	c.Assert(inputs["input_variable"], DeepEquals, "resolved")
+	commit := unit.GetInputsForCommit(runCtx, "deploy", inputs)
+	c.Assert(commit["input_variable"], DeepEquals, ref)
+	c.Assert(commit["METADATA_key"], DeepEquals, "value")
+
+	preDepInputs, err := unit.GetPreDependencyInputs(runCtx, "deploy")
+	c.Assert(err, IsNil)
+	c.Assert(preDepInputs["input_variable"], DeepEquals, ref)
+
+	os.Unsetenv("ESCAPE_TEST_REFERENCE")
+	inputs, err = unit.GetInputsForPreStep(runCtx, "deploy")
+	c.Assert(err, IsNil)
+	c.Assert(inputs["input_variable"], DeepEquals, "resolved")
+
+	runCtx = getRunContext(c, "testdata/env_state.json", "testdata/env_test_plan.yml")
+	runCtx.GetDeploymentState().GetUserInputs("deploy")["input_variable"] = ref
+	_, err = unit.GetInputsForPreStep(runCtx, "deploy")
+	c.Assert(err, ErrorMatches, "Couldn't resolve reference 'env:ESCAPE_TEST_REFERENCE': .* in variable 'input_variable'")
+}
+
+func (s *testSuite) Test_GetInputsForPreStep_doesnt_resolve_maps_with_a_ref_key(c *C) {
+	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/env_map_plan.yml")
+	value := map[string]interface{}{"ref": "refs/heads/main"}
+	runCtx.GetDeploymentState().GetUserInputs("deploy")["input_variable"] = value
+	unit := NewEmptyEnvEnvironmentBuilder()
+	inputs, err := unit.GetInputsForPreStep(runCtx, "deploy")
+	c.Assert(err, IsNil)
+	c.Assert(inputs["input_variable"], DeepEquals, value)
+}
+
+func (s *testSuite) Test_GetInputsForPreStep_doesnt_resolve_strings(c *C) {
+	os.Setenv("ESCAPE_TEST_REFERENCE", "resolved")
+	defer os.Unsetenv("ESCAPE_TEST_REFERENCE")
+	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/env_test_plan.yml")
+	runCtx.GetDeploymentState().GetUserInputs("deploy")["input_variable"] = "env:ESCAPE_TEST_REFERENCE"
+	inputs, err := NewEmptyEnvEnvironmentBuilder().GetInputsForPreStep(runCtx, "deploy")
+	c.Assert(err, IsNil)
+	c.Assert(inputs["input_variable"], DeepEquals, "env:ESCAPE_TEST_REFERENCE")
+}
+
+func (s *testSuite) Test_GetInputsForPreStep_fails_on_command_references(c *C) {
+	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/env_test_plan.yml")
+	runCtx.GetDeploymentState().GetUserInputs("deploy")["input_variable"] = references.NewReference("cmd:echo hi")
+	_, err := NewEmptyEnvEnvironmentBuilder().GetInputsForPreStep(runCtx, "deploy")
+	c.Assert(err, ErrorMatches, "Couldn't resolve reference 'cmd:echo hi': command references are disabled.*")
+
+	runCtx = getRunContext(c, "testdata/env_state.json", "testdata/env_test_plan.yml")
+	runCtx.GetDeploymentState().GetUserInputs("deploy")["input_variable"] = references.NewReference("cmd:echo hi")
+	runCtx.resolver.AllowCommands = true
+	inputs, err := NewEmptyEnvEnvironmentBuilder().GetInputsForPreStep(runCtx, "deploy")
+	c.Assert(err, IsNil)
+	c.Assert(inputs["input_variable"], DeepEquals, "hi")
+}
+
+func (s *testSuite) Test_GetCalculatedInputs_resolves_references(c *C) {
+	os.Setenv("ESCAPE_TEST_REFERENCE", "resolved")
+	defer os.Unsetenv("ESCAPE_TEST_REFERENCE")
+	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/env_test_plan.yml")
+	runCtx.GetDeploymentState().GetCalculatedInputs("deploy")["input_variable"] = references.NewReference("env:ESCAPE_TEST_REFERENCE")
+	inputs, err := NewEmptyEnvEnvironmentBuilder().GetCalculatedInputs(runCtx, "deploy")
+	c.Assert(err, IsNil)
+	c.Assert(inputs["input_variable"], DeepEquals, "resolved")
+
+	env, err := runCtx.GetScriptEnvironment("deploy")
+	c.Assert(err, IsNil)
+	val, err := script.ParseAndEvalToGoValue("$this.inputs.input_variable", env)
+	c.Assert(err, IsNil)
+	c.Assert(val, DeepEquals, "resolved")
+}
+
+func (s *testSuite) Test_GetPreStepInputs_keeps_file_paths(c *C) {
+	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/env_file_plan.yml")
+	runCtx.GetDeploymentState().GetUserInputs("deploy")["input_variable"] = "testdata/file_input.txt"
+	unit := NewEmptyEnvEnvironmentBuilder()
+	inputs, err := unit.GetInputsForPreStep(runCtx, "deploy")
+	c.Assert(err, IsNil)
+	c.Assert(inputs["input_variable"], DeepEquals, "testdata/file_input.txt")
+	commit := unit.GetInputsForCommit(runCtx, "deploy", inputs)
+	c.Assert(commit["input_variable"], DeepEquals, "testdata/file_input.txt")
+}
+
+func (s *testSuite) Test_GetScriptEnvironment_reads_file_variables(c *C) {
+	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/env_file_plan.yml")
+	runCtx.GetDeploymentState().GetCalculatedInputs("deploy")["input_variable"] = "testdata/file_input.txt"
+	env, err := runCtx.GetScriptEnvironment("deploy")
+	c.Assert(err, IsNil)
+	val, err := script.ParseAndEvalToGoValue("$this.inputs.input_variable", env)
+	c.Assert(err, IsNil)
+	c.Assert(val, DeepEquals, "file contents\n")
+
+}
+
+func (s *testSuite) Test_GetPreStepInputs_doesnt_read_previous_files_that_are_gone(c *C) {
+	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/env_file_plan.yml")
+	runCtx.GetDeploymentState().GetCalculatedInputs("deploy")["input_variable"] = "testdata/doesnt_exist.txt"
+	runCtx.GetDeploymentState().GetUserInputs("deploy")["input_variable"] = "testdata/file_input.txt"
+	inputs, err := NewEmptyEnvEnvironmentBuilder().GetInputsForPreStep(runCtx, "deploy")
+	c.Assert(err, IsNil)
+	c.Assert(inputs["input_variable"], DeepEquals, "testdata/file_input.txt")
+	c.Assert(inputs["PREVIOUS_input_variable"], DeepEquals, "testdata/doesnt_exist.txt")
+}
+
 func (s *testSuite) Test_GetInputsForErrand(c *C) {
 	runCtx := getRunContext(c, "testdata/errand.json", "testdata/errand.yml")
 	errand := runCtx.GetReleaseMetadata().Errands["my-errand"]
@@ -107,6 +233,26 @@ func (s *testSuite) Test_MergeInputsWithOsEnvironment(c *C) {
 	c.Assert(env, HasItem, "INPUT_input_variable=yo")
 }
 
+func (s *testSuite) Test_MergeInputsWithOsEnvironment_exports_file_contents(c *C) {
+	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/env_file_plan.yml")
+	inputs := map[string]interface{}{"input_variable": "testdata/file_input.txt"}
+	runCtx.SetBuildInputs(inputs)
+	exported, err := exportInputs(runCtx.GetReleaseMetadata().GetInputs("deploy"), inputs)
+	c.Assert(err, IsNil)
+	runCtx.SetExportedBuildInputs(exported)
+	c.Assert(inputs["input_variable"], DeepEquals, "testdata/file_input.txt")
+
+	env := NewEnvironmentBuilderWithEnv([]string{}).MergeInputsWithOsEnvironment(runCtx)
+	c.Assert(env, HasItem, "INPUT_input_variable=file contents\n")
+}
+
+func (s *testSuite) Test_exportInputs_fails_if_file_cant_be_read(c *C) {
+	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/env_file_plan.yml")
+	inputs := map[string]interface{}{"input_variable": "testdata/doesnt_exist.txt"}
+	_, err := exportInputs(runCtx.GetReleaseMetadata().GetInputs("deploy"), inputs)
+	c.Assert(err, ErrorMatches, "Couldn't read file 'testdata/doesnt_exist.txt' for variable 'input_variable': .*")
+}
+
 func (s *testSuite) Test_MergeInputsAndOutputsWithOsEnvironment(c *C) {
 	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/env_test_plan.yml")
 	inputs := map[string]interface{}{"input_variable": "yo"}
@@ -177,9 +323,21 @@ func (s *testSuite) Test_AddToEnvironmentWithKeyPrefix_empty_env(c *C) {
 	c.Assert(listFound, Equals, true)
 }
 
+func (s *testSuite) Test_AddToEnvironmentWithKeyPrefix_maps_and_floats(c *C) {
+	values := map[string]interface{}{
+		"map_test":   map[string]interface{}{"key": "value"},
+		"float_test": 0.75,
+		"whole_test": 2.0,
+	}
+	newEnv := addToEnvironmentWithKeyPrefix(nil, values, "PREFIX_")
+	c.Assert(newEnv, HasItem, `PREFIX_map_test={"key":"value"}`)
+	c.Assert(newEnv, HasItem, "PREFIX_float_test=0.75")
+	c.Assert(newEnv, HasItem, "PREFIX_whole_test=2")
+}
+
 func (s *testSuite) Test_AddToEnvironmentWithKeyPrefix_unsupported_type(c *C) {
 	values := map[string]interface{}{
-		"test": map[string]interface{}{},
+		"test": struct{}{},
 	}
 	c.Assert(func() { addToEnvironmentWithKeyPrefix(nil, values, "PREFIX_") }, PanicMatches,
 		`Type '.*' not supported \(key: 'test'\). This is a bug in Escape.`)
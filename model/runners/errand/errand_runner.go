@@ -17,31 +17,142 @@ limitations under the License.
 package errand
 
 import (
+	"fmt"
+	"os"
+	"os/user"
+
 	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape-core/state"
+	"github.com/ankyra/escape-core/variables"
+	"github.com/ankyra/escape/model/references"
 	. "github.com/ankyra/escape/model/runners"
+	"github.com/ankyra/escape/util"
 )
 
 var Stage = "deploy"
 
 func NewErrandRunner(errand *core.Errand, extraVars map[string]interface{}) Runner {
+	return newErrandRunner(errand, extraVars, false)
+}
+
+// NewScheduledErrandRunner returns a Runner that records the errand run as
+// having been started by the scheduler.
+func NewScheduledErrandRunner(errand *core.Errand, extraVars map[string]interface{}) Runner {
+	return newErrandRunner(errand, extraVars, true)
+}
+
+func newErrandRunner(errand *core.Errand, extraVars map[string]interface{}, scheduled bool) Runner {
 	return NewCompoundRunner(
 		NewProviderActivationRunner(Stage),
-		NewErrandScriptRunner(errand, extraVars),
+		NewErrandScriptRunner(errand, extraVars, scheduled),
 		NewProviderDeactivationRunner(Stage),
 	)
 }
 
-func NewErrandScriptRunner(errand *core.Errand, extraVars map[string]interface{}) Runner {
+// NewErrandScriptRunner runs the errand's script and records the run in the
+// errand history of the deployment state.
+func NewErrandScriptRunner(errand *core.Errand, extraVars map[string]interface{}, scheduled bool) Runner {
 	return NewRunner(func(ctx *RunnerContext) error {
+		var run *state.ErrandRun
 		step := NewScriptStep(ctx, Stage, errand.Name, true)
+		step.Variables = append(step.Variables, errand.GetInputs()...)
 		step.Inputs = func(ctx *RunnerContext, stage string) (map[string]interface{}, error) {
 			inputs, err := NewEnvironmentBuilder().GetInputsForErrand(ctx, errand, extraVars)
 			if err != nil {
 				return nil, err
 			}
+			run = state.NewErrandRun(errand.Name, ctx.GetReleaseMetadata().Version, currentUser(),
+				getRecordedInputs(ctx, errand, extraVars, inputs))
+			run.Scheduled = scheduled
+			if err := ctx.GetDeploymentState().StartErrandRun(run); err != nil {
+				return nil, err
+			}
 			return inputs, nil
 		}
 		step.Script = errand.Run
-		return step.Run(ctx)
+		// The errand's outputs are read from the outputs file. They're kept
+		// apart from the deployment's outputs, which aren't changed.
+		os.Remove(ctx.GetPath().OutputsFile())
+		err := step.Run(ctx)
+		var outputs map[string]interface{}
+		if err == nil && len(errand.Outputs) > 0 {
+			outputs, err = ReadOutputsFile(ctx)
+		}
+		if err == nil {
+			outputs, err = NewEnvironmentBuilder().GetOutputsForErrand(ctx, errand, outputs)
+			if err == nil {
+				ctx.SetErrandOutputs(errand.Name, outputs)
+				if run != nil {
+					run.Outputs = MaskSensitiveValues(errand.GetOutputs(), outputs)
+				}
+			}
+		}
+		if run == nil {
+			return err
+		}
+		exitCode := util.CommandExitCode(err)
+		if err == nil {
+			exitCode = new(int)
+		}
+		if saveErr := ctx.GetDeploymentState().FinishErrandRun(run, exitCode, err); saveErr != nil && err == nil {
+			return saveErr
+		}
+		return err
 	})
 }
+
+// getRecordedInputs returns the errand inputs and extra variables that are
+// recorded in the errand history. Sensitive values are masked and values that
+// were given as references are recorded as references.
+func getRecordedInputs(ctx *RunnerContext, errand *core.Errand, extraVars, inputs map[string]interface{}) map[string]interface{} {
+	sensitive := map[string]bool{}
+	for _, input := range ctx.GetReleaseMetadata().GetInputs(Stage) {
+		sensitive[input.Id] = input.Sensitive
+	}
+	result := map[string]interface{}{}
+	for _, input := range errand.GetInputs() {
+		sensitive[input.Id] = input.Sensitive
+		result[input.Id] = inputs[input.Id]
+	}
+	raw := map[string]interface{}{}
+	for key, val := range ctx.GetDeploymentState().GetCalculatedInputs(Stage) {
+		raw[key] = val
+	}
+	for key, val := range extraVars {
+		raw[key] = val
+		result[key] = inputs[key]
+	}
+	result = references.KeepReferences(result, raw)
+	for key := range result {
+		if sensitive[key] {
+			result[key] = state.MaskedValue
+		}
+	}
+	return result
+}
+
+// MaskSensitiveValues returns a copy of the values in which the values of
+// sensitive variables have been masked.
+func MaskSensitiveValues(vars []*variables.Variable, values map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for key, val := range values {
+		result[key] = val
+	}
+	for _, v := range vars {
+		if _, ok := result[v.Id]; ok && v.Sensitive {
+			result[v.Id] = state.MaskedValue
+		}
+	}
+	return result
+}
+
+func currentUser() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		return fmt.Sprintf("%s@%s", name, host)
+	}
+	return name
+}
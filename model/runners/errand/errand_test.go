@@ -17,9 +17,12 @@ limitations under the License.
 package errand
 
 import (
+	"io/ioutil"
 	"os"
 	"testing"
 
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape-core/state"
 	"github.com/ankyra/escape/model"
 	"github.com/ankyra/escape/model/runners"
 	. "gopkg.in/check.v1"
@@ -31,7 +34,19 @@ type testSuite struct{}
 
 var _ = Suite(&testSuite{})
 
+func (s *testSuite) TearDownTest(c *C) {
+	os.Remove("testdata/errand_state_copy.json")
+	os.RemoveAll(".escape")
+}
+
 func getRunContext(c *C, stateFile, escapePlan string) *runners.RunnerContext {
+	// Errand runs are recorded in the state, so we work on a copy.
+	if stateFile == "testdata/errand_state.json" {
+		content, err := ioutil.ReadFile(stateFile)
+		c.Assert(err, IsNil)
+		stateFile = "testdata/errand_state_copy.json"
+		c.Assert(ioutil.WriteFile(stateFile, content, 0644), IsNil)
+	}
 	ctx := model.NewContext()
 	err := ctx.InitFromLocalEscapePlanAndState(stateFile, "dev", escapePlan)
 	c.Assert(err, IsNil)
@@ -85,3 +100,64 @@ func (s *testSuite) Test_ErrandRunner_failing_script(c *C) {
 	errand.Script = "testdata/failing_test.sh"
 	c.Assert(NewErrandRunner(errand, nil).Run(runCtx), Not(IsNil))
 }
+
+func (s *testSuite) Test_ErrandRunner_records_errand_runs(c *C) {
+	runCtx := getRunContext(c, "testdata/errand_state.json", "testdata/errand_plan.yml")
+	errand := runCtx.GetReleaseMetadata().GetErrands()["errand-with-secret"]
+	extraVars := map[string]interface{}{
+		"errand_variable": "yo",
+	}
+	c.Assert(NewErrandRunner(errand, extraVars).Run(runCtx), IsNil)
+	errand.Run = core.NewExecStageForRelativeScript("testdata/failing_test.sh")
+	c.Assert(NewScheduledErrandRunner(errand, extraVars).Run(runCtx), Not(IsNil))
+
+	runCtx = getRunContext(c, "testdata/errand_state_copy.json", "testdata/errand_plan.yml")
+	history := runCtx.GetDeploymentState().GetErrandHistory("errand-with-secret")
+	c.Assert(history, HasLen, 2)
+	c.Assert(history[0].Status, Equals, state.ErrandSucceeded)
+	c.Assert(*history[0].ExitCode, Equals, 0)
+	c.Assert(history[0].Version, Equals, "0.0.1")
+	c.Assert(history[0].Scheduled, Equals, false)
+	c.Assert(history[0].FinishedAt, Not(IsNil))
+	c.Assert(history[0].Inputs, DeepEquals, map[string]interface{}{
+		"variable":        "testinput",
+		"errand_variable": "yo",
+		"password":        state.MaskedValue,
+	})
+	c.Assert(history[1].Status, Equals, state.ErrandFailed)
+	c.Assert(*history[1].ExitCode, Equals, 1)
+	c.Assert(history[1].Scheduled, Equals, true)
+	c.Assert(history[1].Error, Not(Equals), "")
+	c.Assert(runCtx.GetDeploymentState().GetErrandHistory("my-errand"), HasLen, 0)
+}
+
+func (s *testSuite) Test_ErrandRunner_outputs_can_be_used_by_other_errands(c *C) {
+	runCtx := getRunContext(c, "testdata/errand_state.json", "testdata/errand_plan.yml")
+	errands := runCtx.GetReleaseMetadata().GetErrands()
+	c.Assert(NewErrandRunner(errands["snapshot"], nil).Run(runCtx), IsNil)
+	c.Assert(runCtx.GetErrandOutputs()["snapshot"], DeepEquals, map[string]interface{}{
+		"snapshot_id":  "snap-123",
+		"snapshot_key": "secret",
+	})
+	c.Assert(NewErrandRunner(errands["export"], nil).Run(runCtx), IsNil)
+	c.Assert(runCtx.GetErrandOutputs()["export"], DeepEquals, map[string]interface{}{
+		"export_location": "snap-123",
+	})
+
+	history := runCtx.GetDeploymentState().GetErrandHistory("snapshot")
+	c.Assert(history, HasLen, 1)
+	c.Assert(history[0].Outputs, DeepEquals, map[string]interface{}{
+		"snapshot_id":  "snap-123",
+		"snapshot_key": state.MaskedValue,
+	})
+	c.Assert(runCtx.GetDeploymentState().GetErrandHistory("export")[0].Inputs["snapshot_id"], Equals, "snap-123")
+	c.Assert(runCtx.GetDeploymentState().GetCalculatedOutputs("deploy"), DeepEquals, map[string]interface{}{
+		"variable": "testoutput test",
+	})
+}
+
+func (s *testSuite) Test_ErrandRunner_fails_if_required_errand_outputs_are_missing(c *C) {
+	runCtx := getRunContext(c, "testdata/errand_state.json", "testdata/errand_plan.yml")
+	errand := runCtx.GetReleaseMetadata().GetErrands()["export"]
+	c.Assert(NewErrandRunner(errand, nil).Run(runCtx), Not(IsNil))
+}
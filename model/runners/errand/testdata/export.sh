@@ -0,0 +1,5 @@
+#!/bin/bash
+
+set -euf -o pipefail
+
+test "$INPUT_snapshot_id" = "snap-123"
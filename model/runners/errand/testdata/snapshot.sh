@@ -0,0 +1,5 @@
+#!/bin/bash
+
+set -euf -o pipefail
+
+echo '{"snapshot_id": "snap-123", "snapshot_key": "secret"}' > .escape/outputs.json
@@ -0,0 +1,101 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runners
+
+import (
+	"fmt"
+	"strings"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape/util"
+)
+
+// NewHookedRunner wraps the runner for a step with the `before_<step>` and
+// `after_<step>` hooks of the release. If any of them fails the
+// `on_failure` hooks are run, after which the original error is returned.
+// The hooks are run with the environment returned by getEnv.
+func NewHookedRunner(stage, step string, runner Runner, getEnv func(*RunnerContext) []string) Runner {
+	return NewRunner(func(ctx *RunnerContext) error {
+		err := NewCompoundRunner(
+			NewHooksRunner(stage, "before_"+step, getEnv),
+			runner,
+			NewHooksRunner(stage, "after_"+step, getEnv),
+		).Run(ctx)
+		if err == nil {
+			return nil
+		}
+		failureEnv := func(ctx *RunnerContext) []string {
+			return append(getEnv(ctx), "ESCAPE_FAILED_STEP="+step, "ESCAPE_ERROR="+err.Error())
+		}
+		NewHooksRunner(stage, core.OnFailureHookPoint, failureEnv).Run(ctx)
+		return err
+	})
+}
+
+// NewHooksRunner runs the hooks that are registered for the hook point, in
+// order. Failures of hooks that are not fatal, and of all `on_failure`
+// hooks, are logged and otherwise ignored.
+func NewHooksRunner(stage, point string, getEnv func(*RunnerContext) []string) Runner {
+	return NewRunner(func(ctx *RunnerContext) error {
+		for _, hook := range ctx.GetReleaseMetadata().GetHooks(point) {
+			err := runHook(ctx, stage, point, hook, getEnv(ctx))
+			if err == nil {
+				continue
+			}
+			if hook.Fatal && point != core.OnFailureHookPoint {
+				return fmt.Errorf("The %s hook %s failed: %s", point, hook.String(), err.Error())
+			}
+			ctx.Logger().Log("hook.failed", map[string]string{
+				"point": point,
+				"hook":  hook.String(),
+				"error": err.Error(),
+			})
+		}
+		return nil
+	})
+}
+
+func runHook(ctx *RunnerContext, stage, point string, hook *core.Hook, env []string) error {
+	ctx.Logger().Log("hook.run", map[string]string{
+		"point": point,
+		"hook":  hook.String(),
+	})
+	if hook.Run.RelativeScript != "" {
+		script := strings.Fields(hook.Run.RelativeScript)[0]
+		if !util.PathExists(script) {
+			return fmt.Errorf("Referenced hook script '%s' does not exist", script)
+		}
+		if err := util.MakeExecutable(script); err != nil {
+			return err
+		}
+	}
+	scriptEnv, err := ctx.GetScriptEnvironment(stage)
+	if err != nil {
+		return err
+	}
+	run, err := hook.Run.Eval(scriptEnv)
+	if err != nil {
+		return err
+	}
+	cmd, err := run.GetAsCommand()
+	if err != nil {
+		return err
+	}
+	proc := util.NewProcessRecorder()
+	proc.SetWorkingDirectory(ctx.GetPath().GetBaseDir())
+	return proc.Run(cmd, env, ctx.Logger())
+}
@@ -22,8 +22,10 @@ import (
 	"github.com/ankyra/escape-core"
 	"github.com/ankyra/escape-core/script"
 	"github.com/ankyra/escape-core/state"
+	"github.com/ankyra/escape-core/variables"
 	"github.com/ankyra/escape/model"
 	"github.com/ankyra/escape/model/paths"
+	"github.com/ankyra/escape/model/references"
 	"github.com/ankyra/escape/util/logger/api"
 )
 
@@ -33,9 +35,12 @@ type RunnerContext struct {
 	releaseMetadata  *core.ReleaseMetadata
 	path             *paths.Path
 	inputs           map[string]interface{}
+	exportedInputs   map[string]interface{}
 	outputs          map[string]interface{}
+	errandOutputs    map[string]map[string]interface{}
 	logger           api.Logger
 	context          *model.Context
+	resolver         *references.Resolver
 
 	toScriptEnvironment func(d *state.DeploymentState, metadata *core.ReleaseMetadata, stage string, context state.DeploymentResolver) (*script.ScriptEnvironment, error)
 }
@@ -50,6 +55,10 @@ func NewRunnerContext(context *model.Context) (*RunnerContext, error) {
 		return nil, err
 	}
 	deplState.Release = metadata.GetVersionlessReleaseId()
+	resolver := references.NewResolver()
+	if profile := context.GetEscapeConfig().GetCurrentProfile(); profile != nil {
+		resolver.AllowCommands = profile.GetAllowCommandReferences()
+	}
 	return &RunnerContext{
 		path:                paths.NewPath(),
 		environmentState:    context.GetEnvironmentState(),
@@ -57,6 +66,7 @@ func NewRunnerContext(context *model.Context) (*RunnerContext, error) {
 		releaseMetadata:     metadata,
 		logger:              context.GetLogger(),
 		context:             context,
+		resolver:            resolver,
 		toScriptEnvironment: state.ToScriptEnvironment,
 	}, nil
 }
@@ -99,6 +109,21 @@ func (r *RunnerContext) GetBuildInputs() map[string]interface{} {
 
 func (r *RunnerContext) SetBuildInputs(inputs map[string]interface{}) {
 	r.inputs = inputs
+	r.exportedInputs = nil
+}
+
+// GetExportedBuildInputs returns the build inputs as they are exported to
+// the environment of scripts: the values of file variables are replaced by
+// the contents of the files.
+func (r *RunnerContext) GetExportedBuildInputs() map[string]interface{} {
+	if r.exportedInputs == nil {
+		return r.inputs
+	}
+	return r.exportedInputs
+}
+
+func (r *RunnerContext) SetExportedBuildInputs(inputs map[string]interface{}) {
+	r.exportedInputs = inputs
 }
 
 func (r *RunnerContext) GetBuildOutputs() map[string]interface{} {
@@ -109,8 +134,89 @@ func (r *RunnerContext) SetBuildOutputs(outputs map[string]interface{}) {
 	r.outputs = outputs
 }
 
+// GetErrandOutputs returns the outputs of the errands that have been run
+// with this context, by errand name.
+func (r *RunnerContext) GetErrandOutputs() map[string]map[string]interface{} {
+	return r.errandOutputs
+}
+
+func (r *RunnerContext) SetErrandOutputs(errand string, outputs map[string]interface{}) {
+	if r.errandOutputs == nil {
+		r.errandOutputs = map[string]map[string]interface{}{}
+	}
+	r.errandOutputs[errand] = outputs
+}
+
+// GetScriptEnvironment returns the script environment for the stage. The
+// references in $this.inputs are resolved and the values of file variables
+// are replaced by the contents of the files. Before the pre-step commits,
+// $this.inputs holds the values of the previous run, so files that can no
+// longer be read are left as paths; the new values are checked when they're
+// validated and exported.
 func (r *RunnerContext) GetScriptEnvironment(stage string) (*script.ScriptEnvironment, error) {
-	return r.toScriptEnvironment(r.GetDeploymentState(), r.GetReleaseMetadata(), stage, r.context)
+	env, err := r.toScriptEnvironment(r.GetDeploymentState(), r.GetReleaseMetadata(), stage, r.context)
+	if err != nil {
+		return nil, err
+	}
+	return env, r.resolveInputs(env, r.GetReleaseMetadata().GetInputs(stage))
+}
+
+// ResolveReferences returns a copy of values in which the references have
+// been resolved. References are resolved once per run.
+func (r *RunnerContext) ResolveReferences(values map[string]interface{}) (map[string]interface{}, error) {
+	return r.resolver.ResolveAll(values)
+}
+
+func (r *RunnerContext) resolveInputs(env *script.ScriptEnvironment, vars []*variables.Variable) error {
+	globals, ok := (*env)["$"]
+	if !ok || !script.IsDictAtom(globals) {
+		return nil
+	}
+	this, ok := script.ExpectDictAtom(globals)["this"]
+	if !ok || !script.IsDictAtom(this) {
+		return nil
+	}
+	inputs, ok := script.ExpectDictAtom(this)["inputs"]
+	if !ok || !script.IsDictAtom(inputs) {
+		return nil
+	}
+	inputsDict := script.ExpectDictAtom(inputs)
+	for key, val := range inputsDict {
+		ref, ok := getScriptReference(val)
+		if !ok {
+			continue
+		}
+		resolved, err := r.resolver.Resolve(ref)
+		if err != nil {
+			return fmt.Errorf("%s in variable '%s'", err.Error(), key)
+		}
+		inputsDict[key] = script.LiftString(resolved.(string))
+	}
+	for _, v := range vars {
+		val, ok := inputsDict[v.Id]
+		if !ok || v.Type != "file" || !script.IsStringAtom(val) {
+			continue
+		}
+		contents, err := readFileInput(v.Id, script.ExpectStringAtom(val))
+		if err == nil {
+			inputsDict[v.Id] = script.LiftString(contents)
+		}
+	}
+	return nil
+}
+
+// getScriptReference returns the reference if the value is a dict holding
+// one.
+func getScriptReference(val script.Script) (map[string]interface{}, bool) {
+	if !script.IsDictAtom(val) {
+		return nil, false
+	}
+	dict := script.ExpectDictAtom(val)
+	ref, ok := dict[references.ReferenceKey]
+	if len(dict) != 1 || !ok || !script.IsStringAtom(ref) {
+		return nil, false
+	}
+	return references.NewReference(script.ExpectStringAtom(ref)), true
 }
 
 func (r *RunnerContext) GetScriptEnvironmentForPreDependencyStep(stage string) (*script.ScriptEnvironment, error) {
@@ -132,6 +238,7 @@ func (r *RunnerContext) NewContextForProvider(depl *state.DeploymentState, metad
 		inputs:              inputs,
 		outputs:             outputs,
 		context:             r.context,
+		resolver:            r.resolver,
 		toScriptEnvironment: r.toScriptEnvironment,
 	}, depl.ConfigureProviders(metadata, "deploy", nil)
 }
@@ -168,6 +275,7 @@ func (r *RunnerContext) NewContextForDependency(stage, deploymentName string, me
 		inputs:              r.inputs,
 		outputs:             r.outputs,
 		context:             r.context,
+		resolver:            r.resolver,
 		toScriptEnvironment: r.toScriptEnvironment,
 	}, depl.ConfigureProviders(metadata, "deploy", compiledConsumerMapping)
 }
@@ -20,10 +20,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"strings"
 
 	core "github.com/ankyra/escape-core"
 	"github.com/ankyra/escape-core/state"
+	"github.com/ankyra/escape-core/templates"
+	"github.com/ankyra/escape-core/variables"
 	"github.com/ankyra/escape/model/dependency_resolvers"
 	"github.com/ankyra/escape/model/paths"
 	"github.com/ankyra/escape/util"
@@ -36,6 +39,7 @@ type ScriptStep struct {
 	Stage                   string
 	Step                    string
 	Inputs                  func(ctx *RunnerContext, stage string) (map[string]interface{}, error)
+	Variables               []*variables.Variable
 	LoadOutputs             bool
 	Script                  *core.ExecStage
 	Commit                  func(ctx *RunnerContext, d *state.DeploymentState, stage string) error
@@ -48,6 +52,7 @@ func NewScriptStep(ctx *RunnerContext, stage, step string, shouldBeDeployed bool
 		Stage:                   stage,
 		Step:                    step,
 		Inputs:                  nil,
+		Variables:               ctx.GetReleaseMetadata().GetInputs(stage),
 		LoadOutputs:             shouldBeDeployed,
 		Script:                  ctx.GetReleaseMetadata().GetExecStage(step),
 		Commit:                  nil,
@@ -107,8 +112,34 @@ func compileTemplates(ctx *RunnerContext, stage string) error {
 	return nil
 }
 
+// RenderTemplates renders the templates of the stage without writing them
+// to disk. If file is set only the template with that file name is rendered.
+func RenderTemplates(ctx *RunnerContext, stage, file string) ([]*templates.RenderedFile, error) {
+	env, err := ctx.GetScriptEnvironment(stage)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	result := []*templates.RenderedFile{}
+	for _, tpl := range ctx.GetReleaseMetadata().GetTemplates(stage) {
+		if file != "" && filepath.Clean(tpl.File) != filepath.Clean(file) {
+			continue
+		}
+		found = true
+		files, err := tpl.RenderFiles(stage, env)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, files...)
+	}
+	if file != "" && !found {
+		return nil, fmt.Errorf("Template '%s' not found in the %s stage", file, stage)
+	}
+	return result, nil
+}
+
 func preCommit(ctx *RunnerContext, deploymentState *state.DeploymentState, stage string) error {
-	inputs := ctx.GetBuildInputs()
+	inputs := NewEnvironmentBuilder().GetInputsForCommit(ctx, stage, ctx.GetBuildInputs())
 	metadata := ctx.GetReleaseMetadata()
 	if err := deploymentState.CommitVersion(stage, metadata); err != nil {
 		return err
@@ -145,10 +176,14 @@ func (b *ScriptStep) Run(ctx *RunnerContext) error {
 	if err := b.handleDownloads(ctx); err != nil {
 		return err
 	}
-	if b.Script != nil && !b.Script.IsEmpty() {
-		if err := b.runScript(ctx); err != nil {
-			return err
+	scriptRunner := NewRunner(func(ctx *RunnerContext) error {
+		if b.Script != nil && !b.Script.IsEmpty() {
+			return b.runScript(ctx)
 		}
+		return nil
+	})
+	if err := NewHookedRunner(b.Stage, b.Step, scriptRunner, b.getHookEnv).Run(ctx); err != nil {
+		return err
 	}
 	if b.Commit != nil {
 		return b.Commit(ctx, deploymentState, b.Stage)
@@ -204,8 +239,17 @@ func (b *ScriptStep) initDeploymentState(ctx *RunnerContext) (*state.DeploymentS
 		}
 		ctx.SetBuildInputs(inputs)
 	} else {
-		ctx.SetBuildInputs(deploymentState.GetCalculatedInputs(b.Stage))
+		inputs, err := NewEnvironmentBuilder().GetCalculatedInputs(ctx, b.Stage)
+		if err != nil {
+			return nil, err
+		}
+		ctx.SetBuildInputs(inputs)
+	}
+	exported, err := exportInputs(b.Variables, ctx.GetBuildInputs())
+	if err != nil {
+		return nil, err
 	}
+	ctx.SetExportedBuildInputs(exported)
 	if b.LoadOutputs {
 		ctx.SetBuildOutputs(deploymentState.GetCalculatedOutputs(b.Stage))
 	}
@@ -219,6 +263,15 @@ func (b *ScriptStep) getEnv(ctx *RunnerContext) []string {
 	return NewEnvironmentBuilder().MergeInputsAndOutputsWithOsEnvironment(ctx)
 }
 
+// getHookEnv returns the environment for the hooks of this step. Unlike the
+// step itself, the hooks also get the outputs of steps that modify them.
+func (b *ScriptStep) getHookEnv(ctx *RunnerContext) []string {
+	if !b.LoadOutputs && !b.ModifiesOutputVariables {
+		return NewEnvironmentBuilder().MergeInputsWithOsEnvironment(ctx)
+	}
+	return NewEnvironmentBuilder().MergeInputsAndOutputsWithOsEnvironment(ctx)
+}
+
 func (b *ScriptStep) handleDownloads(ctx *RunnerContext) error {
 	if !b.ShouldDownload {
 		return nil
@@ -301,6 +354,12 @@ func writeOutputsToFile(outputs map[string]interface{}) error {
 	return nil
 }
 
+// ReadOutputsFile returns the values that a script wrote to the outputs file
+// (`.escape/outputs.json`), without merging them into the build outputs.
+func ReadOutputsFile(ctx *RunnerContext) (map[string]interface{}, error) {
+	return readOutputsFromFile(ctx.GetPath().OutputsFile())
+}
+
 func readOutputsFromFile(outputsJsonLocation string) (map[string]interface{}, error) {
 	result := map[string]interface{}{}
 	if !util.PathExists(outputsJsonLocation) {
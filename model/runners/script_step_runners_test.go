@@ -0,0 +1,34 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runners
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *testSuite) Test_compileTemplates(c *C) {
+	defer os.Remove("testdata/template.txt")
+	runCtx := getRunContext(c, "testdata/env_state.json", "testdata/template_plan.yml")
+	runCtx.GetDeploymentState().GetCalculatedInputs("deploy")["input_variable"] = "world"
+	c.Assert(compileTemplates(runCtx, "deploy"), IsNil)
+	content, err := ioutil.ReadFile("testdata/template.txt")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "Hello world\n")
+}
@@ -0,0 +1 @@
+Hello {{input_variable}}
@@ -0,0 +1,128 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"time"
+
+	core "github.com/ankyra/escape-core"
+)
+
+// A Job is run every time its Schedule fires.
+type Job struct {
+	Name     string
+	Schedule *core.Schedule
+	Run      func() error
+}
+
+// The Scheduler runs Jobs on their Schedule until it's stopped. Jobs run one
+// at a time; a Job that fires while another one is running is started as
+// soon as that one finishes.
+type Scheduler struct {
+	Jobs []*Job
+
+	// OnError is called when a Job fails. Failing Jobs don't stop the
+	// Scheduler.
+	OnError func(job *Job, err error)
+	// OnSchedule is called with the next time a Job is going to run.
+	OnSchedule func(job *Job, next time.Time)
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func NewScheduler(jobs []*Job) *Scheduler {
+	return &Scheduler{
+		Jobs:  jobs,
+		now:   time.Now,
+		sleep: time.Sleep,
+	}
+}
+
+// Run runs the Jobs until there are no more Jobs to run (because none of
+// their Schedules fire again) or until stop is closed.
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	next := map[*Job]time.Time{}
+	for _, job := range s.Jobs {
+		s.scheduleNext(next, job, s.now())
+	}
+	for len(next) > 0 {
+		job, at := s.firstJob(next)
+		if wait := at.Sub(s.now()); wait > 0 {
+			if !s.wait(wait, stop) {
+				return
+			}
+			continue
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if err := job.Run(); err != nil && s.OnError != nil {
+			s.OnError(job, err)
+		}
+		s.scheduleNext(next, job, at)
+	}
+}
+
+// wait sleeps for the given duration, in steps of at most a minute, so that
+// the clock is checked again regularly (e.g. after the machine was
+// suspended). Returns false if the Scheduler was stopped.
+func (s *Scheduler) wait(d time.Duration, stop <-chan struct{}) bool {
+	if d > time.Minute {
+		d = time.Minute
+	}
+	done := make(chan struct{})
+	go func() {
+		s.sleep(d)
+		close(done)
+	}()
+	select {
+	case <-stop:
+		return false
+	case <-done:
+		return true
+	}
+}
+
+func (s *Scheduler) scheduleNext(next map[*Job]time.Time, job *Job, after time.Time) {
+	if now := s.now(); now.After(after) {
+		after = now
+	}
+	at := job.Schedule.Next(after)
+	if at.IsZero() {
+		delete(next, job)
+		return
+	}
+	next[job] = at
+	if s.OnSchedule != nil {
+		s.OnSchedule(job, at)
+	}
+}
+
+func (s *Scheduler) firstJob(next map[*Job]time.Time) (*Job, time.Time) {
+	var first *Job
+	var firstAt time.Time
+	for _, job := range s.Jobs {
+		at, ok := next[job]
+		if ok && (first == nil || at.Before(firstAt)) {
+			first, firstAt = job, at
+		}
+	}
+	return first, firstAt
+}
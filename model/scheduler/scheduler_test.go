@@ -0,0 +1,110 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	core "github.com/ankyra/escape-core"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type suite struct{}
+
+var _ = Suite(&suite{})
+
+type fakeClock struct {
+	now time.Time
+}
+
+func newTestScheduler(c *C, jobs []*Job) (*Scheduler, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2018, 1, 1, 10, 0, 30, 0, time.UTC)}
+	s := NewScheduler(jobs)
+	s.now = func() time.Time { return clock.now }
+	s.sleep = func(d time.Duration) { clock.now = clock.now.Add(d) }
+	return s, clock
+}
+
+func newTestJob(c *C, name, schedule string, run func() error) *Job {
+	sched, err := core.ParseSchedule(schedule)
+	c.Assert(err, IsNil)
+	return &Job{Name: name, Schedule: sched, Run: run}
+}
+
+func (s *suite) Test_Scheduler_runs_jobs_in_order(c *C) {
+	stop := make(chan struct{})
+	runs := []string{}
+	var clock *fakeClock
+	record := func(name string) func() error {
+		return func() error {
+			runs = append(runs, clock.now.Format("15:04:05")+" "+name)
+			if len(runs) == 5 {
+				close(stop)
+			}
+			return nil
+		}
+	}
+	jobs := []*Job{
+		newTestJob(c, "every-minute", "* * * * *", record("every-minute")),
+		newTestJob(c, "every-two-minutes", "*/2 * * * *", record("every-two-minutes")),
+	}
+	var scheduler *Scheduler
+	scheduler, clock = newTestScheduler(c, jobs)
+	scheduler.Run(stop)
+	c.Assert(runs, DeepEquals, []string{
+		"10:01:00 every-minute",
+		"10:02:00 every-minute",
+		"10:02:00 every-two-minutes",
+		"10:03:00 every-minute",
+		"10:04:00 every-minute",
+	})
+}
+
+func (s *suite) Test_Scheduler_keeps_running_when_jobs_fail(c *C) {
+	stop := make(chan struct{})
+	calls := 0
+	failures := []string{}
+	job := newTestJob(c, "failing", "@hourly", func() error {
+		calls++
+		if calls == 3 {
+			close(stop)
+		}
+		return errors.New("failed")
+	})
+	scheduler, clock := newTestScheduler(c, []*Job{job})
+	scheduler.OnError = func(job *Job, err error) {
+		failures = append(failures, job.Name+": "+err.Error())
+	}
+	scheduler.Run(stop)
+	c.Assert(calls, Equals, 3)
+	c.Assert(failures, HasLen, 3)
+	c.Assert(failures[0], Equals, "failing: failed")
+	c.Assert(clock.now, Equals, time.Date(2018, 1, 1, 13, 0, 0, 0, time.UTC))
+}
+
+func (s *suite) Test_Scheduler_stops_when_no_jobs_are_left(c *C) {
+	job := newTestJob(c, "never", "0 0 31 2 *", func() error {
+		c.Fatal("Job shouldn't run")
+		return nil
+	})
+	scheduler, _ := newTestScheduler(c, []*Job{job})
+	scheduler.Run(make(chan struct{}))
+}
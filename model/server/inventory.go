@@ -0,0 +1,291 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape-core/parsers"
+	"github.com/ankyra/escape-core/state/validate"
+)
+
+// inventoryHandler handles the api/v1/inventory/ endpoints. The parts are the
+// path segments after "inventory".
+func (s *Server) inventoryHandler(w http.ResponseWriter, r *http.Request, parts []string) {
+	if err := validateInventoryPath(r, parts); err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	if len(parts) > 0 {
+		defer s.lockProject(parts[0])()
+	}
+	switch {
+	case len(parts) == 0 && r.Method == "GET":
+		s.listProjects(w)
+	case len(parts) == 2 && parts[1] == "register" && r.Method == "POST":
+		s.register(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "units" && r.Method == "GET":
+		s.listApplications(w, parts[0])
+	case len(parts) < 3 || parts[1] != "units":
+		writeError(w, 404, "Not found")
+	case len(parts) == 3 && r.Method == "GET":
+		s.listVersions(w, parts[0], parts[2])
+	case len(parts) == 4 && parts[3] == "next-version" && r.Method == "GET":
+		s.nextVersion(w, r, parts[0], parts[2])
+	case len(parts) == 4 && parts[3] == "tags" && r.Method == "POST":
+		s.tagRelease(w, r, parts[0], parts[2])
+	case len(parts) == 5 && parts[3] == "versions" && r.Method == "GET":
+		s.queryRelease(w, parts[0], parts[2], parts[4])
+	case len(parts) == 5 && parts[3] == "versions" && r.Method == "DELETE":
+		s.deleteRelease(w, parts[0], parts[2], strings.TrimPrefix(parts[4], "v"))
+	case len(parts) == 6 && parts[3] == "versions":
+		s.releaseAction(w, r, parts[0], parts[2], strings.TrimPrefix(parts[4], "v"), parts[5])
+	default:
+		writeError(w, 404, "Not found")
+	}
+}
+
+// validateInventoryPath checks the project, release name and version in the
+// path, because they end up in file paths in the local inventory.
+func validateInventoryPath(r *http.Request, parts []string) error {
+	if len(parts) == 0 {
+		return nil
+	}
+	project := parts[0]
+	if !isSafePathSegment(project) || (project != "_" && !validate.IsValidProjectName(project)) {
+		return validate.InvalidProjectNameError(project)
+	}
+	if len(parts) < 3 || parts[1] != "units" {
+		return nil
+	}
+	if err := core.ValidateReleaseName(parts[2]); err != nil {
+		return err
+	}
+	if len(parts) < 5 || parts[3] != "versions" {
+		return nil
+	}
+	version := parts[4]
+	if !isSafePathSegment(version) {
+		return parsers.InvalidVersionError(version)
+	}
+	if len(parts) == 5 && r.Method == "GET" {
+		// A version query, which can also be a tag or "latest".
+		return nil
+	}
+	return parsers.ValidateVersion(strings.TrimPrefix(version, "v"))
+}
+
+func (s *Server) releaseAction(w http.ResponseWriter, r *http.Request, project, name, version, action string) {
+	switch {
+	case action == "download" && r.Method == "GET":
+		s.downloadRelease(w, project, name, version)
+	case action == "upload" && r.Method == "POST":
+		s.uploadRelease(w, r, project, name, version)
+	case action == "deprecate" && r.Method == "POST":
+		s.markRelease(w, r, func(reason string) error {
+			return s.inventory.DeprecateRelease(project, name, version, reason)
+		})
+	case action == "yank" && r.Method == "POST":
+		s.markRelease(w, r, func(reason string) error {
+			return s.inventory.YankRelease(project, name, version, reason)
+		})
+	default:
+		writeError(w, 404, "Not found")
+	}
+}
+
+// The remote inventory turns the keys of these objects into lists.
+func toKeys(values []string) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, v := range values {
+		result[v] = map[string]interface{}{}
+	}
+	return result
+}
+
+func (s *Server) listProjects(w http.ResponseWriter) {
+	projects, err := s.inventory.ListProjects()
+	if err != nil {
+		// A fresh data directory doesn't have an inventory yet.
+		projects = []string{}
+	}
+	writeJson(w, toKeys(projects))
+}
+
+func (s *Server) listApplications(w http.ResponseWriter, project string) {
+	apps, err := s.inventory.ListApplications(project)
+	if err != nil {
+		writeError(w, 404, err.Error())
+		return
+	}
+	writeJson(w, toKeys(apps))
+}
+
+func (s *Server) listVersions(w http.ResponseWriter, project, name string) {
+	versions, err := s.inventory.ListVersions(project, name)
+	if err != nil {
+		writeError(w, 404, err.Error())
+		return
+	}
+	writeJson(w, map[string]interface{}{
+		"name":     name,
+		"versions": versions,
+	})
+}
+
+func (s *Server) nextVersion(w http.ResponseWriter, r *http.Request, project, name string) {
+	version, err := s.inventory.QueryNextVersion(project, name, r.URL.Query().Get("prefix"))
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	w.Write([]byte(version))
+}
+
+func (s *Server) queryRelease(w http.ResponseWriter, project, name, version string) {
+	metadata, err := s.inventory.QueryReleaseMetadata(project, name, version)
+	if err != nil {
+		writeError(w, 404, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(metadata.ToJson()))
+}
+
+func (s *Server) register(w http.ResponseWriter, r *http.Request, project string) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	metadata, err := core.NewReleaseMetadataFromJsonString(string(body))
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	if _, err := s.inventory.QueryReleaseMetadata(project, metadata.Name, "v"+metadata.Version); err == nil {
+		writeError(w, 400, "Release "+project+"/"+metadata.GetReleaseId()+" already exists")
+		return
+	}
+	if err := s.addRegistration(project+"/"+metadata.GetReleaseId(), metadata); err != nil {
+		writeError(w, 503, err.Error())
+		return
+	}
+	w.Write([]byte("OK"))
+}
+
+func (s *Server) uploadRelease(w http.ResponseWriter, r *http.Request, project, name, version string) {
+	releaseId := project + "/" + name + "-v" + version
+	metadata := s.getRegistration(releaseId)
+	if metadata == nil {
+		writeError(w, 400, "Release "+releaseId+" needs to be registered before it can be uploaded")
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	defer file.Close()
+	tmpDir := filepath.Join(s.DataDir, "tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		writeError(w, 500, err.Error())
+		return
+	}
+	tmp, err := ioutil.TempFile(tmpDir, "upload-")
+	if err != nil {
+		writeError(w, 500, err.Error())
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, file)
+	tmp.Close()
+	if err != nil {
+		writeError(w, 500, err.Error())
+		return
+	}
+	if err := s.inventory.UploadRelease(project, tmp.Name(), metadata); err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	s.lock.Lock()
+	delete(s.registered, releaseId)
+	s.lock.Unlock()
+	w.Write([]byte("OK"))
+}
+
+func (s *Server) downloadRelease(w http.ResponseWriter, project, name, version string) {
+	path := filepath.Join(s.inventory.BaseDir, project, name, name+"-v"+version+".tgz")
+	fp, err := os.Open(path)
+	if err != nil {
+		writeError(w, 404, "Release "+project+"/"+name+"-v"+version+" could not be found")
+		return
+	}
+	defer fp.Close()
+	w.Header().Set("Content-Type", "application/gzip")
+	io.Copy(w, fp)
+}
+
+func (s *Server) deleteRelease(w http.ResponseWriter, project, name, version string) {
+	if err := s.inventory.DeleteRelease(project, name, version); err != nil {
+		writeError(w, 404, err.Error())
+		return
+	}
+	w.Write([]byte("OK"))
+}
+
+// tagRelease expects a release ID as sent by the remote inventory, e.g.
+// "project/name-v1.0", "project/name-latest" or "project/name:tag".
+func (s *Server) tagRelease(w http.ResponseWriter, r *http.Request, project, name string) {
+	data := map[string]string{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	releaseId, tag := data["release_id"], data["tag"]
+	prefix := project + "/" + name
+	if tag == "" || len(releaseId) <= len(prefix)+1 || !strings.HasPrefix(releaseId, prefix) {
+		writeError(w, 400, "Expecting a 'release_id' for "+prefix+" and a 'tag'")
+		return
+	}
+	version := releaseId[len(prefix)+1:]
+	if err := s.inventory.TagRelease(project, name, version, tag); err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	w.Write([]byte("OK"))
+}
+
+func (s *Server) markRelease(w http.ResponseWriter, r *http.Request, mark func(reason string) error) {
+	data := map[string]string{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	if err := mark(data["reason"]); err != nil {
+		writeError(w, 404, err.Error())
+		return
+	}
+	w.Write([]byte("OK"))
+}
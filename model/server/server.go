@@ -0,0 +1,219 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape/model/inventory/local"
+)
+
+// The Server implements the Inventory and state API described by
+// remote.ServerEndpoints on top of a local inventory and local state files,
+// so that the remote inventory and remote state providers can talk to it.
+//
+// The data directory contains the local inventory ("inventory/") and a state
+// file per project ("state/<project>.json").
+type Server struct {
+	DataDir  string
+	Username string
+	Password string
+
+	inventory *local.LocalInventory
+	// Releases are registered before they are uploaded. Registrations
+	// expire after RegistrationTimeout and at most MaxRegistrations are
+	// kept.
+	registered map[string]*registration
+	// The local inventory and state files are not safe for concurrent
+	// writers, so requests for the same project are handled one at a time.
+	projectLocks map[string]*sync.Mutex
+	// Guards registered and projectLocks.
+	lock sync.Mutex
+}
+
+// How long a registered release can wait for its upload, and how many
+// registrations can be pending at the same time.
+var RegistrationTimeout = time.Hour
+var MaxRegistrations = 1000
+
+type registration struct {
+	Metadata *core.ReleaseMetadata
+	Expires  time.Time
+}
+
+// CheckListenAddress refuses to serve on an address that isn't a loopback
+// address unless authentication and TLS are enabled, because anyone that
+// can reach the server can read and change all the releases and state.
+func CheckListenAddress(address string, authenticated, tls bool) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("Invalid listen address '%s': %s", address, err.Error())
+	}
+	if isLoopback(host) {
+		return nil
+	}
+	if !authenticated {
+		return fmt.Errorf("Refusing to listen on '%s' without authentication. Set a username and password, or listen on a loopback address (e.g. 127.0.0.1:7770).", address)
+	}
+	if !tls {
+		return fmt.Errorf("Refusing to listen on '%s' without TLS. Set a TLS certificate and key, or listen on a loopback address (e.g. 127.0.0.1:7770).", address)
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func NewServer(dataDir, username, password string) *Server {
+	return &Server{
+		DataDir:      dataDir,
+		Username:     username,
+		Password:     password,
+		inventory:    local.NewLocalInventory(filepath.Join(dataDir, "inventory")),
+		registered:   map[string]*registration{},
+		projectLocks: map[string]*sync.Mutex{},
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "api/v1/auth/login-methods" {
+		s.loginMethods(w, r)
+		return
+	}
+	if !s.isAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Escape"`)
+		writeError(w, 401, "Unauthorized")
+		return
+	}
+	parts := strings.Split(path, "/")
+	if path == "" {
+		fmt.Fprint(w, "OK")
+	} else if len(parts) >= 3 && parts[0] == "api" && parts[1] == "v1" && parts[2] == "inventory" {
+		s.inventoryHandler(w, r, parts[3:])
+	} else if len(parts) >= 3 && parts[0] == "api" && parts[1] == "v1" && parts[2] == "state" {
+		s.stateHandler(w, r, parts[3:])
+	} else {
+		writeError(w, 404, "Not found")
+	}
+}
+
+// lockProject locks the project and returns the function to unlock it.
+// addRegistration registers the release until it's uploaded. Expired
+// registrations are dropped first; an error is returned if there are too
+// many pending registrations.
+func (s *Server) addRegistration(releaseId string, metadata *core.ReleaseMetadata) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	for id, reg := range s.registered {
+		if now.After(reg.Expires) {
+			delete(s.registered, id)
+		}
+	}
+	if _, found := s.registered[releaseId]; !found && len(s.registered) >= MaxRegistrations {
+		return fmt.Errorf("Too many pending registrations. Try again later.")
+	}
+	s.registered[releaseId] = &registration{
+		Metadata: metadata,
+		Expires:  now.Add(RegistrationTimeout),
+	}
+	return nil
+}
+
+// getRegistration returns the metadata of the registered release, or nil if
+// the release hasn't been registered or if the registration has expired.
+func (s *Server) getRegistration(releaseId string) *core.ReleaseMetadata {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	reg, ok := s.registered[releaseId]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(reg.Expires) {
+		delete(s.registered, releaseId)
+		return nil
+	}
+	return reg.Metadata
+}
+
+func (s *Server) lockProject(project string) func() {
+	s.lock.Lock()
+	projectLock, ok := s.projectLocks[project]
+	if !ok {
+		projectLock = &sync.Mutex{}
+		s.projectLocks[project] = projectLock
+	}
+	s.lock.Unlock()
+	projectLock.Lock()
+	return projectLock.Unlock
+}
+
+// isSafePathSegment makes sure that a value taken from the URL can't be used
+// to escape the data directory when it's used in a file path.
+func isSafePathSegment(segment string) bool {
+	return segment != "" && segment != "." && segment != ".." && !strings.ContainsAny(segment, `/\`)
+}
+
+// loginMethods advertises basic authentication when it's enabled. Without
+// credentials there's nothing to log in to, which the remote inventory
+// expects to be signalled with a 404.
+func (s *Server) loginMethods(w http.ResponseWriter, r *http.Request) {
+	if s.Password != "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Escape"`)
+		writeError(w, 401, "Unauthorized")
+		return
+	}
+	writeError(w, 404, "Authentication not required")
+}
+
+func (s *Server) isAuthorized(r *http.Request) bool {
+	if s.Password == "" {
+		return true
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	usernameOk := subtle.ConstantTimeCompare([]byte(username), []byte(s.Username)) == 1
+	passwordOk := subtle.ConstantTimeCompare([]byte(password), []byte(s.Password)) == 1
+	return usernameOk && passwordOk
+}
+
+func writeJson(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	fmt.Fprint(w, msg)
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/ankyra/escape-core"
+	"github.com/ankyra/escape-core/state"
+	"github.com/ankyra/escape/model/inventory/remote"
+	remotestate "github.com/ankyra/escape/model/state/remote"
+	. "gopkg.in/check.v1"
+)
+
+type suite struct{}
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&suite{})
+
+const testDir = "testdata_server"
+
+func (s *suite) SetUpTest(c *C) {
+	os.RemoveAll(testDir)
+	c.Assert(os.MkdirAll(testDir, 0755), IsNil)
+}
+
+func (s *suite) TearDownTest(c *C) {
+	os.RemoveAll(testDir)
+}
+
+func newTestServer(username, password string) *httptest.Server {
+	return httptest.NewServer(NewServer(filepath.Join(testDir, "data"), username, password))
+}
+
+func upload(c *C, url, project, name, version string) {
+	archive := filepath.Join(testDir, name+"-v"+version+".tgz")
+	c.Assert(ioutil.WriteFile(archive, []byte("archive "+version), 0644), IsNil)
+	metadata := core.NewReleaseMetadata(name, version)
+	metadata.Project = project
+	c.Assert(remote.NewRemoteInventory(url, "", "", "", false).UploadRelease(project, archive, metadata), IsNil)
+}
+
+func (s *suite) Test_Inventory_releases(c *C) {
+	ts := newTestServer("", "")
+	defer ts.Close()
+	inv := remote.NewRemoteInventory(ts.URL, "", "", "", false)
+
+	next, err := inv.QueryNextVersion("prj", "app", "")
+	c.Assert(err, IsNil)
+	c.Assert(next, Equals, "0")
+
+	upload(c, ts.URL, "prj", "app", "1.0")
+	upload(c, ts.URL, "prj", "app", "1.1")
+
+	m, err := inv.QueryReleaseMetadata("prj", "app", "latest")
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "1.1")
+
+	m, err = inv.QueryReleaseMetadata("prj", "app", "v1.@")
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "1.1")
+
+	_, err = inv.QueryReleaseMetadata("prj", "app", "v2.0")
+	c.Assert(err, ErrorMatches, ".*could not be found.*")
+
+	target := filepath.Join(testDir, "download.tgz")
+	c.Assert(inv.DownloadRelease("prj", "app", "1.0", target), IsNil)
+	content, err := ioutil.ReadFile(target)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "archive 1.0")
+
+	next, err = inv.QueryNextVersion("prj", "app", "1.")
+	c.Assert(err, IsNil)
+	c.Assert(next, Equals, "1.2")
+}
+
+func (s *suite) Test_Inventory_cant_upload_existing_version(c *C) {
+	ts := newTestServer("", "")
+	defer ts.Close()
+	upload(c, ts.URL, "prj", "app", "1.0")
+	metadata := core.NewReleaseMetadata("app", "1.0")
+	inv := remote.NewRemoteInventory(ts.URL, "", "", "", false)
+	err := inv.UploadRelease("prj", filepath.Join(testDir, "app-v1.0.tgz"), metadata)
+	c.Assert(err, ErrorMatches, ".*already exists")
+}
+
+func (s *suite) Test_Inventory_listing(c *C) {
+	ts := newTestServer("", "")
+	defer ts.Close()
+	inv := remote.NewRemoteInventory(ts.URL, "", "", "", false)
+
+	projects, err := inv.ListProjects()
+	c.Assert(err, IsNil)
+	c.Assert(projects, HasLen, 0)
+
+	upload(c, ts.URL, "prj", "app", "1.0")
+	upload(c, ts.URL, "prj", "other-app", "1.0")
+	upload(c, ts.URL, "prj2", "app", "1.0")
+
+	projects, err = inv.ListProjects()
+	c.Assert(err, IsNil)
+	c.Assert(projects, DeepEquals, []string{"prj", "prj2"})
+
+	apps, err := inv.ListApplications("prj")
+	c.Assert(err, IsNil)
+	c.Assert(apps, DeepEquals, []string{"app", "other-app"})
+
+	versions, err := inv.ListVersions("prj", "app")
+	c.Assert(err, IsNil)
+	c.Assert(versions, DeepEquals, []string{"1.0"})
+
+	_, err = inv.ListApplications("unknown")
+	c.Assert(err, ErrorMatches, ".*could not be found.*")
+}
+
+func (s *suite) Test_Inventory_tag_deprecate_yank_and_delete(c *C) {
+	ts := newTestServer("", "")
+	defer ts.Close()
+	inv := remote.NewRemoteInventory(ts.URL, "", "", "", false)
+	upload(c, ts.URL, "prj", "app", "1.0")
+	upload(c, ts.URL, "prj", "app", "1.1")
+
+	c.Assert(inv.TagRelease("prj", "app", "1.0", "production"), IsNil)
+	m, err := inv.QueryReleaseMetadata("prj", "app", "production")
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "1.0")
+
+	c.Assert(inv.DeprecateRelease("prj", "app", "1.0", "old"), IsNil)
+	m, err = inv.QueryReleaseMetadata("prj", "app", "v1.0")
+	c.Assert(err, IsNil)
+	c.Assert(m.Deprecated, Equals, true)
+	c.Assert(m.DeprecatedReason, Equals, "old")
+
+	c.Assert(inv.YankRelease("prj", "app", "1.1", "broken"), IsNil)
+	m, err = inv.QueryReleaseMetadata("prj", "app", "latest")
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "1.0")
+
+	c.Assert(inv.DeleteRelease("prj", "app", "1.1"), IsNil)
+	versions, err := inv.ListVersions("prj", "app")
+	c.Assert(err, IsNil)
+	c.Assert(versions, DeepEquals, []string{"1.0"})
+}
+
+func (s *suite) Test_State(c *C) {
+	ts := newTestServer("", "")
+	defer ts.Close()
+	provider := remotestate.NewRemoteStateProvider(ts.URL, "", "", "", false)
+
+	env, err := provider.Load("prj", "dev")
+	c.Assert(err, IsNil)
+	c.Assert(env.Deployments, HasLen, 0)
+
+	depl, err := env.GetOrCreateDeploymentState("my-deployment")
+	c.Assert(err, IsNil)
+	c.Assert(depl.UpdateUserInputs(state.DeployStage, map[string]interface{}{"key": "value"}), IsNil)
+	sub, err := depl.GetDeploymentOrMakeNew(state.DeployStage, "my-dependency")
+	c.Assert(err, IsNil)
+	c.Assert(sub.UpdateUserInputs(state.DeployStage, map[string]interface{}{"sub": "value"}), IsNil)
+
+	env, err = provider.Load("prj", "dev")
+	c.Assert(err, IsNil)
+	depl, err = env.LookupDeploymentState("my-deployment")
+	c.Assert(err, IsNil)
+	c.Assert(depl.GetUserInputs(state.DeployStage)["key"], Equals, "value")
+	sub, err = depl.GetDeployment(state.DeployStage, "my-dependency")
+	c.Assert(err, IsNil)
+	c.Assert(sub.GetUserInputs(state.DeployStage)["sub"], Equals, "value")
+
+	c.Assert(env.DeleteDeployment("my-deployment"), IsNil)
+	env, err = provider.Load("prj", "dev")
+	c.Assert(err, IsNil)
+	c.Assert(env.Deployments, HasLen, 0)
+}
+
+func (s *suite) Test_Basic_Authentication(c *C) {
+	ts := newTestServer("admin", "secret")
+	defer ts.Close()
+
+	inv := remote.NewRemoteInventory(ts.URL, "", "", "", false)
+	methods, err := inv.GetAuthMethods(ts.URL)
+	c.Assert(err, IsNil)
+	c.Assert(methods["Basic Authentication"].Type, Equals, "basic-auth")
+	c.Assert(inv.LoginWithBasicAuth(ts.URL, "admin", "wrong"), Not(IsNil))
+	c.Assert(inv.LoginWithBasicAuth(ts.URL, "admin", "secret"), IsNil)
+	_, err = inv.ListProjects()
+	c.Assert(err, ErrorMatches, "You don't have a valid authentication token.*")
+
+	inv = remote.NewRemoteInventory(ts.URL, "", "admin", "secret", false)
+	_, err = inv.ListProjects()
+	c.Assert(err, IsNil)
+
+	_, err = remotestate.NewRemoteStateProvider(ts.URL, "", "", "", false).Load("prj", "dev")
+	c.Assert(err, ErrorMatches, "Unauthorized")
+	_, err = remotestate.NewRemoteStateProvider(ts.URL, "", "admin", "secret", false).Load("prj", "dev")
+	c.Assert(err, IsNil)
+}
+
+func (s *suite) Test_GetAuthMethods_without_authentication(c *C) {
+	ts := newTestServer("", "")
+	defer ts.Close()
+	methods, err := remote.NewRemoteInventory(ts.URL, "", "", "", false).GetAuthMethods(ts.URL)
+	c.Assert(err, IsNil)
+	c.Assert(methods, IsNil)
+}
+
+func (s *suite) Test_Inventory_rejects_invalid_path_segments(c *C) {
+	unit := NewServer(filepath.Join(testDir, "data"), "", "")
+	c.Assert(ioutil.WriteFile(filepath.Join(testDir, "secret.tgz"), []byte("secret"), 0644), IsNil)
+	paths := []string{
+		"/api/v1/inventory/../units/app/",
+		"/api/v1/inventory/prj/units/../versions/v1.0/download",
+		"/api/v1/inventory/prj/units/app/versions/v..%2F..%2F..%2Fsecret/download",
+		"/api/v1/inventory/prj/units/app/versions/..%2F..%2F..%2Fsecret/",
+		"/api/v1/inventory/prj/units/App/",
+	}
+	for _, path := range paths {
+		req := httptest.NewRequest("GET", "http://localhost"+path, nil)
+		w := httptest.NewRecorder()
+		unit.ServeHTTP(w, req)
+		c.Assert(w.Code, Equals, 400, Commentf("%s", path))
+	}
+	req := httptest.NewRequest("DELETE", "http://localhost/api/v1/inventory/prj/units/app/versions/v..%2F..%2Fsecret/", nil)
+	w := httptest.NewRecorder()
+	unit.ServeHTTP(w, req)
+	c.Assert(w.Code, Equals, 400)
+}
+
+func (s *suite) Test_State_rejects_invalid_environment_names(c *C) {
+	unit := NewServer(filepath.Join(testDir, "data"), "", "")
+	req := httptest.NewRequest("GET", "http://localhost/api/v1/state/prj/environments/..%2F..%2Fsecret", nil)
+	w := httptest.NewRecorder()
+	unit.ServeHTTP(w, req)
+	c.Assert(w.Code, Equals, 400)
+	c.Assert(w.Body.String(), Matches, "The environment name '..' is not allowed.*")
+}
+
+func (s *suite) Test_CheckListenAddress(c *C) {
+	for _, address := range []string{"127.0.0.1:7770", "localhost:7770", "[::1]:7770"} {
+		c.Assert(CheckListenAddress(address, false, false), IsNil)
+	}
+	c.Assert(CheckListenAddress(":7770", false, false), ErrorMatches, "Refusing to listen on ':7770' without authentication.*")
+	c.Assert(CheckListenAddress("0.0.0.0:7770", false, true), ErrorMatches, "Refusing to listen on '0.0.0.0:7770' without authentication.*")
+	c.Assert(CheckListenAddress("10.0.0.1:7770", true, false), ErrorMatches, "Refusing to listen on '10.0.0.1:7770' without TLS.*")
+	c.Assert(CheckListenAddress(":7770", true, true), IsNil)
+	c.Assert(CheckListenAddress("7770", false, false), ErrorMatches, "Invalid listen address '7770'.*")
+}
+
+func register(unit *Server, name, version string) *httptest.ResponseRecorder {
+	metadata := core.NewReleaseMetadata(name, version)
+	req := httptest.NewRequest("POST", "http://localhost/api/v1/inventory/prj/register", strings.NewReader(metadata.ToJson()))
+	w := httptest.NewRecorder()
+	unit.ServeHTTP(w, req)
+	return w
+}
+
+func (s *suite) Test_Inventory_limits_pending_registrations(c *C) {
+	defer func(max int) { MaxRegistrations = max }(MaxRegistrations)
+	MaxRegistrations = 2
+	unit := NewServer(filepath.Join(testDir, "data"), "", "")
+	c.Assert(register(unit, "app", "1.0").Code, Equals, 200)
+	c.Assert(register(unit, "app", "1.1").Code, Equals, 200)
+	c.Assert(register(unit, "app", "1.1").Code, Equals, 200)
+	w := register(unit, "app", "1.2")
+	c.Assert(w.Code, Equals, 503)
+	c.Assert(w.Body.String(), Equals, "Too many pending registrations. Try again later.")
+}
+
+func (s *suite) Test_Inventory_registrations_expire(c *C) {
+	defer func(timeout time.Duration) { RegistrationTimeout = timeout }(RegistrationTimeout)
+	defer func(max int) { MaxRegistrations = max }(MaxRegistrations)
+	RegistrationTimeout = -time.Second
+	MaxRegistrations = 1
+	unit := NewServer(filepath.Join(testDir, "data"), "", "")
+	c.Assert(register(unit, "app", "1.0").Code, Equals, 200)
+	c.Assert(unit.getRegistration("prj/app-v1.0"), IsNil)
+	c.Assert(register(unit, "app", "1.1").Code, Equals, 200)
+	c.Assert(unit.registered, HasLen, 1)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ankyra/escape-core/state"
+	"github.com/ankyra/escape-core/state/validate"
+	localstate "github.com/ankyra/escape/model/state/local"
+)
+
+// stateHandler handles the api/v1/state/ endpoints. The parts are the path
+// segments after "state".
+func (s *Server) stateHandler(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) < 3 || parts[1] != "environments" {
+		writeError(w, 404, "Not found")
+		return
+	}
+	project, env := parts[0], parts[2]
+	if !validate.IsValidProjectName(project) {
+		writeError(w, 400, validate.InvalidProjectNameError(project).Error())
+		return
+	}
+	if !validate.IsValidEnvironmentName(env) {
+		writeError(w, 400, validate.InvalidEnvironmentNameError(env).Error())
+		return
+	}
+	defer s.lockProject(project)()
+	stateDir := filepath.Join(s.DataDir, "state")
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		writeError(w, 500, err.Error())
+		return
+	}
+	provider := localstate.NewLocalStateProvider(filepath.Join(stateDir, project+".json"))
+	envState, err := provider.Load(project, env)
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	deployment := r.URL.Query().Get("deployment")
+	switch {
+	case len(parts) == 3 && r.Method == "GET":
+		writeJson(w, envState)
+	case len(parts) == 4 && parts[3] == "deployments" && r.Method == "PUT":
+		s.updateDeployment(w, r, provider, envState)
+	case len(parts) == 4 && parts[3] == "deployments" && r.Method == "DELETE":
+		if err := envState.DeleteDeployment(deployment); err != nil {
+			writeError(w, 404, err.Error())
+			return
+		}
+		w.Write([]byte("OK"))
+	default:
+		writeError(w, 404, "Not found")
+	}
+}
+
+type deploymentUpdate struct {
+	Path      string                 `json:"path"`
+	State     *state.DeploymentState `json:"state"`
+	RootStage string                 `json:"root_stage"`
+}
+
+// updateDeployment stores a (sub-)deployment, as sent by the remote state
+// provider. Sub-deployments are identified by their path (e.g.
+// "root-deployment:dependency") and the stage of the root deployment they
+// belong to.
+func (s *Server) updateDeployment(w http.ResponseWriter, r *http.Request, provider state.Backend, envState *state.EnvironmentState) {
+	update := &deploymentUpdate{}
+	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	path := strings.Split(update.Path, ":")
+	if update.State == nil || path[0] == "" {
+		writeError(w, 400, "Expecting a deployment 'path' and 'state'")
+		return
+	}
+	name := path[len(path)-1]
+	if len(path) == 1 {
+		envState.Deployments[name] = update.State
+	} else {
+		parent, err := envState.GetOrCreateDeploymentState(path[0])
+		if err != nil {
+			writeError(w, 400, err.Error())
+			return
+		}
+		stage := update.RootStage
+		if stage == "" {
+			stage = state.DeployStage
+		}
+		for _, p := range path[1 : len(path)-1] {
+			parent, err = parent.GetDeploymentOrMakeNew(stage, p)
+			if err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+			stage = state.DeployStage
+		}
+		parent.GetStageOrCreateNew(stage).Deployments[name] = update.State
+	}
+	if err := envState.Project.ValidateAndFix(); err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	if err := provider.Save(update.State); err != nil {
+		writeError(w, 500, err.Error())
+		return
+	}
+	w.Write([]byte("OK"))
+}
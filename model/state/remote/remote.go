@@ -23,6 +23,7 @@ import (
 
 	. "github.com/ankyra/escape-core/state"
 	"github.com/ankyra/escape/model/remote"
+	"github.com/ankyra/escape/util"
 )
 
 type remoteStateProvider struct {
@@ -30,9 +31,9 @@ type remoteStateProvider struct {
 	endpoints *remote.ServerEndpoints
 }
 
-func NewRemoteStateProvider(apiServer, escapeToken string, insecureSkipVerify bool) *remoteStateProvider {
+func NewRemoteStateProvider(apiServer, escapeToken, basicAuthUsername, basicAuthPassword string, insecureSkipVerify bool) *remoteStateProvider {
 	return &remoteStateProvider{
-		client:    remote.NewRemoteClient(escapeToken, "", "", insecureSkipVerify),
+		client:    remote.NewRemoteClient(escapeToken, basicAuthUsername, basicAuthPassword, insecureSkipVerify),
 		endpoints: remote.NewServerEndpoints(apiServer),
 	}
 }
@@ -44,7 +45,7 @@ func (r *remoteStateProvider) Load(project, env string) (*EnvironmentState, erro
 		return nil, err
 	}
 	if resp.StatusCode == 401 {
-		return nil, fmt.Errorf("Unauthorized")
+		return nil, util.NewAuthenticationRequiredError("Unauthorized")
 	} else if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("Couldn't load environment state: %s", resp.Status)
 	}
@@ -75,7 +76,7 @@ func (r *remoteStateProvider) Save(depl *DeploymentState) error {
 		return err
 	}
 	if resp.StatusCode == 401 {
-		return fmt.Errorf("Unauthorized")
+		return util.NewAuthenticationRequiredError("Unauthorized")
 	} else if resp.StatusCode != 200 {
 		bytes, err := ioutil.ReadAll(resp.Body)
 		if err != nil || len(bytes) == 0 {
@@ -93,7 +94,7 @@ func (r *remoteStateProvider) DeleteDeployment(project, env, depl string) error
 		return err
 	}
 	if resp.StatusCode == 401 {
-		return fmt.Errorf("Unauthorized")
+		return util.NewAuthenticationRequiredError("Unauthorized")
 	} else if resp.StatusCode != 200 {
 		bytes, err := ioutil.ReadAll(resp.Body)
 		if err != nil || len(bytes) == 0 {
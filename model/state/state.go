@@ -31,6 +31,6 @@ func NewLocalStateProvider(file string) StateProvider {
 	return local.NewLocalStateProvider(file)
 }
 
-func NewRemoteStateProvider(apiServer, escapeToken string, insecureSkipVerify bool) StateProvider {
-	return remote.NewRemoteStateProvider(apiServer, escapeToken, insecureSkipVerify)
+func NewRemoteStateProvider(apiServer, escapeToken, basicAuthUsername, basicAuthPassword string, insecureSkipVerify bool) StateProvider {
+	return remote.NewRemoteStateProvider(apiServer, escapeToken, basicAuthUsername, basicAuthPassword, insecureSkipVerify)
 }
@@ -0,0 +1,75 @@
+/*
+Copyright 2017, 2018 Ankyra
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type suite struct{}
+
+var _ = Suite(&suite{})
+
+func (s *suite) Test_UnifiedDiff_returns_empty_string_if_texts_are_equal(c *C) {
+	c.Assert(UnifiedDiff("a", "b", "same\n", "same\n"), Equals, "")
+}
+
+func (s *suite) Test_UnifiedDiff(c *C) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	to := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nK"
+	c.Assert(UnifiedDiff("from", "to", from, to), Equals, `--- from
++++ to
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -8,4 +8,4 @@
+ h
+ i
+ j
+-k
++K
+\ No newline at end of file
+`)
+}
+
+func (s *suite) Test_UnifiedDiff_merges_nearby_changes(c *C) {
+	c.Assert(UnifiedDiff("from", "to", "a\nb\nc\nd\n", "a\nc\nd\ne\n"), Equals, `--- from
++++ to
+@@ -1,4 +1,4 @@
+ a
+-b
+ c
+ d
++e
+`)
+}
+
+func (s *suite) Test_UnifiedDiff_new_file(c *C) {
+	c.Assert(UnifiedDiff("/dev/null", "to", "", "a\n"), Equals, `--- /dev/null
++++ to
+@@ -0,0 +1 @@
++a
+`)
+}
@@ -18,10 +18,82 @@ package util
 
 import (
 	"errors"
+	"os/exec"
 	"strings"
+	"syscall"
 )
 
+// A CommandError is returned when an external command couldn't be started or
+// exited unsuccessfully.
+type CommandError struct {
+	Cmd []string
+	Err error
+}
+
+func (c *CommandError) Error() string {
+	return "Failed to successfully execute command '" + strings.Join(c.Cmd, " ") + "': " + c.Err.Error()
+}
+
 func RecordError(cmd []string, err error) error {
-	cmdString := strings.Join(cmd, " ")
-	return errors.New("Failed to successfully execute command '" + cmdString + "': " + err.Error())
+	return &CommandError{Cmd: cmd, Err: err}
+}
+
+// CommandExitCode returns the exit code of the command that caused the
+// error, or nil if the error wasn't caused by a command exiting.
+func CommandExitCode(err error) *int {
+	cmdErr, ok := err.(*CommandError)
+	if !ok {
+		return nil
+	}
+	exitErr, ok := cmdErr.Err.(*exec.ExitError)
+	if !ok {
+		return nil
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return nil
+	}
+	code := status.ExitStatus()
+	return &code
+}
+
+// Exit codes used by the escape command. Anything that isn't more specific
+// exits with ExitCodeError.
+const (
+	ExitCodeError                  = 1
+	ExitCodeAuthenticationRequired = 3
+	ExitCodeAuthenticationFailed   = 4
+)
+
+// An ExitError is an error that should make escape exit with a specific
+// exit code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+// NewAuthenticationRequiredError is used when there are no (valid)
+// credentials and escape isn't allowed to ask for them.
+func NewAuthenticationRequiredError(msg string) error {
+	return &ExitError{Code: ExitCodeAuthenticationRequired, Err: errors.New(msg)}
+}
+
+// NewAuthenticationFailedError is used when the server rejected the
+// credentials, or when an authorization request was denied or expired.
+func NewAuthenticationFailedError(msg string) error {
+	return &ExitError{Code: ExitCodeAuthenticationFailed, Err: errors.New(msg)}
+}
+
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*ExitError); ok {
+		return exitErr.Code
+	}
+	return ExitCodeError
 }
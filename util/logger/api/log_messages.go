@@ -29,6 +29,10 @@ var LogMessages = map[string]map[string]string{
 		"msg":   "Started packaging.",
 		"level": "info",
 	},
+	"compile.extension_conflict": map[string]string{
+		"msg":   "Extension conflict: {{ .conflict }}",
+		"level": "warn",
+	},
 	"download.finished": map[string]string{
 		"msg":   "Finished downloading {{ .URL }}",
 		"level": "success",
@@ -318,6 +322,18 @@ var LogMessages = map[string]map[string]string{
 		"msg":   "Running {{ .errand }}.",
 		"level": "info",
 	},
+	"errand.scheduled": map[string]string{
+		"msg":   "Next run of errand '{{ .errand }}' is scheduled for {{ .next }}",
+		"level": "info",
+	},
+	"errand.scheduled_run": map[string]string{
+		"msg":   "Starting scheduled run of errand '{{ .errand }}'",
+		"level": "info",
+	},
+	"errand.scheduled_run_failed": map[string]string{
+		"msg":   "Scheduled run of errand '{{ .errand }}' failed: {{ .error }}",
+		"level": "error",
+	},
 	"error": map[string]string{
 		"msg":      "Error: {{ .error }}",
 		"level":    "error",
@@ -328,6 +344,26 @@ var LogMessages = map[string]map[string]string{
 		"level":    "info",
 		"collapse": "false",
 	},
+	"notification.failed": map[string]string{
+		"msg":   "Couldn't send notification to {{ .sink }}: {{ .error }}",
+		"level": "warn",
+	},
+	"variable.migrated": map[string]string{
+		"msg":   "{{ .migration }}. Run `escape state migrate-inputs` to update the state.",
+		"level": "warn",
+	},
+	"variable.migrated_output": map[string]string{
+		"msg":   "{{ .migration }}.",
+		"level": "warn",
+	},
+	"hook.run": map[string]string{
+		"msg":   "Running {{ .point }} hook {{ .hook }}.",
+		"level": "info",
+	},
+	"hook.failed": map[string]string{
+		"msg":   "The {{ .point }} hook {{ .hook }} failed: {{ .error }}",
+		"level": "warn",
+	},
 	"fetch.download_from_gcs": map[string]string{
 		"msg":   "Downloading {{ .release }} from {{ .gcs_path }} into {{ .target_dir }}.",
 		"level": "info",
@@ -353,6 +389,10 @@ var LogMessages = map[string]map[string]string{
 		"msg":   "Finished installing all dependencies.",
 		"level": "success",
 	},
+	"inventory.search_skipped": map[string]string{
+		"msg":   "Skipping {{ .entry }} in the search: {{ .error }}",
+		"level": "warn",
+	},
 	"install.start": map[string]string{
 		"msg":   "Installing dependencies.",
 		"level": "info",
@@ -424,6 +464,14 @@ var LogMessages = map[string]map[string]string{
 		"msg":   "Releasing {{.release}}",
 		"level": "info",
 	},
+	"release.deprecated": map[string]string{
+		"msg":   "The release {{ .release }} has been deprecated: {{ .reason }}",
+		"level": "warn",
+	},
+	"release.yanked": map[string]string{
+		"msg":   "The release {{ .release }} has been yanked: {{ .reason }}",
+		"level": "warn",
+	},
 	"release.skip_existing": map[string]string{
 		"msg":   "Skipping release, because version v{{.version}} already exists in the Inventory and --skip-if-exists is set.",
 		"level": "success",
@@ -436,6 +484,10 @@ var LogMessages = map[string]map[string]string{
 		"msg":   "Running.",
 		"level": "info",
 	},
+	"server.start": map[string]string{
+		"msg":   "Serving the Inventory and state API for {{ .data_dir }} on {{ .address }}",
+		"level": "info",
+	},
 	"smoke.finished": map[string]string{
 		"msg":   "Smoke tests passed.",
 		"level": "success",
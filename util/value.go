@@ -19,6 +19,7 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -50,10 +51,17 @@ func InterfaceToString(val interface{}) (string, error) {
 			stringVal = "1"
 		}
 	case float64:
-		stringVal = strconv.Itoa(int(val.(float64)))
+		f := val.(float64)
+		if f == math.Trunc(f) {
+			stringVal = strconv.Itoa(int(f))
+		} else {
+			stringVal = strconv.FormatFloat(f, 'f', -1, 64)
+		}
 	case int:
 		stringVal = strconv.Itoa(val.(int))
-	case []interface{}:
+	case nil:
+		stringVal = ""
+	case []interface{}, map[string]interface{}:
 		jsonBytes, err := json.Marshal(val)
 		if err != nil {
 			panic(err)